@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/scheduler"
+)
+
+const backupUsage = `usage: dem backup [FILE]
+
+Writes a backup of the database configured by the DB_* variables to FILE,
+by default dem-backup-<timestamp>.zip in the current directory.`
+
+const restoreUsage = `usage: dem restore [--replace] FILE
+
+Restores the backup in FILE into the database configured by the DB_* variables.
+The database must be empty unless --replace is given.`
+
+// runBackup handles "dem backup"
+func runBackup(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("unexpected arguments %v\n%s", args[1:], backupUsage)
+	}
+	path := repository.BackupFileName(time.Now())
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	dbDriver, dbPath := databaseConfig()
+	db, err := repository.Open(dbPath, dbDriver)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	manifest, err := db.BackupFile(context.Background(), path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s (schema version %d)\n", path, manifest.SchemaVersion)
+	for _, table := range manifest.Tables {
+		fmt.Printf("  %-20s %d row(s)\n", table.Name, table.Rows)
+	}
+	return nil
+}
+
+// runRestore handles "dem restore"
+// The archive is checked in full before the database is opened.
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("dem restore", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	replace := flags.Bool("replace", false, "overwrite existing data")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%v\n%s", err, restoreUsage)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("restore needs one backup file\n%s", restoreUsage)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	archive, err := repository.OpenBackup(f, info.Size())
+	if err != nil {
+		return err
+	}
+	manifest := archive.Manifest
+	fmt.Printf("Backup of a %s database taken %s, schema version %d\n",
+		manifest.Driver, manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"), manifest.SchemaVersion)
+
+	dbDriver, dbPath := databaseConfig()
+	db, err := repository.NewDB(dbPath, dbDriver)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Restore(context.Background(), archive, *replace); err != nil {
+		return err
+	}
+
+	for _, table := range manifest.Tables {
+		fmt.Printf("  %-20s %d row(s)\n", table.Name, table.Rows)
+	}
+	fmt.Println("Restore complete")
+	return nil
+}
+
+// backupSchedule reads the scheduled backup settings from the environment
+func backupSchedule(dir string) (scheduler.BackupSchedule, error) {
+	interval, err := time.ParseDuration(getEnv("BACKUP_INTERVAL", "24h"))
+	if err != nil || interval <= 0 {
+		return scheduler.BackupSchedule{}, fmt.Errorf("BACKUP_INTERVAL must be a positive duration such as 24h")
+	}
+	keep, err := strconv.Atoi(getEnv("BACKUP_KEEP", "7"))
+	if err != nil || keep < 0 {
+		return scheduler.BackupSchedule{}, fmt.Errorf("BACKUP_KEEP must be a number of backups, or 0 to keep all")
+	}
+	return scheduler.BackupSchedule{Dir: dir, Interval: interval, Keep: keep}, nil
+}
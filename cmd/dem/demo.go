@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+// demoDomain is a fake registration known to the demo WHOIS backend
+type demoDomain struct {
+	name      string
+	registrar string
+	expiresIn time.Duration
+	mode      string
+	metadata  domain.Metadata
+	// seeded domains are added to the demo store; the rest only exist in WHOIS
+	seeded bool
+	// failing domains have a WHOIS server that never answers
+	failing bool
+}
+
+// demoDomains covers every alert window, a failing WHOIS server, a lapsed watchlist
+// entry and a registered lookalike
+var demoDomains = []demoDomain{
+	{name: "example-shop.com", registrar: "Demo Registrar, Inc.", expiresIn: 400 * 24 * time.Hour, mode: domain.ModeMonitor, seeded: true,
+		metadata: domain.Metadata{Owner: "Web Team", Criticality: domain.CriticalityCritical, AnnualCostCents: 1299, Tags: domain.Strings{"prod", "shop"}}},
+	{name: "example-blog.io", registrar: "Demo Registrar, Inc.", expiresIn: 45 * 24 * time.Hour, mode: domain.ModeMonitor, seeded: true,
+		metadata: domain.Metadata{Owner: "Marketing", Criticality: domain.CriticalityLow, AnnualCostCents: 4500, Tags: domain.Strings{"content"}}},
+	{name: "example-api.co", registrar: "Sample Names LLC", expiresIn: 12 * 24 * time.Hour, mode: domain.ModeMonitor, seeded: true,
+		metadata: domain.Metadata{Owner: "Platform", Criticality: domain.CriticalityHigh, AnnualCostCents: 2900, Tags: domain.Strings{"prod", "api"}}},
+	{name: "example-mail.com", registrar: "Sample Names LLC", expiresIn: 3 * 24 * time.Hour, mode: domain.ModeMonitor, seeded: true,
+		metadata: domain.Metadata{Owner: "Platform", Criticality: domain.CriticalityHigh, AnnualCostCents: 1299, Tags: domain.Strings{"prod", "mail"}}},
+	{name: "wanted-name.com", registrar: "Other Registrar Ltd", expiresIn: 20 * 24 * time.Hour, mode: domain.ModeWatch, seeded: true,
+		metadata: domain.Metadata{Owner: "Marketing", Notes: "Would make a good campaign name"}},
+	{name: "example-legacy.net", registrar: "Sample Names LLC", expiresIn: 200 * 24 * time.Hour, mode: domain.ModeMonitor, seeded: true, failing: true,
+		metadata: domain.Metadata{Owner: "Platform", Criticality: domain.CriticalityLow, Tags: domain.Strings{"legacy"}}},
+	{name: "lapsed-name.com", mode: domain.ModeWatch, seeded: true},
+	{name: "examp1e-shop.com", registrar: "Anonymous Names Ltd", expiresIn: 365 * 24 * time.Hour},
+}
+
+// seedDemo adds the demo domains to stores, due for their first check right away
+func seedDemo(ctx context.Context, stores repository.Stores) error {
+	now := time.Now()
+	for _, dd := range demoDomains {
+		if !dd.seeded {
+			continue
+		}
+		d := &domain.Domain{
+			Name:        dd.name,
+			Mode:        dd.mode,
+			Nameservers: domain.Strings{},
+			Registrar:   dd.registrar,
+			NextCheck:   now,
+			Metadata:    dd.metadata,
+		}
+		// As last seen, for a domain whose WHOIS server fails from the start
+		if dd.registrar != "" {
+			d.ExpirationDate = now.Add(dd.expiresIn)
+		}
+		if err := stores.Domains.Create(ctx, d); err != nil {
+			return fmt.Errorf("failed to seed demo domain %s: %w", dd.name, err)
+		}
+	}
+	return nil
+}
+
+// demoLookup returns a WHOIS lookup that answers from demoDomains instead of the network
+// Expiration dates are relative to start, so the demo looks the same whenever it runs.
+func demoLookup(start time.Time) func(ctx context.Context, query string, servers ...string) (string, error) {
+	return func(ctx context.Context, query string, servers ...string) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		query = strings.ToLower(query)
+		if len(servers) == 0 {
+			tld := query[strings.LastIndex(query, ".")+1:]
+			return fmt.Sprintf("refer: whois.nic.%s\n", tld), nil
+		}
+		for _, dd := range demoDomains {
+			if dd.name == query && dd.failing {
+				return "", fmt.Errorf("demo WHOIS server for %s is unreachable", query)
+			}
+			if dd.name == query && dd.registrar != "" {
+				return demoRecord(dd, start), nil
+			}
+		}
+		return fmt.Sprintf("No match for \"%s\".\n", strings.ToUpper(query)), nil
+	}
+}
+
+// demoRecord renders a registered WHOIS record in the Verisign layout
+func demoRecord(dd demoDomain, start time.Time) string {
+	const layout = "2006-01-02T15:04:05Z"
+	created := start.AddDate(-3, 0, 0).UTC()
+	expires := start.Add(dd.expiresIn).UTC()
+	return fmt.Sprintf(`Domain Name: %s
+Registry Domain ID: DEMO-%s
+Updated Date: %s
+Creation Date: %s
+Registry Expiry Date: %s
+Registrar: %s
+Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited
+Registrant Organization: Demo Holdings
+Name Server: NS1.DEMO.INVALID
+Name Server: NS2.DEMO.INVALID
+DNSSEC: unsigned
+`, strings.ToUpper(dd.name), strings.ToUpper(strings.ReplaceAll(dd.name, ".", "-")),
+		start.UTC().Format(layout), created.Format(layout), expires.Format(layout), dd.registrar)
+}
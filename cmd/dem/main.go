@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/alert"
+	"github.com/domain-expiration-monitor/dem/internal/httpcheck"
 	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/memory"
 	"github.com/domain-expiration-monitor/dem/internal/scheduler"
 	"github.com/domain-expiration-monitor/dem/internal/web"
 	"github.com/domain-expiration-monitor/dem/internal/whois"
+	"github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
@@ -18,57 +25,126 @@ func main() {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 	
+	// Subcommands run and exit instead of starting the monitor
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		case "db":
+			if err := runDB(os.Args[2:]); err != nil {
+				log.Fatalf("Database command failed: %v", err)
+			}
+			return
+		case "backup":
+			if err := runBackup(os.Args[2:]); err != nil {
+				log.Fatalf("Backup failed: %v", err)
+			}
+			return
+		case "restore":
+			if err := runRestore(os.Args[2:]); err != nil {
+				log.Fatalf("Restore failed: %v", err)
+			}
+			return
+		case "rotate-key":
+			if err := runRotateKey(os.Args[2:]); err != nil {
+				log.Fatalf("Key rotation failed: %v", err)
+			}
+			return
+		}
+	}
+
+	// --demo runs against seeded in-memory data and a fake WHOIS backend
+	demo := len(os.Args) > 1 && os.Args[1] == "--demo"
+
 	log.Println("Domain Expiration Monitor starting...")
 
-	// Initialize database
-	dbDriver := getEnv("DB_DRIVER", "sqlite3")
-	var dbPath string
-	
-	if dbDriver == "mysql" {
-		// Build MySQL connection string from environment variables
-		dbHost := getEnv("DB_HOST", "localhost")
-		dbPort := getEnv("DB_PORT", "3306")
-		dbName := getEnv("DB_NAME", "dem")
-		dbUser := getEnv("DB_USER", "root")
-		dbPassword := getEnv("DB_PASSWORD", "")
-		
-		dbPath = dbUser + ":" + dbPassword + "@tcp(" + dbHost + ":" + dbPort + ")/" + dbName + "?parseTime=true&charset=utf8mb4"
-		log.Printf("Connecting to MySQL database at %s:%s/%s...", dbHost, dbPort, dbName)
+	// Initialize repositories
+	var db *repository.DB
+	var stores repository.Stores
+	if demo {
+		stores = memory.NewStore().Stores()
+		if err := seedDemo(context.Background(), stores); err != nil {
+			log.Fatalf("Failed to seed demo data: %v", err)
+		}
+		log.Printf("Demo mode: using in-memory data, changes are lost on exit")
 	} else {
-		// SQLite
-		dbPath = getEnv("DB_PATH", "dem.db")
-		log.Printf("Connecting to SQLite database at %s...", dbPath)
-	}
-	
-	db, err := repository.NewDB(dbPath, dbDriver)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer db.Close()
-	log.Printf("Database connected successfully")
+		var err error
+		dbDriver, dbPath := databaseConfig()
+		db, err = repository.NewDB(dbPath, dbDriver)
+		if err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
+		}
+		defer db.Close()
+		log.Printf("Database connected successfully")
 
-	// Initialize repositories
-	domainRepo := repository.NewDomainRepository(db)
-	configRepo := repository.NewConfigRepository(db)
-	alertRepo := repository.NewAlertRepository(db)
+		cipher, err := secretCipher()
+		if err != nil {
+			log.Fatalf("Invalid secret key: %v", err)
+		}
+		if cipher == nil {
+			log.Printf("Warning: SECRET_KEY and SECRET_KEY_FILE are not set; the webhook URL is stored in plain text")
+		}
+		db.SetCipher(cipher)
+		stores = repository.NewStores(db)
+
+		// A missing or wrong key shows now rather than when the first alert is due
+		if _, err := stores.Config.Get(context.Background()); err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+
+		// Secrets saved before the key was set are encrypted now
+		encrypted, err := db.EncryptSecrets(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to encrypt stored secrets: %v", err)
+		}
+		if encrypted > 0 {
+			log.Printf("Encrypted %d secret(s) stored in plain text", encrypted)
+		}
+	}
 
 	// Initialize services
 	whoisSvc := whois.NewService()
-	alertSvc := alert.NewService(alertRepo, configRepo)
+	if spec := getEnv("WHOIS_RATE_LIMITS", ""); spec != "" {
+		limits, err := whois.ParseLimits(spec, whois.DefaultLimits())
+		if err != nil {
+			log.Fatalf("Invalid WHOIS_RATE_LIMITS: %v", err)
+		}
+		whoisSvc.SetLimits(limits)
+	}
+	if demo {
+		whoisSvc.SetLookup(demoLookup(time.Now()))
+	}
+	alertSvc := alert.NewService(stores.Alerts, stores.Config)
+	httpSvc := httpcheck.NewService()
 
 	// Initialize scheduler
-	sched := scheduler.NewScheduler(domainRepo, configRepo, whoisSvc, alertSvc)
+	sched := scheduler.NewScheduler(stores.Domains, stores.Config, stores.Alerts, stores.HTTPChecks, stores.Lookalikes, stores.Leader, whoisSvc, alertSvc, httpSvc)
+	// Backups copy the database, so there is nothing to back up in demo mode
+	if dir := getEnv("BACKUP_DIR", ""); dir != "" && !demo {
+		schedule, err := backupSchedule(dir)
+		if err != nil {
+			log.Fatalf("Invalid backup settings: %v", err)
+		}
+		sched.SetBackups(db, schedule)
+		log.Printf("Backing up to %s every %s, keeping %d", dir, schedule.Interval, schedule.Keep)
+	}
 
-	// Load all domains and start scheduler
+	// Start scheduler; it runs checks only while this replica is the leader
 	if err := sched.Start(); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
 	}
 
 	// Initialize web server
-	server, err := web.NewServer(domainRepo, configRepo, alertRepo, whoisSvc, sched)
+	server, err := web.NewServer(stores.Domains, stores.Config, stores.Alerts, stores.HTTPChecks, stores.Lookalikes, stores.Audit, whoisSvc, alertSvc, sched)
 	if err != nil {
 		log.Fatalf("Failed to initialize web server: %v", err)
 	}
+	if header := getEnv("AUDIT_ACTOR_HEADER", ""); header != "" {
+		server.SetActorHeader(header)
+	}
 
 	// Start web server in goroutine
 	httpAddr := getEnv("HTTP_ADDR", ":8080")
@@ -96,6 +172,53 @@ func main() {
 	log.Println("Shutdown complete")
 }
 
+// databaseConfig returns the database driver and connection string from the environment
+func databaseConfig() (string, string) {
+	dbDriver := getEnv("DB_DRIVER", "sqlite3")
+	switch dbDriver {
+	case "mysql":
+		// Build MySQL connection string from environment variables
+		host := net.JoinHostPort(getEnv("DB_HOST", "localhost"), getEnv("DB_PORT", "3306"))
+		name := getEnv("DB_NAME", "dem")
+		log.Printf("Connecting to MySQL database at %s/%s...", host, name)
+		return dbDriver, mysqlDSN(getEnv("DB_USER", "root"), getEnv("DB_PASSWORD", ""), host, name, nil)
+
+	case "postgres":
+		// Build PostgreSQL connection URL from environment variables
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(getEnv("DB_USER", "postgres"), getEnv("DB_PASSWORD", "")),
+			Host:     net.JoinHostPort(getEnv("DB_HOST", "localhost"), getEnv("DB_PORT", "5432")),
+			Path:     "/" + getEnv("DB_NAME", "dem"),
+			RawQuery: url.Values{"sslmode": {getEnv("DB_SSLMODE", "disable")}}.Encode(),
+		}
+
+		log.Printf("Connecting to PostgreSQL database at %s%s...", dsn.Host, dsn.Path)
+		return dbDriver, dsn.String()
+	}
+
+	// SQLite
+	dbPath := getEnv("DB_PATH", "dem.db")
+	log.Printf("Connecting to SQLite database at %s...", dbPath)
+	return dbDriver, dbPath
+}
+
+// mysqlDSN builds a MySQL connection string that parses times and uses utf8mb4
+func mysqlDSN(user, password, addr, name string, params map[string]string) string {
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = addr
+	cfg.DBName = name
+	cfg.ParseTime = true
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+	for k, v := range params {
+		cfg.Params[k] = v
+	}
+	return cfg.FormatDSN()
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
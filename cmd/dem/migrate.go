@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+const migrateUsage = `usage: dem migrate <command>
+
+Commands:
+  status     list migrations and whether they are applied
+  up         apply all pending migrations
+  down [N]   revert the last N applied migrations (default 1)`
+
+// runMigrate handles "dem migrate status|up|down"
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	steps := 1
+	switch args[0] {
+	case "status", "up":
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected arguments %v\n%s", args[1:], migrateUsage)
+		}
+	case "down":
+		if len(args) > 2 {
+			return fmt.Errorf("unexpected arguments %v\n%s", args[2:], migrateUsage)
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("down needs a positive number of migrations, got %q", args[1])
+			}
+			steps = n
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+
+	dbDriver, dbPath := databaseConfig()
+	db, err := repository.Open(dbPath, dbDriver)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+	case "down":
+		reverted, err := db.MigrateDown(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+	}
+
+	return printMigrationStatus(ctx, db)
+}
+
+// printMigrationStatus writes a table of migrations and when they were applied
+func printMigrationStatus(ctx context.Context, db *repository.DB) error {
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, s := range statuses {
+		status := "pending"
+		if s.Applied() {
+			status = "applied " + s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, status)
+	}
+	return w.Flush()
+}
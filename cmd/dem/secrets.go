@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/secret"
+)
+
+const rotateKeyUsage = `usage: dem rotate-key --new-key-file FILE
+
+Re-encrypts the secrets stored in the database configured by the DB_* variables,
+such as the webhook URL, with the key in FILE, generating a new key into FILE if
+it does not exist. Secrets are decrypted with the current SECRET_KEY or
+SECRET_KEY_FILE; secrets stored in plain text before a key was set are encrypted.
+Stop the application first, and start it again with SECRET_KEY_FILE=FILE.`
+
+// secretCipher returns the cipher of the key in SECRET_KEY or SECRET_KEY_FILE,
+// or nil when neither is set
+func secretCipher() (*secret.Cipher, error) {
+	return secret.LoadCipher(getEnv("SECRET_KEY", ""), getEnv("SECRET_KEY_FILE", ""))
+}
+
+// runRotateKey handles "dem rotate-key"
+func runRotateKey(args []string) error {
+	flags := flag.NewFlagSet("dem rotate-key", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	keyFile := flags.String("new-key-file", "", "file holding the new key")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%v\n%s", err, rotateKeyUsage)
+	}
+	if *keyFile == "" || flags.NArg() > 0 {
+		return fmt.Errorf("rotate-key needs --new-key-file and no other arguments\n%s", rotateKeyUsage)
+	}
+
+	current, err := secretCipher()
+	if err != nil {
+		return err
+	}
+
+	next, err := secret.LoadCipher("", *keyFile)
+	generated := errors.Is(err, fs.ErrNotExist)
+	if generated {
+		next, err = secret.WriteKeyFile(*keyFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	rotated, err := rotateSecrets(current, next)
+	if err != nil {
+		// A key nothing was encrypted with would only be mistaken for the current one
+		if generated {
+			os.Remove(*keyFile)
+		}
+		return err
+	}
+
+	if generated {
+		fmt.Printf("Generated a new key in %s\n", *keyFile)
+	}
+	fmt.Printf("Re-encrypted %d secret(s)\n", rotated)
+	fmt.Printf("Start the application with SECRET_KEY_FILE=%s and without SECRET_KEY; the old key no longer works\n", *keyFile)
+	return nil
+}
+
+// rotateSecrets re-encrypts the secrets of the configured database from current to next
+func rotateSecrets(current, next *secret.Cipher) (int, error) {
+	dbDriver, dbPath := databaseConfig()
+	db, err := repository.NewDB(dbPath, dbDriver)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	db.SetCipher(current)
+
+	return db.RotateSecrets(context.Background(), next)
+}
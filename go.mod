@@ -8,14 +8,15 @@ require (
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/joho/godotenv v1.5.1
 	github.com/leanovate/gopter v0.2.11
+	github.com/lib/pq v1.10.9
 	github.com/likexian/whois v1.15.6
 	github.com/likexian/whois-parser v1.24.20
 	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/net v0.35.0
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/likexian/gokit v0.25.15 // indirect
-	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 )
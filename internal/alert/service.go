@@ -2,39 +2,72 @@ package alert
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/domain"
 	"github.com/domain-expiration-monitor/dem/internal/repository"
 )
 
+const (
+	// claimTimeout is how long a claimed alert may stay pending before another
+	// evaluation assumes the sender died and sends it instead
+	claimTimeout = 5 * time.Minute
+	// maxDeliveryAttempts is how many times an alert is delivered before it is dead-lettered
+	maxDeliveryAttempts = 8
+	// retryBaseDelay is the wait after the first failed delivery; it doubles per attempt
+	retryBaseDelay = 5 * time.Minute
+	// retryMaxDelay caps the wait between delivery attempts
+	retryMaxDelay = 4 * time.Hour
+	// retryBatchSize limits the failed alerts retried per run
+	retryBatchSize = 50
+)
+
+// ErrAlertNotResendable is returned when resending an alert that was delivered or is being sent
+var ErrAlertNotResendable = errors.New("alert was delivered or is being sent")
+
 // Service handles alert evaluation and sending
 type Service struct {
-	alertRepo  *repository.AlertRepository
-	configRepo *repository.ConfigRepository
+	alertRepo  repository.AlertStore
+	configRepo repository.ConfigStore
 	httpClient *http.Client
+	// sendBackoff is the initial wait between the quick retries within one delivery attempt
+	sendBackoff time.Duration
 }
 
 // NewService creates a new alert service
-func NewService(alertRepo *repository.AlertRepository, configRepo *repository.ConfigRepository) *Service {
+func NewService(alertRepo repository.AlertStore, configRepo repository.ConfigStore) *Service {
 	return &Service{
 		alertRepo:  alertRepo,
 		configRepo: configRepo,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		sendBackoff: time.Second,
 	}
 }
 
 // EvaluateAlerts checks if any alert thresholds are crossed for a domain
-func (s *Service) EvaluateAlerts(d *domain.Domain) error {
-	config, err := s.configRepo.Get()
+// Watched domains are not ours to renew and never get expiration alerts, and a dropped
+// domain has no expiration date left to alert on
+func (s *Service) EvaluateAlerts(ctx context.Context, d *domain.Domain) error {
+	if d.IsWatched() || d.RegistrationState == domain.RegistrationAvailable {
+		return nil
+	}
+
+	config, err := s.configRepo.Get(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
+	if !config.AlertsFor(d) {
+		return nil
+	}
 
 	thresholds := config.GetAlertThresholds()
 	timeUntilExpiration := time.Until(d.ExpirationDate)
@@ -42,33 +75,19 @@ func (s *Service) EvaluateAlerts(d *domain.Domain) error {
 	for _, threshold := range thresholds {
 		// Check if we're within the threshold
 		if timeUntilExpiration <= threshold && timeUntilExpiration > 0 {
-			// Check if alert already sent
-			alreadySent, err := s.alertRepo.HasAlertBeenSent(d.ID, threshold)
-			if err != nil {
-				return fmt.Errorf("failed to check if alert was sent: %w", err)
+			// One alert per threshold and expiry cycle; the claim deduplicates
+			alert := &domain.Alert{
+				DomainID:       d.ID,
+				DomainName:     d.Name,
+				ExpirationDate: d.ExpirationDate,
+				SentAt:         time.Now(),
+				DedupKey:       domain.ExpirationDedupKey(d.ID, threshold, d.ExpirationDate),
+				Message:        domainDetails(d),
 			}
+			alert.SetThreshold(threshold)
 
-			if !alreadySent {
-				// Create and send alert
-				alert := &domain.Alert{
-					DomainID:       d.ID,
-					DomainName:     d.Name,
-					ExpirationDate: d.ExpirationDate,
-					SentAt:         time.Now(),
-				}
-				alert.SetThreshold(threshold)
-
-				if err := s.SendAlert(alert, config.GoogleChatWebhook); err != nil {
-					alert.Success = false
-					alert.ErrorMessage = err.Error()
-				} else {
-					alert.Success = true
-				}
-
-				// Save alert record
-				if err := s.alertRepo.Create(alert); err != nil {
-					return fmt.Errorf("failed to save alert: %w", err)
-				}
+			if err := s.deliver(ctx, alert, config.GoogleChatWebhook); err != nil {
+				return err
 			}
 		}
 	}
@@ -76,8 +95,348 @@ func (s *Service) EvaluateAlerts(d *domain.Domain) error {
 	return nil
 }
 
+// EvaluateHTTPCheck sends an alert when an HTTP check reaches its failure
+// threshold and a recovery notice when a failing check passes again
+func (s *Service) EvaluateHTTPCheck(ctx context.Context, d *domain.Domain, check *domain.HTTPCheck, result *domain.HTTPCheckResult, previousFailures int) error {
+	var message string
+	switch {
+	case !result.Success && check.FailureThreshold > 0 && check.ConsecutiveFailures == check.FailureThreshold:
+		message = fmt.Sprintf(
+			"🚨 HTTP Check Failing\n\n"+
+				"Domain: %s\n"+
+				"URL: %s\n"+
+				"Consecutive Failures: %d\n"+
+				"Last Error: %s",
+			d.Name, check.URL, check.ConsecutiveFailures, result.ErrorMessage,
+		)
+	case result.Success && check.FailureThreshold > 0 && previousFailures >= check.FailureThreshold:
+		message = fmt.Sprintf(
+			"✅ HTTP Check Recovered\n\n"+
+				"Domain: %s\n"+
+				"URL: %s\n"+
+				"Status: %d\n"+
+				"Latency: %s",
+			d.Name, check.URL, result.StatusCode, result.GetLatency().Round(time.Millisecond),
+		)
+	default:
+		return nil
+	}
+
+	alert := &domain.Alert{
+		DomainID:       d.ID,
+		DomainName:     d.Name,
+		ExpirationDate: d.ExpirationDate,
+		SentAt:         time.Now(),
+		Type:           domain.AlertTypeHTTPCheck,
+		Message:        message + domainDetails(d),
+	}
+
+	return s.notify(ctx, d, alert)
+}
+
+// EvaluateWatchState alerts when a watched domain moves into a state that matters for
+// acquiring it: redemption, pending delete or available for registration
+func (s *Service) EvaluateWatchState(ctx context.Context, d *domain.Domain, previousState string) error {
+	if !d.IsWatched() || d.RegistrationState == previousState {
+		return nil
+	}
+
+	var headline string
+	switch d.RegistrationState {
+	case domain.RegistrationRedemption:
+		headline = "🎯 Watched Domain Entered Redemption"
+	case domain.RegistrationPendingDelete:
+		headline = "🎯 Watched Domain Pending Delete"
+	case domain.RegistrationAvailable:
+		headline = "🎯 Watched Domain Available"
+	default:
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"%s\n\n"+
+			"Domain: %s\n"+
+			"State: %s (was %s)\n"+
+			"Last Known Expiration: %s\n"+
+			"Registrar: %s",
+		headline, d.Name, d.RegistrationState, previousState,
+		d.ExpirationDate.Format("2006-01-02"), d.Registrar,
+	)
+
+	alert := &domain.Alert{
+		DomainID:       d.ID,
+		DomainName:     d.Name,
+		ExpirationDate: d.ExpirationDate,
+		SentAt:         time.Now(),
+		Type:           domain.AlertTypeWatch,
+		Message:        message + domainDetails(d),
+	}
+
+	return s.notify(ctx, d, alert)
+}
+
+// EvaluateRegistration alerts when a monitored domain is reported as no longer registered
+// It alerts once per drop; the domain has to be registered again before it can alert anew
+func (s *Service) EvaluateRegistration(ctx context.Context, d *domain.Domain, previousState string) error {
+	if d.IsWatched() || d.RegistrationState != domain.RegistrationAvailable ||
+		previousState == domain.RegistrationAvailable {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"🚨 CRITICAL: Domain No Longer Registered\n\n"+
+			"Domain: %s\n"+
+			"The registry reports that this domain does not exist and anyone can register it.\n"+
+			"Last Known Expiration: %s\n"+
+			"Last Known Registrar: %s",
+		d.Name, d.ExpirationDate.Format("2006-01-02"), d.Registrar,
+	)
+
+	alert := &domain.Alert{
+		DomainID:       d.ID,
+		DomainName:     d.Name,
+		ExpirationDate: d.ExpirationDate,
+		SentAt:         time.Now(),
+		Type:           domain.AlertTypeNotRegistered,
+		Message:        message + domainDetails(d),
+	}
+
+	return s.notify(ctx, d, alert)
+}
+
+// EvaluateStatusChanges alerts when a required EPP status disappears from a domain or a
+// transfer starts. Only changes since the previous check alert, so each event alerts once.
+func (s *Service) EvaluateStatusChanges(ctx context.Context, d *domain.Domain, previous domain.Strings) error {
+	if d.IsWatched() {
+		return nil
+	}
+
+	var lost []string
+	for _, required := range d.RequiredStatuses {
+		if previous.Contains(required) && !d.HasStatus(required) {
+			lost = append(lost, required)
+		}
+	}
+	transferStarted := d.HasStatus(domain.StatusPendingTransfer) && !previous.Contains(domain.StatusPendingTransfer)
+
+	if len(lost) == 0 && !transferStarted {
+		return nil
+	}
+
+	var events []string
+	if transferStarted {
+		events = append(events, "A transfer is pending (pendingTransfer)")
+	}
+	if len(lost) > 0 {
+		events = append(events, "Required status removed: "+strings.Join(lost, ", "))
+	}
+
+	message := fmt.Sprintf(
+		"🔓 Domain Status Changed\n\n"+
+			"Domain: %s\n"+
+			"%s\n"+
+			"Current Statuses: %s\n"+
+			"Registrar: %s",
+		d.Name, strings.Join(events, "\n"), strings.Join(d.Statuses, ", "), d.Registrar,
+	)
+
+	alert := &domain.Alert{
+		DomainID:       d.ID,
+		DomainName:     d.Name,
+		ExpirationDate: d.ExpirationDate,
+		SentAt:         time.Now(),
+		Type:           domain.AlertTypeStatus,
+		Message:        message + domainDetails(d),
+	}
+
+	return s.notify(ctx, d, alert)
+}
+
+// NotifyLookalikeRegistered sends an alert about a newly registered lookalike of a monitored domain
+func (s *Service) NotifyLookalikeRegistered(ctx context.Context, d *domain.Domain, l *domain.Lookalike) error {
+	created := "unknown"
+	if l.CreatedDate != nil {
+		created = l.CreatedDate.Format("2006-01-02")
+	}
+	registrar := l.Registrar
+	if registrar == "" {
+		registrar = "unknown"
+	}
+
+	message := fmt.Sprintf(
+		"👀 Lookalike Domain Registered\n\n"+
+			"Lookalike: %s\n"+
+			"Resembles: %s\n"+
+			"Technique: %s\n"+
+			"Registrar: %s\n"+
+			"Created: %s",
+		l.DisplayName(), d.Name, l.Kind, registrar, created,
+	)
+
+	alert := &domain.Alert{
+		DomainID:       d.ID,
+		DomainName:     d.Name,
+		ExpirationDate: d.ExpirationDate,
+		SentAt:         time.Now(),
+		Type:           domain.AlertTypeLookalike,
+		Message:        message + domainDetails(d),
+	}
+
+	return s.notify(ctx, d, alert)
+}
+
+// domainDetails lists who owns a domain, how critical it is and its tags, one
+// line each, for the end of an alert message
+func domainDetails(d *domain.Domain) string {
+	var details string
+	if d.Owner != "" {
+		details += "\nOwner: " + d.Owner
+	}
+	if d.Criticality != "" {
+		details += "\nCriticality: " + d.Criticality
+	}
+	if len(d.Tags) > 0 {
+		details += "\nTags: " + strings.Join(d.Tags, ", ")
+	}
+	return details
+}
+
+// notify sends a preformatted alert about d to the configured webhook and records
+// the attempt, unless d is less critical than alerts are configured for
+func (s *Service) notify(ctx context.Context, d *domain.Domain, alert *domain.Alert) error {
+	config, err := s.configRepo.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	if !config.AlertsFor(d) {
+		return nil
+	}
+
+	return s.deliver(ctx, alert, config.GoogleChatWebhook)
+}
+
+// deliver claims an alert, sends it and records the outcome
+// Nothing is sent when another evaluation already claimed the alert's dedup key
+func (s *Service) deliver(ctx context.Context, alert *domain.Alert, webhookURL string) error {
+	claimed, err := s.alertRepo.Claim(ctx, alert, claimTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to claim alert: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	return s.attempt(ctx, alert, webhookURL)
+}
+
+// attempt sends a claimed alert and records the outcome
+// A failed delivery is scheduled for a retry with exponential backoff until the
+// alert runs out of attempts and is dead-lettered. An attempt cut short by ctx
+// leaves the alert pending, to be retried once its claim goes stale.
+func (s *Service) attempt(ctx context.Context, alert *domain.Alert, webhookURL string) error {
+	alert.Attempts++
+	alert.SentAt = time.Now()
+	alert.NextAttemptAt = nil
+
+	if err := s.SendAlert(ctx, alert, webhookURL); err != nil {
+		alert.Success = false
+		alert.ErrorMessage = err.Error()
+		if alert.Attempts >= maxDeliveryAttempts {
+			alert.Status = domain.AlertStatusDead
+		} else {
+			alert.Status = domain.AlertStatusFailed
+			next := time.Now().Add(retryDelay(alert.Attempts))
+			alert.NextAttemptAt = &next
+		}
+	} else {
+		alert.Status = domain.AlertStatusSent
+		alert.Success = true
+		alert.ErrorMessage = ""
+	}
+
+	if err := s.alertRepo.Finalize(ctx, alert); err != nil {
+		return fmt.Errorf("failed to save alert: %w", err)
+	}
+
+	return nil
+}
+
+// retryDelay returns how long to wait before the next delivery after the given attempts
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// RetryFailed redelivers failed alerts whose next attempt is due
+// Each alert is claimed first, so replicas sharing a database retry it once.
+func (s *Service) RetryFailed(ctx context.Context) error {
+	config, err := s.configRepo.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+
+	now := time.Now()
+	alerts, err := s.alertRepo.GetDueRetries(ctx, now, claimTimeout, retryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, alert := range alerts {
+		claimed, err := s.alertRepo.ClaimRetry(ctx, alert.ID, now, claimTimeout)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		alert.Status = domain.AlertStatusPending
+		if err := s.attempt(ctx, alert, config.GoogleChatWebhook); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Resend immediately redelivers a failed or dead alert and returns its new state
+// A resend that fails starts a fresh retry schedule.
+func (s *Service) Resend(ctx context.Context, id string) (*domain.Alert, error) {
+	config, err := s.configRepo.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+
+	claimed, err := s.alertRepo.ClaimResend(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrAlertNotResendable
+	}
+
+	alert, err := s.alertRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attempt(ctx, alert, config.GoogleChatWebhook); err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
 // SendAlert sends an alert to Google Chat with retry logic
-func (s *Service) SendAlert(alert *domain.Alert, webhookURL string) error {
+func (s *Service) SendAlert(ctx context.Context, alert *domain.Alert, webhookURL string) error {
 	if webhookURL == "" {
 		// No webhook configured, just log
 		return fmt.Errorf("no webhook URL configured")
@@ -86,17 +445,21 @@ func (s *Service) SendAlert(alert *domain.Alert, webhookURL string) error {
 	message := s.FormatAlertMessage(alert)
 
 	var lastErr error
-	backoff := time.Second
+	backoff := s.sendBackoff
 
 	for attempt := 0; attempt < 3; attempt++ {
-		err := s.sendToWebhook(webhookURL, message)
+		err := s.sendToWebhook(ctx, webhookURL, message, alert.ID)
 		if err == nil {
 			return nil
 		}
 
 		lastErr = err
 		if attempt < 2 {
-			time.Sleep(backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return fmt.Errorf("sending alert canceled: %w", ctx.Err())
+			}
 			backoff *= 2
 		}
 	}
@@ -105,7 +468,10 @@ func (s *Service) SendAlert(alert *domain.Alert, webhookURL string) error {
 }
 
 // sendToWebhook sends a message to a Google Chat webhook
-func (s *Service) sendToWebhook(webhookURL string, message string) error {
+// The idempotency key makes retries of the same alert safe: Google Chat returns the
+// message already created for a requestId, and other webhook receivers get it as
+// an Idempotency-Key header
+func (s *Service) sendToWebhook(ctx context.Context, webhookURL string, message string, idempotencyKey string) error {
 	payload := map[string]interface{}{
 		"text": message,
 	}
@@ -115,9 +481,18 @@ func (s *Service) sendToWebhook(webhookURL string, message string) error {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	resp, err := s.httpClient.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, withRequestID(webhookURL, idempotencyKey), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", redactURLError(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		return fmt.Errorf("failed to send webhook: %w", redactURLError(err))
 	}
 	defer resp.Body.Close()
 
@@ -128,8 +503,38 @@ func (s *Service) sendToWebhook(webhookURL string, message string) error {
 	return nil
 }
 
+// redactURLError hides the credentials in the URL that an error of the HTTP
+// client quotes, as the error is logged and stored with the alert
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = domain.RedactURL(urlErr.URL)
+	}
+	return err
+}
+
+// withRequestID adds the idempotency key as requestId to Google Chat webhook URLs
+func withRequestID(webhookURL, idempotencyKey string) string {
+	if idempotencyKey == "" {
+		return webhookURL
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Host != "chat.googleapis.com" {
+		return webhookURL
+	}
+	query := u.Query()
+	query.Set("requestId", idempotencyKey)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 // FormatAlertMessage creates a human-readable alert message
+// Expiration alerts are formatted when sent, followed by the domain details they were raised with.
 func (s *Service) FormatAlertMessage(alert *domain.Alert) string {
+	if alert.Type != "" && alert.Type != domain.AlertTypeExpiration {
+		return alert.Message
+	}
+
 	daysRemaining := alert.DaysUntilExpiration()
 	thresholdDays := int(alert.GetThreshold().Hours() / 24)
 
@@ -138,11 +543,12 @@ func (s *Service) FormatAlertMessage(alert *domain.Alert) string {
 			"Domain: %s\n"+
 			"Expiration Date: %s\n"+
 			"Days Remaining: %d\n"+
-			"Alert Threshold: %d days\n\n"+
+			"Alert Threshold: %d days%s\n\n"+
 			"Please renew this domain to avoid service disruption.",
 		alert.DomainName,
 		alert.ExpirationDate.Format("2006-01-02"),
 		daysRemaining,
 		thresholdDays,
+		alert.Message,
 	)
 }
@@ -1,13 +1,13 @@
 package alert
 
 import (
-	"os"
+	"context"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/domain"
-	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/memory"
 	"github.com/leanovate/gopter"
 	"github.com/leanovate/gopter/gen"
 	"github.com/leanovate/gopter/prop"
@@ -18,17 +18,10 @@ import (
 // when the time until expiration is less than or equal to the threshold, an alert should be generated.
 // Validates: Requirements 5.1
 func TestProperty_AlertThresholdTriggering(t *testing.T) {
-	dbPath := "test_alert_threshold.db"
-	defer os.Remove(dbPath)
-
-	db, err := repository.NewDB(dbPath, "sqlite3")
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
-	defer db.Close()
-
-	alertRepo := repository.NewAlertRepository(db)
-	configRepo := repository.NewConfigRepository(db)
+	ctx := context.Background()
+	store := memory.NewStore()
+	alertRepo := memory.NewAlertRepository(store)
+	configRepo := memory.NewConfigRepository(store)
 	service := NewService(alertRepo, configRepo)
 
 	properties := gopter.NewProperties(nil)
@@ -55,18 +48,18 @@ func TestProperty_AlertThresholdTriggering(t *testing.T) {
 
 			// Set threshold
 			threshold := time.Duration(thresholdDays) * 24 * time.Hour
-			config, _ := configRepo.Get()
+			config, _ := configRepo.Get(ctx)
 			config.SetAlertThresholds([]time.Duration{threshold})
-			configRepo.Update(config)
+			configRepo.Update(ctx, config)
 
 			// Evaluate alerts
-			err := service.EvaluateAlerts(d)
+			err := service.EvaluateAlerts(ctx, d)
 			if err != nil && !strings.Contains(err.Error(), "no webhook") {
 				return false
 			}
 
 			// Check if alert was created when it should be
-			alerts, _ := alertRepo.GetByDomainID(d.ID)
+			alerts, _ := alertRepo.GetByDomainID(ctx, d.ID)
 
 			if daysUntilExpiration <= thresholdDays {
 				// Should have created an alert
@@ -123,17 +116,10 @@ func TestProperty_AlertMessageCompleteness(t *testing.T) {
 // when that threshold is crossed, and subsequent evaluations should not generate duplicate alerts.
 // Validates: Requirements 6.4, 6.5
 func TestProperty_AlertDeduplication(t *testing.T) {
-	dbPath := "test_alert_dedup.db"
-	defer os.Remove(dbPath)
-
-	db, err := repository.NewDB(dbPath, "sqlite3")
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
-	defer db.Close()
-
-	alertRepo := repository.NewAlertRepository(db)
-	configRepo := repository.NewConfigRepository(db)
+	ctx := context.Background()
+	store := memory.NewStore()
+	alertRepo := memory.NewAlertRepository(store)
+	configRepo := memory.NewConfigRepository(store)
 	service := NewService(alertRepo, configRepo)
 
 	properties := gopter.NewProperties(nil)
@@ -161,16 +147,16 @@ func TestProperty_AlertDeduplication(t *testing.T) {
 
 			// Set threshold higher than days until expiration so alert will trigger
 			threshold := time.Duration(daysUntilExpiration+10) * 24 * time.Hour
-			config, _ := configRepo.Get()
+			config, _ := configRepo.Get(ctx)
 			config.SetAlertThresholds([]time.Duration{threshold})
-			configRepo.Update(config)
+			configRepo.Update(ctx, config)
 
 			// Evaluate alerts twice
-			service.EvaluateAlerts(d)
-			service.EvaluateAlerts(d)
+			service.EvaluateAlerts(ctx, d)
+			service.EvaluateAlerts(ctx, d)
 
 			// Should only have one alert (deduplication works)
-			alerts, _ := alertRepo.GetByDomainID(d.ID)
+			alerts, _ := alertRepo.GetByDomainID(ctx, d.ID)
 			return len(alerts) == 1
 		},
 		gen.UInt8Range(1, 50),
@@ -0,0 +1,565 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/memory"
+)
+
+// newWebhookTestService creates an alert service backed by an in-memory store and a
+// webhook that counts the notifications it receives
+func newWebhookTestService(t *testing.T) (*Service, repository.AlertStore, *int32) {
+	t.Helper()
+
+	webhookCalls := new(int32)
+	service, alertRepo := newTestServiceWithWebhook(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(webhookCalls, 1)
+	}))
+	return service, alertRepo, webhookCalls
+}
+
+// newTestServiceWithWebhook creates an alert service backed by an in-memory store
+// that sends to the given webhook handler
+func newTestServiceWithWebhook(t *testing.T, handler http.Handler) (*Service, repository.AlertStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	webhook := httptest.NewServer(handler)
+	t.Cleanup(webhook.Close)
+
+	store := memory.NewStore()
+	alertRepo := memory.NewAlertRepository(store)
+	configRepo := memory.NewConfigRepository(store)
+
+	config, err := configRepo.Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	config.GoogleChatWebhook = webhook.URL
+	if err := configRepo.Update(ctx, config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	return NewService(alertRepo, configRepo), alertRepo
+}
+
+// Test that HTTP check alerts fire once when the failure threshold is reached and once on recovery
+func TestEvaluateHTTPCheck_ConsecutiveFailures(t *testing.T) {
+	ctx := context.Background()
+	service, alertRepo, webhookCalls := newWebhookTestService(t)
+
+	d := &domain.Domain{ID: "http-check-domain", Name: "example.com", ExpirationDate: time.Now().Add(365 * 24 * time.Hour)}
+	check := &domain.HTTPCheck{ID: "check-1", DomainID: d.ID, URL: "https://example.com/", FailureThreshold: 3}
+
+	// Five consecutive failures should alert exactly once, on the third
+	for i := 0; i < 5; i++ {
+		previous := check.ConsecutiveFailures
+		check.ConsecutiveFailures++
+		result := &domain.HTTPCheckResult{CheckID: check.ID, ErrorMessage: "expected a 2xx status, got 503"}
+		if err := service.EvaluateHTTPCheck(ctx, d, check, result, previous); err != nil {
+			t.Fatalf("EvaluateHTTPCheck() unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(webhookCalls); got != 1 {
+		t.Fatalf("Expected 1 failure alert, got %d webhook calls", got)
+	}
+
+	// Recovery sends a single notice
+	previous := check.ConsecutiveFailures
+	check.ConsecutiveFailures = 0
+	result := &domain.HTTPCheckResult{CheckID: check.ID, StatusCode: 200, Success: true}
+	if err := service.EvaluateHTTPCheck(ctx, d, check, result, previous); err != nil {
+		t.Fatalf("EvaluateHTTPCheck() unexpected error: %v", err)
+	}
+
+	if got := atomic.LoadInt32(webhookCalls); got != 2 {
+		t.Fatalf("Expected a recovery alert, got %d webhook calls", got)
+	}
+
+	alerts, err := alertRepo.GetByDomainID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("Expected 2 recorded alerts, got %d", len(alerts))
+	}
+	for _, a := range alerts {
+		if a.Type != domain.AlertTypeHTTPCheck || !a.Success || a.Message == "" {
+			t.Errorf("Unexpected alert record: %+v", a)
+		}
+	}
+}
+
+// Test that watched domains alert on lifecycle transitions and never on expiration thresholds
+func TestEvaluateWatchState_Transitions(t *testing.T) {
+	ctx := context.Background()
+	service, _, webhookCalls := newWebhookTestService(t)
+
+	d := &domain.Domain{
+		ID:                "watched-domain",
+		Name:              "wanted.com",
+		ExpirationDate:    time.Now().Add(-10 * 24 * time.Hour),
+		Mode:              domain.ModeWatch,
+		RegistrationState: domain.RegistrationRegistered,
+	}
+
+	// An expired watched domain is someone else's problem
+	if err := service.EvaluateAlerts(ctx, d); err != nil {
+		t.Fatalf("EvaluateAlerts() unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(webhookCalls); got != 0 {
+		t.Fatalf("Expected no expiration alerts for a watched domain, got %d webhook calls", got)
+	}
+
+	transitions := []string{
+		domain.RegistrationRegistered,    // unchanged
+		domain.RegistrationRedemption,    // alert
+		domain.RegistrationRedemption,    // unchanged
+		domain.RegistrationPendingDelete, // alert
+		domain.RegistrationAvailable,     // alert
+	}
+	for _, state := range transitions {
+		previous := d.RegistrationState
+		d.RegistrationState = state
+		if err := service.EvaluateWatchState(ctx, d, previous); err != nil {
+			t.Fatalf("EvaluateWatchState() unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(webhookCalls); got != 3 {
+		t.Fatalf("Expected 3 watch alerts, got %d webhook calls", got)
+	}
+}
+
+// Test that a dropped monitored domain alerts once and stops expiration alerts
+func TestEvaluateRegistration_NotRegistered(t *testing.T) {
+	ctx := context.Background()
+	service, alertRepo, webhookCalls := newWebhookTestService(t)
+
+	d := &domain.Domain{
+		ID:                "dropped-domain",
+		Name:              "dropped.com",
+		ExpirationDate:    time.Now().Add(5 * 24 * time.Hour),
+		Mode:              domain.ModeMonitor,
+		RegistrationState: domain.RegistrationRegistered,
+	}
+
+	for i := 0; i < 3; i++ {
+		previous := d.RegistrationState
+		d.RegistrationState = domain.RegistrationAvailable
+		if err := service.EvaluateRegistration(ctx, d, previous); err != nil {
+			t.Fatalf("EvaluateRegistration() unexpected error: %v", err)
+		}
+		// The stale expiration date would otherwise cross every threshold
+		if err := service.EvaluateAlerts(ctx, d); err != nil {
+			t.Fatalf("EvaluateAlerts() unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(webhookCalls); got != 1 {
+		t.Fatalf("Expected 1 not-registered alert, got %d webhook calls", got)
+	}
+
+	alerts, err := alertRepo.GetByDomainID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Type != domain.AlertTypeNotRegistered {
+		t.Fatalf("Expected a single not_registered alert, got %+v", alerts)
+	}
+}
+
+// Test that alerts name the owner, criticality and tags of the domain, including
+// expiration alerts that are formatted when sent
+func TestAlerts_IncludeDomainDetails(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var messages []string
+	service, _ := newTestServiceWithWebhook(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		messages = append(messages, payload.Text)
+		mu.Unlock()
+	}))
+
+	d := &domain.Domain{
+		ID:                "shop-domain",
+		Name:              "shop.com",
+		ExpirationDate:    time.Now().Add(5 * 24 * time.Hour),
+		Mode:              domain.ModeMonitor,
+		RegistrationState: domain.RegistrationRegistered,
+		Metadata:          domain.Metadata{Owner: "Web Team", Criticality: domain.CriticalityCritical, Tags: domain.Strings{"eu", "prod"}},
+	}
+	if err := service.EvaluateAlerts(ctx, d); err != nil {
+		t.Fatalf("EvaluateAlerts() unexpected error: %v", err)
+	}
+	previous := d.RegistrationState
+	d.RegistrationState = domain.RegistrationAvailable
+	if err := service.EvaluateRegistration(ctx, d, previous); err != nil {
+		t.Fatalf("EvaluateRegistration() unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) == 0 {
+		t.Fatal("No alerts sent")
+	}
+	for _, message := range messages {
+		for _, want := range []string{"Owner: Web Team", "Criticality: critical", "Tags: eu, prod"} {
+			if !strings.Contains(message, want) {
+				t.Errorf("Alert %q does not contain %q", message, want)
+			}
+		}
+	}
+}
+
+// Test that only domains at least as critical as configured alert
+func TestAlerts_CriticalityFilter(t *testing.T) {
+	ctx := context.Background()
+	service, _, webhookCalls := newWebhookTestService(t)
+
+	config, err := service.configRepo.Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	config.AlertCriticality = domain.CriticalityHigh
+	if err := service.configRepo.Update(ctx, config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	alert := func(id, criticality string) {
+		t.Helper()
+		d := &domain.Domain{
+			ID:                id,
+			Name:              id + ".com",
+			ExpirationDate:    time.Now().Add(5 * 24 * time.Hour),
+			Mode:              domain.ModeMonitor,
+			RegistrationState: domain.RegistrationAvailable,
+			Metadata:          domain.Metadata{Criticality: criticality},
+		}
+		if err := service.EvaluateAlerts(ctx, d); err != nil {
+			t.Fatalf("EvaluateAlerts() unexpected error: %v", err)
+		}
+		if err := service.EvaluateRegistration(ctx, d, domain.RegistrationRegistered); err != nil {
+			t.Fatalf("EvaluateRegistration() unexpected error: %v", err)
+		}
+	}
+
+	alert("blog", domain.CriticalityNormal)
+	if calls := atomic.LoadInt32(webhookCalls); calls != 0 {
+		t.Errorf("Webhook called %d times for a normal domain, want none", calls)
+	}
+	alert("shop", domain.CriticalityCritical)
+	if calls := atomic.LoadInt32(webhookCalls); calls == 0 {
+		t.Error("Webhook not called for a critical domain")
+	}
+}
+
+// Test that losing a required lock or starting a transfer alerts once per change
+func TestEvaluateStatusChanges(t *testing.T) {
+	ctx := context.Background()
+	service, alertRepo, webhookCalls := newWebhookTestService(t)
+
+	d := &domain.Domain{
+		ID:               "locked-domain",
+		Name:             "locked.com",
+		ExpirationDate:   time.Now().Add(365 * 24 * time.Hour),
+		Statuses:         domain.Strings{domain.StatusClientTransferProhibited, domain.StatusServerUpdateProhibited},
+		RequiredStatuses: domain.Strings{domain.StatusClientTransferProhibited, domain.StatusServerUpdateProhibited},
+	}
+
+	steps := []domain.Strings{
+		{domain.StatusClientTransferProhibited, domain.StatusServerUpdateProhibited}, // unchanged
+		{domain.StatusServerUpdateProhibited},                                        // lock removed: alert
+		{domain.StatusServerUpdateProhibited},                                        // still removed
+		{domain.StatusServerUpdateProhibited, domain.StatusPendingTransfer},          // transfer: alert
+		{domain.StatusServerUpdateProhibited, domain.StatusPendingTransfer},          // still pending
+	}
+	for _, statuses := range steps {
+		previous := d.Statuses
+		d.Statuses = statuses
+		if err := service.EvaluateStatusChanges(ctx, d, previous); err != nil {
+			t.Fatalf("EvaluateStatusChanges() unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(webhookCalls); got != 2 {
+		t.Fatalf("Expected 2 status alerts, got %d webhook calls", got)
+	}
+
+	alerts, err := alertRepo.GetByDomainID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	for _, a := range alerts {
+		if a.Type != domain.AlertTypeStatus {
+			t.Errorf("Unexpected alert type %q", a.Type)
+		}
+	}
+}
+
+// Test that replicas evaluating the same domain concurrently send each threshold alert once
+func TestEvaluateAlerts_ConcurrentReplicas(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_concurrent_alerts.db"
+	t.Cleanup(func() { os.Remove(dbPath) })
+
+	var webhookCalls int32
+	keys := make(chan string, 100)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&webhookCalls, 1)
+		keys <- r.Header.Get("Idempotency-Key")
+	}))
+	t.Cleanup(webhook.Close)
+
+	// Each replica has its own connection pool to the shared database
+	var services []*Service
+	var alertRepo *repository.AlertRepository
+	for i := 0; i < 2; i++ {
+		db, err := repository.NewDB(dbPath, "sqlite3")
+		if err != nil {
+			t.Fatalf("Failed to create test database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		configRepo := repository.NewConfigRepository(db)
+		config, err := configRepo.Get(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get config: %v", err)
+		}
+		config.GoogleChatWebhook = webhook.URL
+		config.AlertThresholds = domain.Durations{30 * 24 * time.Hour}
+		if err := configRepo.Update(ctx, config); err != nil {
+			t.Fatalf("Failed to update config: %v", err)
+		}
+		alertRepo = repository.NewAlertRepository(db)
+		services = append(services, NewService(alertRepo, configRepo))
+	}
+
+	expiration := time.Now().Add(10 * 24 * time.Hour)
+	evaluate := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(service *Service) {
+				defer wg.Done()
+				d := &domain.Domain{ID: "shared-domain", Name: "shared.com", ExpirationDate: expiration}
+				if err := service.EvaluateAlerts(ctx, d); err != nil {
+					t.Errorf("EvaluateAlerts() unexpected error: %v", err)
+				}
+			}(services[i%len(services)])
+		}
+		wg.Wait()
+	}
+
+	evaluate()
+	if got := atomic.LoadInt32(&webhookCalls); got != 1 {
+		t.Fatalf("Expected 1 webhook call, got %d", got)
+	}
+
+	alerts, err := alertRepo.GetByDomainID(ctx, "shared-domain")
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Status != domain.AlertStatusSent {
+		t.Fatalf("Expected a single sent alert, got %+v", alerts)
+	}
+	if key := <-keys; key != alerts[0].ID {
+		t.Errorf("Idempotency-Key = %q, want alert ID %q", key, alerts[0].ID)
+	}
+
+	// A renewal starts a new expiration cycle that alerts again
+	expiration = expiration.Add(5 * 24 * time.Hour)
+	evaluate()
+	if got := atomic.LoadInt32(&webhookCalls); got != 2 {
+		t.Fatalf("Expected a second alert for the new expiration date, got %d webhook calls", got)
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "google chat",
+			url:  "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&token=t",
+			want: "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&requestId=abc&token=t",
+		},
+		{
+			name: "other webhook",
+			url:  "https://hooks.example.com/notify?token=t",
+			want: "https://hooks.example.com/notify?token=t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withRequestID(tt.url, "abc"); got != tt.want {
+				t.Errorf("withRequestID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test that delivery errors, which are stored and shown, leave out the webhook's credentials
+func TestSendToWebhook_RedactsURL(t *testing.T) {
+	webhook := httptest.NewServer(http.NotFoundHandler())
+	webhook.Close()
+
+	service := NewService(nil, nil)
+	err := service.sendToWebhook(context.Background(), webhook.URL+"/notify?token=secret-token", "test", "abc")
+	if err == nil {
+		t.Fatal("sendToWebhook() to a closed server succeeded, want an error")
+	}
+	if strings.Contains(err.Error(), "secret-token") || !strings.Contains(err.Error(), "token=REDACTED") {
+		t.Errorf("sendToWebhook() error = %q, want the token redacted", err)
+	}
+}
+
+// Test that failed deliveries are retried once due, dead-lettered after the last
+// attempt and can be resent by hand
+func TestRetryFailed_BackoffAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	var healthy atomic.Bool
+	var webhookCalls int32
+	service, alertRepo := newTestServiceWithWebhook(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&webhookCalls, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	service.sendBackoff = time.Millisecond
+
+	d := &domain.Domain{ID: "retry-domain", Name: "retry.com", RegistrationState: domain.RegistrationAvailable}
+	if err := service.EvaluateRegistration(ctx, d, domain.RegistrationRegistered); err != nil {
+		t.Fatalf("EvaluateRegistration() unexpected error: %v", err)
+	}
+
+	alerts, err := alertRepo.GetFailedAlerts(ctx)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("Expected 1 failed alert, got %d (%v)", len(alerts), err)
+	}
+	a := alerts[0]
+	if a.Status != domain.AlertStatusFailed || a.Attempts != 1 || a.NextAttemptAt == nil {
+		t.Fatalf("Expected a scheduled retry after the first attempt, got %+v", a)
+	}
+	if wait := time.Until(*a.NextAttemptAt); wait < retryBaseDelay-time.Minute || wait > retryBaseDelay {
+		t.Errorf("First retry in %v, want about %v", wait, retryBaseDelay)
+	}
+
+	// Nothing is due yet
+	calls := atomic.LoadInt32(&webhookCalls)
+	if err := service.RetryFailed(ctx); err != nil {
+		t.Fatalf("RetryFailed() unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&webhookCalls); got != calls {
+		t.Fatalf("Expected no retry before it is due, got %d webhook calls", got-calls)
+	}
+
+	// Fast-forward through every remaining attempt
+	for attempt := 2; attempt <= maxDeliveryAttempts; attempt++ {
+		a, err = alertRepo.GetByID(ctx, a.ID)
+		if err != nil {
+			t.Fatalf("Failed to get alert: %v", err)
+		}
+		past := time.Now().Add(-time.Second)
+		a.NextAttemptAt = &past
+		if err := alertRepo.Finalize(ctx, a); err != nil {
+			t.Fatalf("Failed to make retry due: %v", err)
+		}
+		if err := service.RetryFailed(ctx); err != nil {
+			t.Fatalf("RetryFailed() unexpected error: %v", err)
+		}
+	}
+
+	a, err = alertRepo.GetByID(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("Failed to get alert: %v", err)
+	}
+	if a.Status != domain.AlertStatusDead || a.Attempts != maxDeliveryAttempts || a.NextAttemptAt != nil {
+		t.Fatalf("Expected a dead alert after %d attempts, got %+v", maxDeliveryAttempts, a)
+	}
+
+	// The webhook recovers and the alert is resent by hand
+	healthy.Store(true)
+	resent, err := service.Resend(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("Resend() unexpected error: %v", err)
+	}
+	if resent.Status != domain.AlertStatusSent || !resent.Success || resent.Attempts != 1 {
+		t.Fatalf("Expected the resent alert to be delivered, got %+v", resent)
+	}
+
+	if _, err := service.Resend(ctx, a.ID); err != ErrAlertNotResendable {
+		t.Errorf("Resend() of a delivered alert error = %v, want ErrAlertNotResendable", err)
+	}
+}
+
+// Test that a canceled delivery stops waiting on a slow webhook and leaves the
+// alert pending for a later retry
+func TestEvaluateRegistration_Canceled(t *testing.T) {
+	var webhookCalls int32
+	release := make(chan struct{})
+	service, alertRepo := newTestServiceWithWebhook(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&webhookCalls, 1)
+		<-release
+	}))
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	d := &domain.Domain{ID: "slow-domain", Name: "slow.com", RegistrationState: domain.RegistrationAvailable}
+	if err := service.EvaluateRegistration(ctx, d, domain.RegistrationRegistered); err == nil {
+		t.Error("EvaluateRegistration() succeeded after its context ended")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("EvaluateRegistration() returned after %v, want it to stop when canceled", elapsed)
+	}
+	if got := atomic.LoadInt32(&webhookCalls); got != 1 {
+		t.Errorf("Webhook calls = %d, want no retries after cancellation", got)
+	}
+
+	alerts, err := alertRepo.GetByDomainID(context.Background(), d.ID)
+	if err != nil || len(alerts) != 1 || alerts[0].Status != domain.AlertStatusPending {
+		t.Fatalf("Alerts = %+v (%v), want one pending alert", alerts, err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{6, 160 * time.Minute},
+		{7, 4 * time.Hour},
+		{20, 4 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -1,19 +1,54 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 )
 
+// Alert types
+const (
+	AlertTypeExpiration    = "expiration"
+	AlertTypeHTTPCheck     = "http_check"
+	AlertTypeLookalike     = "lookalike"
+	AlertTypeWatch         = "watch"
+	AlertTypeNotRegistered = "not_registered"
+	AlertTypeStatus        = "status"
+)
+
+// Alert delivery statuses
+const (
+	// AlertStatusPending marks an alert claimed for sending whose outcome is not recorded yet
+	AlertStatusPending = "pending"
+	AlertStatusSent    = "sent"
+	// AlertStatusFailed marks an alert whose delivery failed and is scheduled for a retry
+	AlertStatusFailed = "failed"
+	// AlertStatusDead marks an alert that ran out of delivery attempts
+	AlertStatusDead = "dead"
+)
+
 // Alert represents a notification sent for a domain approaching expiration
+// or for another monitored event such as a failing HTTP check
 type Alert struct {
-	ID             string    `db:"id" json:"id"`
-	DomainID       string    `db:"domain_id" json:"domain_id"`
-	DomainName     string    `db:"domain_name" json:"domain_name"`
-	Threshold      int64     `db:"threshold" json:"threshold"` // stored as nanoseconds
-	ExpirationDate time.Time `db:"expiration_date" json:"expiration_date"`
-	SentAt         time.Time `db:"sent_at" json:"sent_at"`
-	Success        bool      `db:"success" json:"success"`
-	ErrorMessage   string    `db:"error_message" json:"error_message"`
+	ID             string     `db:"id" json:"id"`
+	DomainID       string     `db:"domain_id" json:"domain_id"`
+	DomainName     string     `db:"domain_name" json:"domain_name"`
+	Threshold      int64      `db:"threshold" json:"threshold"` // stored as nanoseconds
+	ExpirationDate time.Time  `db:"expiration_date" json:"expiration_date"`
+	SentAt         time.Time  `db:"sent_at" json:"sent_at"`
+	Success        bool       `db:"success" json:"success"`
+	ErrorMessage   string     `db:"error_message" json:"error_message"`
+	Type           string     `db:"type" json:"type"`
+	Message        string     `db:"message" json:"message"` // preformatted text, or the domain details of an expiration alert
+	DedupKey       string     `db:"dedup_key" json:"dedup_key"`
+	Status         string     `db:"status" json:"status"`
+	Attempts       int        `db:"attempts" json:"attempts"`
+	NextAttemptAt  *time.Time `db:"next_attempt_at" json:"next_attempt_at,omitempty"`
+}
+
+// ExpirationDedupKey identifies the expiration alert for a domain, threshold and expiry cycle
+// Renewing the domain starts a new cycle, so each threshold alerts again
+func ExpirationDedupKey(domainID string, threshold time.Duration, expirationDate time.Time) string {
+	return fmt.Sprintf("%s:%s:%d:%s", AlertTypeExpiration, domainID, int64(threshold), expirationDate.UTC().Format("2006-01-02"))
 }
 
 // GetThreshold returns the threshold as a time.Duration
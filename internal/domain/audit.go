@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Audited actions, named after the object they change
+const (
+	AuditDomainCreate           = "domain.create"
+	AuditDomainDelete           = "domain.delete"
+	AuditDomainState            = "domain.state"
+	AuditDomainMetadata         = "domain.metadata"
+	AuditDomainRequiredStatuses = "domain.required_statuses"
+	AuditDomainCheck            = "domain.check"
+	AuditHTTPCheckCreate        = "http_check.create"
+	AuditHTTPCheckDelete        = "http_check.delete"
+	AuditAlertResend            = "alert.resend"
+	AuditConfigUpdate           = "config.update"
+	AuditConfigRollback         = "config.rollback"
+)
+
+// AuditActions lists the audited actions
+var AuditActions = []string{
+	AuditDomainCreate, AuditDomainDelete, AuditDomainState, AuditDomainMetadata,
+	AuditDomainRequiredStatuses, AuditDomainCheck, AuditHTTPCheckCreate,
+	AuditHTTPCheckDelete, AuditAlertResend, AuditConfigUpdate, AuditConfigRollback,
+}
+
+// Types of audited objects
+const (
+	AuditObjectDomain    = "domain"
+	AuditObjectHTTPCheck = "http_check"
+	AuditObjectAlert     = "alert"
+	AuditObjectConfig    = "config"
+)
+
+// AuditObjectTypes lists the types of audited objects
+var AuditObjectTypes = []string{AuditObjectDomain, AuditObjectHTTPCheck, AuditObjectAlert, AuditObjectConfig}
+
+// AnonymousActor is the actor of changes made without a known user
+const AnonymousActor = "anonymous"
+
+// AuditEntry records one change: who made it, from where, and the object
+// before and after it. Entries are only ever appended.
+type AuditEntry struct {
+	ID         string    `db:"id" json:"id"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	Actor      string    `db:"actor" json:"actor"`
+	SourceIP   string    `db:"source_ip" json:"source_ip"`
+	Action     string    `db:"action" json:"action"`
+	ObjectType string    `db:"object_type" json:"object_type"`
+	ObjectID   string    `db:"object_id" json:"object_id"`
+	// ObjectName identifies the object to people, also once it is deleted
+	ObjectName string `db:"object_name" json:"object_name"`
+	// Before and After are JSON documents of the object, empty where it did not exist
+	Before string `db:"before_json" json:"before,omitempty"`
+	After  string `db:"after_json" json:"after,omitempty"`
+}
+
+// SetBefore records the object as it was before the change
+func (e *AuditEntry) SetBefore(v interface{}) error {
+	data, err := auditJSON(v)
+	e.Before = data
+	return err
+}
+
+// SetAfter records the object as it is after the change
+func (e *AuditEntry) SetAfter(v interface{}) error {
+	data, err := auditJSON(v)
+	e.After = data
+	return err
+}
+
+// auditJSON encodes an audited object, leaving nil as no document
+func auditJSON(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audited object: %w", err)
+	}
+	return string(data), nil
+}
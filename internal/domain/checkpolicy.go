@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinCheckInterval is the shortest interval a check policy may use
+const MinCheckInterval = time.Hour
+
+// CheckTier sets how often a domain is checked while at least MinRemaining is left
+// until it expires
+type CheckTier struct {
+	MinRemaining time.Duration `json:"min_remaining"`
+	Interval     time.Duration `json:"interval"`
+}
+
+// CheckPolicy decides how often domains are checked from the time left until they
+// expire. The tier with the largest MinRemaining the domain still has applies; the
+// tier with the smallest MinRemaining also covers expired domains.
+type CheckPolicy []CheckTier
+
+// DefaultCheckPolicy checks weekly while a domain has half a year left, every three
+// days after that, daily in its last two months and hourly in its last week and
+// after it expires
+func DefaultCheckPolicy() CheckPolicy {
+	return CheckPolicy{
+		{MinRemaining: 180 * 24 * time.Hour, Interval: 7 * 24 * time.Hour},
+		{MinRemaining: 60 * 24 * time.Hour, Interval: 3 * 24 * time.Hour},
+		{MinRemaining: 7 * 24 * time.Hour, Interval: 24 * time.Hour},
+		{MinRemaining: 0, Interval: time.Hour},
+	}
+}
+
+// Sorted returns the tiers ordered from the furthest expiration to the nearest
+func (p CheckPolicy) Sorted() CheckPolicy {
+	sorted := append(CheckPolicy(nil), p...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinRemaining > sorted[j].MinRemaining
+	})
+	return sorted
+}
+
+// Validate reports whether the policy can schedule checks
+func (p CheckPolicy) Validate() error {
+	if len(p) == 0 {
+		return fmt.Errorf("at least one check tier is required")
+	}
+	seen := make(map[time.Duration]bool, len(p))
+	for _, tier := range p {
+		if tier.MinRemaining < 0 {
+			return fmt.Errorf("time remaining must not be negative: %s", formatPolicyDuration(tier.MinRemaining))
+		}
+		if tier.Interval < MinCheckInterval {
+			return fmt.Errorf("check interval must be at least %s: %s", formatPolicyDuration(MinCheckInterval), formatPolicyDuration(tier.Interval))
+		}
+		if seen[tier.MinRemaining] {
+			return fmt.Errorf("duplicate check tier for %s remaining", formatPolicyDuration(tier.MinRemaining))
+		}
+		seen[tier.MinRemaining] = true
+	}
+	return nil
+}
+
+// String formats the policy as ParseCheckPolicy accepts it, e.g. "180d:7d, 7d:1d, 0d:1h"
+func (p CheckPolicy) String() string {
+	parts := make([]string, 0, len(p))
+	for _, tier := range p.Sorted() {
+		parts = append(parts, formatPolicyDuration(tier.MinRemaining)+":"+formatPolicyDuration(tier.Interval))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseCheckPolicy parses comma-separated "remaining:interval" tiers such as
+// "180d:7d, 60d:1d, 0d:1h", meaning "with at least 180 days left check every 7 days"
+func ParseCheckPolicy(s string) (CheckPolicy, error) {
+	var policy CheckPolicy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		remaining, interval, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid check tier %q: expected remaining:interval", part)
+		}
+		minRemaining, err := parsePolicyDuration(remaining)
+		if err != nil {
+			return nil, fmt.Errorf("invalid check tier %q: %w", part, err)
+		}
+		every, err := parsePolicyDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid check tier %q: %w", part, err)
+		}
+		policy = append(policy, CheckTier{MinRemaining: minRemaining, Interval: every})
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy.Sorted(), nil
+}
+
+// parsePolicyDuration parses a duration that may use a "d" suffix for days
+func parsePolicyDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatPolicyDuration formats whole days as "7d" and whole hours as "12h"
+func formatPolicyDuration(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return d.String()
+	}
+}
+
+// Value implements the driver.Valuer interface for database storage
+// The JSON is passed as a string so PostgreSQL accepts it for JSONB columns.
+func (p CheckPolicy) Value() (driver.Value, error) {
+	if p == nil {
+		p = CheckPolicy{}
+	}
+	data, err := json.Marshal([]CheckTier(p))
+	return string(data), err
+}
+
+// Scan implements the sql.Scanner interface for database retrieval
+func (p *CheckPolicy) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		*p = CheckPolicy{}
+		return nil
+	}
+
+	if len(data) == 0 {
+		*p = CheckPolicy{}
+		return nil
+	}
+
+	var tiers []CheckTier
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		return err
+	}
+	*p = tiers
+	return nil
+}
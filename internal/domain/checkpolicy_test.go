@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCheckPolicy(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		input   string
+		want    CheckPolicy
+		wantErr bool
+	}{
+		{
+			name:  "sorted from furthest to nearest expiration",
+			input: "0d:1h, 180d:7d,60d:24h",
+			want: CheckPolicy{
+				{MinRemaining: 180 * day, Interval: 7 * day},
+				{MinRemaining: 60 * day, Interval: day},
+				{MinRemaining: 0, Interval: time.Hour},
+			},
+		},
+		{
+			name:  "hours and minutes",
+			input: "36h:90m",
+			want:  CheckPolicy{{MinRemaining: 36 * time.Hour, Interval: 90 * time.Minute}},
+		},
+		{name: "empty", input: " , ", wantErr: true},
+		{name: "missing interval", input: "180d", wantErr: true},
+		{name: "bad days", input: "xd:1d", wantErr: true},
+		{name: "interval too short", input: "0d:30m", wantErr: true},
+		{name: "negative remaining", input: "-1d:1h", wantErr: true},
+		{name: "duplicate tier", input: "7d:1d, 7d:1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCheckPolicy(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseCheckPolicy(%q) expected an error, got %v", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCheckPolicy(%q) unexpected error: %v", tt.input, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseCheckPolicy(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("tier %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPolicy_String(t *testing.T) {
+	if got, want := DefaultCheckPolicy().String(), "180d:7d, 60d:3d, 7d:1d, 0d:1h"; got != want {
+		t.Errorf("DefaultCheckPolicy().String() = %q, want %q", got, want)
+	}
+}
@@ -3,17 +3,20 @@ package domain
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"net/url"
 	"time"
 )
 
 // Config represents the application configuration
 type Config struct {
-	ID                 int       `db:"id" json:"id"`
-	MonitoringInterval int64     `db:"monitoring_interval" json:"monitoring_interval"` // stored as nanoseconds
-	AlertThresholds    Durations `db:"alert_thresholds" json:"alert_thresholds"`
-	GoogleChatWebhook  string    `db:"google_chat_webhook" json:"google_chat_webhook"`
-	RetentionPeriod    int64     `db:"retention_period" json:"retention_period"` // stored as nanoseconds
-	UpdatedAt          time.Time `db:"updated_at" json:"updated_at"`
+	ID                 int         `db:"id" json:"id"`
+	MonitoringInterval int64       `db:"monitoring_interval" json:"monitoring_interval"` // stored as nanoseconds
+	AlertThresholds    Durations   `db:"alert_thresholds" json:"alert_thresholds"`
+	GoogleChatWebhook  string      `db:"google_chat_webhook" json:"google_chat_webhook"`
+	RetentionPeriod    int64       `db:"retention_period" json:"retention_period"` // stored as nanoseconds
+	CheckPolicy        CheckPolicy `db:"check_policy" json:"check_policy"`
+	AlertCriticality   string      `db:"alert_criticality" json:"alert_criticality"` // least criticality that alerts; empty for all
+	UpdatedAt          time.Time   `db:"updated_at" json:"updated_at"`
 }
 
 // GetMonitoringInterval returns the monitoring interval as a time.Duration
@@ -36,6 +39,14 @@ func (c *Config) SetRetentionPeriod(d time.Duration) {
 	c.RetentionPeriod = int64(d)
 }
 
+// GetCheckPolicy returns the configured check policy, or the default when none is set
+func (c *Config) GetCheckPolicy() CheckPolicy {
+	if len(c.CheckPolicy) == 0 {
+		return DefaultCheckPolicy()
+	}
+	return c.CheckPolicy
+}
+
 // GetAlertThresholds returns the alert thresholds as []time.Duration
 func (c *Config) GetAlertThresholds() []time.Duration {
 	return []time.Duration(c.AlertThresholds)
@@ -46,21 +57,65 @@ func (c *Config) SetAlertThresholds(thresholds []time.Duration) {
 	c.AlertThresholds = Durations(thresholds)
 }
 
+// AlertsFor reports whether alerts about d are sent, given its criticality
+func (c *Config) AlertsFor(d *Domain) bool {
+	if c.AlertCriticality == "" {
+		return true
+	}
+	criticality := d.Criticality
+	if criticality == "" {
+		criticality = CriticalityNormal
+	}
+	return criticalityRank(criticality) >= criticalityRank(c.AlertCriticality)
+}
+
+// AlertCriticalityName describes which domains alert, e.g. "high and above"
+func (c *Config) AlertCriticalityName() string {
+	if c.AlertCriticality == "" {
+		return "all domains"
+	}
+	return c.AlertCriticality + " and above"
+}
+
+// Redacted returns a copy of the config that can be shown and logged, with the
+// credentials in the webhook URL hidden but the space it posts to still visible
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.GoogleChatWebhook = RedactURL(c.GoogleChatWebhook)
+	return &redacted
+}
+
+// RedactURL hides the password and the query parameter values of a URL, where
+// webhooks carry their keys and tokens
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "REDACTED"
+	}
+	query := u.Query()
+	for key := range query {
+		query.Set(key, "REDACTED")
+	}
+	u.RawQuery = query.Encode()
+	return u.Redacted()
+}
+
 // Durations is a custom type for storing duration slices as JSON in the database
 type Durations []time.Duration
 
 // Value implements the driver.Valuer interface for database storage
+// The JSON is passed as a string so PostgreSQL accepts it for JSONB columns.
 func (d Durations) Value() (driver.Value, error) {
-	if d == nil {
-		return json.Marshal([]int64{})
-	}
-	
 	// Convert durations to nanoseconds for storage
 	nanos := make([]int64, len(d))
 	for i, dur := range d {
 		nanos[i] = int64(dur)
 	}
-	return json.Marshal(nanos)
+	data, err := json.Marshal(nanos)
+	return string(data), err
 }
 
 // Scan implements the sql.Scanner interface for database retrieval
@@ -70,8 +125,13 @@ func (d *Durations) Scan(value interface{}) error {
 		return nil
 	}
 	
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		*d = []time.Duration{}
 		return nil
 	}
@@ -112,5 +112,47 @@ func TestProperty_ConfigurationPersistence(t *testing.T) {
 		gen.SliceOf(gen.Int64Range(1, 365)),
 	))
 
+	properties.Property("check policy text and JSON round-trip", prop.ForAll(
+		func(remainingDays []int64, intervalHours int64) bool {
+			seen := make(map[int64]bool)
+			original := CheckPolicy{}
+			for i, days := range remainingDays {
+				if seen[days] {
+					continue
+				}
+				seen[days] = true
+				interval := time.Duration(intervalHours+int64(i)) * time.Hour
+				original = append(original, CheckTier{MinRemaining: time.Duration(days) * 24 * time.Hour, Interval: interval})
+			}
+			if len(original) == 0 {
+				return true
+			}
+			original = original.Sorted()
+
+			parsed, err := ParseCheckPolicy(original.String())
+			if err != nil || len(parsed) != len(original) {
+				return false
+			}
+
+			value, err := original.Value()
+			if err != nil {
+				return false
+			}
+			var scanned CheckPolicy
+			if err := scanned.Scan(value); err != nil || len(scanned) != len(original) {
+				return false
+			}
+
+			for i := range original {
+				if parsed[i] != original[i] || scanned[i] != original[i] {
+					return false
+				}
+			}
+			return true
+		},
+		gen.SliceOf(gen.Int64Range(0, 1000)),
+		gen.Int64Range(1, 24*30),
+	))
+
 	properties.TestingRun(t, gopter.ConsoleReporter(false))
 }
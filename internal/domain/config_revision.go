@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConfigRevision is one saved version of the configuration
+// Every save adds a revision and none is ever changed, so earlier settings can be
+// compared and restored.
+type ConfigRevision struct {
+	ID                 string      `db:"id" json:"id"`
+	Revision           int         `db:"revision" json:"revision"` // counts up from 1
+	CreatedAt          time.Time   `db:"created_at" json:"created_at"`
+	MonitoringInterval int64       `db:"monitoring_interval" json:"monitoring_interval"`
+	AlertThresholds    Durations   `db:"alert_thresholds" json:"alert_thresholds"`
+	GoogleChatWebhook  string      `db:"google_chat_webhook" json:"google_chat_webhook"`
+	RetentionPeriod    int64       `db:"retention_period" json:"retention_period"`
+	CheckPolicy        CheckPolicy `db:"check_policy" json:"check_policy"`
+	AlertCriticality   string      `db:"alert_criticality" json:"alert_criticality"`
+	// RestoredFrom is the revision a rollback restored, or 0 for a saved edit
+	RestoredFrom int `db:"restored_from" json:"restored_from,omitempty"`
+}
+
+// NewConfigRevision returns an unnumbered revision holding the settings of c
+func NewConfigRevision(c *Config) *ConfigRevision {
+	return &ConfigRevision{
+		CreatedAt:          c.UpdatedAt,
+		MonitoringInterval: c.MonitoringInterval,
+		AlertThresholds:    append(Durations{}, c.AlertThresholds...),
+		GoogleChatWebhook:  c.GoogleChatWebhook,
+		RetentionPeriod:    c.RetentionPeriod,
+		CheckPolicy:        append(CheckPolicy{}, c.CheckPolicy...),
+		AlertCriticality:   c.AlertCriticality,
+	}
+}
+
+// Config returns the configuration the revision holds
+func (r *ConfigRevision) Config() *Config {
+	return &Config{
+		ID:                 1,
+		MonitoringInterval: r.MonitoringInterval,
+		AlertThresholds:    append(Durations{}, r.AlertThresholds...),
+		GoogleChatWebhook:  r.GoogleChatWebhook,
+		RetentionPeriod:    r.RetentionPeriod,
+		CheckPolicy:        append(CheckPolicy{}, r.CheckPolicy...),
+		AlertCriticality:   r.AlertCriticality,
+		UpdatedAt:          r.CreatedAt,
+	}
+}
+
+// ConfigChange is one setting that differs between two configurations, formatted for people
+type ConfigChange struct {
+	Setting string
+	From    string
+	To      string
+}
+
+// DiffConfigs lists the settings that differ from one configuration to the next
+// A nil from lists every setting of to. The webhook is shown by its host only,
+// as its URL carries credentials.
+func DiffConfigs(from, to *Config) []ConfigChange {
+	format := func(c *Config) []string {
+		if c == nil {
+			return make([]string, 6)
+		}
+		thresholds := make([]string, len(c.AlertThresholds))
+		for i, threshold := range c.AlertThresholds {
+			thresholds[i] = formatPolicyDuration(threshold)
+		}
+		return []string{
+			formatPolicyDuration(c.GetMonitoringInterval()),
+			c.GetCheckPolicy().String(),
+			strings.Join(thresholds, ", "),
+			formatPolicyDuration(c.GetRetentionPeriod()),
+			webhookHost(c.GoogleChatWebhook),
+			c.AlertCriticalityName(),
+		}
+	}
+	settings := []string{"Monitoring interval", "Check schedule", "Alert thresholds", "Retention period", "Webhook", "Alerts for"}
+
+	before, after := format(from), format(to)
+	if from != nil && to != nil && from.GoogleChatWebhook != to.GoogleChatWebhook && before[4] == after[4] {
+		after[4] += " (new URL)"
+	}
+
+	var changes []ConfigChange
+	for i, setting := range settings {
+		if before[i] != after[i] {
+			changes = append(changes, ConfigChange{Setting: setting, From: before[i], To: after[i]})
+		}
+	}
+	return changes
+}
+
+// webhookHost returns the host a webhook posts to, or "none"
+func webhookHost(raw string) string {
+	if raw == "" {
+		return "none"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "invalid URL"
+	}
+	return u.Host
+}
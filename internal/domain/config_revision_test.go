@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	day := 24 * time.Hour
+	base := &Config{
+		MonitoringInterval: int64(day),
+		AlertThresholds:    Durations{30 * day, 7 * day},
+		GoogleChatWebhook:  "https://chat.googleapis.com/v1/spaces/AAA/messages?key=one",
+		RetentionPeriod:    int64(90 * day),
+		CheckPolicy:        CheckPolicy{{MinRemaining: 30 * day, Interval: day}, {MinRemaining: 0, Interval: time.Hour}},
+	}
+	with := func(change func(c *Config)) *Config {
+		c := NewConfigRevision(base).Config()
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name string
+		to   *Config
+		want []ConfigChange
+	}{
+		{name: "unchanged", to: with(func(c *Config) {}), want: nil},
+		{
+			name: "thresholds and retention",
+			to: with(func(c *Config) {
+				c.AlertThresholds = Durations{60 * day, 30 * day, 7 * day}
+				c.SetRetentionPeriod(30 * day)
+			}),
+			want: []ConfigChange{
+				{Setting: "Alert thresholds", From: "30d, 7d", To: "60d, 30d, 7d"},
+				{Setting: "Retention period", From: "90d", To: "30d"},
+			},
+		},
+		{
+			name: "interval",
+			to:   with(func(c *Config) { c.SetMonitoringInterval(12 * time.Hour) }),
+			want: []ConfigChange{{Setting: "Monitoring interval", From: "1d", To: "12h"}},
+		},
+		{
+			name: "webhook on the same host",
+			to:   with(func(c *Config) { c.GoogleChatWebhook = "https://chat.googleapis.com/v1/spaces/BBB/messages?key=two" }),
+			want: []ConfigChange{{Setting: "Webhook", From: "chat.googleapis.com", To: "chat.googleapis.com (new URL)"}},
+		},
+		{
+			name: "webhook removed",
+			to:   with(func(c *Config) { c.GoogleChatWebhook = "" }),
+			want: []ConfigChange{{Setting: "Webhook", From: "chat.googleapis.com", To: "none"}},
+		},
+		{
+			name: "alert criticality",
+			to:   with(func(c *Config) { c.AlertCriticality = CriticalityHigh }),
+			want: []ConfigChange{{Setting: "Alerts for", From: "all domains", To: "high and above"}},
+		},
+		{
+			name: "check schedule",
+			to:   with(func(c *Config) { c.CheckPolicy = CheckPolicy{{MinRemaining: 0, Interval: day}} }),
+			want: []ConfigChange{{Setting: "Check schedule", From: "30d:1d, 0d:1h", To: "0d:1d"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffConfigs(base, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffConfigs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := DiffConfigs(nil, base); len(got) != 6 || got[0].From != "" || got[4].To != "chat.googleapis.com" {
+		t.Errorf("DiffConfigs(nil, config) = %+v, want every setting", got)
+	}
+}
@@ -6,6 +6,32 @@ import (
 	"time"
 )
 
+// Domain modes
+const (
+	// ModeMonitor is a domain we own and want renewal alerts for
+	ModeMonitor = "monitor"
+	// ModeWatch is a domain owned by someone else that we want to acquire when it lapses
+	ModeWatch = "watch"
+)
+
+// Domain lifecycle states
+const (
+	// StateActive domains are checked and alerted on
+	StateActive = "active"
+	// StatePaused domains are kept but neither checked nor alerted on
+	StatePaused = "paused"
+	// StateArchived domains are read-only and purged once the retention period has passed
+	StateArchived = "archived"
+)
+
+// Registration states derived from WHOIS
+const (
+	RegistrationRegistered    = "registered"
+	RegistrationRedemption    = "redemption"
+	RegistrationPendingDelete = "pending_delete"
+	RegistrationAvailable     = "available"
+)
+
 // Domain represents a monitored domain with its WHOIS information
 type Domain struct {
 	ID             string    `db:"id" json:"id"`
@@ -18,6 +44,48 @@ type Domain struct {
 	NextCheck      time.Time `db:"next_check" json:"next_check"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+
+	Mode              string  `db:"mode" json:"mode"`
+	RegistrationState string  `db:"registration_state" json:"registration_state"`
+	Statuses          Strings `db:"statuses" json:"statuses"`
+	RequiredStatuses  Strings `db:"required_statuses" json:"required_statuses"`
+
+	State      string     `db:"state" json:"state"`
+	ArchivedAt *time.Time `db:"archived_at" json:"archived_at,omitempty"`
+
+	// Check lease held by the scheduler instance currently checking the domain
+	LeaseOwner    string     `db:"lease_owner" json:"-"`
+	LeaseUntil    *time.Time `db:"lease_until" json:"-"`
+	CheckAttempts int        `db:"check_attempts" json:"check_attempts"`
+	// LookupFailures counts the WHOIS lookups that failed since the last one that succeeded
+	LookupFailures int `db:"lookup_failures" json:"lookup_failures"`
+
+	Metadata
+}
+
+// IsWatched reports whether the domain is on the drop-catch watchlist rather than owned by us
+func (d *Domain) IsWatched() bool {
+	return d.Mode == ModeWatch
+}
+
+// IsActive reports whether the domain is checked and alerted on
+func (d *Domain) IsActive() bool {
+	return d.State == StateActive
+}
+
+// LookupFailing reports whether the latest WHOIS lookup of the domain failed
+func (d *Domain) LookupFailing() bool {
+	return d.LookupFailures > 0
+}
+
+// IsArchived reports whether the domain is read-only and awaiting purge
+func (d *Domain) IsArchived() bool {
+	return d.State == StateArchived
+}
+
+// ValidState reports whether state is a known domain lifecycle state
+func ValidState(state string) bool {
+	return state == StateActive || state == StatePaused || state == StateArchived
 }
 
 // DaysUntilExpiration calculates the number of days until the domain expires
@@ -31,15 +99,43 @@ func (d *Domain) IsExpired() bool {
 	return time.Now().After(d.ExpirationDate)
 }
 
+// RegistrationStateFromStatuses derives the registration state from EPP status codes
+// During the redemption grace period registries report both redemptionPeriod and
+// pendingDelete, so redemption takes precedence
+func RegistrationStateFromStatuses(statuses []string) string {
+	state := RegistrationRegistered
+	for _, status := range statuses {
+		switch NormalizeStatus(status) {
+		case StatusRedemptionPeriod, StatusPendingRestore:
+			return RegistrationRedemption
+		case StatusPendingDelete:
+			state = RegistrationPendingDelete
+		}
+	}
+	return state
+}
+
 // Strings is a custom type for storing string slices as JSON in the database
 type Strings []string
 
+// Contains reports whether the list contains the given value
+func (s Strings) Contains(value string) bool {
+	for _, v := range s {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // Value implements the driver.Valuer interface for database storage
+// The JSON is passed as a string so PostgreSQL accepts it for JSONB columns.
 func (s Strings) Value() (driver.Value, error) {
 	if s == nil {
-		return json.Marshal([]string{})
+		s = Strings{}
 	}
-	return json.Marshal(s)
+	data, err := json.Marshal([]string(s))
+	return string(data), err
 }
 
 // Scan implements the sql.Scanner interface for database retrieval
@@ -49,8 +145,13 @@ func (s *Strings) Scan(value interface{}) error {
 		return nil
 	}
 	
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		*s = []string{}
 		return nil
 	}
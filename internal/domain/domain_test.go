@@ -0,0 +1,64 @@
+package domain
+
+import "testing"
+
+// Test that EPP statuses map to the registration lifecycle states
+func TestRegistrationStateFromStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+	}{
+		{name: "no statuses", statuses: nil, want: RegistrationRegistered},
+		{name: "ordinary locks", statuses: []string{"clientTransferProhibited", "serverDeleteProhibited"}, want: RegistrationRegistered},
+		{name: "redemption period", statuses: []string{"redemptionPeriod"}, want: RegistrationRedemption},
+		{name: "pending restore", statuses: []string{"pendingRestore"}, want: RegistrationRedemption},
+		{name: "pending delete", statuses: []string{"pendingDelete"}, want: RegistrationPendingDelete},
+		{name: "redemption takes precedence", statuses: []string{"pendingDelete", "redemptionPeriod"}, want: RegistrationRedemption},
+		{name: "case and url suffix", statuses: []string{"PENDINGDELETE https://icann.org/epp#pendingDelete"}, want: RegistrationPendingDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RegistrationStateFromStatuses(tt.statuses); got != tt.want {
+				t.Errorf("RegistrationStateFromStatuses(%v) = %q, want %q", tt.statuses, got, tt.want)
+			}
+		})
+	}
+}
+
+// Test that the spellings used by different registries normalize to EPP codes
+func TestNormalizeStatuses(t *testing.T) {
+	got := NormalizeStatuses([]string{
+		"clientTransferProhibited https://icann.org/epp#clientTransferProhibited",
+		"client transfer prohibited",
+		"SERVERDELETEPROHIBITED",
+		"server_update_prohibited",
+		"active",
+		"",
+		"connect",
+	})
+	want := []string{StatusClientTransferProhibited, StatusServerDeleteProhibited, StatusServerUpdateProhibited, StatusOK, "connect"}
+
+	if len(got) != len(want) {
+		t.Fatalf("NormalizeStatuses() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("NormalizeStatuses()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// Test that missing required statuses are reported
+func TestMissingRequiredStatuses(t *testing.T) {
+	d := &Domain{
+		Statuses:         Strings{StatusClientTransferProhibited, StatusServerDeleteProhibited},
+		RequiredStatuses: Strings{StatusClientTransferProhibited, StatusServerUpdateProhibited},
+	}
+
+	missing := d.MissingRequiredStatuses()
+	if len(missing) != 1 || missing[0] != StatusServerUpdateProhibited {
+		t.Errorf("MissingRequiredStatuses() = %v, want [%s]", missing, StatusServerUpdateProhibited)
+	}
+}
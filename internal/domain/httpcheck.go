@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+)
+
+// HTTPCheck represents an HTTP(S) probe attached to a monitored domain
+type HTTPCheck struct {
+	ID                  string     `db:"id" json:"id"`
+	DomainID            string     `db:"domain_id" json:"domain_id"`
+	URL                 string     `db:"url" json:"url"`
+	ExpectedStatus      int        `db:"expected_status" json:"expected_status"` // 0 accepts any 2xx (or 3xx when a redirect is expected)
+	ExpectedRedirect    string     `db:"expected_redirect" json:"expected_redirect"`
+	BodyContains        string     `db:"body_contains" json:"body_contains"`
+	Interval            int64      `db:"check_interval" json:"check_interval"` // stored as nanoseconds
+	FailureThreshold    int        `db:"failure_threshold" json:"failure_threshold"`
+	ConsecutiveFailures int        `db:"consecutive_failures" json:"consecutive_failures"`
+	LastChecked         *time.Time `db:"last_checked" json:"last_checked"` // nil until the first probe
+	NextCheck           time.Time  `db:"next_check" json:"next_check"`
+	CreatedAt           time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// GetInterval returns the probe interval as a time.Duration
+func (c *HTTPCheck) GetInterval() time.Duration {
+	return time.Duration(c.Interval)
+}
+
+// SetInterval sets the probe interval from a time.Duration
+func (c *HTTPCheck) SetInterval(d time.Duration) {
+	c.Interval = int64(d)
+}
+
+// IsFailing reports whether the check has reached its failure threshold
+func (c *HTTPCheck) IsFailing() bool {
+	return c.FailureThreshold > 0 && c.ConsecutiveFailures >= c.FailureThreshold
+}
+
+// HTTPCheckResult represents the outcome of a single HTTP probe
+type HTTPCheckResult struct {
+	ID           string    `db:"id" json:"id"`
+	CheckID      string    `db:"check_id" json:"check_id"`
+	CheckedAt    time.Time `db:"checked_at" json:"checked_at"`
+	StatusCode   int       `db:"status_code" json:"status_code"`
+	RedirectURL  string    `db:"redirect_url" json:"redirect_url"`
+	Latency      int64     `db:"latency" json:"latency"` // stored as nanoseconds
+	Success      bool      `db:"success" json:"success"`
+	ErrorMessage string    `db:"error_message" json:"error_message"`
+}
+
+// GetLatency returns the request latency as a time.Duration
+func (r *HTTPCheckResult) GetLatency() time.Duration {
+	return time.Duration(r.Latency)
+}
+
+// SetLatency sets the request latency from a time.Duration
+func (r *HTTPCheckResult) SetLatency(d time.Duration) {
+	r.Latency = int64(d)
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+)
+
+// Lookalike represents a permutation of a monitored domain that is watched for registration
+type Lookalike struct {
+	ID          string     `db:"id" json:"id"`
+	DomainID    string     `db:"domain_id" json:"domain_id"`
+	Name        string     `db:"name" json:"name"` // ASCII (punycode) form
+	UnicodeName string     `db:"unicode_name" json:"unicode_name"`
+	Kind        string     `db:"kind" json:"kind"`
+	Registered  bool       `db:"registered" json:"registered"`
+	Registrar   string     `db:"registrar" json:"registrar"`
+	CreatedDate *time.Time `db:"created_date" json:"created_date"` // registration date reported by WHOIS
+	FirstSeen   *time.Time `db:"first_seen" json:"first_seen"`     // when we first found it registered
+	LastChecked *time.Time `db:"last_checked" json:"last_checked"` // nil until the first lookup
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+// DisplayName returns the Unicode form when it differs from the ASCII name
+func (l *Lookalike) DisplayName() string {
+	if l.UnicodeName != "" && l.UnicodeName != l.Name {
+		return l.UnicodeName + " (" + l.Name + ")"
+	}
+	return l.Name
+}
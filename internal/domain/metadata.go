@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Criticality levels, from least to most important
+const (
+	CriticalityLow      = "low"
+	CriticalityNormal   = "normal"
+	CriticalityHigh     = "high"
+	CriticalityCritical = "critical"
+)
+
+// Criticalities lists the criticality levels from least to most important
+var Criticalities = []string{CriticalityLow, CriticalityNormal, CriticalityHigh, CriticalityCritical}
+
+const (
+	// MaxTagLength is the longest tag name allowed
+	MaxTagLength = 64
+	// MaxTags is the most tags a domain may have
+	MaxTags = 20
+)
+
+// Metadata is what users record about a domain besides what WHOIS reports:
+// who owns it, how much it matters and what it costs
+type Metadata struct {
+	Owner           string `db:"owner" json:"owner"` // person or team
+	Criticality     string `db:"criticality" json:"criticality"`
+	Notes           string `db:"notes" json:"notes"`
+	AnnualCostCents int64  `db:"annual_cost_cents" json:"annual_cost_cents"`
+	// Tags are kept in their own table and loaded by the repository
+	Tags Strings `db:"-" json:"tags"`
+}
+
+// ValidCriticality reports whether c is a known criticality level
+func ValidCriticality(c string) bool {
+	for _, level := range Criticalities {
+		if c == level {
+			return true
+		}
+	}
+	return false
+}
+
+// criticalityRank returns the position of a criticality level in Criticalities, or -1
+func criticalityRank(c string) int {
+	for i, level := range Criticalities {
+		if c == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate reports whether the metadata can be saved
+// Tags are expected to be normalized with NormalizeTags.
+func (m Metadata) Validate() error {
+	if !ValidCriticality(m.Criticality) {
+		return fmt.Errorf("invalid criticality: %s", m.Criticality)
+	}
+	if m.AnnualCostCents < 0 {
+		return fmt.Errorf("annual cost must not be negative")
+	}
+	if len(m.Tags) > MaxTags {
+		return fmt.Errorf("a domain can have at most %d tags", MaxTags)
+	}
+	for _, tag := range m.Tags {
+		if len(tag) > MaxTagLength {
+			return fmt.Errorf("tag is longer than %d characters: %s", MaxTagLength, tag)
+		}
+	}
+	return nil
+}
+
+// HasTag reports whether the metadata carries the given tag
+func (m Metadata) HasTag(tag string) bool {
+	return m.Tags.Contains(NormalizeTag(tag))
+}
+
+// AnnualCost formats the annual cost with two decimals
+func (m Metadata) AnnualCost() string {
+	return fmt.Sprintf("%d.%02d", m.AnnualCostCents/100, m.AnnualCostCents%100)
+}
+
+// NormalizeTag lowercases a tag and joins its words with hyphens, so "Web Shop"
+// and "web-shop" are the same tag
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// NormalizeTags normalizes each tag and returns them sorted without blanks or duplicates
+func NormalizeTags(tags []string) Strings {
+	normalized := Strings{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// ParseTags splits a comma-separated list of tags and normalizes them
+func ParseTags(s string) Strings {
+	return NormalizeTags(strings.Split(s, ","))
+}
+
+// ParseCost parses an amount such as "12", "12.5" or "1,200.00" into cents
+func ParseCost(s string) (int64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0, nil
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || len(fraction) > 2 || (fraction != "" && !isDigits(fraction)) {
+		return 0, fmt.Errorf("invalid cost: %s", s)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > (1<<63-1)/100-1 {
+		return 0, fmt.Errorf("cost is too large: %s", s)
+	}
+	cents, _ := strconv.ParseInt(fraction, 10, 64)
+	return units*100 + cents, nil
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
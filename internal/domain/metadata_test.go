@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Strings
+	}{
+		{input: "", want: Strings{}},
+		{input: " , ,", want: Strings{}},
+		{input: "prod", want: Strings{"prod"}},
+		{input: "Web Shop, prod,PROD,  eu ", want: Strings{"eu", "prod", "web-shop"}},
+		{input: "cost-centre:1234", want: Strings{"cost-centre:1234"}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseTags(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTags(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseCost(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "12", want: 1200},
+		{input: "12.5", want: 1250},
+		{input: "12.05", want: 1205},
+		{input: ".99", want: 99},
+		{input: " 1,200.00 ", want: 120000},
+		{input: "12.345", wantErr: true},
+		{input: "-5", wantErr: true},
+		{input: "1.+5", wantErr: true},
+		{input: "ten", wantErr: true},
+		{input: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCost(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCost(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseCost(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMetadata_Validate(t *testing.T) {
+	long := make([]byte, MaxTagLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	tooMany := Strings{}
+	for i := 0; i <= MaxTags; i++ {
+		tooMany = append(tooMany, string(rune('a'+i)))
+	}
+
+	tests := []struct {
+		name    string
+		m       Metadata
+		wantErr bool
+	}{
+		{name: "valid", m: Metadata{Owner: "web", Criticality: CriticalityHigh, AnnualCostCents: 1000, Tags: Strings{"prod"}}},
+		{name: "missing criticality", m: Metadata{}, wantErr: true},
+		{name: "unknown criticality", m: Metadata{Criticality: "urgent"}, wantErr: true},
+		{name: "negative cost", m: Metadata{Criticality: CriticalityLow, AnnualCostCents: -1}, wantErr: true},
+		{name: "long tag", m: Metadata{Criticality: CriticalityLow, Tags: Strings{string(long)}}, wantErr: true},
+		{name: "too many tags", m: Metadata{Criticality: CriticalityLow, Tags: tooMany}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.m.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetadata_AnnualCost(t *testing.T) {
+	for cents, want := range map[int64]string{0: "0.00", 5: "0.05", 1299: "12.99", 120000: "1200.00"} {
+		if got := (Metadata{AnnualCostCents: cents}).AnnualCost(); got != want {
+			t.Errorf("AnnualCost() of %d cents = %q, want %q", cents, got, want)
+		}
+	}
+}
+
+func TestConfig_AlertsFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		min         string
+		criticality string
+		want        bool
+	}{
+		{name: "no minimum", min: "", criticality: CriticalityLow, want: true},
+		{name: "below the minimum", min: CriticalityHigh, criticality: CriticalityNormal, want: false},
+		{name: "at the minimum", min: CriticalityHigh, criticality: CriticalityHigh, want: true},
+		{name: "above the minimum", min: CriticalityHigh, criticality: CriticalityCritical, want: true},
+		{name: "unset counts as normal", min: CriticalityNormal, criticality: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{AlertCriticality: tt.min}
+			d := &Domain{Metadata: Metadata{Criticality: tt.criticality}}
+			if got := c.AlertsFor(d); got != tt.want {
+				t.Errorf("AlertsFor() with minimum %q and criticality %q = %v, want %v", tt.min, tt.criticality, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package domain
+
+import "strings"
+
+// EPP status codes (RFC 5731) that registries report for a domain
+const (
+	StatusOK                       = "ok"
+	StatusInactive                 = "inactive"
+	StatusAddPeriod                = "addPeriod"
+	StatusAutoRenewPeriod          = "autoRenewPeriod"
+	StatusRenewPeriod              = "renewPeriod"
+	StatusTransferPeriod           = "transferPeriod"
+	StatusRedemptionPeriod         = "redemptionPeriod"
+	StatusPendingCreate            = "pendingCreate"
+	StatusPendingDelete            = "pendingDelete"
+	StatusPendingRenew             = "pendingRenew"
+	StatusPendingRestore           = "pendingRestore"
+	StatusPendingTransfer          = "pendingTransfer"
+	StatusPendingUpdate            = "pendingUpdate"
+	StatusClientDeleteProhibited   = "clientDeleteProhibited"
+	StatusClientHold               = "clientHold"
+	StatusClientRenewProhibited    = "clientRenewProhibited"
+	StatusClientTransferProhibited = "clientTransferProhibited"
+	StatusClientUpdateProhibited   = "clientUpdateProhibited"
+	StatusServerDeleteProhibited   = "serverDeleteProhibited"
+	StatusServerHold               = "serverHold"
+	StatusServerRenewProhibited    = "serverRenewProhibited"
+	StatusServerTransferProhibited = "serverTransferProhibited"
+	StatusServerUpdateProhibited   = "serverUpdateProhibited"
+)
+
+// LockStatuses are the prohibitions that can be required for a domain
+var LockStatuses = []string{
+	StatusClientTransferProhibited,
+	StatusClientDeleteProhibited,
+	StatusClientUpdateProhibited,
+	StatusClientRenewProhibited,
+	StatusServerTransferProhibited,
+	StatusServerDeleteProhibited,
+	StatusServerUpdateProhibited,
+	StatusServerRenewProhibited,
+}
+
+// eppStatuses maps compacted lowercase spellings to the canonical EPP code
+var eppStatuses = map[string]string{}
+
+func init() {
+	for _, code := range []string{
+		StatusOK, StatusInactive, StatusAddPeriod, StatusAutoRenewPeriod, StatusRenewPeriod,
+		StatusTransferPeriod, StatusRedemptionPeriod, StatusPendingCreate, StatusPendingDelete,
+		StatusPendingRenew, StatusPendingRestore, StatusPendingTransfer, StatusPendingUpdate,
+		StatusClientDeleteProhibited, StatusClientHold, StatusClientRenewProhibited,
+		StatusClientTransferProhibited, StatusClientUpdateProhibited, StatusServerDeleteProhibited,
+		StatusServerHold, StatusServerRenewProhibited, StatusServerTransferProhibited,
+		StatusServerUpdateProhibited,
+	} {
+		eppStatuses[strings.ToLower(code)] = code
+	}
+	// RDAP reports "active" where EPP uses "ok"
+	eppStatuses["active"] = StatusOK
+}
+
+// NormalizeStatus converts a status as reported by WHOIS or RDAP to its EPP code
+// "client transfer prohibited", "CLIENTTRANSFERPROHIBITED" and
+// "clientTransferProhibited https://icann.org/epp#clientTransferProhibited"
+// all become "clientTransferProhibited". Unknown statuses are returned trimmed.
+func NormalizeStatus(status string) string {
+	var words []string
+	for _, word := range strings.Fields(status) {
+		// Drop the ICANN reference URL registries append to the code
+		if strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") || strings.HasPrefix(word, "(") {
+			break
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		return ""
+	}
+
+	compact := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(strings.Join(words, "")))
+	if code, ok := eppStatuses[compact]; ok {
+		return code
+	}
+	return strings.Join(words, " ")
+}
+
+// NormalizeStatuses normalizes a list of statuses, dropping blanks and duplicates
+func NormalizeStatuses(statuses []string) Strings {
+	normalized := Strings{}
+	seen := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		code := NormalizeStatus(status)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		normalized = append(normalized, code)
+	}
+	return normalized
+}
+
+// HasStatus reports whether the domain currently has the given EPP status
+func (d *Domain) HasStatus(status string) bool {
+	return d.Statuses.Contains(status)
+}
+
+// MissingRequiredStatuses returns the required statuses the domain does not currently have
+func (d *Domain) MissingRequiredStatuses() []string {
+	var missing []string
+	for _, required := range d.RequiredStatuses {
+		if !d.HasStatus(required) {
+			missing = append(missing, required)
+		}
+	}
+	return missing
+}
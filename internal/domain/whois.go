@@ -11,6 +11,7 @@ type DomainInfo struct {
 	Nameservers    []string
 	Registrant     string
 	Registrar      string
+	Statuses       []string // EPP status codes such as clientTransferProhibited
 	CreatedDate    time.Time
 	UpdatedDate    time.Time
 }
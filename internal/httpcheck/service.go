@@ -0,0 +1,133 @@
+package httpcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// maxBodyBytes limits how much of a response body is searched for the expected substring
+const maxBodyBytes = 1 << 20
+
+// Service performs HTTP(S) probes against monitored endpoints
+type Service struct {
+	httpClient *http.Client
+}
+
+// NewService creates a new HTTP check service
+func NewService() *Service {
+	return &Service{
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+			// Redirects are not followed so the redirect target can be verified
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Probe performs a single request for the check and evaluates the response
+// A probe cut short by ctx fails like any other request; callers that canceled it
+// should discard the result.
+func (s *Service) Probe(ctx context.Context, check *domain.HTTPCheck) *domain.HTTPCheckResult {
+	result := &domain.HTTPCheckResult{
+		CheckID:   check.ID,
+		CheckedAt: time.Now(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("invalid request: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		result.SetLatency(time.Since(start))
+		result.ErrorMessage = fmt.Sprintf("request failed: %v", err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	result.SetLatency(time.Since(start))
+	result.StatusCode = resp.StatusCode
+	if location := resp.Header.Get("Location"); location != "" {
+		result.RedirectURL = resolveLocation(resp.Request.URL, location)
+	}
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to read response body: %v", err)
+		return result
+	}
+
+	if msg := Evaluate(check, result, string(body)); msg != "" {
+		result.ErrorMessage = msg
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+// Evaluate compares a response against the check expectations
+// It returns an empty string when all expectations are met
+func Evaluate(check *domain.HTTPCheck, result *domain.HTTPCheckResult, body string) string {
+	status := result.StatusCode
+	switch {
+	case check.ExpectedStatus != 0:
+		if status != check.ExpectedStatus {
+			return fmt.Sprintf("expected status %d, got %d", check.ExpectedStatus, status)
+		}
+	case check.ExpectedRedirect != "":
+		if status < 300 || status >= 400 {
+			return fmt.Sprintf("expected a redirect, got status %d", status)
+		}
+	default:
+		if status < 200 || status >= 300 {
+			return fmt.Sprintf("expected a 2xx status, got %d", status)
+		}
+	}
+
+	if check.ExpectedRedirect != "" && !sameURL(result.RedirectURL, check.ExpectedRedirect) {
+		if result.RedirectURL == "" {
+			return fmt.Sprintf("expected redirect to %s, got no redirect", check.ExpectedRedirect)
+		}
+		return fmt.Sprintf("expected redirect to %s, got %s", check.ExpectedRedirect, result.RedirectURL)
+	}
+
+	if check.BodyContains != "" && !strings.Contains(body, check.BodyContains) {
+		return fmt.Sprintf("response body does not contain %q", check.BodyContains)
+	}
+
+	return ""
+}
+
+// resolveLocation resolves a possibly relative Location header against the request URL
+func resolveLocation(base *url.URL, location string) string {
+	ref, err := url.Parse(location)
+	if err != nil || base == nil {
+		return location
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// sameURL compares two URLs ignoring host case and a trailing slash on the path
+func sameURL(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Host, ub.Host) &&
+		strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/") &&
+		ua.RawQuery == ub.RawQuery
+}
@@ -0,0 +1,124 @@
+package httpcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>Welcome to Example</body></html>")
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, "ok")
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestProbe(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	service := NewService()
+
+	tests := []struct {
+		name      string
+		check     domain.HTTPCheck
+		wantOK    bool
+		wantError string
+	}{
+		{
+			name:   "default expects 2xx",
+			check:  domain.HTTPCheck{URL: server.URL + "/ok"},
+			wantOK: true,
+		},
+		{
+			name:   "expected status and body",
+			check:  domain.HTTPCheck{URL: server.URL + "/ok", ExpectedStatus: 200, BodyContains: "Welcome"},
+			wantOK: true,
+		},
+		{
+			name:      "missing body substring",
+			check:     domain.HTTPCheck{URL: server.URL + "/ok", BodyContains: "Parked domain"},
+			wantError: "does not contain",
+		},
+		{
+			name:      "unexpected status",
+			check:     domain.HTTPCheck{URL: server.URL + "/down"},
+			wantError: "expected a 2xx status, got 503",
+		},
+		{
+			name:   "expected redirect target",
+			check:  domain.HTTPCheck{URL: server.URL + "/old", ExpectedStatus: 301, ExpectedRedirect: server.URL + "/new"},
+			wantOK: true,
+		},
+		{
+			name:      "wrong redirect target",
+			check:     domain.HTTPCheck{URL: server.URL + "/old", ExpectedRedirect: server.URL + "/elsewhere"},
+			wantError: "expected redirect to",
+		},
+		{
+			name:      "redirect expected but none returned",
+			check:     domain.HTTPCheck{URL: server.URL + "/ok", ExpectedRedirect: server.URL + "/new"},
+			wantError: "expected a redirect",
+		},
+		{
+			name:      "connection failure",
+			check:     domain.HTTPCheck{URL: "http://127.0.0.1:1/unreachable"},
+			wantError: "request failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := service.Probe(context.Background(), &tt.check)
+			if result.Success != tt.wantOK {
+				t.Fatalf("Probe() success = %v, want %v (error: %s)", result.Success, tt.wantOK, result.ErrorMessage)
+			}
+			if tt.wantError != "" && !strings.Contains(result.ErrorMessage, tt.wantError) {
+				t.Errorf("Probe() error = %q, want it to contain %q", result.ErrorMessage, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestProbe_RecordsLatencyAndRedirect(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	service := NewService()
+
+	result := service.Probe(context.Background(), &domain.HTTPCheck{URL: server.URL + "/slow"})
+	if !result.Success {
+		t.Fatalf("Probe() unexpected failure: %s", result.ErrorMessage)
+	}
+	if result.GetLatency() < 20*time.Millisecond {
+		t.Errorf("Probe() latency = %v, want at least 20ms", result.GetLatency())
+	}
+
+	result = service.Probe(context.Background(), &domain.HTTPCheck{URL: server.URL + "/old", ExpectedRedirect: server.URL + "/new/"})
+	if !result.Success {
+		t.Fatalf("Probe() unexpected failure: %s", result.ErrorMessage)
+	}
+	if result.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Probe() status = %d, want %d", result.StatusCode, http.StatusMovedPermanently)
+	}
+	if result.RedirectURL != server.URL+"/new" {
+		t.Errorf("Probe() redirect = %q, want %q", result.RedirectURL, server.URL+"/new")
+	}
+}
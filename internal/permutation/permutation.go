@@ -0,0 +1,239 @@
+// Package permutation generates lookalike (typosquat) variants of a domain name
+package permutation
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Kind identifies the technique used to produce a permutation
+type Kind string
+
+// Permutation techniques
+const (
+	KindHomoglyph     Kind = "homoglyph"
+	KindIDNConfusable Kind = "idn-confusable"
+	KindBitFlip       Kind = "bitflip"
+	KindTLDSwap       Kind = "tld-swap"
+	KindHyphenation   Kind = "hyphenation"
+	KindOmission      Kind = "omission"
+)
+
+// Permutation is a lookalike variant of a domain name
+type Permutation struct {
+	// Domain is the registrable ASCII form (punycode for IDNs)
+	Domain string
+	// Unicode is the human-readable form, equal to Domain for ASCII names
+	Unicode string
+	Kind    Kind
+}
+
+// asciiHomoglyphs maps character sequences to ASCII sequences that look alike
+var asciiHomoglyphs = map[string][]string{
+	"a":  {"4"},
+	"b":  {"6"},
+	"cl": {"d"},
+	"d":  {"cl"},
+	"e":  {"3"},
+	"g":  {"q", "9"},
+	"i":  {"1", "l"},
+	"l":  {"1", "i"},
+	"m":  {"rn", "nn"},
+	"nn": {"m"},
+	"o":  {"0"},
+	"q":  {"g"},
+	"rn": {"m"},
+	"s":  {"5"},
+	"u":  {"v"},
+	"v":  {"u"},
+	"vv": {"w"},
+	"w":  {"vv"},
+	"0":  {"o"},
+	"1":  {"l", "i"},
+}
+
+// idnConfusables maps ASCII letters to visually identical non-Latin code points
+var idnConfusables = map[rune][]rune{
+	'a': {'а', 'ɑ'}, // Cyrillic a, Latin alpha
+	'c': {'с', 'ϲ'}, // Cyrillic es, Greek lunate sigma
+	'd': {'ԁ'},      // Cyrillic komi de
+	'e': {'е'},      // Cyrillic ie
+	'g': {'ɡ'},      // Latin script g
+	'h': {'һ'},      // Cyrillic shha
+	'i': {'і', 'ı'}, // Cyrillic i, Latin dotless i
+	'j': {'ј'},      // Cyrillic je
+	'k': {'κ'},      // Greek kappa
+	'l': {'ӏ'},      // Cyrillic palochka
+	'n': {'ո'},      // Armenian vo
+	'o': {'о', 'ο'}, // Cyrillic o, Greek omicron
+	'p': {'р'},      // Cyrillic er
+	'q': {'ԛ'},      // Cyrillic qa
+	's': {'ѕ'},      // Cyrillic dze
+	'u': {'υ'},      // Greek upsilon
+	'v': {'ν'},      // Greek nu
+	'w': {'ԝ'},      // Cyrillic we
+	'x': {'х'},      // Cyrillic ha
+	'y': {'у'},      // Cyrillic u
+}
+
+// swapTLDs are the top-level domains tried for TLD swaps
+var swapTLDs = []string{
+	"com", "net", "org", "info", "biz", "co", "io", "app", "dev",
+	"xyz", "online", "site", "shop", "store", "us", "cc",
+}
+
+// Generate returns the lookalike permutations of a domain name
+// The original domain is never included and every result is a valid hostname
+func Generate(domainName string) []Permutation {
+	domainName = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domainName), "."))
+	label, suffix, ok := strings.Cut(domainName, ".")
+	if !ok || label == "" || suffix == "" {
+		return nil
+	}
+
+	seen := map[string]bool{domainName: true}
+	var result []Permutation
+
+	add := func(newLabel, newSuffix string, kind Kind) {
+		unicodeName := newLabel + "." + newSuffix
+		asciiName, err := idna.ToASCII(unicodeName)
+		if err != nil || !isValidLabel(strings.SplitN(asciiName, ".", 2)[0]) {
+			return
+		}
+		if seen[asciiName] {
+			return
+		}
+		seen[asciiName] = true
+		result = append(result, Permutation{Domain: asciiName, Unicode: unicodeName, Kind: kind})
+	}
+
+	for _, l := range homoglyphs(label) {
+		add(l, suffix, KindHomoglyph)
+	}
+	for _, l := range confusables(label) {
+		add(l, suffix, KindIDNConfusable)
+	}
+	for _, l := range bitFlips(label) {
+		add(l, suffix, KindBitFlip)
+	}
+	for _, tld := range swapTLDs {
+		add(label, tld, KindTLDSwap)
+	}
+	for _, l := range hyphenations(label) {
+		add(l, suffix, KindHyphenation)
+	}
+	for _, l := range omissions(label) {
+		add(l, suffix, KindOmission)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Domain < result[j].Domain
+	})
+
+	return result
+}
+
+// homoglyphs replaces one occurrence of a sequence with an ASCII lookalike
+func homoglyphs(label string) []string {
+	var out []string
+	for from, replacements := range asciiHomoglyphs {
+		for i := 0; i+len(from) <= len(label); i++ {
+			if label[i:i+len(from)] != from {
+				continue
+			}
+			for _, to := range replacements {
+				out = append(out, label[:i]+to+label[i+len(from):])
+			}
+		}
+	}
+	return out
+}
+
+// confusables replaces one letter with a non-Latin lookalike
+func confusables(label string) []string {
+	var out []string
+	runes := []rune(label)
+	for i, r := range runes {
+		for _, c := range idnConfusables[r] {
+			variant := make([]rune, len(runes))
+			copy(variant, runes)
+			variant[i] = c
+			out = append(out, string(variant))
+		}
+	}
+	return out
+}
+
+// bitFlips flips each bit of each character, keeping results that are valid hostname characters
+func bitFlips(label string) []string {
+	var out []string
+	for i := 0; i < len(label); i++ {
+		for bit := 0; bit < 8; bit++ {
+			flipped := label[i] ^ (1 << bit)
+			if !isHostChar(flipped) {
+				continue
+			}
+			out = append(out, label[:i]+string(flipped)+label[i+1:])
+		}
+	}
+	return out
+}
+
+// hyphenations inserts a hyphen between characters or removes an existing one
+func hyphenations(label string) []string {
+	var out []string
+	for i := 1; i < len(label); i++ {
+		if label[i] == '-' || label[i-1] == '-' {
+			continue
+		}
+		out = append(out, label[:i]+"-"+label[i:])
+	}
+	for i := 0; i < len(label); i++ {
+		if label[i] == '-' {
+			out = append(out, label[:i]+label[i+1:])
+		}
+	}
+	return out
+}
+
+// omissions removes one character at a time
+func omissions(label string) []string {
+	if len(label) < 2 {
+		return nil
+	}
+	var out []string
+	for i := 0; i < len(label); i++ {
+		out = append(out, label[:i]+label[i+1:])
+	}
+	return out
+}
+
+// isHostChar reports whether c is a lowercase letter, digit or hyphen
+func isHostChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-'
+}
+
+// isValidLabel reports whether an ASCII label is a valid hostname label
+func isValidLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	// Hyphens in the third and fourth position are reserved for punycode
+	if len(label) >= 4 && label[2:4] == "--" && !strings.HasPrefix(label, "xn--") {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		if !isHostChar(label[i]) {
+			return false
+		}
+	}
+	return true
+}
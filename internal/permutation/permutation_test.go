@@ -0,0 +1,116 @@
+package permutation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+)
+
+func findPermutation(perms []Permutation, name string) (Permutation, bool) {
+	for _, p := range perms {
+		if p.Domain == name {
+			return p, true
+		}
+	}
+	return Permutation{}, false
+}
+
+// Test that each technique produces the expected variants
+func TestGenerate_Techniques(t *testing.T) {
+	perms := Generate("example.com")
+
+	tests := []struct {
+		name string
+		kind Kind
+	}{
+		{"examp1e.com", KindHomoglyph},
+		{"exarnple.com", KindHomoglyph},
+		{"exampie.com", KindHomoglyph},
+		{"xn--xample-2of.com", KindIDNConfusable}, // Cyrillic "е" in place of "e"
+		{"exaople.com", KindBitFlip},              // 'm' (0x6d) with bit 1 flipped is 'o' (0x6f)
+		{"example.net", KindTLDSwap},
+		{"example.io", KindTLDSwap},
+		{"ex-ample.com", KindHyphenation},
+		{"exmple.com", KindOmission},
+		{"xample.com", KindOmission},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := findPermutation(perms, tt.name)
+			if !ok {
+				t.Fatalf("Generate() missing %s", tt.name)
+			}
+			if p.Kind != tt.kind {
+				t.Errorf("Generate() %s kind = %s, want %s", tt.name, p.Kind, tt.kind)
+			}
+		})
+	}
+}
+
+// Test that IDN permutations keep their Unicode form for display
+func TestGenerate_IDNUnicodeForm(t *testing.T) {
+	p, ok := findPermutation(Generate("example.com"), "xn--xample-2of.com")
+	if !ok {
+		t.Fatal("Generate() missing Cyrillic confusable")
+	}
+	if p.Unicode != "еxample.com" {
+		t.Errorf("Unicode form = %q, want %q", p.Unicode, "еxample.com")
+	}
+}
+
+// Test hyphen removal and multi-label suffixes
+func TestGenerate_HyphenRemovalAndSuffix(t *testing.T) {
+	perms := Generate("my-brand.co.uk")
+
+	if _, ok := findPermutation(perms, "mybrand.co.uk"); !ok {
+		t.Error("Generate() missing hyphen removal")
+	}
+	if _, ok := findPermutation(perms, "my-brand.com"); !ok {
+		t.Error("Generate() missing TLD swap of multi-label suffix")
+	}
+	if _, ok := findPermutation(perms, "my-brand.co.uk"); ok {
+		t.Error("Generate() included the original domain")
+	}
+}
+
+// Test invalid input
+func TestGenerate_InvalidInput(t *testing.T) {
+	for _, input := range []string{"", "localhost", ".com", "example."} {
+		if perms := Generate(input); len(perms) != 0 {
+			t.Errorf("Generate(%q) returned %d permutations, want none", input, len(perms))
+		}
+	}
+}
+
+// Property: permutations are valid, unique and never the original domain
+func TestProperty_PermutationsAreValidAndUnique(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("all permutations are valid distinct hostnames", prop.ForAll(
+		func(label string) bool {
+			original := strings.ToLower(label) + ".com"
+			perms := Generate(original)
+
+			seen := make(map[string]bool)
+			for _, p := range perms {
+				if p.Domain == original || seen[p.Domain] {
+					return false
+				}
+				seen[p.Domain] = true
+
+				parts := strings.SplitN(p.Domain, ".", 2)
+				if len(parts) != 2 || !isValidLabel(parts[0]) {
+					return false
+				}
+			}
+			return true
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) < 30 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
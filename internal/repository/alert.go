@@ -1,14 +1,25 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
+// alertColumns lists the columns selected for a domain.Alert
+const alertColumns = `id, domain_id, domain_name, threshold, expiration_date,
+		       sent_at, success, error_message, type, message, dedup_key, status,
+		       attempts, next_attempt_at`
+
+// errAlreadyClaimed rolls back a claim whose dedup key is taken
+var errAlreadyClaimed = errors.New("alert already claimed")
+
 // AlertRepository handles alert data persistence
 type AlertRepository struct {
 	db *DB
@@ -20,82 +31,258 @@ func NewAlertRepository(db *DB) *AlertRepository {
 }
 
 // Create adds a new alert to the database
-func (r *AlertRepository) Create(alert *domain.Alert) error {
+// Alerts without a dedup key are keyed by their ID and never collide
+func (r *AlertRepository) Create(ctx context.Context, alert *domain.Alert) error {
 	if alert.ID == "" {
 		alert.ID = uuid.New().String()
 	}
+	if alert.Type == "" {
+		alert.Type = domain.AlertTypeExpiration
+	}
+	if alert.DedupKey == "" {
+		alert.DedupKey = alert.ID
+	}
+	if alert.Status == "" {
+		alert.Status = domain.AlertStatusFailed
+		if alert.Success {
+			alert.Status = domain.AlertStatusSent
+		}
+	}
 
-	query := `
-		INSERT INTO alerts (
-			id, domain_id, domain_name, threshold, expiration_date,
-			sent_at, success, error_message
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(insertAlertQuery), alertArgs(alert)...); err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	return nil
+}
+
+const insertAlertQuery = `
+	INSERT INTO alerts (
+		id, domain_id, domain_name, threshold, expiration_date,
+		sent_at, success, error_message, type, message, dedup_key, status,
+		attempts, next_attempt_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
 
-	_, err := r.db.Exec(query,
+// alertArgs returns the values for insertAlertQuery
+func alertArgs(alert *domain.Alert) []interface{} {
+	return []interface{}{
 		alert.ID, alert.DomainID, alert.DomainName, alert.Threshold,
 		alert.ExpirationDate, alert.SentAt, alert.Success, alert.ErrorMessage,
+		alert.Type, alert.Message, alert.DedupKey, alert.Status,
+		alert.Attempts, alert.NextAttemptAt,
+	}
+}
+
+// Claim records an alert as pending under its dedup key before it is sent
+// It reports false when the key is already claimed, so concurrent evaluations
+// send an alert once. A claim left pending for longer than staleAfter, by a process
+// that died while sending, is taken over and keeps its ID.
+func (r *AlertRepository) Claim(ctx context.Context, alert *domain.Alert, staleAfter time.Duration) (bool, error) {
+	if alert.ID == "" {
+		alert.ID = uuid.New().String()
+	}
+	if alert.Type == "" {
+		alert.Type = domain.AlertTypeExpiration
+	}
+	if alert.DedupKey == "" {
+		alert.DedupKey = alert.ID
+	}
+	alert.Status = domain.AlertStatusPending
+	alert.Success = false
+
+	err := r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		var existing struct {
+			ID     string    `db:"id"`
+			Status string    `db:"status"`
+			SentAt time.Time `db:"sent_at"`
+		}
+		err := tx.GetContext(ctx, &existing, r.db.Rebind(`SELECT id, status, sent_at FROM alerts WHERE dedup_key = ?`), alert.DedupKey)
+		if err == sql.ErrNoRows {
+			if _, err := tx.ExecContext(ctx, r.db.Rebind(insertAlertQuery), alertArgs(alert)...); err != nil {
+				if IsConstraintError(err) {
+					return errAlreadyClaimed // Claimed concurrently
+				}
+				return fmt.Errorf("failed to claim alert: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up alert claim: %w", err)
+		}
+
+		staleBefore := time.Now().Add(-staleAfter)
+		if existing.Status != domain.AlertStatusPending || existing.SentAt.After(staleBefore) {
+			return errAlreadyClaimed
+		}
+
+		result, err := tx.ExecContext(ctx,
+			r.db.Rebind(`UPDATE alerts SET sent_at = ? WHERE id = ? AND status = ? AND sent_at < ?`),
+			alert.SentAt, existing.ID, domain.AlertStatusPending, staleBefore,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to take over alert claim: %w", err)
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows != 1 {
+			return errAlreadyClaimed
+		}
+		alert.ID = existing.ID
+		return nil
+	})
+
+	if errors.Is(err, errAlreadyClaimed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClaimRetry claims a failed alert whose next attempt is due for redelivery
+// Pending alerts whose sender died more than staleAfter ago are claimed as well.
+// It reports false when the alert is not due or another instance claimed it first.
+func (r *AlertRepository) ClaimRetry(ctx context.Context, id string, now time.Time, staleAfter time.Duration) (bool, error) {
+	query := `
+		UPDATE alerts
+		SET status = ?, sent_at = ?
+		WHERE id = ?
+		  AND ((status = ? AND next_attempt_at <= ?) OR (status = ? AND sent_at < ?))
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		domain.AlertStatusPending, now, id,
+		domain.AlertStatusFailed, now, domain.AlertStatusPending, now.Add(-staleAfter),
 	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim alert retry: %w", err)
+	}
 
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to create alert: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ClaimResend claims a failed or dead alert for a manual resend and resets its attempts
+// It reports false when the alert was delivered or is being sent
+func (r *AlertRepository) ClaimResend(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `
+		UPDATE alerts
+		SET status = ?, sent_at = ?, attempts = 0, next_attempt_at = NULL
+		WHERE id = ? AND status IN (?, ?)
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), domain.AlertStatusPending, now, id, domain.AlertStatusFailed, domain.AlertStatusDead)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim alert resend: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// Finalize records the outcome of sending a claimed alert
+func (r *AlertRepository) Finalize(ctx context.Context, alert *domain.Alert) error {
+	query := `
+		UPDATE alerts
+		SET status = ?, success = ?, error_message = ?, sent_at = ?, attempts = ?, next_attempt_at = ?
+		WHERE id = ?
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		alert.Status, alert.Success, alert.ErrorMessage, alert.SentAt,
+		alert.Attempts, alert.NextAttemptAt, alert.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finalize alert: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("alert not found: %s", alert.ID)
 	}
 
 	return nil
 }
 
-// GetByDomainID retrieves all alerts for a specific domain
-func (r *AlertRepository) GetByDomainID(domainID string) ([]*domain.Alert, error) {
+// GetByID retrieves an alert by its ID
+func (r *AlertRepository) GetByID(ctx context.Context, id string) (*domain.Alert, error) {
+	var alert domain.Alert
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE id = ?`
+
+	err := r.db.GetContext(ctx, &alert, r.db.Rebind(query), id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+
+	return &alert, nil
+}
+
+// GetDueRetries retrieves failed alerts whose next delivery attempt is due and
+// pending alerts abandoned for longer than staleAfter, oldest first
+func (r *AlertRepository) GetDueRetries(ctx context.Context, now time.Time, staleAfter time.Duration, limit int) ([]*domain.Alert, error) {
 	var alerts []*domain.Alert
 	query := `
-		SELECT id, domain_id, domain_name, threshold, expiration_date,
-		       sent_at, success, error_message
+		SELECT ` + alertColumns + `
 		FROM alerts
-		WHERE domain_id = ?
-		ORDER BY sent_at DESC
+		WHERE (status = ? AND next_attempt_at <= ?) OR (status = ? AND sent_at < ?)
+		ORDER BY sent_at ASC
+		LIMIT ?
 	`
 
-	err := r.db.Select(&alerts, query, domainID)
+	err := r.db.SelectContext(ctx, &alerts, r.db.Rebind(query),
+		domain.AlertStatusFailed, now, domain.AlertStatusPending, now.Add(-staleAfter), limit,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get alerts for domain: %w", err)
+		return nil, fmt.Errorf("failed to get due alert retries: %w", err)
 	}
 
 	return alerts, nil
 }
 
-// HasAlertBeenSent checks if an alert has already been sent for a domain and threshold
-// This checks for ANY alert attempt (successful or not) to prevent duplicate alerts
-func (r *AlertRepository) HasAlertBeenSent(domainID string, threshold time.Duration) (bool, error) {
-	var count int
+// GetByDomainID retrieves all alerts for a specific domain
+func (r *AlertRepository) GetByDomainID(ctx context.Context, domainID string) ([]*domain.Alert, error) {
+	var alerts []*domain.Alert
 	query := `
-		SELECT COUNT(*)
+		SELECT ` + alertColumns + `
 		FROM alerts
-		WHERE domain_id = ? AND threshold = ?
+		WHERE domain_id = ?
+		ORDER BY sent_at DESC
 	`
 
-	err := r.db.Get(&count, query, domainID, int64(threshold))
+	err := r.db.SelectContext(ctx, &alerts, r.db.Rebind(query), domainID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
-		return false, fmt.Errorf("failed to check if alert was sent: %w", err)
+		return nil, fmt.Errorf("failed to get alerts for domain: %w", err)
 	}
 
-	return count > 0, nil
+	return alerts, nil
 }
 
 // GetRecentAlerts retrieves alerts sent within a specific time period
-func (r *AlertRepository) GetRecentAlerts(since time.Time) ([]*domain.Alert, error) {
+func (r *AlertRepository) GetRecentAlerts(ctx context.Context, since time.Time) ([]*domain.Alert, error) {
 	var alerts []*domain.Alert
 	query := `
-		SELECT id, domain_id, domain_name, threshold, expiration_date,
-		       sent_at, success, error_message
+		SELECT ` + alertColumns + `
 		FROM alerts
 		WHERE sent_at >= ?
 		ORDER BY sent_at DESC
 	`
 
-	err := r.db.Select(&alerts, query, since)
+	err := r.db.SelectContext(ctx, &alerts, r.db.Rebind(query), since)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recent alerts: %w", err)
 	}
@@ -103,38 +290,54 @@ func (r *AlertRepository) GetRecentAlerts(since time.Time) ([]*domain.Alert, err
 	return alerts, nil
 }
 
-// DeleteOlderThan deletes alerts that were sent before the cutoff time
-func (r *AlertRepository) DeleteOlderThan(cutoff time.Time) error {
-	query := `DELETE FROM alerts WHERE sent_at < ?`
+// expiredAlertsWhere matches alerts past retention at a cutoff: delivered or
+// dead-lettered before it. Expiration alerts are also kept until their expiry is
+// before the cutoff, since their deduplication key keeps the threshold from
+// alerting again in the same cycle.
+const expiredAlertsWhere = `sent_at < ? AND status IN (?, ?) AND (type <> ? OR expiration_date < ?)`
 
-	result, err := r.db.Exec(query, cutoff)
-	if err != nil {
-		return fmt.Errorf("failed to delete old alerts: %w", err)
-	}
+// expiredAlertsArgs returns the arguments of expiredAlertsWhere
+func expiredAlertsArgs(cutoff time.Time) []interface{} {
+	return []interface{}{cutoff, domain.AlertStatusSent, domain.AlertStatusDead, domain.AlertTypeExpiration, cutoff}
+}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+// CountOlderThan counts the alerts DeleteOlderThan would delete at the cutoff
+func (r *AlertRepository) CountOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM alerts WHERE ` + expiredAlertsWhere
+	if err := r.db.GetContext(ctx, &count, r.db.Rebind(query), expiredAlertsArgs(cutoff)...); err != nil {
+		return 0, fmt.Errorf("failed to count old alerts: %w", err)
 	}
+	return count, nil
+}
 
-	// Log how many alerts were deleted (in production, use proper logging)
-	_ = rows
+// DeleteOlderThan deletes up to limit alerts sent before the cutoff time and
+// returns how many were deleted. Alerts awaiting delivery are kept.
+func (r *AlertRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	var ids []string
+	query := `SELECT id FROM alerts WHERE ` + expiredAlertsWhere + ` ORDER BY sent_at ASC LIMIT ?`
+	if err := r.db.SelectContext(ctx, &ids, r.db.Rebind(query), append(expiredAlertsArgs(cutoff), limit)...); err != nil {
+		return 0, fmt.Errorf("failed to find old alerts: %w", err)
+	}
 
-	return nil
+	deleted, err := r.db.deleteIDs(ctx, "alerts", ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old alerts: %w", err)
+	}
+	return deleted, nil
 }
 
-// GetFailedAlerts retrieves alerts that failed to send
-func (r *AlertRepository) GetFailedAlerts() ([]*domain.Alert, error) {
+// GetFailedAlerts retrieves alerts awaiting a retry or out of delivery attempts
+func (r *AlertRepository) GetFailedAlerts(ctx context.Context) ([]*domain.Alert, error) {
 	var alerts []*domain.Alert
 	query := `
-		SELECT id, domain_id, domain_name, threshold, expiration_date,
-		       sent_at, success, error_message
+		SELECT ` + alertColumns + `
 		FROM alerts
-		WHERE success = 0
+		WHERE status IN (?, ?)
 		ORDER BY sent_at DESC
 	`
 
-	err := r.db.Select(&alerts, query)
+	err := r.db.SelectContext(ctx, &alerts, r.db.Rebind(query), domain.AlertStatusFailed, domain.AlertStatusDead)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get failed alerts: %w", err)
 	}
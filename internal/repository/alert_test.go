@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test that a dedup key is claimed once and that a claim abandoned mid-send is taken over
+func TestAlertRepository_Claim(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_alert_claims.db", func(t *testing.T, db *DB) {
+		repo := NewAlertRepository(db)
+		newAlert := func(sentAt time.Time) *domain.Alert {
+			return &domain.Alert{DomainID: "d1", DomainName: "example.com", SentAt: sentAt, DedupKey: "expiration:d1:1:2030-01-01"}
+		}
+
+		first := newAlert(time.Now().Add(-10 * time.Minute))
+		if claimed, err := repo.Claim(ctx, first, 5*time.Minute); err != nil || !claimed {
+			t.Fatalf("Claim() = %v, %v; want true", claimed, err)
+		}
+		if first.Status != domain.AlertStatusPending {
+			t.Errorf("Claimed alert status = %q, want pending", first.Status)
+		}
+
+		// The first sender died ten minutes ago without finalizing
+		second := newAlert(time.Now())
+		if claimed, err := repo.Claim(ctx, second, 5*time.Minute); err != nil || !claimed {
+			t.Fatalf("Claim() of a stale pending alert = %v, %v; want true", claimed, err)
+		}
+		if second.ID != first.ID {
+			t.Errorf("Takeover got ID %s, want the original %s so retries stay idempotent", second.ID, first.ID)
+		}
+
+		third := newAlert(time.Now())
+		if claimed, err := repo.Claim(ctx, third, 5*time.Minute); err != nil || claimed {
+			t.Fatalf("Claim() of a fresh pending alert = %v, %v; want false", claimed, err)
+		}
+
+		second.Status = domain.AlertStatusSent
+		second.Success = true
+		if err := repo.Finalize(ctx, second); err != nil {
+			t.Fatalf("Finalize() unexpected error: %v", err)
+		}
+
+		late := newAlert(time.Now().Add(time.Hour))
+		if claimed, err := repo.Claim(ctx, late, 0); err != nil || claimed {
+			t.Fatalf("Claim() of a sent alert = %v, %v; want false", claimed, err)
+		}
+
+		alerts, err := repo.GetByDomainID(ctx, "d1")
+		if err != nil {
+			t.Fatalf("Failed to get alerts: %v", err)
+		}
+		if len(alerts) != 1 || alerts[0].Status != domain.AlertStatusSent || !alerts[0].Success {
+			t.Fatalf("Expected one sent alert, got %+v", alerts)
+		}
+	})
+}
+
+// Test that a due retry is claimed by one instance and a dead alert only by a resend
+func TestAlertRepository_ClaimRetry(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_alert_retries.db", func(t *testing.T, db *DB) {
+		repo := NewAlertRepository(db)
+		now := time.Now()
+		due := now.Add(-time.Minute)
+		later := now.Add(time.Hour)
+
+		alerts := []*domain.Alert{
+			{ID: "due", Status: domain.AlertStatusFailed, Attempts: 1, NextAttemptAt: &due},
+			{ID: "later", Status: domain.AlertStatusFailed, Attempts: 1, NextAttemptAt: &later},
+			{ID: "dead", Status: domain.AlertStatusDead, Attempts: 8},
+			{ID: "sent", Status: domain.AlertStatusSent, Success: true},
+		}
+		for _, a := range alerts {
+			a.DomainID = "d1"
+			a.DomainName = "example.com"
+			a.SentAt = now.Add(-2 * time.Hour)
+			if err := repo.Create(ctx, a); err != nil {
+				t.Fatalf("Failed to create alert %s: %v", a.ID, err)
+			}
+		}
+
+		retries, err := repo.GetDueRetries(ctx, now, 5*time.Minute, 10)
+		if err != nil {
+			t.Fatalf("GetDueRetries() unexpected error: %v", err)
+		}
+		if len(retries) != 1 || retries[0].ID != "due" {
+			t.Fatalf("Expected only the due alert, got %+v", retries)
+		}
+
+		for _, tt := range []struct {
+			id   string
+			want bool
+		}{
+			{"due", true},
+			{"due", false}, // claimed by the first call
+			{"later", false},
+			{"dead", false},
+			{"sent", false},
+		} {
+			if claimed, err := repo.ClaimRetry(ctx, tt.id, now, 5*time.Minute); err != nil || claimed != tt.want {
+				t.Errorf("ClaimRetry(%s) = %v, %v; want %v", tt.id, claimed, err, tt.want)
+			}
+		}
+
+		for _, tt := range []struct {
+			id   string
+			want bool
+		}{
+			{"dead", true},
+			{"later", true},
+			{"sent", false},
+			{"due", false}, // pending
+		} {
+			if claimed, err := repo.ClaimResend(ctx, tt.id, now); err != nil || claimed != tt.want {
+				t.Errorf("ClaimResend(%s) = %v, %v; want %v", tt.id, claimed, err, tt.want)
+			}
+		}
+
+		dead, err := repo.GetByID(ctx, "dead")
+		if err != nil {
+			t.Fatalf("GetByID() unexpected error: %v", err)
+		}
+		if dead.Status != domain.AlertStatusPending || dead.Attempts != 0 {
+			t.Errorf("Resend claim left %q with %d attempts, want pending with 0", dead.Status, dead.Attempts)
+		}
+	})
+}
+
+// Test that retention deletes delivered history but keeps alerts awaiting delivery
+// and expiration alerts whose cycle has not ended
+func TestAlertRepository_DeleteOlderThan(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_alert_retention.db", func(t *testing.T, db *DB) {
+		repo := NewAlertRepository(db)
+
+		now := time.Now()
+		cutoff := now.Add(-90 * 24 * time.Hour)
+		old := cutoff.Add(-24 * time.Hour)
+		tests := []struct {
+			name       string
+			alert      *domain.Alert
+			wantPurged bool
+		}{
+			{"old http alert", &domain.Alert{Type: domain.AlertTypeHTTPCheck, Status: domain.AlertStatusSent, SentAt: old, ExpirationDate: now}, true},
+			{"old dead alert", &domain.Alert{Type: domain.AlertTypeStatus, Status: domain.AlertStatusDead, SentAt: old, ExpirationDate: now}, true},
+			{"old expiration alert of a past cycle", &domain.Alert{Status: domain.AlertStatusSent, SentAt: old, ExpirationDate: old}, true},
+			{"old expiration alert of the current cycle", &domain.Alert{Status: domain.AlertStatusSent, SentAt: old, ExpirationDate: now.Add(30 * 24 * time.Hour)}, false},
+			{"old alert awaiting retry", &domain.Alert{Type: domain.AlertTypeHTTPCheck, Status: domain.AlertStatusFailed, SentAt: old, ExpirationDate: now}, false},
+			{"recent alert", &domain.Alert{Type: domain.AlertTypeHTTPCheck, Status: domain.AlertStatusSent, SentAt: now, ExpirationDate: now}, false},
+		}
+
+		wantPurged := 0
+		for _, tt := range tests {
+			tt.alert.DomainID = "d1"
+			tt.alert.DomainName = "example.com"
+			if err := repo.Create(ctx, tt.alert); err != nil {
+				t.Fatalf("Failed to create %s: %v", tt.name, err)
+			}
+			if tt.wantPurged {
+				wantPurged++
+			}
+		}
+
+		if n, err := repo.CountOlderThan(ctx, cutoff); err != nil || n != wantPurged {
+			t.Fatalf("CountOlderThan() = %d, %v; want %d", n, err, wantPurged)
+		}
+
+		// Batches stop at the limit
+		if n, err := repo.DeleteOlderThan(ctx, cutoff, 2); err != nil || n != 2 {
+			t.Fatalf("DeleteOlderThan() first batch = %d, %v; want 2", n, err)
+		}
+		if n, err := repo.DeleteOlderThan(ctx, cutoff, 2); err != nil || n != wantPurged-2 {
+			t.Fatalf("DeleteOlderThan() second batch = %d, %v; want %d", n, err, wantPurged-2)
+		}
+
+		for _, tt := range tests {
+			_, err := repo.GetByID(ctx, tt.alert.ID)
+			if purged := err != nil; purged != tt.wantPurged {
+				t.Errorf("%s purged = %v, want %v", tt.name, purged, tt.wantPurged)
+			}
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/google/uuid"
+)
+
+// auditColumns lists the columns selected for a domain.AuditEntry
+const auditColumns = `id, created_at, actor, source_ip, action, object_type, object_id,
+		       object_name, before_json, after_json`
+
+// AuditQuery selects one page of the audit log, newest entries first
+type AuditQuery struct {
+	Actor      string
+	Action     string
+	ObjectType string
+	Object     string // ID or name of the changed object
+
+	Since time.Time // entries at or after Since; zero for no lower bound
+	Until time.Time // entries before Until; zero for no upper bound
+
+	Page     int // 1-based; defaults to the first page
+	PageSize int // defaults to DefaultPageSize, at most MaxPageSize
+}
+
+// AuditPage is one page of the audit entries matching a query
+type AuditPage struct {
+	Entries []*domain.AuditEntry
+	Pagination
+}
+
+// First returns the 1-based position of the page's first entry, or 0 when it is empty
+func (p *AuditPage) First() int {
+	if len(p.Entries) == 0 {
+		return 0
+	}
+	return p.offset() + 1
+}
+
+// Last returns the 1-based position of the page's last entry
+func (p *AuditPage) Last() int {
+	return p.offset() + len(p.Entries)
+}
+
+// Normalize fills in the defaults of a query
+func (q AuditQuery) Normalize() AuditQuery {
+	q.Actor = strings.TrimSpace(q.Actor)
+	q.Object = strings.TrimSpace(q.Object)
+	q.Page, q.PageSize = normalizePage(q.Page, q.PageSize)
+	return q
+}
+
+// Matches reports whether an entry is selected by a normalized query
+// It is what the SQL of AuditRepository.Query expresses, for stores without SQL.
+func (q AuditQuery) Matches(e *domain.AuditEntry) bool {
+	return (q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.ObjectType == "" || e.ObjectType == q.ObjectType) &&
+		(q.Object == "" || e.ObjectID == q.Object || e.ObjectName == q.Object) &&
+		(q.Since.IsZero() || !e.CreatedAt.Before(q.Since)) &&
+		(q.Until.IsZero() || e.CreatedAt.Before(q.Until))
+}
+
+// where builds the WHERE clause of a normalized query and its arguments
+func (q AuditQuery) where() (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if q.Actor != "" {
+		add("actor = ?", q.Actor)
+	}
+	if q.Action != "" {
+		add("action = ?", q.Action)
+	}
+	if q.ObjectType != "" {
+		add("object_type = ?", q.ObjectType)
+	}
+	if q.Object != "" {
+		add("(object_id = ? OR object_name = ?)", q.Object, q.Object)
+	}
+	if !q.Since.IsZero() {
+		add("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		add("created_at < ?", q.Until)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// AuditRepository handles audit log persistence
+// It only appends and reads; nothing, retention included, changes or removes entries.
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, e *domain.AuditEntry) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO audit_log (` + auditColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		e.ID, e.CreatedAt, e.Actor, e.SourceIP, e.Action, e.ObjectType, e.ObjectID,
+		e.ObjectName, e.Before, e.After,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns one page of the audit entries matching q, newest first
+func (r *AuditRepository) Query(ctx context.Context, q AuditQuery) (*AuditPage, error) {
+	q = q.Normalize()
+	where, args := q.where()
+
+	page := &AuditPage{Entries: []*domain.AuditEntry{}, Pagination: Pagination{Page: q.Page, PageSize: q.PageSize}}
+	if err := r.db.GetContext(ctx, &page.Total, r.db.Rebind(`SELECT COUNT(*) FROM audit_log WHERE `+where), args...); err != nil {
+		return nil, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_log
+		WHERE ` + where + `
+		ORDER BY created_at DESC, id ASC
+		LIMIT ? OFFSET ?
+	`
+	args = append(args, q.PageSize, page.offset())
+	if err := r.db.SelectContext(ctx, &page.Entries, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// BackupFormat is the version of the archive layout written by Backup
+const BackupFormat = 1
+
+// backupManifestName is the archive entry describing the backup
+const backupManifestName = "manifest.json"
+
+// BackupManifest describes a backup archive
+type BackupManifest struct {
+	Format        int           `json:"format"`
+	SchemaVersion int           `json:"schema_version"`
+	Driver        string        `json:"driver"` // Driver of the database that was backed up
+	CreatedAt     time.Time     `json:"created_at"`
+	Tables        []BackupTable `json:"tables"`
+}
+
+// BackupTable names the archive entry holding a table and its number of rows
+type BackupTable struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Rows int    `json:"rows"`
+}
+
+// BackupArchive is a backup that has been read and checked by OpenBackup
+type BackupArchive struct {
+	Manifest BackupManifest
+	files    map[string]*zip.File
+}
+
+// BackupFileName returns the name of an archive taken at t; names sort by time
+func BackupFileName(t time.Time) string {
+	return "dem-backup-" + t.UTC().Format("20060102-150405") + ".zip"
+}
+
+// Backup writes a consistent, driver-independent archive of all data to w
+// The archive is a zip file with one JSON Lines file per table and a manifest
+// recording the schema version. SQLite databases are first snapshotted with the
+// online backup API; MySQL and PostgreSQL are read in one repeatable-read transaction.
+func (db *DB) Backup(ctx context.Context, w io.Writer) (*BackupManifest, error) {
+	if db.driver == "sqlite3" {
+		return db.backupSQLite(ctx, w)
+	}
+
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin backup transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	return writeBackup(ctx, tx, db.driver, w)
+}
+
+// BackupFile writes a backup to path, replacing it only once the archive is complete
+func (db *DB) BackupFile(ctx context.Context, path string) (*BackupManifest, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := db.Backup(ctx, tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	return manifest, nil
+}
+
+// backupSQLite copies the database to a temporary file with SQLite's online backup
+// API and archives the copy, so writers are blocked only while pages are copied
+func (db *DB) backupSQLite(ctx context.Context, w io.Writer) (*BackupManifest, error) {
+	snapshotFile, err := os.CreateTemp("", "dem-snapshot-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	snapshotFile.Close()
+	defer os.Remove(snapshotFile.Name())
+
+	snapshot, err := sqlx.Open("sqlite3", snapshotFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer snapshot.Close()
+
+	if err := db.snapshotSQLite(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return writeBackup(ctx, snapshot, db.driver, w)
+}
+
+// snapshotSQLite copies every page of the database into snapshot
+func (db *DB) snapshotSQLite(ctx context.Context, snapshot *sqlx.DB) error {
+	src, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := snapshot.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot connection: %w", err)
+	}
+	defer dst.Close()
+
+	return dst.Raw(func(dstConn interface{}) error {
+		return src.Raw(func(srcConn interface{}) error {
+			backup, err := dstConn.(*sqlite3.SQLiteConn).Backup("main", srcConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return fmt.Errorf("failed to start snapshot: %w", err)
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to copy snapshot: %w", err)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish snapshot: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// writeBackup archives every data table read through q, followed by the manifest
+func writeBackup(ctx context.Context, q sqlx.QueryerContext, driver string, w io.Writer) (*BackupManifest, error) {
+	version, err := schemaVersion(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{
+		Format:        BackupFormat,
+		SchemaVersion: version,
+		Driver:        driver,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	archive := zip.NewWriter(w)
+	for _, table := range dataTables {
+		entry := BackupTable{Name: table.name, File: table.name + ".jsonl"}
+		f, err := archive.Create(entry.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to backup: %w", table.name, err)
+		}
+
+		encoder := json.NewEncoder(f)
+		err = table.scanRows(ctx, q, table.selectQuery(""), nil, func(row interface{}) error {
+			entry.Rows++
+			return encoder.Encode(row)
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest.Tables = append(manifest.Tables, entry)
+	}
+
+	f, err := archive.Create(backupManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add manifest to backup: %w", err)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+	return manifest, nil
+}
+
+// OpenBackup reads a backup archive and checks that it is complete
+// Every row is decoded and counted against the manifest, so a damaged or
+// truncated archive is rejected before anything is restored.
+func OpenBackup(r io.ReaderAt, size int64) (*BackupArchive, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+
+	archive := &BackupArchive{files: make(map[string]*zip.File, len(reader.File))}
+	for _, f := range reader.File {
+		archive.files[f.Name] = f
+	}
+
+	manifestFile, ok := archive.files[backupManifestName]
+	if !ok {
+		return nil, errors.New("not a backup archive: manifest missing")
+	}
+	if err := readJSON(manifestFile, &archive.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if archive.Manifest.Format != BackupFormat {
+		return nil, fmt.Errorf("unsupported backup format %d, want %d", archive.Manifest.Format, BackupFormat)
+	}
+
+	entries := make(map[string]BackupTable, len(archive.Manifest.Tables))
+	for _, entry := range archive.Manifest.Tables {
+		entries[entry.Name] = entry
+	}
+	for _, table := range dataTables {
+		entry, ok := entries[table.name]
+		if !ok {
+			return nil, fmt.Errorf("backup has no %s table", table.name)
+		}
+		rows := 0
+		if err := archive.eachRow(table, entry, func(interface{}) error { rows++; return nil }); err != nil {
+			return nil, err
+		}
+		if rows != entry.Rows {
+			return nil, fmt.Errorf("backup of %s has %d rows, manifest says %d", table.name, rows, entry.Rows)
+		}
+		delete(entries, table.name)
+	}
+	for name := range entries {
+		return nil, fmt.Errorf("backup has unknown table %s", name)
+	}
+
+	return archive, nil
+}
+
+// eachRow decodes the rows of table from the archive and calls fn with each
+func (a *BackupArchive) eachRow(table dataTable, entry BackupTable, fn func(row interface{}) error) error {
+	f, ok := a.files[entry.File]
+	if !ok {
+		return fmt.Errorf("backup file %s missing", entry.File)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", entry.File, err)
+	}
+	defer rc.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(rc))
+	for {
+		row := table.newRow()
+		err := decoder.Decode(row)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.File, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSON decodes the archive entry f into v
+func readJSON(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
+}
+
+// Restore replaces all data with the contents of archive, in one transaction
+// The archive must have the schema version of the database. Unless replace is set,
+// the database must hold no data besides its configuration.
+func (db *DB) Restore(ctx context.Context, archive *BackupArchive, replace bool) error {
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if archive.Manifest.SchemaVersion != version {
+		return fmt.Errorf("backup has schema version %d but the database is at version %d; restore it with the release that wrote it",
+			archive.Manifest.SchemaVersion, version)
+	}
+
+	entries := make(map[string]BackupTable, len(archive.Manifest.Tables))
+	for _, entry := range archive.Manifest.Tables {
+		entries[entry.Name] = entry
+	}
+
+	return db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		if !replace {
+			for _, table := range dataTables {
+				if table.whole {
+					continue
+				}
+				var count int
+				if err := tx.GetContext(ctx, &count, `SELECT COUNT(*) FROM `+table.name); err != nil {
+					return fmt.Errorf("failed to count %s: %w", table.name, err)
+				}
+				if count > 0 {
+					return fmt.Errorf("database already has %d %s; restore with replace to overwrite its data", count, table.name)
+				}
+			}
+		}
+
+		// Children first, so no foreign key is left dangling
+		for i := len(dataTables) - 1; i >= 0; i-- {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+dataTables[i].name); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", dataTables[i].name, err)
+			}
+		}
+
+		for _, table := range dataTables {
+			insert := table.insertQuery()
+			err := archive.eachRow(table, entries[table.name], func(row interface{}) error {
+				if _, err := tx.NamedExecContext(ctx, insert, row); err != nil {
+					return fmt.Errorf("failed to restore %s %s: %w", table.name, table.rowID(row), err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test that a backup restores on every driver and that restore refuses to
+// overwrite data unless asked to
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	srcPath := "test_backup_source.db"
+	defer os.Remove(srcPath)
+
+	src, err := NewDB(srcPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to create source database: %v", err)
+	}
+	defer src.Close()
+
+	now := time.Now()
+	d := &domain.Domain{Name: "example.com", ExpirationDate: now.Add(30 * 24 * time.Hour), Nameservers: domain.Strings{"ns1.example.com"}, LastChecked: now, NextCheck: now,
+		Metadata: domain.Metadata{Owner: "platform", Criticality: domain.CriticalityHigh, Notes: "Main site", AnnualCostCents: 1299, Tags: domain.Strings{"prod", "web"}}}
+	if err := NewDomainRepository(src).Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+	if err := NewAlertRepository(src).Create(ctx, &domain.Alert{DomainID: d.ID, DomainName: d.Name, Threshold: 1, ExpirationDate: d.ExpirationDate, SentAt: now}); err != nil {
+		t.Fatalf("Failed to create alert: %v", err)
+	}
+	entry := &domain.AuditEntry{Actor: "alice", SourceIP: "192.0.2.1", Action: domain.AuditDomainCreate, ObjectType: domain.AuditObjectDomain, ObjectID: d.ID, ObjectName: d.Name, After: `{"name":"example.com"}`}
+	if err := NewAuditRepository(src).Create(ctx, entry); err != nil {
+		t.Fatalf("Failed to create audit entry: %v", err)
+	}
+	config, err := NewConfigRepository(src).Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	config.GoogleChatWebhook = "https://chat.example.com/hook"
+	if err := NewConfigRepository(src).Update(ctx, config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := src.Backup(ctx, &buf)
+	if err != nil {
+		t.Fatalf("Backup() unexpected error: %v", err)
+	}
+	if manifest.SchemaVersion == 0 || manifest.Driver != "sqlite3" || len(manifest.Tables) != len(dataTables) {
+		t.Errorf("Manifest = %+v", manifest)
+	}
+
+	archive, err := OpenBackup(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("OpenBackup() unexpected error: %v", err)
+	}
+
+	forEachDriver(t, "test_backup_target.db", func(t *testing.T, dst *DB) {
+		if err := dst.Restore(ctx, archive, false); err != nil {
+			t.Fatalf("Restore() unexpected error: %v", err)
+		}
+
+		got, err := NewDomainRepository(dst).GetByID(ctx, d.ID)
+		if err != nil {
+			t.Fatalf("Restored domain missing: %v", err)
+		}
+		if got.Name != d.Name || !got.ExpirationDate.Equal(d.ExpirationDate) || len(got.Nameservers) != 1 ||
+			!reflect.DeepEqual(got.Metadata, d.Metadata) {
+			t.Errorf("Restored domain = %+v, want %+v", got, d)
+		}
+		alerts, err := NewAlertRepository(dst).GetByDomainID(ctx, d.ID)
+		if err != nil || len(alerts) != 1 {
+			t.Errorf("Restored alerts = %v, %v; want 1", alerts, err)
+		}
+		gotConfig, err := NewConfigRepository(dst).Get(ctx)
+		if err != nil || gotConfig.GoogleChatWebhook != config.GoogleChatWebhook {
+			t.Errorf("Restored config = %+v, %v; want the backed up config", gotConfig, err)
+		}
+		audit, err := NewAuditRepository(dst).Query(ctx, AuditQuery{})
+		if err != nil || len(audit.Entries) != 1 || audit.Entries[0].Actor != "alice" || audit.Entries[0].After != entry.After {
+			t.Errorf("Restored audit log = %+v, %v; want the backed up entry", audit, err)
+		}
+
+		// A second restore would overwrite the restored data
+		if err := dst.Restore(ctx, archive, false); err == nil {
+			t.Error("Restore() into a database with data succeeded without replace")
+		}
+		if err := NewDomainRepository(dst).Create(ctx, &domain.Domain{Name: "new.com", ExpirationDate: now, LastChecked: now, NextCheck: now}); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+		if err := dst.Restore(ctx, archive, true); err != nil {
+			t.Fatalf("Restore() with replace unexpected error: %v", err)
+		}
+		if count, err := dst.countRows(ctx, "domains"); err != nil || count != 1 {
+			t.Errorf("Domains after replacing restore = %d, %v; want 1", count, err)
+		}
+	})
+}
+
+// Test that damaged or incompatible archives are rejected before anything is restored
+func TestOpenBackup_Invalid(t *testing.T) {
+	ctx := context.Background()
+
+	// archive builds a backup of an empty database with its manifest edited
+	archive := func(edit func(m map[string]interface{})) []byte {
+		dbPath := "test_backup_invalid.db"
+		defer os.Remove(dbPath)
+		db, err := NewDB(dbPath, "sqlite3")
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		defer db.Close()
+
+		var buf bytes.Buffer
+		if _, err := db.Backup(ctx, &buf); err != nil {
+			t.Fatalf("Backup() unexpected error: %v", err)
+		}
+		files := unzip(t, buf.Bytes())
+
+		var manifest map[string]interface{}
+		if err := json.Unmarshal([]byte(files[backupManifestName]), &manifest); err != nil {
+			t.Fatalf("Failed to decode manifest: %v", err)
+		}
+		edit(manifest)
+		data, _ := json.Marshal(manifest)
+		files[backupManifestName] = string(data)
+
+		buf.Reset()
+		w := zip.NewWriter(&buf)
+		for name, content := range files {
+			f, _ := w.Create(name)
+			f.Write([]byte(content))
+		}
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		archive []byte
+		want    string
+	}{
+		{"not an archive", []byte("dem.db"), "not a backup archive"},
+		{"newer format", archive(func(m map[string]interface{}) { m["format"] = BackupFormat + 1 }), "unsupported backup format"},
+		{"row count mismatch", archive(func(m map[string]interface{}) {
+			m["tables"].([]interface{})[0].(map[string]interface{})["rows"] = 5
+		}), "manifest says 5"},
+		{"missing table", archive(func(m map[string]interface{}) { m["tables"] = m["tables"].([]interface{})[1:] }), "no domains table"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenBackup(bytes.NewReader(tt.archive), int64(len(tt.archive)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("OpenBackup() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A valid archive from another schema version is refused by Restore
+	data := archive(func(m map[string]interface{}) { m["schema_version"] = 9999 })
+	newer, err := OpenBackup(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("OpenBackup() unexpected error: %v", err)
+	}
+	dbPath := "test_backup_version.db"
+	defer os.Remove(dbPath)
+	db, err := NewDB(dbPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Restore(ctx, newer, true); err == nil || !strings.Contains(err.Error(), "schema version 9999") {
+		t.Errorf("Restore() of a newer schema = %v, want a version error", err)
+	}
+}
+
+// unzip returns the entries of a zip archive by name
+func unzip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	files := make(map[string]string, len(r.File))
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(rc)
+		rc.Close()
+		files[f.Name] = buf.String()
+	}
+	return files
+}
@@ -1,14 +1,23 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
+// configRevisionColumns lists the columns selected for a domain.ConfigRevision
+const configRevisionColumns = `id, revision, created_at, monitoring_interval, alert_thresholds,
+		       google_chat_webhook, retention_period, check_policy, alert_criticality, restored_from`
+
 // ConfigRepository handles configuration data persistence
+// Every save also appends a revision, the configuration's history.
 type ConfigRepository struct {
 	db *DB
 }
@@ -20,46 +29,97 @@ func NewConfigRepository(db *DB) *ConfigRepository {
 
 // Get retrieves the application configuration
 // If no configuration exists, it creates and returns default values
-func (r *ConfigRepository) Get() (*domain.Config, error) {
+func (r *ConfigRepository) Get(ctx context.Context) (*domain.Config, error) {
+	config, err := r.get(ctx)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return config, err
+	}
+
+	// Create default configuration
+	defaultConfig := DefaultConfig()
+	err = r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		return r.create(ctx, tx, defaultConfig)
+	})
+	if err == nil {
+		return defaultConfig, nil
+	}
+	if !IsConstraintError(err) {
+		return nil, fmt.Errorf("failed to create default config: %w", err)
+	}
+
+	// Another caller, such as a concurrent check, created it first
+	return r.get(ctx)
+}
+
+// get reads the configuration row, returning sql.ErrNoRows when there is none
+func (r *ConfigRepository) get(ctx context.Context) (*domain.Config, error) {
 	var config domain.Config
 	query := `
 		SELECT id, monitoring_interval, alert_thresholds, google_chat_webhook,
-		       retention_period, updated_at
+		       retention_period, check_policy, alert_criticality, updated_at
 		FROM config
 		WHERE id = 1
 	`
 
-	err := r.db.Get(&config, query)
+	err := r.db.GetContext(ctx, &config, r.db.Rebind(query))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Create default configuration
-			defaultConfig := r.createDefaultConfig()
-			if err := r.create(defaultConfig); err != nil {
-				return nil, fmt.Errorf("failed to create default config: %w", err)
-			}
-			return defaultConfig, nil
-		}
 		return nil, fmt.Errorf("failed to get config: %w", err)
 	}
 
+	if config.GoogleChatWebhook, err = r.db.secrets.Decrypt(config.GoogleChatWebhook); err != nil {
+		return nil, fmt.Errorf("failed to decrypt webhook URL: %w", err)
+	}
+
 	return &config, nil
 }
 
-// Update updates the application configuration
-func (r *ConfigRepository) Update(config *domain.Config) error {
+// Update updates the application configuration, saving it as a new revision
+func (r *ConfigRepository) Update(ctx context.Context, config *domain.Config) error {
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		return r.save(ctx, tx, config, 0)
+	})
+}
+
+// Rollback restores the configuration of an earlier revision, saving it as a new
+// revision, and returns the restored configuration
+func (r *ConfigRepository) Rollback(ctx context.Context, revision int) (*domain.Config, error) {
+	var config *domain.Config
+	err := r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		rev, err := r.getRevision(ctx, tx, revision)
+		if err != nil {
+			return err
+		}
+		config = rev.Config()
+		return r.save(ctx, tx, config, revision)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// save writes the configuration and appends it as the next revision
+// The configuration row is written first: its lock makes concurrent saves take
+// their revision numbers one after the other.
+func (r *ConfigRepository) save(ctx context.Context, tx *sqlx.Tx, config *domain.Config, restoredFrom int) error {
 	config.ID = 1 // Ensure we're always updating the single config row
 	config.UpdatedAt = time.Now()
 
+	webhook, err := r.db.secrets.Encrypt(config.GoogleChatWebhook)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook URL: %w", err)
+	}
+
 	query := `
 		UPDATE config
 		SET monitoring_interval = ?, alert_thresholds = ?, google_chat_webhook = ?,
-		    retention_period = ?, updated_at = ?
+		    retention_period = ?, check_policy = ?, alert_criticality = ?, updated_at = ?
 		WHERE id = 1
 	`
 
-	result, err := r.db.Exec(query,
-		config.MonitoringInterval, config.AlertThresholds, config.GoogleChatWebhook,
-		config.RetentionPeriod, config.UpdatedAt,
+	result, err := tx.ExecContext(ctx, tx.Rebind(query),
+		config.MonitoringInterval, config.AlertThresholds, webhook,
+		config.RetentionPeriod, config.CheckPolicy, config.AlertCriticality, config.UpdatedAt,
 	)
 
 	if err != nil {
@@ -73,27 +133,43 @@ func (r *ConfigRepository) Update(config *domain.Config) error {
 
 	if rows == 0 {
 		// Config doesn't exist, create it
-		return r.create(config)
+		if err := insertConfig(ctx, tx, config, webhook); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return addRevision(ctx, tx, config, webhook, restoredFrom)
 }
 
-// create inserts a new configuration record
-func (r *ConfigRepository) create(config *domain.Config) error {
-	config.ID = 1 // Ensure single config row
+// create inserts the configuration row and its first revision
+// It fails with a constraint error when the row already exists.
+func (r *ConfigRepository) create(ctx context.Context, tx *sqlx.Tx, config *domain.Config) error {
+	config.ID = 1
 	config.UpdatedAt = time.Now()
 
+	webhook, err := r.db.secrets.Encrypt(config.GoogleChatWebhook)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook URL: %w", err)
+	}
+
+	if err := insertConfig(ctx, tx, config, webhook); err != nil {
+		return err
+	}
+	return addRevision(ctx, tx, config, webhook, 0)
+}
+
+// insertConfig inserts the configuration row with its webhook already encrypted
+func insertConfig(ctx context.Context, tx *sqlx.Tx, config *domain.Config, webhook string) error {
 	query := `
 		INSERT INTO config (
 			id, monitoring_interval, alert_thresholds, google_chat_webhook,
-			retention_period, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?)
+			retention_period, check_policy, alert_criticality, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.Exec(query,
+	_, err := tx.ExecContext(ctx, tx.Rebind(query),
 		config.ID, config.MonitoringInterval, config.AlertThresholds,
-		config.GoogleChatWebhook, config.RetentionPeriod, config.UpdatedAt,
+		webhook, config.RetentionPeriod, config.CheckPolicy, config.AlertCriticality, config.UpdatedAt,
 	)
 
 	if err != nil {
@@ -103,8 +179,80 @@ func (r *ConfigRepository) create(config *domain.Config) error {
 	return nil
 }
 
-// createDefaultConfig creates a configuration with default values
-func (r *ConfigRepository) createDefaultConfig() *domain.Config {
+// addRevision appends config, with its webhook already encrypted, to the history
+func addRevision(ctx context.Context, tx *sqlx.Tx, config *domain.Config, webhook string, restoredFrom int) error {
+	rev := domain.NewConfigRevision(config)
+	rev.ID = uuid.New().String()
+	rev.RestoredFrom = restoredFrom
+	if err := tx.GetContext(ctx, &rev.Revision, `SELECT COALESCE(MAX(revision), 0) + 1 FROM config_revisions`); err != nil {
+		return fmt.Errorf("failed to number config revision: %w", err)
+	}
+
+	query := `INSERT INTO config_revisions (` + configRevisionColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, tx.Rebind(query),
+		rev.ID, rev.Revision, rev.CreatedAt, rev.MonitoringInterval, rev.AlertThresholds,
+		webhook, rev.RetentionPeriod, rev.CheckPolicy, rev.AlertCriticality, rev.RestoredFrom,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create config revision: %w", err)
+	}
+
+	return nil
+}
+
+// Revisions returns up to limit revisions of the configuration, newest first
+func (r *ConfigRepository) Revisions(ctx context.Context, limit int) ([]*domain.ConfigRevision, error) {
+	revisions := []*domain.ConfigRevision{}
+	query := `
+		SELECT ` + configRevisionColumns + `
+		FROM config_revisions
+		ORDER BY revision DESC
+		LIMIT ?
+	`
+	if err := r.db.SelectContext(ctx, &revisions, r.db.Rebind(query), limit); err != nil {
+		return nil, fmt.Errorf("failed to get config revisions: %w", err)
+	}
+	for _, rev := range revisions {
+		if err := r.decryptRevision(rev); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves a revision of the configuration by its number
+func (r *ConfigRepository) GetRevision(ctx context.Context, revision int) (*domain.ConfigRevision, error) {
+	return r.getRevision(ctx, r.db, revision)
+}
+
+// getRevision retrieves a revision through q
+func (r *ConfigRepository) getRevision(ctx context.Context, q sqlx.QueryerContext, revision int) (*domain.ConfigRevision, error) {
+	var rev domain.ConfigRevision
+	query := `SELECT ` + configRevisionColumns + ` FROM config_revisions WHERE revision = ?`
+	if err := sqlx.GetContext(ctx, q, &rev, r.db.Rebind(query), revision); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("config revision not found: %d", revision)
+		}
+		return nil, fmt.Errorf("failed to get config revision: %w", err)
+	}
+	if err := r.decryptRevision(&rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// decryptRevision decrypts the webhook URL of a revision read from the database
+func (r *ConfigRepository) decryptRevision(rev *domain.ConfigRevision) error {
+	webhook, err := r.db.secrets.Decrypt(rev.GoogleChatWebhook)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt webhook URL of config revision %d: %w", rev.Revision, err)
+	}
+	rev.GoogleChatWebhook = webhook
+	return nil
+}
+
+// DefaultConfig returns the configuration used until one is saved
+func DefaultConfig() *domain.Config {
 	config := &domain.Config{
 		ID:                 1,
 		GoogleChatWebhook:  "",
@@ -126,5 +274,8 @@ func (r *ConfigRepository) createDefaultConfig() *domain.Config {
 	}
 	config.SetAlertThresholds(defaultThresholds)
 
+	// Check more often as expiration approaches
+	config.CheckPolicy = domain.DefaultCheckPolicy()
+
 	return config
 }
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// Test that concurrent reads of a missing configuration all get the default one,
+// which is created once
+func TestConfigRepository_GetConcurrentDefault(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_config.db", func(t *testing.T, db *DB) {
+		repo := NewConfigRepository(db)
+
+		const readers = 8
+		var wg sync.WaitGroup
+		errs := make(chan error, readers)
+		start := make(chan struct{})
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				config, err := repo.Get(ctx)
+				if err == nil && config.GetMonitoringInterval() != DefaultConfig().GetMonitoringInterval() {
+					t.Errorf("Get() monitoring interval = %v, want the default", config.GetMonitoringInterval())
+				}
+				errs <- err
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Get() unexpected error: %v", err)
+			}
+		}
+
+		revisions, err := repo.Revisions(ctx, 10)
+		if err != nil {
+			t.Fatalf("Revisions() unexpected error: %v", err)
+		}
+		if len(revisions) != 1 || revisions[0].Revision != 1 {
+			t.Errorf("Revisions() = %d revisions, want the default saved once", len(revisions))
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// copyBatchSize is how many rows are read from the source and written to the target at a time
+const copyBatchSize = 500
+
+// TableCopy reports the progress of copying one table
+type TableCopy struct {
+	Table  string
+	Copied int // Rows written to the target by this run
+	Source int // Rows in the source once the table is done
+	Target int // Rows in the target once the table is done
+}
+
+// Copy copies all data from src to dst, which may use different drivers
+// Rows already in dst are skipped, so a copy that was interrupted can simply be run
+// again; the configuration and its revisions are replaced. Both databases must be fully migrated.
+// progress, if not nil, is called after every batch. Copy fails if the row counts
+// of any table differ afterwards.
+func Copy(ctx context.Context, src, dst *DB, progress func(TableCopy)) ([]TableCopy, error) {
+	if progress == nil {
+		progress = func(TableCopy) {}
+	}
+
+	for _, db := range []struct {
+		name string
+		db   *DB
+	}{{"source", src}, {"target", dst}} {
+		statuses, err := db.db.MigrationStatus(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s schema version: %w", db.name, err)
+		}
+		for _, s := range statuses {
+			if !s.Applied() {
+				return nil, fmt.Errorf("%s database has pending migration %04d_%s; run \"dem migrate up\" first", db.name, s.Version, s.Name)
+			}
+		}
+	}
+
+	var results []TableCopy
+	var mismatched []string
+	for _, table := range dataTables {
+		result := TableCopy{Table: table.name}
+		report := func(copied int) {
+			result.Copied += copied
+			progress(result)
+		}
+
+		var err error
+		if table.whole {
+			err = copyWhole(ctx, src, dst, table, report)
+		} else {
+			err = copyRows(ctx, src, dst, table, report)
+		}
+		if err != nil {
+			return results, err
+		}
+
+		if result.Source, err = src.countRows(ctx, table.name); err != nil {
+			return results, err
+		}
+		if result.Target, err = dst.countRows(ctx, table.name); err != nil {
+			return results, err
+		}
+		if result.Source != result.Target {
+			mismatched = append(mismatched, fmt.Sprintf("%s (source %d, target %d)", table.name, result.Source, result.Target))
+		}
+
+		results = append(results, result)
+	}
+
+	if len(mismatched) > 0 {
+		return results, fmt.Errorf("row counts differ after copy: %s", strings.Join(mismatched, ", "))
+	}
+	return results, nil
+}
+
+// copyRows copies the rows of table that dst is missing, in batches ordered by id
+func copyRows(ctx context.Context, src, dst *DB, table dataTable, progress func(int)) error {
+	selectQuery := src.Rebind(table.selectQuery(`WHERE id > ?`) + ` LIMIT ?`)
+	insertQuery := table.insertQuery()
+
+	after := ""
+	for {
+		var rows []interface{}
+		var ids []string
+		err := table.scanRows(ctx, src, selectQuery, []interface{}{after, copyBatchSize}, func(row interface{}) error {
+			rows = append(rows, row)
+			ids = append(ids, table.rowID(row))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		copied := 0
+		err = dst.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+			existing, err := existingIDs(ctx, tx, table.name, ids)
+			if err != nil {
+				return err
+			}
+			for i, row := range rows {
+				if existing[ids[i]] {
+					continue
+				}
+				if _, err := tx.NamedExecContext(ctx, insertQuery, row); err != nil {
+					return fmt.Errorf("failed to copy %s %s: %w", table.name, ids[i], err)
+				}
+				copied++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		progress(copied)
+		after = ids[len(ids)-1]
+	}
+}
+
+// existingIDs returns which of ids are already rows of table
+func existingIDs(ctx context.Context, tx *sqlx.Tx, table string, ids []string) (map[string]bool, error) {
+	query, args, err := sqlx.In(`SELECT id FROM `+table+` WHERE id IN (?)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build lookup in %s: %w", table, err)
+	}
+
+	var found []string
+	if err := tx.SelectContext(ctx, &found, tx.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to look up existing %s: %w", table, err)
+	}
+
+	existing := make(map[string]bool, len(found))
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// copyWhole replaces the rows of table in dst with those of src
+// The target may already hold the default configuration written when an instance
+// started on it, and the revision numbering it.
+func copyWhole(ctx context.Context, src, dst *DB, table dataTable, progress func(int)) error {
+	var rows []interface{}
+	err := table.scanRows(ctx, src, table.selectQuery(""), nil, func(row interface{}) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	err = dst.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table.name); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table.name, err)
+		}
+		for _, row := range rows {
+			if _, err := tx.NamedExecContext(ctx, table.insertQuery(), row); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", table.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	progress(len(rows))
+	return nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test that a copy moves every table, can be rerun after an interruption and
+// replaces the target's default configuration
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	srcPath := "test_copy_source.db"
+	defer os.Remove(srcPath)
+
+	src, err := NewDB(srcPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to create source database: %v", err)
+	}
+	defer src.Close()
+
+	now := time.Now().Truncate(time.Second)
+	domains := NewDomainRepository(src)
+	httpChecks := NewHTTPCheckRepository(src)
+	var ids []string
+	for _, name := range []string{"a.com", "b.com", "c.com"} {
+		d := &domain.Domain{Name: name, ExpirationDate: now.Add(90 * 24 * time.Hour), Nameservers: domain.Strings{"ns1." + name}, LastChecked: now, NextCheck: now,
+			Metadata: domain.Metadata{Owner: "web team", Tags: domain.Strings{"shop", "tld-" + name[2:]}}}
+		if err := domains.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+		ids = append(ids, d.ID)
+
+		if err := NewAlertRepository(src).Create(ctx, &domain.Alert{DomainID: d.ID, DomainName: name, Threshold: 1, ExpirationDate: d.ExpirationDate, SentAt: now, Success: true}); err != nil {
+			t.Fatalf("Failed to create alert: %v", err)
+		}
+		c := &domain.HTTPCheck{DomainID: d.ID, URL: "https://" + name, ExpectedStatus: 200, NextCheck: now}
+		if err := httpChecks.Create(ctx, c); err != nil {
+			t.Fatalf("Failed to create http check: %v", err)
+		}
+		if err := httpChecks.CreateResult(ctx, &domain.HTTPCheckResult{CheckID: c.ID, CheckedAt: now, StatusCode: 200, Success: true}); err != nil {
+			t.Fatalf("Failed to create http check result: %v", err)
+		}
+		if _, err := NewLookalikeRepository(src).AddCandidates(ctx, d.ID, []*domain.Lookalike{{Name: "x" + name, Kind: "insertion"}}); err != nil {
+			t.Fatalf("Failed to create lookalike: %v", err)
+		}
+		if err := NewAuditRepository(src).Create(ctx, &domain.AuditEntry{Actor: "alice", Action: domain.AuditDomainCreate, ObjectType: domain.AuditObjectDomain, ObjectID: d.ID, ObjectName: name, After: `{"name":"` + name + `"}`}); err != nil {
+			t.Fatalf("Failed to create audit entry: %v", err)
+		}
+	}
+
+	config, err := NewConfigRepository(src).Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	config.GoogleChatWebhook = "https://chat.example.com/hook"
+	config.SetAlertThresholds([]time.Duration{14 * 24 * time.Hour})
+	if err := NewConfigRepository(src).Update(ctx, config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	forEachDriver(t, "test_copy_target.db", func(t *testing.T, dst *DB) {
+		// The target was started once and wrote its default configuration
+		if _, err := NewConfigRepository(dst).Get(ctx); err != nil {
+			t.Fatalf("Failed to get target config: %v", err)
+		}
+
+		results, err := Copy(ctx, src, dst, nil)
+		if err != nil {
+			t.Fatalf("Copy() unexpected error: %v", err)
+		}
+		for _, r := range results {
+			if r.Copied != r.Source || r.Source != r.Target || r.Source == 0 {
+				t.Errorf("Copy of %s = %+v, want every source row copied", r.Table, r)
+			}
+		}
+
+		got, err := NewDomainRepository(dst).GetByID(ctx, ids[0])
+		if err != nil {
+			t.Fatalf("Copied domain missing: %v", err)
+		}
+		if got.Name != "a.com" || len(got.Nameservers) != 1 || !got.ExpirationDate.Equal(now.Add(90*24*time.Hour)) ||
+			got.Owner != "web team" || strings.Join(got.Tags, ",") != "shop,tld-com" {
+			t.Errorf("Copied domain = %+v", got)
+		}
+		gotConfig, err := NewConfigRepository(dst).Get(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get copied config: %v", err)
+		}
+		if gotConfig.GoogleChatWebhook != config.GoogleChatWebhook || len(gotConfig.AlertThresholds) != 1 {
+			t.Errorf("Copied config = %+v, want the source config", gotConfig)
+		}
+
+		// A copy interrupted after the first domain resumes with the rest
+		if _, err := dst.ExecContext(ctx, dst.Rebind(`DELETE FROM domains WHERE id <> ?`), ids[0]); err != nil {
+			t.Fatalf("Failed to delete domains: %v", err)
+		}
+		results, err = Copy(ctx, src, dst, nil)
+		if err != nil {
+			t.Fatalf("Resumed Copy() unexpected error: %v", err)
+		}
+		if results[0].Table != "domains" || results[0].Copied != 2 {
+			t.Errorf("Resumed copy of domains = %+v, want 2 copied", results[0])
+		}
+
+		// A target with rows of its own fails the count check
+		extra := &domain.Domain{Name: "extra.com", ExpirationDate: now, LastChecked: now, NextCheck: now}
+		if err := NewDomainRepository(dst).Create(ctx, extra); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+		if _, err := Copy(ctx, src, dst, nil); err == nil || !strings.Contains(err.Error(), "domains (source 3, target 4)") {
+			t.Errorf("Copy() to a diverged target = %v, want a row count error", err)
+		}
+	})
+}
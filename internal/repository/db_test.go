@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Test that databases created before a column was introduced are upgraded on startup
+func TestMigrate_AddsMissingColumns(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_column_upgrade.db"
+	defer os.Remove(dbPath)
+
+	legacy, err := sqlx.Connect("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create legacy database: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE alerts (
+			id TEXT PRIMARY KEY,
+			domain_id TEXT NOT NULL,
+			domain_name TEXT NOT NULL,
+			threshold INTEGER NOT NULL,
+			expiration_date DATETIME NOT NULL,
+			sent_at DATETIME NOT NULL,
+			success INTEGER NOT NULL,
+			error_message TEXT NOT NULL
+		);
+		INSERT INTO alerts VALUES ('a1', 'd1', 'example.com', 1, '2030-01-01', '2029-12-01', 1, '');
+		INSERT INTO alerts VALUES ('a2', 'd1', 'example.com', 1, '2030-01-01', '2029-12-02', 0, 'racy duplicate');
+		CREATE TABLE domains (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			expiration_date DATETIME NOT NULL,
+			nameservers TEXT NOT NULL,
+			registrant TEXT NOT NULL,
+			registrar TEXT NOT NULL,
+			last_checked DATETIME NOT NULL,
+			next_check DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);
+		INSERT INTO domains VALUES ('d1', 'example.com', '2030-01-01', '[]', '', '', '2029-12-01', '2029-12-02', '2029-01-01', '2029-01-01');
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	db, err := NewDB(dbPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer db.Close()
+
+	alertRepo := NewAlertRepository(db)
+	alerts, err := alertRepo.GetByDomainID(ctx, "d1")
+	if err != nil {
+		t.Fatalf("Failed to query upgraded alerts table: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("Expected 2 existing alerts, got %d", len(alerts))
+	}
+	for _, a := range alerts {
+		if a.Type != "expiration" {
+			t.Errorf("Expected existing alert to default to expiration type, got %+v", a)
+		}
+	}
+
+	// The first alert takes the expiration dedup key, the duplicate its own ID
+	key := domain.ExpirationDedupKey("d1", 1, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	byID := map[string]*domain.Alert{alerts[0].ID: alerts[0], alerts[1].ID: alerts[1]}
+	if byID["a1"].DedupKey != key || byID["a1"].Status != domain.AlertStatusSent {
+		t.Errorf("a1 backfilled as %q/%q, want %q/sent", byID["a1"].DedupKey, byID["a1"].Status, key)
+	}
+	if byID["a2"].DedupKey != "a2" || byID["a2"].Status != domain.AlertStatusDead {
+		t.Errorf("a2 backfilled as %q/%q, want a2/dead", byID["a2"].DedupKey, byID["a2"].Status)
+	}
+
+	// An alert sent before the upgrade is not sent again
+	claimed, err := alertRepo.Claim(ctx, &domain.Alert{DomainID: "d1", DedupKey: key, SentAt: time.Now()}, time.Minute)
+	if err != nil || claimed {
+		t.Errorf("Claim() of a backfilled key = %v, %v; want false", claimed, err)
+	}
+
+	// Domains from before lifecycle states stay active
+	d, err := NewDomainRepository(db).GetByID(ctx, "d1")
+	if err != nil {
+		t.Fatalf("Failed to query upgraded domains table: %v", err)
+	}
+	if d.State != domain.StateActive || d.ArchivedAt != nil {
+		t.Errorf("Existing domain upgraded to state %q archived at %v, want active", d.State, d.ArchivedAt)
+	}
+
+	// Running migrations again must be a no-op
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Second migration failed: %v", err)
+	}
+}
+
+// Test that duplicate keys are recognized as constraint errors on every driver
+func TestIsConstraintError(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_constraint_error.db", func(t *testing.T, db *DB) {
+		insert := db.Rebind(`INSERT INTO leader_leases (name, holder, expires_at) VALUES (?, ?, ?)`)
+		if _, err := db.ExecContext(ctx, insert, "scheduler", "a", time.Now()); err != nil {
+			t.Fatalf("Insert unexpected error: %v", err)
+		}
+
+		_, err := db.ExecContext(ctx, insert, "scheduler", "b", time.Now())
+		if err == nil {
+			t.Fatal("Insert of a duplicate key succeeded")
+		}
+		if !IsConstraintError(err) {
+			t.Errorf("IsConstraintError(%v) = false, want true", err)
+		}
+
+		_, err = db.ExecContext(ctx, `SELECT * FROM missing_table`)
+		if err == nil {
+			t.Fatal("Query of a missing table succeeded")
+		}
+		if IsConstraintError(err) {
+			t.Errorf("IsConstraintError(%v) = true, want false", err)
+		}
+	})
+}
@@ -1,14 +1,23 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
+// domainColumns lists the columns selected for a domain.Domain
+const domainColumns = `id, name, expiration_date, nameservers, registrant, registrar,
+		       last_checked, next_check, created_at, updated_at,
+		       mode, registration_state, statuses, required_statuses,
+		       lease_owner, lease_until, check_attempts, state, archived_at,
+		       owner, criticality, notes, annual_cost_cents, lookup_failures`
+
 // DomainRepository handles domain data persistence
 type DomainRepository struct {
 	db *DB
@@ -20,11 +29,28 @@ func NewDomainRepository(db *DB) *DomainRepository {
 }
 
 // Create adds a new domain to the database
-func (r *DomainRepository) Create(d *domain.Domain) error {
+func (r *DomainRepository) Create(ctx context.Context, d *domain.Domain) error {
 	if d.ID == "" {
 		d.ID = uuid.New().String()
 	}
 	
+	if d.Mode == "" {
+		d.Mode = domain.ModeMonitor
+	}
+	if d.RegistrationState == "" {
+		d.RegistrationState = domain.RegistrationRegistered
+	}
+	if d.State == "" {
+		d.State = domain.StateActive
+	}
+	if d.Criticality == "" {
+		d.Criticality = domain.CriticalityNormal
+	}
+	d.Tags = domain.NormalizeTags(d.Tags)
+	if err := d.Metadata.Validate(); err != nil {
+		return err
+	}
+
 	now := time.Now()
 	d.CreatedAt = now
 	d.UpdatedAt = now
@@ -32,36 +58,43 @@ func (r *DomainRepository) Create(d *domain.Domain) error {
 	query := `
 		INSERT INTO domains (
 			id, name, expiration_date, nameservers, registrant, registrar,
-			last_checked, next_check, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			last_checked, next_check, created_at, updated_at,
+			mode, registration_state, statuses, required_statuses,
+			state, archived_at,
+			owner, criticality, notes, annual_cost_cents, lookup_failures
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.Exec(query,
-		d.ID, d.Name, d.ExpirationDate, d.Nameservers, d.Registrant, d.Registrar,
-		d.LastChecked, d.NextCheck, d.CreatedAt, d.UpdatedAt,
-	)
-
-	if err != nil {
-		if IsConstraintError(err) {
-			return fmt.Errorf("domain %s already exists", d.Name)
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, r.db.Rebind(query),
+			d.ID, d.Name, d.ExpirationDate, d.Nameservers, d.Registrant, d.Registrar,
+			d.LastChecked, d.NextCheck, d.CreatedAt, d.UpdatedAt,
+			d.Mode, d.RegistrationState, d.Statuses, d.RequiredStatuses,
+			d.State, d.ArchivedAt,
+			d.Owner, d.Criticality, d.Notes, d.AnnualCostCents, d.LookupFailures,
+		)
+
+		if err != nil {
+			if IsConstraintError(err) {
+				return fmt.Errorf("domain %s already exists", d.Name)
+			}
+			return fmt.Errorf("failed to create domain: %w", err)
 		}
-		return fmt.Errorf("failed to create domain: %w", err)
-	}
 
-	return nil
+		return r.db.setTags(ctx, tx, d.ID, d.Tags)
+	})
 }
 
 // GetByID retrieves a domain by its ID
-func (r *DomainRepository) GetByID(id string) (*domain.Domain, error) {
+func (r *DomainRepository) GetByID(ctx context.Context, id string) (*domain.Domain, error) {
 	var d domain.Domain
 	query := `
-		SELECT id, name, expiration_date, nameservers, registrant, registrar,
-		       last_checked, next_check, created_at, updated_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		WHERE id = ?
 	`
 
-	err := r.db.Get(&d, query, id)
+	err := r.db.GetContext(ctx, &d, r.db.Rebind(query), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("domain not found: %s", id)
@@ -69,20 +102,23 @@ func (r *DomainRepository) GetByID(id string) (*domain.Domain, error) {
 		return nil, fmt.Errorf("failed to get domain: %w", err)
 	}
 
+	if err := r.db.loadTags(ctx, r.db, []*domain.Domain{&d}); err != nil {
+		return nil, err
+	}
+
 	return &d, nil
 }
 
 // GetByName retrieves a domain by its name
-func (r *DomainRepository) GetByName(name string) (*domain.Domain, error) {
+func (r *DomainRepository) GetByName(ctx context.Context, name string) (*domain.Domain, error) {
 	var d domain.Domain
 	query := `
-		SELECT id, name, expiration_date, nameservers, registrant, registrar,
-		       last_checked, next_check, created_at, updated_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		WHERE name = ?
 	`
 
-	err := r.db.Get(&d, query, name)
+	err := r.db.GetContext(ctx, &d, r.db.Rebind(query), name)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("domain not found: %s", name)
@@ -90,45 +126,112 @@ func (r *DomainRepository) GetByName(name string) (*domain.Domain, error) {
 		return nil, fmt.Errorf("failed to get domain: %w", err)
 	}
 
+	if err := r.db.loadTags(ctx, r.db, []*domain.Domain{&d}); err != nil {
+		return nil, err
+	}
+
 	return &d, nil
 }
 
 // GetAll retrieves all domains
-func (r *DomainRepository) GetAll() ([]*domain.Domain, error) {
+func (r *DomainRepository) GetAll(ctx context.Context) ([]*domain.Domain, error) {
 	var domains []*domain.Domain
 	query := `
-		SELECT id, name, expiration_date, nameservers, registrant, registrar,
-		       last_checked, next_check, created_at, updated_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		ORDER BY expiration_date ASC
 	`
 
-	err := r.db.Select(&domains, query)
+	err := r.db.SelectContext(ctx, &domains, r.db.Rebind(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all domains: %w", err)
 	}
 
+	if err := r.db.loadTags(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+
 	return domains, nil
 }
 
 // Update updates an existing domain
-func (r *DomainRepository) Update(d *domain.Domain) error {
+// Required statuses, the lifecycle state and the metadata are user settings and are
+// only changed by UpdateRequiredStatuses, SetState and UpdateMetadata
+func (r *DomainRepository) Update(ctx context.Context, d *domain.Domain) error {
+	rows, err := r.update(ctx, d, "", time.Time{})
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("domain not found: %s", d.ID)
+	}
+
+	return nil
+}
+
+// UpdateLeased updates a domain like Update, but only while owner holds a live check
+// lease on it at now. It returns ErrLeaseLost once the lease ran out, so a check that
+// overran its lease cannot overwrite a newer result.
+func (r *DomainRepository) UpdateLeased(ctx context.Context, d *domain.Domain, owner string, now time.Time) error {
+	rows, err := r.update(ctx, d, owner, now)
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// update saves the checked fields of a domain, if owner is set only while owner
+// holds a live lease at now
+func (r *DomainRepository) update(ctx context.Context, d *domain.Domain, owner string, now time.Time) (int64, error) {
 	d.UpdatedAt = time.Now()
 
 	query := `
 		UPDATE domains
 		SET name = ?, expiration_date = ?, nameservers = ?, registrant = ?,
-		    registrar = ?, last_checked = ?, next_check = ?, updated_at = ?
+		    registrar = ?, last_checked = ?, next_check = ?, updated_at = ?,
+		    mode = ?, registration_state = ?, statuses = ?, lookup_failures = ?
 		WHERE id = ?
 	`
-
-	result, err := r.db.Exec(query,
+	args := []interface{}{
 		d.Name, d.ExpirationDate, d.Nameservers, d.Registrant, d.Registrar,
-		d.LastChecked, d.NextCheck, d.UpdatedAt, d.ID,
-	)
+		d.LastChecked, d.NextCheck, d.UpdatedAt,
+		d.Mode, d.RegistrationState, d.Statuses, d.LookupFailures, d.ID,
+	}
+	if owner != "" {
+		query += ` AND ` + leaseHeld
+		args = append(args, owner, now)
+	}
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update domain: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// UpdateRequiredStatuses sets the EPP statuses a domain is required to have
+func (r *DomainRepository) UpdateRequiredStatuses(ctx context.Context, id string, statuses domain.Strings) error {
+	if statuses == nil {
+		statuses = domain.Strings{}
+	}
 
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE domains SET required_statuses = ?, updated_at = ? WHERE id = ?`),
+		statuses, time.Now(), id,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to update domain: %w", err)
+		return fmt.Errorf("failed to update required statuses: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -137,19 +240,64 @@ func (r *DomainRepository) Update(d *domain.Domain) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("domain not found: %s", d.ID)
+		return fmt.Errorf("domain not found: %s", id)
 	}
 
 	return nil
 }
 
-// Delete removes a domain from the database
-func (r *DomainRepository) Delete(id string) error {
-	query := `DELETE FROM domains WHERE id = ?`
+// UpdateMetadata replaces the owner, criticality, notes, annual cost and tags of a domain
+func (r *DomainRepository) UpdateMetadata(ctx context.Context, id string, m domain.Metadata) error {
+	m.Tags = domain.NormalizeTags(m.Tags)
+	if err := m.Validate(); err != nil {
+		return err
+	}
 
-	result, err := r.db.Exec(query, id)
+	query := `
+		UPDATE domains
+		SET owner = ?, criticality = ?, notes = ?, annual_cost_cents = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, r.db.Rebind(query),
+			m.Owner, m.Criticality, m.Notes, m.AnnualCostCents, time.Now(), id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update domain metadata: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rows == 0 {
+			return fmt.Errorf("domain not found: %s", id)
+		}
+
+		return r.db.setTags(ctx, tx, id, m.Tags)
+	})
+}
+
+// SetState moves a domain to another lifecycle state
+// Archiving records when it happened so retention can purge the domain later;
+// leaving the archive clears it.
+func (r *DomainRepository) SetState(ctx context.Context, id, state string, now time.Time) error {
+	if !domain.ValidState(state) {
+		return fmt.Errorf("invalid domain state: %s", state)
+	}
+
+	var archivedAt *time.Time
+	if state == domain.StateArchived {
+		archivedAt = &now
+	}
+
+	query := `UPDATE domains SET state = ?, archived_at = ?, updated_at = ? WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), state, archivedAt, now, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete domain: %w", err)
+		return fmt.Errorf("failed to set domain state: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -164,38 +312,205 @@ func (r *DomainRepository) Delete(id string) error {
 	return nil
 }
 
-// DeleteOlderThan deletes domains that were created before the cutoff time
-// This is used for retention policy, but excludes actively monitored domains
-func (r *DomainRepository) DeleteOlderThan(cutoff time.Time) error {
-	query := `DELETE FROM domains WHERE created_at < ? AND id NOT IN (SELECT id FROM domains)`
-	
-	// Note: This query is simplified. In practice, we'd need a way to mark domains as "inactive"
-	// For now, we won't delete any domains that are in the domains table (all are considered active)
-	// A proper implementation would have an "active" flag or separate table for inactive domains
-	
-	_, err := r.db.Exec(query, cutoff)
-	if err != nil {
-		return fmt.Errorf("failed to delete old domains: %w", err)
+// Delete removes a domain from the database along with its alerts, HTTP checks, lookalikes and tags
+func (r *DomainRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		// Dependent rows are removed explicitly because SQLite does not enforce
+		// foreign key cascades unless enabled per connection
+		dependents := []string{
+			`DELETE FROM alerts WHERE domain_id = ?`,
+			`DELETE FROM http_check_results WHERE check_id IN (SELECT id FROM http_checks WHERE domain_id = ?)`,
+			`DELETE FROM http_checks WHERE domain_id = ?`,
+			`DELETE FROM lookalikes WHERE domain_id = ?`,
+			`DELETE FROM domain_tags WHERE domain_id = ?`,
+		}
+		for _, query := range dependents {
+			if _, err := tx.ExecContext(ctx, r.db.Rebind(query), id); err != nil {
+				return fmt.Errorf("failed to delete domain dependents: %w", err)
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM domains WHERE id = ?`), id)
+		if err != nil {
+			return fmt.Errorf("failed to delete domain: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rows == 0 {
+			return fmt.Errorf("domain not found: %s", id)
+		}
+
+		return r.db.pruneTags(ctx, tx)
+	})
+}
+
+// CountOlderThan counts the domains DeleteOlderThan would delete at the cutoff
+func (r *DomainRepository) CountOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM domains WHERE state = ? AND archived_at < ?`
+	if err := r.db.GetContext(ctx, &count, r.db.Rebind(query), domain.StateArchived, cutoff); err != nil {
+		return 0, fmt.Errorf("failed to count expired archived domains: %w", err)
 	}
+	return count, nil
+}
 
-	return nil
+// DeleteOlderThan deletes up to limit domains archived before the cutoff time,
+// with their history, and returns how many were deleted. Active and paused
+// domains are never deleted by retention.
+func (r *DomainRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	var ids []string
+	query := `SELECT id FROM domains WHERE state = ? AND archived_at < ? ORDER BY archived_at ASC LIMIT ?`
+	if err := r.db.SelectContext(ctx, &ids, r.db.Rebind(query), domain.StateArchived, cutoff, limit); err != nil {
+		return 0, fmt.Errorf("failed to find expired archived domains: %w", err)
+	}
+
+	for i, id := range ids {
+		if err := r.Delete(ctx, id); err != nil {
+			return i, fmt.Errorf("failed to delete old domains: %w", err)
+		}
+	}
+
+	return len(ids), nil
 }
 
-// GetDomainsForCheck retrieves domains that need to be checked
-func (r *DomainRepository) GetDomainsForCheck() ([]*domain.Domain, error) {
+// GetDomainsForCheck retrieves up to limit active domains whose check is due and
+// not leased by a running check, most overdue first
+func (r *DomainRepository) GetDomainsForCheck(ctx context.Context, now time.Time, limit int) ([]*domain.Domain, error) {
 	var domains []*domain.Domain
 	query := `
-		SELECT id, name, expiration_date, nameservers, registrant, registrar,
-		       last_checked, next_check, created_at, updated_at
+		SELECT ` + domainColumns + `
 		FROM domains
-		WHERE next_check <= ?
+		WHERE state = ? AND next_check <= ? AND (lease_until IS NULL OR lease_until < ?)
 		ORDER BY next_check ASC
+		LIMIT ?
 	`
 
-	err := r.db.Select(&domains, query, time.Now())
+	err := r.db.SelectContext(ctx, &domains, r.db.Rebind(query), domain.StateActive, now, now, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get domains for check: %w", err)
 	}
 
+	if err := r.db.loadTags(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+
 	return domains, nil
 }
+
+// leaseHeld is the condition, taking the owner and now, that a domain's check lease
+// is live and held by owner. Claims use a fresh owner token each, so a check that
+// overran its lease fails it even when the same instance claimed the domain again.
+const leaseHeld = `lease_owner = ? AND lease_until >= ?`
+
+// ClaimDomain leases a due domain to owner until now+lease and counts the attempt
+// It reports false when the domain is no longer due or active or another owner holds a live lease,
+// so only one scheduler instance checks a domain at a time. A lease that runs out,
+// because its owner crashed or the check hung, makes the domain claimable again.
+// owner should be unique to the claim, as the writes made under the lease check it.
+func (r *DomainRepository) ClaimDomain(ctx context.Context, id, owner string, now time.Time, lease time.Duration) (bool, error) {
+	query := `
+		UPDATE domains
+		SET lease_owner = ?, lease_until = ?, check_attempts = check_attempts + 1
+		WHERE id = ? AND state = ? AND next_check <= ? AND (lease_until IS NULL OR lease_until < ?)
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), owner, now.Add(lease), id, domain.StateActive, now, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim domain: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ClaimDomainNow leases an active domain to owner for a check requested ahead of
+// its schedule. It fails only while another check holds the lease, and starts the
+// attempt count afresh since the check was asked for explicitly.
+func (r *DomainRepository) ClaimDomainNow(ctx context.Context, id, owner string, now time.Time, lease time.Duration) (bool, error) {
+	query := `
+		UPDATE domains
+		SET lease_owner = ?, lease_until = ?, check_attempts = 1
+		WHERE id = ? AND state = ? AND (lease_until IS NULL OR lease_until < ?)
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), owner, now.Add(lease), id, domain.StateActive, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim domain: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// DeferCheck ends owner's live lease on a domain whose check could not run yet and
+// reschedules it without counting the claim as an attempt
+func (r *DomainRepository) DeferCheck(ctx context.Context, id, owner string, now, nextCheck time.Time) error {
+	query := `
+		UPDATE domains
+		SET next_check = ?, lease_owner = '', lease_until = NULL,
+		    check_attempts = CASE WHEN check_attempts > 0 THEN check_attempts - 1 ELSE 0 END
+		WHERE id = ? AND ` + leaseHeld
+
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(query), nextCheck, id, owner, now); err != nil {
+		return fmt.Errorf("failed to defer domain check: %w", err)
+	}
+
+	return nil
+}
+
+// QueueCheck makes an active domain due at now so the scheduler instance running
+// checks picks it up on its next poll; a check due sooner is left as it is
+func (r *DomainRepository) QueueCheck(ctx context.Context, id string, now time.Time) error {
+	query := `
+		UPDATE domains
+		SET next_check = ?
+		WHERE id = ? AND state = ? AND next_check > ?
+	`
+
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(query), now, id, domain.StateActive, now); err != nil {
+		return fmt.Errorf("failed to queue domain check: %w", err)
+	}
+
+	return nil
+}
+
+// RetryCheck ends owner's live lease on a domain whose check failed and schedules the
+// retry, keeping the attempt count so repeated failures are limited
+func (r *DomainRepository) RetryCheck(ctx context.Context, id, owner string, now, nextCheck time.Time) error {
+	query := `
+		UPDATE domains
+		SET next_check = ?, lease_owner = '', lease_until = NULL
+		WHERE id = ? AND ` + leaseHeld
+
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(query), nextCheck, id, owner, now); err != nil {
+		return fmt.Errorf("failed to schedule domain check retry: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseDomain ends owner's live lease on a domain after a completed check and resets its attempts
+func (r *DomainRepository) ReleaseDomain(ctx context.Context, id, owner string, now time.Time) error {
+	query := `
+		UPDATE domains
+		SET lease_owner = '', lease_until = NULL, check_attempts = 0
+		WHERE id = ? AND ` + leaseHeld
+
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(query), id, owner, now); err != nil {
+		return fmt.Errorf("failed to release domain: %w", err)
+	}
+
+	return nil
+}
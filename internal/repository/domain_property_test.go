@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -16,57 +17,50 @@ import (
 // should result in the domain being retrievable from the database with all its WHOIS information populated.
 // Validates: Requirements 4.1, 4.2
 func TestProperty_DomainAdditionAndRetrieval(t *testing.T) {
-	// Create temporary database for testing
-	dbPath := "test_domain_addition.db"
-	defer os.Remove(dbPath)
-
-	db, err := NewDB(dbPath, "sqlite3")
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
-	defer db.Close()
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_addition.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
 
-	repo := NewDomainRepository(db)
-
-	properties := gopter.NewProperties(nil)
+		properties := gopter.NewProperties(nil)
 
-	counter := 0
-	properties.Property("domain addition and retrieval preserves all fields", prop.ForAll(
-		func(registrant string, registrar string) bool {
-			counter++
-			// Create a domain with generated data and unique name
-			d := &domain.Domain{
-				Name:           time.Now().Format("20060102150405") + string(rune(counter)) + ".com",
-				ExpirationDate: time.Now().Add(365 * 24 * time.Hour),
-				Nameservers:    domain.Strings{"ns1.example.com", "ns2.example.com"},
-				Registrant:     registrant,
-				Registrar:      registrar,
-				LastChecked:    time.Now(),
-				NextCheck:      time.Now().Add(24 * time.Hour),
-			}
-
-			// Add domain to database
-			if err := repo.Create(d); err != nil {
-				return false
-			}
+		counter := 0
+		properties.Property("domain addition and retrieval preserves all fields", prop.ForAll(
+			func(registrant string, registrar string) bool {
+				counter++
+				// Create a domain with generated data and unique name
+				d := &domain.Domain{
+					Name:           time.Now().Format("20060102150405") + string(rune(counter)) + ".com",
+					ExpirationDate: time.Now().Add(365 * 24 * time.Hour),
+					Nameservers:    domain.Strings{"ns1.example.com", "ns2.example.com"},
+					Registrant:     registrant,
+					Registrar:      registrar,
+					LastChecked:    time.Now(),
+					NextCheck:      time.Now().Add(24 * time.Hour),
+				}
 
-			// Retrieve domain by ID
-			retrieved, err := repo.GetByID(d.ID)
-			if err != nil {
-				return false
-			}
+				// Add domain to database
+				if err := repo.Create(ctx, d); err != nil {
+					return false
+				}
 
-			// Verify all fields match
-			return retrieved.Name == d.Name &&
-				retrieved.Registrant == d.Registrant &&
-				retrieved.Registrar == d.Registrar &&
-				len(retrieved.Nameservers) == len(d.Nameservers)
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) < 100 }),
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) < 100 }),
-	))
+				// Retrieve domain by ID
+				retrieved, err := repo.GetByID(ctx, d.ID)
+				if err != nil {
+					return false
+				}
 
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
+				// Verify all fields match
+				return retrieved.Name == d.Name &&
+					retrieved.Registrant == d.Registrant &&
+					retrieved.Registrar == d.Registrar &&
+					len(retrieved.Nameservers) == len(d.Nameservers)
+			},
+			gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) < 100 }),
+			gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) < 100 }),
+		))
+
+		properties.TestingRun(t, gopter.ConsoleReporter(false))
+	})
 }
 
 // Feature: domain-expiration-monitor, Property 17: Startup domain loading
@@ -74,74 +68,67 @@ func TestProperty_DomainAdditionAndRetrieval(t *testing.T) {
 // should load all of them into the active monitoring list.
 // Validates: Requirements 10.1
 func TestProperty_StartupDomainLoading(t *testing.T) {
-	// Create temporary database for testing
-	dbPath := "test_startup_loading.db"
-	defer os.Remove(dbPath)
-
-	db, err := NewDB(dbPath, "sqlite3")
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
-	defer db.Close()
-
-	repo := NewDomainRepository(db)
+	ctx := context.Background()
+	forEachDriver(t, "test_startup_loading.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
 
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("all persisted domains can be loaded", prop.ForAll(
-		func(count uint8) bool {
-			if count == 0 {
-				return true
-			}
+		properties := gopter.NewProperties(nil)
 
-			// Create multiple domains
-			domainCount := int(count) % 20 // Limit to 20 domains per test
-			if domainCount == 0 {
-				domainCount = 1
-			}
+		properties.Property("all persisted domains can be loaded", prop.ForAll(
+			func(count uint8) bool {
+				if count == 0 {
+					return true
+				}
 
-			createdIDs := make(map[string]bool)
-			for i := 0; i < domainCount; i++ {
-				d := &domain.Domain{
-					Name:           time.Now().Format("20060102150405") + string(rune(i)) + ".com",
-					ExpirationDate: time.Now().Add(365 * 24 * time.Hour),
-					Nameservers:    domain.Strings{"ns1.example.com"},
-					Registrant:     "Test Registrant",
-					Registrar:      "Test Registrar",
-					LastChecked:    time.Now(),
-					NextCheck:      time.Now().Add(24 * time.Hour),
+				// Create multiple domains
+				domainCount := int(count) % 20 // Limit to 20 domains per test
+				if domainCount == 0 {
+					domainCount = 1
 				}
 
-				if err := repo.Create(d); err != nil {
-					continue // Skip duplicates
+				createdIDs := make(map[string]bool)
+				for i := 0; i < domainCount; i++ {
+					d := &domain.Domain{
+						Name:           time.Now().Format("20060102150405") + string(rune(i)) + ".com",
+						ExpirationDate: time.Now().Add(365 * 24 * time.Hour),
+						Nameservers:    domain.Strings{"ns1.example.com"},
+						Registrant:     "Test Registrant",
+						Registrar:      "Test Registrar",
+						LastChecked:    time.Now(),
+						NextCheck:      time.Now().Add(24 * time.Hour),
+					}
+
+					if err := repo.Create(ctx, d); err != nil {
+						continue // Skip duplicates
+					}
+					createdIDs[d.ID] = true
 				}
-				createdIDs[d.ID] = true
-			}
 
-			// Load all domains
-			loaded, err := repo.GetAll()
-			if err != nil {
-				return false
-			}
+				// Load all domains
+				loaded, err := repo.GetAll(ctx)
+				if err != nil {
+					return false
+				}
 
-			// Verify all created domains are in the loaded set
-			loadedIDs := make(map[string]bool)
-			for _, d := range loaded {
-				loadedIDs[d.ID] = true
-			}
+				// Verify all created domains are in the loaded set
+				loadedIDs := make(map[string]bool)
+				for _, d := range loaded {
+					loadedIDs[d.ID] = true
+				}
 
-			for id := range createdIDs {
-				if !loadedIDs[id] {
-					return false
+				for id := range createdIDs {
+					if !loadedIDs[id] {
+						return false
+					}
 				}
-			}
 
-			return true
-		},
-		gen.UInt8(),
-	))
+				return true
+			},
+			gen.UInt8(),
+		))
 
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
+		properties.TestingRun(t, gopter.ConsoleReporter(false))
+	})
 }
 
 // Feature: domain-expiration-monitor, Property 20: Graceful shutdown persistence
@@ -149,6 +136,7 @@ func TestProperty_StartupDomainLoading(t *testing.T) {
 // and then restarting should restore the same state.
 // Validates: Requirements 10.5
 func TestProperty_GracefulShutdownPersistence(t *testing.T) {
+	ctx := context.Background()
 	// Use a single database for all property tests
 	dbPath := "test_shutdown_persistence.db"
 	defer os.Remove(dbPath)
@@ -178,7 +166,7 @@ func TestProperty_GracefulShutdownPersistence(t *testing.T) {
 				NextCheck:      time.Now().Add(24 * time.Hour),
 			}
 
-			if err := repo1.Create(d); err != nil {
+			if err := repo1.Create(ctx, d); err != nil {
 				db1.Close()
 				return false
 			}
@@ -196,7 +184,7 @@ func TestProperty_GracefulShutdownPersistence(t *testing.T) {
 			defer db2.Close()
 
 			repo2 := NewDomainRepository(db2)
-			retrieved, err := repo2.GetByID(originalID)
+			retrieved, err := repo2.GetByID(ctx, originalID)
 			if err != nil {
 				return false
 			}
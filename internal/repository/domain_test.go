@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test that statuses round-trip and that scheduler updates keep the user's required statuses
+func TestDomainRepository_RequiredStatuses(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_statuses.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
+
+		d := &domain.Domain{
+			Name:           "example.com",
+			ExpirationDate: time.Now().Add(365 * 24 * time.Hour),
+			Nameservers:    domain.Strings{"ns1.example.com"},
+			LastChecked:    time.Now(),
+			NextCheck:      time.Now(),
+			Statuses:       domain.Strings{domain.StatusClientTransferProhibited},
+		}
+		if err := repo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+
+		// A stale copy, as held by a running check
+		stale, err := repo.GetByID(ctx, d.ID)
+		if err != nil {
+			t.Fatalf("Failed to get domain: %v", err)
+		}
+
+		required := domain.Strings{domain.StatusClientTransferProhibited, domain.StatusServerUpdateProhibited}
+		if err := repo.UpdateRequiredStatuses(ctx, d.ID, required); err != nil {
+			t.Fatalf("UpdateRequiredStatuses() unexpected error: %v", err)
+		}
+
+		stale.Statuses = domain.Strings{domain.StatusClientTransferProhibited, domain.StatusServerUpdateProhibited}
+		if err := repo.Update(ctx, stale); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+
+		got, err := repo.GetByID(ctx, d.ID)
+		if err != nil {
+			t.Fatalf("Failed to get domain: %v", err)
+		}
+		if len(got.Statuses) != 2 || !got.HasStatus(domain.StatusServerUpdateProhibited) {
+			t.Errorf("Statuses = %v, want the updated statuses", got.Statuses)
+		}
+		if len(got.RequiredStatuses) != 2 || len(got.MissingRequiredStatuses()) != 0 {
+			t.Errorf("RequiredStatuses = %v, want %v", got.RequiredStatuses, required)
+		}
+
+		if err := repo.UpdateRequiredStatuses(ctx, "missing", nil); err == nil {
+			t.Error("UpdateRequiredStatuses() expected an error for an unknown domain")
+		}
+	})
+}
+
+// Test that a due domain is leased to one owner at a time and becomes claimable
+// again when the lease runs out
+func TestDomainRepository_ClaimDomain(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_leases.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
+
+		now := time.Now()
+		due := &domain.Domain{Name: "due.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(-time.Minute)}
+		later := &domain.Domain{Name: "later.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(time.Hour)}
+		for _, d := range []*domain.Domain{due, later} {
+			if err := repo.Create(ctx, d); err != nil {
+				t.Fatalf("Failed to create domain: %v", err)
+			}
+		}
+
+		domains, err := repo.GetDomainsForCheck(ctx, now, 10)
+		if err != nil {
+			t.Fatalf("GetDomainsForCheck() unexpected error: %v", err)
+		}
+		if len(domains) != 1 || domains[0].ID != due.ID {
+			t.Fatalf("GetDomainsForCheck() = %d domains, want only %s", len(domains), due.Name)
+		}
+
+		if claimed, err := repo.ClaimDomain(ctx, later.ID, "worker-a", now, time.Minute); err != nil || claimed {
+			t.Fatalf("ClaimDomain() on a domain that is not due = %v, %v; want false", claimed, err)
+		}
+		if claimed, err := repo.ClaimDomain(ctx, due.ID, "worker-a", now, time.Minute); err != nil || !claimed {
+			t.Fatalf("ClaimDomain() = %v, %v; want true", claimed, err)
+		}
+		if claimed, err := repo.ClaimDomain(ctx, due.ID, "worker-b", now, time.Minute); err != nil || claimed {
+			t.Fatalf("ClaimDomain() on a leased domain = %v, %v; want false", claimed, err)
+		}
+
+		domains, err = repo.GetDomainsForCheck(ctx, now, 10)
+		if err != nil {
+			t.Fatalf("GetDomainsForCheck() unexpected error: %v", err)
+		}
+		if len(domains) != 0 {
+			t.Fatalf("GetDomainsForCheck() returned %d leased domains", len(domains))
+		}
+
+		// worker-a crashed; its lease expires and worker-b retries
+		expired := now.Add(2 * time.Minute)
+		if claimed, err := repo.ClaimDomain(ctx, due.ID, "worker-b", expired, time.Minute); err != nil || !claimed {
+			t.Fatalf("ClaimDomain() after lease expiry = %v, %v; want true", claimed, err)
+		}
+
+		got, err := repo.GetByID(ctx, due.ID)
+		if err != nil {
+			t.Fatalf("Failed to get domain: %v", err)
+		}
+		if got.LeaseOwner != "worker-b" || got.CheckAttempts != 2 {
+			t.Errorf("Lease = %q with %d attempts, want worker-b with 2", got.LeaseOwner, got.CheckAttempts)
+		}
+
+		// A stale owner cannot release someone else's lease
+		if err := repo.ReleaseDomain(ctx, due.ID, "worker-a", expired); err != nil {
+			t.Fatalf("ReleaseDomain() unexpected error: %v", err)
+		}
+		if got, _ := repo.GetByID(ctx, due.ID); got.LeaseOwner != "worker-b" {
+			t.Errorf("Lease owner = %q after a stale release, want worker-b", got.LeaseOwner)
+		}
+
+		if err := repo.ReleaseDomain(ctx, due.ID, "worker-b", expired); err != nil {
+			t.Fatalf("ReleaseDomain() unexpected error: %v", err)
+		}
+		got, err = repo.GetByID(ctx, due.ID)
+		if err != nil {
+			t.Fatalf("Failed to get domain: %v", err)
+		}
+		if got.LeaseOwner != "" || got.LeaseUntil != nil || got.CheckAttempts != 0 {
+			t.Errorf("Released domain still leased: owner %q until %v attempts %d", got.LeaseOwner, got.LeaseUntil, got.CheckAttempts)
+		}
+	})
+}
+
+// Test that a deferred check is rescheduled without counting as an attempt
+func TestDomainRepository_DeferCheck(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_defer.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
+
+		now := time.Now()
+		d := &domain.Domain{Name: "limited.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(-time.Minute)}
+		if err := repo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+
+		if claimed, err := repo.ClaimDomain(ctx, d.ID, "worker-a", now, time.Minute); err != nil || !claimed {
+			t.Fatalf("ClaimDomain() = %v, %v; want true", claimed, err)
+		}
+
+		retryAt := now.Add(30 * time.Second)
+		if err := repo.DeferCheck(ctx, d.ID, "worker-a", now, retryAt); err != nil {
+			t.Fatalf("DeferCheck() unexpected error: %v", err)
+		}
+
+		got, err := repo.GetByID(ctx, d.ID)
+		if err != nil {
+			t.Fatalf("Failed to get domain: %v", err)
+		}
+		if got.LeaseOwner != "" || got.CheckAttempts != 0 || !got.NextCheck.Equal(retryAt.Truncate(time.Second)) && got.NextCheck.Sub(retryAt).Abs() > time.Second {
+			t.Errorf("Deferred domain: owner %q attempts %d next check %v, want unleased, 0 attempts, %v", got.LeaseOwner, got.CheckAttempts, got.NextCheck, retryAt)
+		}
+
+		if claimed, err := repo.ClaimDomain(ctx, d.ID, "worker-b", now, time.Minute); err != nil || claimed {
+			t.Errorf("ClaimDomain() before the deferred time = %v, %v; want false", claimed, err)
+		}
+	})
+}
+
+// Test that a manual check can claim a domain that is not due, but not one
+// another check is running for
+func TestDomainRepository_ClaimDomainNow(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_claim_now.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
+
+		now := time.Now()
+		d := &domain.Domain{Name: "manual.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(24 * time.Hour), CheckAttempts: 4}
+		if err := repo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+
+		if claimed, err := repo.ClaimDomain(ctx, d.ID, "worker-a", now, time.Minute); err != nil || claimed {
+			t.Fatalf("ClaimDomain() before next check = %v, %v; want false", claimed, err)
+		}
+		if claimed, err := repo.ClaimDomainNow(ctx, d.ID, "worker-a", now, time.Minute); err != nil || !claimed {
+			t.Fatalf("ClaimDomainNow() = %v, %v; want true", claimed, err)
+		}
+		if claimed, err := repo.ClaimDomainNow(ctx, d.ID, "worker-b", now, time.Minute); err != nil || claimed {
+			t.Errorf("ClaimDomainNow() while leased = %v, %v; want false", claimed, err)
+		}
+
+		got, err := repo.GetByID(ctx, d.ID)
+		if err != nil {
+			t.Fatalf("Failed to get domain: %v", err)
+		}
+		if got.LeaseOwner != "worker-a" || got.CheckAttempts != 1 {
+			t.Errorf("Claimed domain: owner %q attempts %d, want worker-a and 1 attempt", got.LeaseOwner, got.CheckAttempts)
+		}
+
+		if claimed, err := repo.ClaimDomainNow(ctx, d.ID, "worker-b", now.Add(2*time.Minute), time.Minute); err != nil || !claimed {
+			t.Errorf("ClaimDomainNow() after the lease expired = %v, %v; want true", claimed, err)
+		}
+	})
+}
+
+// Test that paused and archived domains are not handed out for checks and that
+// archiving records when it happened
+func TestDomainRepository_SetState(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_state.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
+		httpCheckRepo := NewHTTPCheckRepository(db)
+
+		now := time.Now()
+		d := &domain.Domain{Name: "paused.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(-time.Minute)}
+		if err := repo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+		if d.State != domain.StateActive {
+			t.Fatalf("New domain state = %q, want active", d.State)
+		}
+		if err := httpCheckRepo.Create(ctx, &domain.HTTPCheck{DomainID: d.ID, URL: "https://paused.com/", NextCheck: now.Add(-time.Minute)}); err != nil {
+			t.Fatalf("Failed to create HTTP check: %v", err)
+		}
+
+		due := func() (int, int) {
+			domains, err := repo.GetDomainsForCheck(ctx, now, 10)
+			if err != nil {
+				t.Fatalf("GetDomainsForCheck() unexpected error: %v", err)
+			}
+			checks, err := httpCheckRepo.GetDueChecks(ctx, now)
+			if err != nil {
+				t.Fatalf("GetDueChecks() unexpected error: %v", err)
+			}
+			return len(domains), len(checks)
+		}
+
+		tests := []struct {
+			state        string
+			wantDue      int
+			wantArchived bool
+		}{
+			{state: domain.StatePaused, wantDue: 0},
+			{state: domain.StateArchived, wantDue: 0, wantArchived: true},
+			{state: domain.StateActive, wantDue: 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.state, func(t *testing.T) {
+				if err := repo.SetState(ctx, d.ID, tt.state, now); err != nil {
+					t.Fatalf("SetState() unexpected error: %v", err)
+				}
+
+				got, err := repo.GetByID(ctx, d.ID)
+				if err != nil {
+					t.Fatalf("Failed to get domain: %v", err)
+				}
+				if got.State != tt.state || (got.ArchivedAt != nil) != tt.wantArchived {
+					t.Errorf("State %q archived at %v, want %q archived %v", got.State, got.ArchivedAt, tt.state, tt.wantArchived)
+				}
+
+				if domains, checks := due(); domains != tt.wantDue || checks != tt.wantDue {
+					t.Errorf("Due domains %d and HTTP checks %d, want %d", domains, checks, tt.wantDue)
+				}
+
+				claimed, err := repo.ClaimDomainNow(ctx, d.ID, "worker", now, time.Minute)
+				if err != nil || claimed != (tt.wantDue == 1) {
+					t.Errorf("ClaimDomainNow() = %v, %v; want %v", claimed, err, tt.wantDue == 1)
+				}
+				if claimed {
+					repo.ReleaseDomain(ctx, d.ID, "worker", now)
+				}
+			})
+		}
+
+		if err := repo.SetState(ctx, d.ID, "deleted", now); err == nil {
+			t.Error("SetState() with an unknown state succeeded, want error")
+		}
+	})
+}
+
+// Test that retention deletes only domains archived before the cutoff, with their alerts
+func TestDomainRepository_DeleteOlderThan(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_domain_retention.db", func(t *testing.T, db *DB) {
+		repo := NewDomainRepository(db)
+		alertRepo := NewAlertRepository(db)
+
+		now := time.Now()
+		cutoff := now.Add(-90 * 24 * time.Hour)
+		domains := map[string]struct {
+			state      string
+			archivedAt time.Time
+			wantKept   bool
+		}{
+			"old-archive.com":    {state: domain.StateArchived, archivedAt: cutoff.Add(-time.Hour), wantKept: false},
+			"recent-archive.com": {state: domain.StateArchived, archivedAt: cutoff.Add(time.Hour), wantKept: true},
+			"old-active.com":     {state: domain.StateActive, archivedAt: cutoff.Add(-time.Hour), wantKept: true},
+			"old-paused.com":     {state: domain.StatePaused, archivedAt: cutoff.Add(-time.Hour), wantKept: true},
+		}
+
+		ids := make(map[string]string)
+		for name, tt := range domains {
+			d := &domain.Domain{Name: name, ExpirationDate: now, LastChecked: now, NextCheck: now}
+			if err := repo.Create(ctx, d); err != nil {
+				t.Fatalf("Failed to create domain: %v", err)
+			}
+			if err := repo.SetState(ctx, d.ID, tt.state, tt.archivedAt); err != nil {
+				t.Fatalf("Failed to set state: %v", err)
+			}
+			if err := alertRepo.Create(ctx, &domain.Alert{DomainID: d.ID, DomainName: name, ExpirationDate: now, SentAt: now, Success: true}); err != nil {
+				t.Fatalf("Failed to create alert: %v", err)
+			}
+			ids[name] = d.ID
+		}
+
+		if n, err := repo.CountOlderThan(ctx, cutoff); err != nil || n != 1 {
+			t.Fatalf("CountOlderThan() = %d, %v; want 1", n, err)
+		}
+		if n, err := repo.DeleteOlderThan(ctx, cutoff, 10); err != nil || n != 1 {
+			t.Fatalf("DeleteOlderThan() = %d, %v; want 1", n, err)
+		}
+
+		for name, tt := range domains {
+			_, err := repo.GetByID(ctx, ids[name])
+			if kept := err == nil; kept != tt.wantKept {
+				t.Errorf("%s kept = %v, want %v", name, kept, tt.wantKept)
+			}
+			alerts, err := alertRepo.GetByDomainID(ctx, ids[name])
+			if err != nil {
+				t.Fatalf("Failed to get alerts: %v", err)
+			}
+			if kept := len(alerts) == 1; kept != tt.wantKept {
+				t.Errorf("%s alerts kept = %v, want %v", name, kept, tt.wantKept)
+			}
+		}
+	})
+}
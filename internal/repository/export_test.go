@@ -0,0 +1,16 @@
+package repository
+
+import "testing"
+
+// ForEachDriver runs fn as a subtest per driver with a function that opens an
+// empty, migrated database of that driver, for the tests in repository_test
+func ForEachDriver(t *testing.T, file string, fn func(t *testing.T, open func(t *testing.T) *DB)) {
+	t.Helper()
+
+	for _, d := range testDrivers {
+		d := d
+		t.Run(d.driver, func(t *testing.T) {
+			fn(t, func(t *testing.T) *DB { return openTestDB(t, d.driver, d.env, file) })
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// HTTPCheckRepository handles HTTP check and result persistence
+type HTTPCheckRepository struct {
+	db *DB
+}
+
+// NewHTTPCheckRepository creates a new HTTP check repository
+func NewHTTPCheckRepository(db *DB) *HTTPCheckRepository {
+	return &HTTPCheckRepository{db: db}
+}
+
+// Create adds a new HTTP check to the database
+func (r *HTTPCheckRepository) Create(ctx context.Context, c *domain.HTTPCheck) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+
+	now := time.Now()
+	c.CreatedAt = now
+	c.UpdatedAt = now
+
+	query := `
+		INSERT INTO http_checks (
+			id, domain_id, url, expected_status, expected_redirect, body_contains,
+			check_interval, failure_threshold, consecutive_failures,
+			last_checked, next_check, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		c.ID, c.DomainID, c.URL, c.ExpectedStatus, c.ExpectedRedirect, c.BodyContains,
+		c.Interval, c.FailureThreshold, c.ConsecutiveFailures,
+		c.LastChecked, c.NextCheck, c.CreatedAt, c.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create http check: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an HTTP check by its ID
+func (r *HTTPCheckRepository) GetByID(ctx context.Context, id string) (*domain.HTTPCheck, error) {
+	var c domain.HTTPCheck
+	query := `
+		SELECT id, domain_id, url, expected_status, expected_redirect, body_contains,
+		       check_interval, failure_threshold, consecutive_failures,
+		       last_checked, next_check, created_at, updated_at
+		FROM http_checks
+		WHERE id = ?
+	`
+
+	err := r.db.GetContext(ctx, &c, r.db.Rebind(query), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("http check not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get http check: %w", err)
+	}
+
+	return &c, nil
+}
+
+// GetByDomainID retrieves all HTTP checks for a specific domain
+func (r *HTTPCheckRepository) GetByDomainID(ctx context.Context, domainID string) ([]*domain.HTTPCheck, error) {
+	var checks []*domain.HTTPCheck
+	query := `
+		SELECT id, domain_id, url, expected_status, expected_redirect, body_contains,
+		       check_interval, failure_threshold, consecutive_failures,
+		       last_checked, next_check, created_at, updated_at
+		FROM http_checks
+		WHERE domain_id = ?
+		ORDER BY created_at ASC
+	`
+
+	err := r.db.SelectContext(ctx, &checks, r.db.Rebind(query), domainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get http checks for domain: %w", err)
+	}
+
+	return checks, nil
+}
+
+// GetDueChecks retrieves HTTP checks of active domains whose next run time has passed
+func (r *HTTPCheckRepository) GetDueChecks(ctx context.Context, now time.Time) ([]*domain.HTTPCheck, error) {
+	var checks []*domain.HTTPCheck
+	query := `
+		SELECT id, domain_id, url, expected_status, expected_redirect, body_contains,
+		       check_interval, failure_threshold, consecutive_failures,
+		       last_checked, next_check, created_at, updated_at
+		FROM http_checks
+		WHERE next_check <= ? AND domain_id IN (SELECT id FROM domains WHERE state = ?)
+		ORDER BY next_check ASC
+	`
+
+	err := r.db.SelectContext(ctx, &checks, r.db.Rebind(query), now, domain.StateActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due http checks: %w", err)
+	}
+
+	return checks, nil
+}
+
+// Update updates an existing HTTP check
+func (r *HTTPCheckRepository) Update(ctx context.Context, c *domain.HTTPCheck) error {
+	c.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE http_checks
+		SET url = ?, expected_status = ?, expected_redirect = ?, body_contains = ?,
+		    check_interval = ?, failure_threshold = ?, consecutive_failures = ?,
+		    last_checked = ?, next_check = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		c.URL, c.ExpectedStatus, c.ExpectedRedirect, c.BodyContains,
+		c.Interval, c.FailureThreshold, c.ConsecutiveFailures,
+		c.LastChecked, c.NextCheck, c.UpdatedAt, c.ID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update http check: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("http check not found: %s", c.ID)
+	}
+
+	return nil
+}
+
+// Delete removes an HTTP check and its results
+func (r *HTTPCheckRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		// Results are removed explicitly because SQLite does not enforce
+		// foreign key cascades unless enabled per connection
+		if _, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM http_check_results WHERE check_id = ?`), id); err != nil {
+			return fmt.Errorf("failed to delete http check results: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM http_checks WHERE id = ?`), id)
+		if err != nil {
+			return fmt.Errorf("failed to delete http check: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rows == 0 {
+			return fmt.Errorf("http check not found: %s", id)
+		}
+
+		return nil
+	})
+}
+
+// CreateResult records the outcome of a probe
+func (r *HTTPCheckRepository) CreateResult(ctx context.Context, res *domain.HTTPCheckResult) error {
+	if res.ID == "" {
+		res.ID = uuid.New().String()
+	}
+
+	query := `
+		INSERT INTO http_check_results (
+			id, check_id, checked_at, status_code, redirect_url,
+			latency, success, error_message
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		res.ID, res.CheckID, res.CheckedAt, res.StatusCode, res.RedirectURL,
+		res.Latency, res.Success, res.ErrorMessage,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create http check result: %w", err)
+	}
+
+	return nil
+}
+
+// GetResults retrieves the most recent results for a check, newest first
+func (r *HTTPCheckRepository) GetResults(ctx context.Context, checkID string, limit int) ([]*domain.HTTPCheckResult, error) {
+	var results []*domain.HTTPCheckResult
+	query := `
+		SELECT id, check_id, checked_at, status_code, redirect_url,
+		       latency, success, error_message
+		FROM http_check_results
+		WHERE check_id = ?
+		ORDER BY checked_at DESC
+		LIMIT ?
+	`
+
+	err := r.db.SelectContext(ctx, &results, r.db.Rebind(query), checkID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get http check results: %w", err)
+	}
+
+	return results, nil
+}
+
+// CountResultsOlderThan counts the HTTP check results recorded before the cutoff time
+func (r *HTTPCheckRepository) CountResultsOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	var count int
+	if err := r.db.GetContext(ctx, &count, r.db.Rebind(`SELECT COUNT(*) FROM http_check_results WHERE checked_at < ?`), cutoff); err != nil {
+		return 0, fmt.Errorf("failed to count old http check results: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteResultsOlderThan deletes up to limit HTTP check results recorded before
+// the cutoff time and returns how many were deleted
+func (r *HTTPCheckRepository) DeleteResultsOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	var ids []string
+	query := `SELECT id FROM http_check_results WHERE checked_at < ? ORDER BY checked_at ASC LIMIT ?`
+	if err := r.db.SelectContext(ctx, &ids, r.db.Rebind(query), cutoff, limit); err != nil {
+		return 0, fmt.Errorf("failed to find old http check results: %w", err)
+	}
+
+	deleted, err := r.db.deleteIDs(ctx, "http_check_results", ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old http check results: %w", err)
+	}
+	return deleted, nil
+}
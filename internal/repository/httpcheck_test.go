@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test that retention deletes HTTP check results recorded before the cutoff in batches
+func TestHTTPCheckRepository_DeleteResultsOlderThan(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_http_check_retention.db", func(t *testing.T, db *DB) {
+		repo := NewHTTPCheckRepository(db)
+
+		now := time.Now()
+		cutoff := now.Add(-24 * time.Hour)
+		for i := 0; i < 5; i++ {
+			checkedAt := cutoff.Add(-time.Duration(i+1) * time.Hour)
+			if i >= 3 {
+				checkedAt = now
+			}
+			if err := repo.CreateResult(ctx, &domain.HTTPCheckResult{CheckID: "c1", CheckedAt: checkedAt}); err != nil {
+				t.Fatalf("Failed to create result: %v", err)
+			}
+		}
+
+		if n, err := repo.CountResultsOlderThan(ctx, cutoff); err != nil || n != 3 {
+			t.Fatalf("CountResultsOlderThan() = %d, %v; want 3", n, err)
+		}
+		if n, err := repo.DeleteResultsOlderThan(ctx, cutoff, 2); err != nil || n != 2 {
+			t.Fatalf("DeleteResultsOlderThan() first batch = %d, %v; want 2", n, err)
+		}
+		if n, err := repo.DeleteResultsOlderThan(ctx, cutoff, 2); err != nil || n != 1 {
+			t.Fatalf("DeleteResultsOlderThan() second batch = %d, %v; want 1", n, err)
+		}
+
+		results, err := repo.GetResults(ctx, "c1", 10)
+		if err != nil {
+			t.Fatalf("Failed to get results: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("Kept %d results, want the 2 recent ones", len(results))
+		}
+	})
+}
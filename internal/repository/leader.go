@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LeaderRepository handles the leases used to elect a single active replica
+type LeaderRepository struct {
+	db *DB
+}
+
+// NewLeaderRepository creates a new leader lease repository
+func NewLeaderRepository(db *DB) *LeaderRepository {
+	return &LeaderRepository{db: db}
+}
+
+// TryAcquire takes or renews the named lease for holder until now+ttl
+// It reports whether holder owns the lease afterwards. A lease held by someone
+// else can only be taken once it has expired.
+func (r *LeaderRepository) TryAcquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	expiresAt := now.Add(ttl)
+
+	update := `
+		UPDATE leader_leases
+		SET holder = ?, expires_at = ?
+		WHERE name = ? AND (holder = ? OR expires_at < ?)
+	`
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(update), holder, expiresAt, name, holder, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to renew leader lease: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 1 {
+		return true, nil
+	}
+
+	// No row yet, or MySQL reported an unchanged row; a concurrent insert by
+	// another replica fails on the primary key
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(`INSERT INTO leader_leases (name, holder, expires_at) VALUES (?, ?, ?)`), name, holder, expiresAt)
+	if err != nil && !IsConstraintError(err) {
+		return false, fmt.Errorf("failed to create leader lease: %w", err)
+	}
+
+	current, err := r.Holder(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return current == holder, nil
+}
+
+// Holder returns who currently holds the named lease, expired or not
+func (r *LeaderRepository) Holder(ctx context.Context, name string) (string, error) {
+	var holder string
+	if err := r.db.GetContext(ctx, &holder, r.db.Rebind(`SELECT holder FROM leader_leases WHERE name = ?`), name); err != nil {
+		return "", fmt.Errorf("failed to get leader lease: %w", err)
+	}
+	return holder, nil
+}
+
+// Release gives up holder's lease so a standby can take over without waiting for it to expire
+func (r *LeaderRepository) Release(ctx context.Context, name, holder string) error {
+	_, err := r.db.ExecContext(ctx,
+		r.db.Rebind(`UPDATE leader_leases SET holder = '', expires_at = ? WHERE name = ? AND holder = ?`),
+		time.Unix(0, 0), name, holder,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release leader lease: %w", err)
+	}
+	return nil
+}
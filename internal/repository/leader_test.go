@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Test that one holder at a time owns a leader lease and that it can be taken over
+// once it expires or is released
+func TestLeaderRepository_TryAcquire(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_leader_leases.db", func(t *testing.T, db *DB) {
+		repo := NewLeaderRepository(db)
+		now := time.Now()
+		ttl := 30 * time.Second
+
+		acquire := func(holder string, at time.Time) bool {
+			t.Helper()
+			ok, err := repo.TryAcquire(ctx, "scheduler", holder, at, ttl)
+			if err != nil {
+				t.Fatalf("TryAcquire(%s) unexpected error: %v", holder, err)
+			}
+			return ok
+		}
+
+		if !acquire("replica-a", now) {
+			t.Fatal("replica-a should acquire a free lease")
+		}
+		if acquire("replica-b", now.Add(time.Second)) {
+			t.Fatal("replica-b should not acquire a live lease")
+		}
+		if !acquire("replica-a", now.Add(10*time.Second)) {
+			t.Fatal("replica-a should renew its own lease")
+		}
+		if acquire("replica-b", now.Add(35*time.Second)) {
+			t.Fatal("replica-b should not acquire a renewed lease")
+		}
+
+		// replica-a stops renewing
+		if !acquire("replica-b", now.Add(41*time.Second)) {
+			t.Fatal("replica-b should take over an expired lease")
+		}
+		if holder, _ := repo.Holder(ctx, "scheduler"); holder != "replica-b" {
+			t.Fatalf("Holder() = %q, want replica-b", holder)
+		}
+		if acquire("replica-a", now.Add(42*time.Second)) {
+			t.Fatal("replica-a should not get its lease back while replica-b holds it")
+		}
+
+		// A graceful shutdown hands over immediately
+		if err := repo.Release(ctx, "scheduler", "replica-b"); err != nil {
+			t.Fatalf("Release() unexpected error: %v", err)
+		}
+		if !acquire("replica-a", now.Add(43*time.Second)) {
+			t.Fatal("replica-a should acquire a released lease")
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/google/uuid"
+)
+
+// LookalikeRepository handles lookalike domain persistence
+type LookalikeRepository struct {
+	db *DB
+}
+
+// NewLookalikeRepository creates a new lookalike repository
+func NewLookalikeRepository(db *DB) *LookalikeRepository {
+	return &LookalikeRepository{db: db}
+}
+
+// AddCandidates stores the given lookalikes for a domain, skipping names that are already tracked
+// It returns the number of candidates added
+func (r *LookalikeRepository) AddCandidates(ctx context.Context, domainID string, candidates []*domain.Lookalike) (int, error) {
+	var existing []string
+	if err := r.db.SelectContext(ctx, &existing, r.db.Rebind(`SELECT name FROM lookalikes WHERE domain_id = ?`), domainID); err != nil {
+		return 0, fmt.Errorf("failed to get existing lookalikes: %w", err)
+	}
+
+	known := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		known[name] = true
+	}
+
+	query := `
+		INSERT INTO lookalikes (
+			id, domain_id, name, unicode_name, kind, registered, registrar,
+			created_date, first_seen, last_checked, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	added := 0
+	now := time.Now()
+	for _, l := range candidates {
+		if known[l.Name] {
+			continue
+		}
+		if l.ID == "" {
+			l.ID = uuid.New().String()
+		}
+		l.DomainID = domainID
+		l.CreatedAt = now
+
+		_, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+			l.ID, l.DomainID, l.Name, l.UnicodeName, l.Kind, l.Registered, l.Registrar,
+			l.CreatedDate, l.FirstSeen, l.LastChecked, l.CreatedAt,
+		)
+		if err != nil {
+			if IsConstraintError(err) {
+				continue // Added concurrently
+			}
+			return added, fmt.Errorf("failed to create lookalike: %w", err)
+		}
+		known[l.Name] = true
+		added++
+	}
+
+	return added, nil
+}
+
+// GetByDomainID retrieves all lookalikes tracked for a domain
+func (r *LookalikeRepository) GetByDomainID(ctx context.Context, domainID string) ([]*domain.Lookalike, error) {
+	var lookalikes []*domain.Lookalike
+	query := `
+		SELECT id, domain_id, name, unicode_name, kind, registered, registrar,
+		       created_date, first_seen, last_checked, created_at
+		FROM lookalikes
+		WHERE domain_id = ?
+		ORDER BY name ASC
+	`
+
+	err := r.db.SelectContext(ctx, &lookalikes, r.db.Rebind(query), domainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lookalikes for domain: %w", err)
+	}
+
+	return lookalikes, nil
+}
+
+// GetRegisteredByDomainID retrieves the lookalikes of a domain that are currently registered
+func (r *LookalikeRepository) GetRegisteredByDomainID(ctx context.Context, domainID string) ([]*domain.Lookalike, error) {
+	var lookalikes []*domain.Lookalike
+	query := `
+		SELECT id, domain_id, name, unicode_name, kind, registered, registrar,
+		       created_date, first_seen, last_checked, created_at
+		FROM lookalikes
+		WHERE domain_id = ? AND registered = ?
+		ORDER BY first_seen DESC
+	`
+
+	err := r.db.SelectContext(ctx, &lookalikes, r.db.Rebind(query), domainID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registered lookalikes: %w", err)
+	}
+
+	return lookalikes, nil
+}
+
+// CountByDomainID returns how many lookalike candidates are tracked for a domain
+func (r *LookalikeRepository) CountByDomainID(ctx context.Context, domainID string) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, r.db.Rebind(`SELECT COUNT(*) FROM lookalikes WHERE domain_id = ?`), domainID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count lookalikes: %w", err)
+	}
+	return count, nil
+}
+
+// GetDueForCheck retrieves lookalikes of active domains never checked or last checked
+// before the cutoff, least recently checked first
+func (r *LookalikeRepository) GetDueForCheck(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Lookalike, error) {
+	var lookalikes []*domain.Lookalike
+	query := `
+		SELECT id, domain_id, name, unicode_name, kind, registered, registrar,
+		       created_date, first_seen, last_checked, created_at
+		FROM lookalikes
+		WHERE (last_checked IS NULL OR last_checked < ?)
+		  AND domain_id IN (SELECT id FROM domains WHERE state = ?)
+		ORDER BY last_checked IS NOT NULL, last_checked ASC
+		LIMIT ?
+	`
+
+	err := r.db.SelectContext(ctx, &lookalikes, r.db.Rebind(query), cutoff, domain.StateActive, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lookalikes for check: %w", err)
+	}
+
+	return lookalikes, nil
+}
+
+// Update updates the registration details of a lookalike
+func (r *LookalikeRepository) Update(ctx context.Context, l *domain.Lookalike) error {
+	query := `
+		UPDATE lookalikes
+		SET registered = ?, registrar = ?, created_date = ?, first_seen = ?, last_checked = ?
+		WHERE id = ?
+	`
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query),
+		l.Registered, l.Registrar, l.CreatedDate, l.FirstSeen, l.LastChecked, l.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update lookalike: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("lookalike not found: %s", l.ID)
+	}
+
+	return nil
+}
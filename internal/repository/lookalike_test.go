@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test candidate de-duplication, due ordering and removal with the parent domain
+func TestLookalikeRepository_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	forEachDriver(t, "test_lookalikes.db", func(t *testing.T, db *DB) {
+		domainRepo := NewDomainRepository(db)
+		repo := NewLookalikeRepository(db)
+
+		d := &domain.Domain{
+			Name:           "example.com",
+			ExpirationDate: time.Now().Add(365 * 24 * time.Hour),
+			Nameservers:    domain.Strings{"ns1.example.com"},
+			LastChecked:    time.Now(),
+			NextCheck:      time.Now(),
+		}
+		if err := domainRepo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+
+		candidates := []*domain.Lookalike{
+			{Name: "examp1e.com", UnicodeName: "examp1e.com", Kind: "homoglyph"},
+			{Name: "example.net", UnicodeName: "example.net", Kind: "tld-swap"},
+		}
+		added, err := repo.AddCandidates(ctx, d.ID, candidates)
+		if err != nil || added != 2 {
+			t.Fatalf("AddCandidates() = %d, %v; want 2, nil", added, err)
+		}
+
+		// Re-adding the same names is a no-op
+		added, err = repo.AddCandidates(ctx, d.ID, []*domain.Lookalike{{Name: "example.net", Kind: "tld-swap"}})
+		if err != nil || added != 0 {
+			t.Fatalf("AddCandidates() duplicate = %d, %v; want 0, nil", added, err)
+		}
+
+		// Never-checked candidates are due; recently checked ones are not
+		checked := candidates[0]
+		now := time.Now()
+		checked.LastChecked = &now
+		checked.Registered = true
+		checked.FirstSeen = &now
+		if err := repo.Update(ctx, checked); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+
+		due, err := repo.GetDueForCheck(ctx, now.Add(-time.Hour), 10)
+		if err != nil {
+			t.Fatalf("GetDueForCheck() unexpected error: %v", err)
+		}
+		if len(due) != 1 || due[0].Name != "example.net" {
+			t.Fatalf("GetDueForCheck() = %v, want only example.net", due)
+		}
+
+		registered, err := repo.GetRegisteredByDomainID(ctx, d.ID)
+		if err != nil || len(registered) != 1 || registered[0].Name != "examp1e.com" {
+			t.Fatalf("GetRegisteredByDomainID() = %v, %v; want examp1e.com", registered, err)
+		}
+
+		// Deleting the domain removes its candidates
+		if err := domainRepo.Delete(ctx, d.ID); err != nil {
+			t.Fatalf("Delete() unexpected error: %v", err)
+		}
+		count, err := repo.CountByDomainID(ctx, d.ID)
+		if err != nil || count != 0 {
+			t.Fatalf("CountByDomainID() after delete = %d, %v; want 0, nil", count, err)
+		}
+	})
+}
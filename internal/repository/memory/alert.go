@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.AlertStore = (*AlertRepository)(nil)
+
+// AlertRepository keeps alerts in a Store
+type AlertRepository struct {
+	s *Store
+}
+
+// NewAlertRepository creates an alert repository backed by s
+func NewAlertRepository(s *Store) *AlertRepository {
+	return &AlertRepository{s: s}
+}
+
+// cloneAlert copies an alert
+func cloneAlert(a *domain.Alert) *domain.Alert {
+	c := *a
+	c.NextAttemptAt = cloneTime(a.NextAttemptAt)
+	return &c
+}
+
+// alertID returns the ID of an alert
+func alertID(a *domain.Alert) string { return a.ID }
+
+// newestAlertFirst orders alerts by descending send time
+func newestAlertFirst(a, b *domain.Alert) bool { return a.SentAt.After(b.SentAt) }
+
+// withDefaults fills in the ID, type and dedup key of a new alert
+func withDefaults(alert *domain.Alert) {
+	if alert.ID == "" {
+		alert.ID = uuid.New().String()
+	}
+	if alert.Type == "" {
+		alert.Type = domain.AlertTypeExpiration
+	}
+	if alert.DedupKey == "" {
+		alert.DedupKey = alert.ID
+	}
+}
+
+// byDedupKey returns the alert claimed under key; the caller holds the lock
+func (s *Store) byDedupKey(key string) *domain.Alert {
+	for _, a := range s.alerts {
+		if a.DedupKey == key {
+			return a
+		}
+	}
+	return nil
+}
+
+// Create adds a new alert
+// Alerts without a dedup key are keyed by their ID and never collide
+func (r *AlertRepository) Create(ctx context.Context, alert *domain.Alert) error {
+	withDefaults(alert)
+	if alert.Status == "" {
+		alert.Status = domain.AlertStatusFailed
+		if alert.Success {
+			alert.Status = domain.AlertStatusSent
+		}
+	}
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.alerts[alert.ID]; ok || r.s.byDedupKey(alert.DedupKey) != nil {
+		return fmt.Errorf("failed to create alert: alert %s already exists", alert.DedupKey)
+	}
+	r.s.alerts[alert.ID] = cloneAlert(alert)
+	return nil
+}
+
+// Claim records an alert as pending under its dedup key before it is sent
+// It reports false when the key is already claimed. A claim left pending for
+// longer than staleAfter is taken over and keeps its ID.
+func (r *AlertRepository) Claim(ctx context.Context, alert *domain.Alert, staleAfter time.Duration) (bool, error) {
+	withDefaults(alert)
+	alert.Status = domain.AlertStatusPending
+	alert.Success = false
+
+	if err := r.s.lock(ctx); err != nil {
+		return false, fmt.Errorf("failed to look up alert claim: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	existing := r.s.byDedupKey(alert.DedupKey)
+	if existing == nil {
+		if _, ok := r.s.alerts[alert.ID]; ok {
+			return false, nil
+		}
+		r.s.alerts[alert.ID] = cloneAlert(alert)
+		return true, nil
+	}
+
+	staleBefore := time.Now().Add(-staleAfter)
+	if existing.Status != domain.AlertStatusPending || !existing.SentAt.Before(staleBefore) {
+		return false, nil
+	}
+	existing.SentAt = alert.SentAt
+	alert.ID = existing.ID
+	return true, nil
+}
+
+// retryDue reports whether an alert is due for redelivery
+func retryDue(a *domain.Alert, now time.Time, staleAfter time.Duration) bool {
+	switch a.Status {
+	case domain.AlertStatusFailed:
+		return a.NextAttemptAt != nil && !a.NextAttemptAt.After(now)
+	case domain.AlertStatusPending:
+		return a.SentAt.Before(now.Add(-staleAfter))
+	}
+	return false
+}
+
+// ClaimRetry claims a failed alert whose next attempt is due, or a pending alert
+// whose sender died more than staleAfter ago, for redelivery
+func (r *AlertRepository) ClaimRetry(ctx context.Context, id string, now time.Time, staleAfter time.Duration) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, fmt.Errorf("failed to claim alert retry: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	a, ok := r.s.alerts[id]
+	if !ok || !retryDue(a, now, staleAfter) {
+		return false, nil
+	}
+	a.Status = domain.AlertStatusPending
+	a.SentAt = now
+	return true, nil
+}
+
+// ClaimResend claims a failed or dead alert for a manual resend and resets its attempts
+func (r *AlertRepository) ClaimResend(ctx context.Context, id string, now time.Time) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, fmt.Errorf("failed to claim alert resend: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	a, ok := r.s.alerts[id]
+	if !ok || (a.Status != domain.AlertStatusFailed && a.Status != domain.AlertStatusDead) {
+		return false, nil
+	}
+	a.Status = domain.AlertStatusPending
+	a.SentAt = now
+	a.Attempts = 0
+	a.NextAttemptAt = nil
+	return true, nil
+}
+
+// Finalize records the outcome of sending a claimed alert
+func (r *AlertRepository) Finalize(ctx context.Context, alert *domain.Alert) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to finalize alert: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	a, ok := r.s.alerts[alert.ID]
+	if !ok {
+		return fmt.Errorf("alert not found: %s", alert.ID)
+	}
+	a.Status = alert.Status
+	a.Success = alert.Success
+	a.ErrorMessage = alert.ErrorMessage
+	a.SentAt = alert.SentAt
+	a.Attempts = alert.Attempts
+	a.NextAttemptAt = cloneTime(alert.NextAttemptAt)
+	return nil
+}
+
+// GetByID retrieves an alert by its ID
+func (r *AlertRepository) GetByID(ctx context.Context, id string) (*domain.Alert, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	a, ok := r.s.alerts[id]
+	if !ok {
+		return nil, fmt.Errorf("alert not found: %s", id)
+	}
+	return cloneAlert(a), nil
+}
+
+// GetDueRetries retrieves alerts due for redelivery, oldest first
+func (r *AlertRepository) GetDueRetries(ctx context.Context, now time.Time, staleAfter time.Duration, limit int) ([]*domain.Alert, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get due alert retries: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	alerts := sorted(r.s.alerts, alertID,
+		func(a *domain.Alert) bool { return retryDue(a, now, staleAfter) },
+		func(a, b *domain.Alert) bool { return a.SentAt.Before(b.SentAt) },
+	)
+	return cloneAll(limited(alerts, limit), cloneAlert), nil
+}
+
+// GetByDomainID retrieves all alerts for a specific domain, newest first
+func (r *AlertRepository) GetByDomainID(ctx context.Context, domainID string) ([]*domain.Alert, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get alerts for domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	alerts := sorted(r.s.alerts, alertID,
+		func(a *domain.Alert) bool { return a.DomainID == domainID },
+		newestAlertFirst,
+	)
+	return cloneAll(alerts, cloneAlert), nil
+}
+
+// GetRecentAlerts retrieves alerts sent since a time, newest first
+func (r *AlertRepository) GetRecentAlerts(ctx context.Context, since time.Time) ([]*domain.Alert, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get recent alerts: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	alerts := sorted(r.s.alerts, alertID,
+		func(a *domain.Alert) bool { return !a.SentAt.Before(since) },
+		newestAlertFirst,
+	)
+	return cloneAll(alerts, cloneAlert), nil
+}
+
+// expiredAlerts returns the alerts past retention at a cutoff, oldest first
+// Expiration alerts are kept until their expiry is before the cutoff, since
+// their deduplication key keeps the threshold from alerting again
+func (s *Store) expiredAlerts(cutoff time.Time) []*domain.Alert {
+	return sorted(s.alerts, alertID,
+		func(a *domain.Alert) bool {
+			return a.SentAt.Before(cutoff) &&
+				(a.Status == domain.AlertStatusSent || a.Status == domain.AlertStatusDead) &&
+				(a.Type != domain.AlertTypeExpiration || a.ExpirationDate.Before(cutoff))
+		},
+		func(a, b *domain.Alert) bool { return a.SentAt.Before(b.SentAt) },
+	)
+}
+
+// CountOlderThan counts the alerts DeleteOlderThan would delete at the cutoff
+func (r *AlertRepository) CountOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count old alerts: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	return len(r.s.expiredAlerts(cutoff)), nil
+}
+
+// DeleteOlderThan deletes up to limit alerts sent before the cutoff time and
+// returns how many were deleted. Alerts awaiting delivery are kept.
+func (r *AlertRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to find old alerts: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	expired := limited(r.s.expiredAlerts(cutoff), limit)
+	for _, a := range expired {
+		delete(r.s.alerts, a.ID)
+	}
+	return len(expired), nil
+}
+
+// GetFailedAlerts retrieves alerts awaiting a retry or out of delivery attempts
+func (r *AlertRepository) GetFailedAlerts(ctx context.Context) ([]*domain.Alert, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get failed alerts: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	alerts := sorted(r.s.alerts, alertID,
+		func(a *domain.Alert) bool {
+			return a.Status == domain.AlertStatusFailed || a.Status == domain.AlertStatusDead
+		},
+		newestAlertFirst,
+	)
+	return cloneAll(alerts, cloneAlert), nil
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.AuditStore = (*AuditRepository)(nil)
+
+// AuditRepository keeps the audit log in a Store
+type AuditRepository struct {
+	s *Store
+}
+
+// NewAuditRepository creates an audit repository backed by s
+func NewAuditRepository(s *Store) *AuditRepository {
+	return &AuditRepository{s: s}
+}
+
+// cloneAuditEntry copies an audit entry
+func cloneAuditEntry(e *domain.AuditEntry) *domain.AuditEntry {
+	c := *e
+	return &c
+}
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, e *domain.AuditEntry) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.audit[e.ID]; ok {
+		return fmt.Errorf("failed to create audit entry: duplicate ID %s", e.ID)
+	}
+	r.s.audit[e.ID] = cloneAuditEntry(e)
+	return nil
+}
+
+// Query returns one page of the audit entries matching q, newest first
+func (r *AuditRepository) Query(ctx context.Context, q repository.AuditQuery) (*repository.AuditPage, error) {
+	q = q.Normalize()
+
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	matching := sorted(r.s.audit, func(e *domain.AuditEntry) string { return e.ID }, q.Matches,
+		func(a, b *domain.AuditEntry) bool { return a.CreatedAt.After(b.CreatedAt) },
+	)
+	page := &repository.AuditPage{
+		Entries:    []*domain.AuditEntry{},
+		Pagination: repository.Pagination{Total: len(matching), Page: q.Page, PageSize: q.PageSize},
+	}
+	if offset := (q.Page - 1) * q.PageSize; offset < len(matching) {
+		page.Entries = cloneAll(limited(matching[offset:], q.PageSize), cloneAuditEntry)
+	}
+	return page, nil
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.ConfigStore = (*ConfigRepository)(nil)
+
+// ConfigRepository keeps the configuration in a Store
+type ConfigRepository struct {
+	s *Store
+}
+
+// NewConfigRepository creates a config repository backed by s
+func NewConfigRepository(s *Store) *ConfigRepository {
+	return &ConfigRepository{s: s}
+}
+
+// cloneConfig copies a configuration and its lists
+func cloneConfig(c *domain.Config) *domain.Config {
+	clone := *c
+	clone.AlertThresholds = append(domain.Durations{}, c.AlertThresholds...)
+	clone.CheckPolicy = append(domain.CheckPolicy{}, c.CheckPolicy...)
+	return &clone
+}
+
+// cloneRevision copies a configuration revision and its lists
+func cloneRevision(r *domain.ConfigRevision) *domain.ConfigRevision {
+	clone := *r
+	clone.AlertThresholds = append(domain.Durations{}, r.AlertThresholds...)
+	clone.CheckPolicy = append(domain.CheckPolicy{}, r.CheckPolicy...)
+	return &clone
+}
+
+// Get retrieves the configuration, saving the default configuration if there is none
+func (r *ConfigRepository) Get(ctx context.Context) (*domain.Config, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if r.s.config == nil {
+		r.save(repository.DefaultConfig(), 0)
+	}
+	return cloneConfig(r.s.config), nil
+}
+
+// Update replaces the configuration, saving it as a new revision
+func (r *ConfigRepository) Update(ctx context.Context, config *domain.Config) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	r.save(config, 0)
+	return nil
+}
+
+// Rollback restores the configuration of an earlier revision, saving it as a new revision
+func (r *ConfigRepository) Rollback(ctx context.Context, revision int) (*domain.Config, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to roll back config: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	rev, err := r.revision(revision)
+	if err != nil {
+		return nil, err
+	}
+	config := rev.Config()
+	r.save(config, revision)
+	return config, nil
+}
+
+// save replaces the configuration and appends it as the next revision; the
+// caller holds the store's lock
+func (r *ConfigRepository) save(config *domain.Config, restoredFrom int) {
+	config.ID = 1
+	config.UpdatedAt = time.Now()
+	r.s.config = cloneConfig(config)
+
+	rev := domain.NewConfigRevision(config)
+	rev.ID = uuid.New().String()
+	rev.Revision = len(r.s.revisions) + 1
+	rev.RestoredFrom = restoredFrom
+	r.s.revisions = append(r.s.revisions, rev)
+}
+
+// Revisions returns up to limit revisions of the configuration, newest first
+func (r *ConfigRepository) Revisions(ctx context.Context, limit int) ([]*domain.ConfigRevision, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get config revisions: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	revisions := []*domain.ConfigRevision{}
+	for i := len(r.s.revisions) - 1; i >= 0 && len(revisions) < limit; i-- {
+		revisions = append(revisions, cloneRevision(r.s.revisions[i]))
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves a revision of the configuration by its number
+func (r *ConfigRepository) GetRevision(ctx context.Context, revision int) (*domain.ConfigRevision, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get config revision: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	rev, err := r.revision(revision)
+	if err != nil {
+		return nil, err
+	}
+	return cloneRevision(rev), nil
+}
+
+// revision finds a revision by its number; the caller holds the store's lock
+func (r *ConfigRepository) revision(revision int) (*domain.ConfigRevision, error) {
+	if revision < 1 || revision > len(r.s.revisions) {
+		return nil, fmt.Errorf("config revision not found: %d", revision)
+	}
+	return r.s.revisions[revision-1], nil
+}
@@ -0,0 +1,519 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.DomainStore = (*DomainRepository)(nil)
+
+// DomainRepository keeps domains in a Store
+type DomainRepository struct {
+	s *Store
+}
+
+// NewDomainRepository creates a domain repository backed by s
+func NewDomainRepository(s *Store) *DomainRepository {
+	return &DomainRepository{s: s}
+}
+
+// cloneDomain copies a domain and the lists and times it points to
+func cloneDomain(d *domain.Domain) *domain.Domain {
+	c := *d
+	c.Nameservers = cloneStrings(d.Nameservers)
+	c.Statuses = cloneStrings(d.Statuses)
+	c.RequiredStatuses = cloneStrings(d.RequiredStatuses)
+	c.Tags = cloneStrings(d.Tags)
+	c.ArchivedAt = cloneTime(d.ArchivedAt)
+	c.LeaseUntil = cloneTime(d.LeaseUntil)
+	return &c
+}
+
+// domainID returns the ID of a domain
+func domainID(d *domain.Domain) string { return d.ID }
+
+// Create adds a new domain
+func (r *DomainRepository) Create(ctx context.Context, d *domain.Domain) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.Mode == "" {
+		d.Mode = domain.ModeMonitor
+	}
+	if d.RegistrationState == "" {
+		d.RegistrationState = domain.RegistrationRegistered
+	}
+	if d.State == "" {
+		d.State = domain.StateActive
+	}
+	if d.Criticality == "" {
+		d.Criticality = domain.CriticalityNormal
+	}
+	d.Tags = domain.NormalizeTags(d.Tags)
+	if err := d.Metadata.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	d.CreatedAt = now
+	d.UpdatedAt = now
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to create domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.domains[d.ID]; ok {
+		return fmt.Errorf("domain %s already exists", d.Name)
+	}
+	for _, existing := range r.s.domains {
+		if existing.Name == d.Name {
+			return fmt.Errorf("domain %s already exists", d.Name)
+		}
+	}
+
+	// Leases start free, as the insert leaves them to their column defaults
+	stored := cloneDomain(d)
+	stored.LeaseOwner = ""
+	stored.LeaseUntil = nil
+	stored.CheckAttempts = 0
+	r.s.domains[d.ID] = stored
+	return nil
+}
+
+// GetByID retrieves a domain by its ID
+func (r *DomainRepository) GetByID(ctx context.Context, id string) (*domain.Domain, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok {
+		return nil, fmt.Errorf("domain not found: %s", id)
+	}
+	return cloneDomain(d), nil
+}
+
+// GetByName retrieves a domain by its name
+func (r *DomainRepository) GetByName(ctx context.Context, name string) (*domain.Domain, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	for _, d := range r.s.domains {
+		if d.Name == name {
+			return cloneDomain(d), nil
+		}
+	}
+	return nil, fmt.Errorf("domain not found: %s", name)
+}
+
+// GetAll retrieves all domains, soonest expiring first
+func (r *DomainRepository) GetAll(ctx context.Context) ([]*domain.Domain, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get all domains: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	domains := sorted(r.s.domains, domainID,
+		func(*domain.Domain) bool { return true },
+		func(a, b *domain.Domain) bool { return a.ExpirationDate.Before(b.ExpirationDate) },
+	)
+	return cloneAll(domains, cloneDomain), nil
+}
+
+// Query returns one page of the domains matching q
+func (r *DomainRepository) Query(ctx context.Context, q repository.DomainQuery) (*repository.DomainPage, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to query domains: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	matching := sorted(r.s.domains, domainID, q.Matches, q.Less)
+	page := &repository.DomainPage{
+		Domains:    []*domain.Domain{},
+		Pagination: repository.Pagination{Total: len(matching), Page: q.Page, PageSize: q.PageSize},
+	}
+	if offset := (q.Page - 1) * q.PageSize; offset < len(matching) {
+		page.Domains = cloneAll(limited(matching[offset:], q.PageSize), cloneDomain)
+	}
+	return page, nil
+}
+
+// Update updates an existing domain
+// Required statuses, the lifecycle state and the metadata are only changed by
+// UpdateRequiredStatuses, SetState and UpdateMetadata
+func (r *DomainRepository) Update(ctx context.Context, d *domain.Domain) error {
+	d.UpdatedAt = time.Now()
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.domains[d.ID]
+	if !ok {
+		return fmt.Errorf("domain not found: %s", d.ID)
+	}
+	applyUpdate(stored, d)
+	return nil
+}
+
+// UpdateLeased updates a domain like Update, but only while owner holds a live check lease
+func (r *DomainRepository) UpdateLeased(ctx context.Context, d *domain.Domain, owner string, now time.Time) error {
+	d.UpdatedAt = time.Now()
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.domains[d.ID]
+	if !ok || !leaseHeld(stored, owner, now) {
+		return repository.ErrLeaseLost
+	}
+	applyUpdate(stored, d)
+	return nil
+}
+
+// applyUpdate copies the fields Update saves onto a stored domain
+func applyUpdate(stored, d *domain.Domain) {
+	stored.Name = d.Name
+	stored.ExpirationDate = d.ExpirationDate
+	stored.Nameservers = cloneStrings(d.Nameservers)
+	stored.Registrant = d.Registrant
+	stored.Registrar = d.Registrar
+	stored.LastChecked = d.LastChecked
+	stored.NextCheck = d.NextCheck
+	stored.UpdatedAt = d.UpdatedAt
+	stored.Mode = d.Mode
+	stored.RegistrationState = d.RegistrationState
+	stored.Statuses = cloneStrings(d.Statuses)
+	stored.LookupFailures = d.LookupFailures
+}
+
+// UpdateRequiredStatuses sets the EPP statuses a domain is required to have
+func (r *DomainRepository) UpdateRequiredStatuses(ctx context.Context, id string, statuses domain.Strings) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update required statuses: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.domains[id]
+	if !ok {
+		return fmt.Errorf("domain not found: %s", id)
+	}
+	stored.RequiredStatuses = cloneStrings(statuses)
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateMetadata replaces the owner, criticality, notes, annual cost and tags of a domain
+func (r *DomainRepository) UpdateMetadata(ctx context.Context, id string, m domain.Metadata) error {
+	m.Tags = domain.NormalizeTags(m.Tags)
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update domain metadata: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.domains[id]
+	if !ok {
+		return fmt.Errorf("domain not found: %s", id)
+	}
+	stored.Metadata = m
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+// Tags returns the names of all tags in use, sorted
+func (r *DomainRepository) Tags(ctx context.Context) ([]string, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	var all []string
+	for _, d := range r.s.domains {
+		all = append(all, d.Tags...)
+	}
+	return []string(domain.NormalizeTags(all)), nil
+}
+
+// Owners returns the owners of all domains, sorted
+func (r *DomainRepository) Owners(ctx context.Context) ([]string, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get owners: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	return distinct(r.s.domains, func(d *domain.Domain) string { return d.Owner }), nil
+}
+
+// Registrars returns the registrars of all domains, sorted
+func (r *DomainRepository) Registrars(ctx context.Context) ([]string, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get registrars: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	return distinct(r.s.domains, func(d *domain.Domain) string { return d.Registrar }), nil
+}
+
+// distinct returns the non-empty values of field among domains, sorted
+func distinct(domains map[string]*domain.Domain, field func(*domain.Domain) string) []string {
+	values := []string{}
+	seen := make(map[string]bool)
+	for _, d := range domains {
+		if v := field(d); v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// SetState moves a domain to another lifecycle state
+func (r *DomainRepository) SetState(ctx context.Context, id, state string, now time.Time) error {
+	if !domain.ValidState(state) {
+		return fmt.Errorf("invalid domain state: %s", state)
+	}
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to set domain state: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.domains[id]
+	if !ok {
+		return fmt.Errorf("domain not found: %s", id)
+	}
+	stored.State = state
+	stored.ArchivedAt = nil
+	if state == domain.StateArchived {
+		stored.ArchivedAt = cloneTime(&now)
+	}
+	stored.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a domain along with its alerts, HTTP checks, lookalikes and tags
+func (r *DomainRepository) Delete(ctx context.Context, id string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to delete domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.domains[id]; !ok {
+		return fmt.Errorf("domain not found: %s", id)
+	}
+	r.s.deleteDomain(id)
+	return nil
+}
+
+// deleteDomain removes a domain and its dependents; the caller holds the lock
+func (s *Store) deleteDomain(id string) {
+	for alertID, a := range s.alerts {
+		if a.DomainID == id {
+			delete(s.alerts, alertID)
+		}
+	}
+	for checkID, c := range s.httpChecks {
+		if c.DomainID == id {
+			s.deleteHTTPCheck(checkID)
+		}
+	}
+	for lookalikeID, l := range s.lookalikes {
+		if l.DomainID == id {
+			delete(s.lookalikes, lookalikeID)
+		}
+	}
+	delete(s.domains, id)
+}
+
+// expiredDomains returns the domains archived before the cutoff, oldest first
+func (s *Store) expiredDomains(cutoff time.Time) []*domain.Domain {
+	return sorted(s.domains, domainID,
+		func(d *domain.Domain) bool {
+			return d.State == domain.StateArchived && d.ArchivedAt != nil && d.ArchivedAt.Before(cutoff)
+		},
+		func(a, b *domain.Domain) bool { return a.ArchivedAt.Before(*b.ArchivedAt) },
+	)
+}
+
+// CountOlderThan counts the domains DeleteOlderThan would delete at the cutoff
+func (r *DomainRepository) CountOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count expired archived domains: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	return len(r.s.expiredDomains(cutoff)), nil
+}
+
+// DeleteOlderThan deletes up to limit domains archived before the cutoff time,
+// with their history, and returns how many were deleted
+func (r *DomainRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to find expired archived domains: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	expired := limited(r.s.expiredDomains(cutoff), limit)
+	for _, d := range expired {
+		r.s.deleteDomain(d.ID)
+	}
+	return len(expired), nil
+}
+
+// leaseFree reports whether no running check holds a domain's lease
+func leaseFree(d *domain.Domain, now time.Time) bool {
+	return d.LeaseUntil == nil || d.LeaseUntil.Before(now)
+}
+
+// leaseHeld reports whether owner holds a live check lease on d at now
+func leaseHeld(d *domain.Domain, owner string, now time.Time) bool {
+	return d.LeaseOwner == owner && d.LeaseUntil != nil && !d.LeaseUntil.Before(now)
+}
+
+// dueForCheck reports whether a domain can be claimed by ClaimDomain
+func dueForCheck(d *domain.Domain, now time.Time) bool {
+	return d.State == domain.StateActive && !d.NextCheck.After(now) && leaseFree(d, now)
+}
+
+// GetDomainsForCheck retrieves up to limit active domains whose check is due and
+// not leased by a running check, most overdue first
+func (r *DomainRepository) GetDomainsForCheck(ctx context.Context, now time.Time, limit int) ([]*domain.Domain, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get domains for check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	domains := sorted(r.s.domains, domainID,
+		func(d *domain.Domain) bool { return dueForCheck(d, now) },
+		func(a, b *domain.Domain) bool { return a.NextCheck.Before(b.NextCheck) },
+	)
+	return cloneAll(limited(domains, limit), cloneDomain), nil
+}
+
+// ClaimDomain leases a due domain to owner until now+lease and counts the attempt
+func (r *DomainRepository) ClaimDomain(ctx context.Context, id, owner string, now time.Time, lease time.Duration) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, fmt.Errorf("failed to claim domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok || !dueForCheck(d, now) {
+		return false, nil
+	}
+	until := now.Add(lease)
+	d.LeaseOwner = owner
+	d.LeaseUntil = &until
+	d.CheckAttempts++
+	return true, nil
+}
+
+// ClaimDomainNow leases an active domain to owner for a check requested ahead of its schedule
+func (r *DomainRepository) ClaimDomainNow(ctx context.Context, id, owner string, now time.Time, lease time.Duration) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, fmt.Errorf("failed to claim domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok || d.State != domain.StateActive || !leaseFree(d, now) {
+		return false, nil
+	}
+	until := now.Add(lease)
+	d.LeaseOwner = owner
+	d.LeaseUntil = &until
+	d.CheckAttempts = 1
+	return true, nil
+}
+
+// DeferCheck ends owner's live lease on a domain and reschedules it without counting the attempt
+func (r *DomainRepository) DeferCheck(ctx context.Context, id, owner string, now, nextCheck time.Time) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to defer domain check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok || !leaseHeld(d, owner, now) {
+		return nil
+	}
+	d.NextCheck = nextCheck
+	d.LeaseOwner = ""
+	d.LeaseUntil = nil
+	if d.CheckAttempts > 0 {
+		d.CheckAttempts--
+	}
+	return nil
+}
+
+// QueueCheck makes an active domain due at now; a check due sooner is left as it is
+func (r *DomainRepository) QueueCheck(ctx context.Context, id string, now time.Time) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to queue domain check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok || d.State != domain.StateActive || !d.NextCheck.After(now) {
+		return nil
+	}
+	d.NextCheck = now
+	return nil
+}
+
+// RetryCheck ends owner's live lease on a domain whose check failed and schedules the retry
+func (r *DomainRepository) RetryCheck(ctx context.Context, id, owner string, now, nextCheck time.Time) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to schedule domain check retry: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok || !leaseHeld(d, owner, now) {
+		return nil
+	}
+	d.NextCheck = nextCheck
+	d.LeaseOwner = ""
+	d.LeaseUntil = nil
+	return nil
+}
+
+// ReleaseDomain ends owner's live lease on a domain after a completed check and resets its attempts
+func (r *DomainRepository) ReleaseDomain(ctx context.Context, id, owner string, now time.Time) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to release domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.domains[id]
+	if !ok || !leaseHeld(d, owner, now) {
+		return nil
+	}
+	d.LeaseOwner = ""
+	d.LeaseUntil = nil
+	d.CheckAttempts = 0
+	return nil
+}
@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.HTTPCheckStore = (*HTTPCheckRepository)(nil)
+
+// HTTPCheckRepository keeps HTTP checks and their results in a Store
+type HTTPCheckRepository struct {
+	s *Store
+}
+
+// NewHTTPCheckRepository creates an HTTP check repository backed by s
+func NewHTTPCheckRepository(s *Store) *HTTPCheckRepository {
+	return &HTTPCheckRepository{s: s}
+}
+
+// cloneHTTPCheck copies an HTTP check
+func cloneHTTPCheck(c *domain.HTTPCheck) *domain.HTTPCheck {
+	clone := *c
+	clone.LastChecked = cloneTime(c.LastChecked)
+	return &clone
+}
+
+// cloneHTTPCheckResult copies an HTTP check result
+func cloneHTTPCheckResult(res *domain.HTTPCheckResult) *domain.HTTPCheckResult {
+	clone := *res
+	return &clone
+}
+
+// httpCheckID returns the ID of an HTTP check
+func httpCheckID(c *domain.HTTPCheck) string { return c.ID }
+
+// httpCheckResultID returns the ID of an HTTP check result
+func httpCheckResultID(res *domain.HTTPCheckResult) string { return res.ID }
+
+// Create adds a new HTTP check
+func (r *HTTPCheckRepository) Create(ctx context.Context, c *domain.HTTPCheck) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+
+	now := time.Now()
+	c.CreatedAt = now
+	c.UpdatedAt = now
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to create http check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.httpChecks[c.ID]; ok {
+		return fmt.Errorf("failed to create http check: http check %s already exists", c.ID)
+	}
+	r.s.httpChecks[c.ID] = cloneHTTPCheck(c)
+	return nil
+}
+
+// GetByID retrieves an HTTP check by its ID
+func (r *HTTPCheckRepository) GetByID(ctx context.Context, id string) (*domain.HTTPCheck, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get http check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.httpChecks[id]
+	if !ok {
+		return nil, fmt.Errorf("http check not found: %s", id)
+	}
+	return cloneHTTPCheck(c), nil
+}
+
+// GetByDomainID retrieves all HTTP checks for a specific domain, oldest first
+func (r *HTTPCheckRepository) GetByDomainID(ctx context.Context, domainID string) ([]*domain.HTTPCheck, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get http checks for domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	checks := sorted(r.s.httpChecks, httpCheckID,
+		func(c *domain.HTTPCheck) bool { return c.DomainID == domainID },
+		func(a, b *domain.HTTPCheck) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	)
+	return cloneAll(checks, cloneHTTPCheck), nil
+}
+
+// GetDueChecks retrieves HTTP checks of active domains whose next run time has passed
+func (r *HTTPCheckRepository) GetDueChecks(ctx context.Context, now time.Time) ([]*domain.HTTPCheck, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get due http checks: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	checks := sorted(r.s.httpChecks, httpCheckID,
+		func(c *domain.HTTPCheck) bool { return !c.NextCheck.After(now) && r.s.domainActive(c.DomainID) },
+		func(a, b *domain.HTTPCheck) bool { return a.NextCheck.Before(b.NextCheck) },
+	)
+	return cloneAll(checks, cloneHTTPCheck), nil
+}
+
+// domainActive reports whether a domain exists and is active; the caller holds the lock
+func (s *Store) domainActive(id string) bool {
+	d, ok := s.domains[id]
+	return ok && d.State == domain.StateActive
+}
+
+// Update updates an existing HTTP check
+func (r *HTTPCheckRepository) Update(ctx context.Context, c *domain.HTTPCheck) error {
+	c.UpdatedAt = time.Now()
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update http check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.httpChecks[c.ID]
+	if !ok {
+		return fmt.Errorf("http check not found: %s", c.ID)
+	}
+	stored.URL = c.URL
+	stored.ExpectedStatus = c.ExpectedStatus
+	stored.ExpectedRedirect = c.ExpectedRedirect
+	stored.BodyContains = c.BodyContains
+	stored.Interval = c.Interval
+	stored.FailureThreshold = c.FailureThreshold
+	stored.ConsecutiveFailures = c.ConsecutiveFailures
+	stored.LastChecked = cloneTime(c.LastChecked)
+	stored.NextCheck = c.NextCheck
+	stored.UpdatedAt = c.UpdatedAt
+	return nil
+}
+
+// Delete removes an HTTP check and its results
+func (r *HTTPCheckRepository) Delete(ctx context.Context, id string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to delete http check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.httpChecks[id]; !ok {
+		return fmt.Errorf("http check not found: %s", id)
+	}
+	r.s.deleteHTTPCheck(id)
+	return nil
+}
+
+// deleteHTTPCheck removes an HTTP check and its results; the caller holds the lock
+func (s *Store) deleteHTTPCheck(id string) {
+	for resultID, res := range s.httpResults {
+		if res.CheckID == id {
+			delete(s.httpResults, resultID)
+		}
+	}
+	delete(s.httpChecks, id)
+}
+
+// CreateResult records the outcome of a probe
+func (r *HTTPCheckRepository) CreateResult(ctx context.Context, res *domain.HTTPCheckResult) error {
+	if res.ID == "" {
+		res.ID = uuid.New().String()
+	}
+
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to create http check result: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.httpResults[res.ID]; ok {
+		return fmt.Errorf("failed to create http check result: result %s already exists", res.ID)
+	}
+	r.s.httpResults[res.ID] = cloneHTTPCheckResult(res)
+	return nil
+}
+
+// GetResults retrieves the most recent results for a check, newest first
+func (r *HTTPCheckRepository) GetResults(ctx context.Context, checkID string, limit int) ([]*domain.HTTPCheckResult, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get http check results: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	results := sorted(r.s.httpResults, httpCheckResultID,
+		func(res *domain.HTTPCheckResult) bool { return res.CheckID == checkID },
+		func(a, b *domain.HTTPCheckResult) bool { return a.CheckedAt.After(b.CheckedAt) },
+	)
+	return cloneAll(limited(results, limit), cloneHTTPCheckResult), nil
+}
+
+// oldResults returns the results recorded before the cutoff, oldest first
+func (s *Store) oldResults(cutoff time.Time) []*domain.HTTPCheckResult {
+	return sorted(s.httpResults, httpCheckResultID,
+		func(res *domain.HTTPCheckResult) bool { return res.CheckedAt.Before(cutoff) },
+		func(a, b *domain.HTTPCheckResult) bool { return a.CheckedAt.Before(b.CheckedAt) },
+	)
+}
+
+// CountResultsOlderThan counts the HTTP check results recorded before the cutoff time
+func (r *HTTPCheckRepository) CountResultsOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count old http check results: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	return len(r.s.oldResults(cutoff)), nil
+}
+
+// DeleteResultsOlderThan deletes up to limit HTTP check results recorded before
+// the cutoff time and returns how many were deleted
+func (r *HTTPCheckRepository) DeleteResultsOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to find old http check results: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	old := limited(r.s.oldResults(cutoff), limit)
+	for _, res := range old {
+		delete(r.s.httpResults, res.ID)
+	}
+	return len(old), nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+var _ repository.LeaderStore = (*LeaderRepository)(nil)
+
+// LeaderRepository keeps leader leases in a Store
+type LeaderRepository struct {
+	s *Store
+}
+
+// NewLeaderRepository creates a leader lease repository backed by s
+func NewLeaderRepository(s *Store) *LeaderRepository {
+	return &LeaderRepository{s: s}
+}
+
+// TryAcquire takes or renews the named lease for holder until now+ttl
+// It reports whether holder owns the lease afterwards. A lease held by someone
+// else can only be taken once it has expired.
+func (r *LeaderRepository) TryAcquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, fmt.Errorf("failed to renew leader lease: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	l, ok := r.s.leases[name]
+	if !ok {
+		r.s.leases[name] = &lease{holder: holder, expiresAt: now.Add(ttl)}
+		return true, nil
+	}
+	if l.holder != holder && !l.expiresAt.Before(now) {
+		return false, nil
+	}
+	l.holder = holder
+	l.expiresAt = now.Add(ttl)
+	return true, nil
+}
+
+// Holder returns who currently holds the named lease, expired or not
+func (r *LeaderRepository) Holder(ctx context.Context, name string) (string, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return "", fmt.Errorf("failed to get leader lease: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	l, ok := r.s.leases[name]
+	if !ok {
+		return "", fmt.Errorf("failed to get leader lease: no lease %s", name)
+	}
+	return l.holder, nil
+}
+
+// Release gives up holder's lease so a standby can take over without waiting for it to expire
+func (r *LeaderRepository) Release(ctx context.Context, name, holder string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to release leader lease: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	if l, ok := r.s.leases[name]; ok && l.holder == holder {
+		l.holder = ""
+		l.expiresAt = time.Unix(0, 0)
+	}
+	return nil
+}
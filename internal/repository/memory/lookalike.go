@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.LookalikeStore = (*LookalikeRepository)(nil)
+
+// LookalikeRepository keeps lookalike domains in a Store
+type LookalikeRepository struct {
+	s *Store
+}
+
+// NewLookalikeRepository creates a lookalike repository backed by s
+func NewLookalikeRepository(s *Store) *LookalikeRepository {
+	return &LookalikeRepository{s: s}
+}
+
+// cloneLookalike copies a lookalike and the times it points to
+func cloneLookalike(l *domain.Lookalike) *domain.Lookalike {
+	c := *l
+	c.CreatedDate = cloneTime(l.CreatedDate)
+	c.FirstSeen = cloneTime(l.FirstSeen)
+	c.LastChecked = cloneTime(l.LastChecked)
+	return &c
+}
+
+// lookalikeID returns the ID of a lookalike
+func lookalikeID(l *domain.Lookalike) string { return l.ID }
+
+// AddCandidates stores the given lookalikes for a domain, skipping names that are already tracked
+// It returns the number of candidates added
+func (r *LookalikeRepository) AddCandidates(ctx context.Context, domainID string, candidates []*domain.Lookalike) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to get existing lookalikes: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	known := make(map[string]bool)
+	for _, l := range r.s.lookalikes {
+		if l.DomainID == domainID {
+			known[l.Name] = true
+		}
+	}
+
+	added := 0
+	now := time.Now()
+	for _, l := range candidates {
+		if known[l.Name] {
+			continue
+		}
+		if l.ID == "" {
+			l.ID = uuid.New().String()
+		}
+		if _, ok := r.s.lookalikes[l.ID]; ok {
+			return added, fmt.Errorf("failed to create lookalike: lookalike %s already exists", l.ID)
+		}
+		l.DomainID = domainID
+		l.CreatedAt = now
+
+		r.s.lookalikes[l.ID] = cloneLookalike(l)
+		known[l.Name] = true
+		added++
+	}
+
+	return added, nil
+}
+
+// GetByDomainID retrieves all lookalikes tracked for a domain, by name
+func (r *LookalikeRepository) GetByDomainID(ctx context.Context, domainID string) ([]*domain.Lookalike, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get lookalikes for domain: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	lookalikes := sorted(r.s.lookalikes, lookalikeID,
+		func(l *domain.Lookalike) bool { return l.DomainID == domainID },
+		func(a, b *domain.Lookalike) bool { return a.Name < b.Name },
+	)
+	return cloneAll(lookalikes, cloneLookalike), nil
+}
+
+// GetRegisteredByDomainID retrieves the lookalikes of a domain that are currently
+// registered, most recently seen first
+func (r *LookalikeRepository) GetRegisteredByDomainID(ctx context.Context, domainID string) ([]*domain.Lookalike, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get registered lookalikes: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	lookalikes := sorted(r.s.lookalikes, lookalikeID,
+		func(l *domain.Lookalike) bool { return l.DomainID == domainID && l.Registered },
+		func(a, b *domain.Lookalike) bool { return timeBefore(b.FirstSeen, a.FirstSeen) },
+	)
+	return cloneAll(lookalikes, cloneLookalike), nil
+}
+
+// CountByDomainID returns how many lookalike candidates are tracked for a domain
+func (r *LookalikeRepository) CountByDomainID(ctx context.Context, domainID string) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count lookalikes: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	count := 0
+	for _, l := range r.s.lookalikes {
+		if l.DomainID == domainID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetDueForCheck retrieves lookalikes of active domains never checked or last checked
+// before the cutoff, least recently checked first
+func (r *LookalikeRepository) GetDueForCheck(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Lookalike, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get lookalikes for check: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	lookalikes := sorted(r.s.lookalikes, lookalikeID,
+		func(l *domain.Lookalike) bool {
+			return (l.LastChecked == nil || l.LastChecked.Before(cutoff)) && r.s.domainActive(l.DomainID)
+		},
+		func(a, b *domain.Lookalike) bool { return timeBefore(a.LastChecked, b.LastChecked) },
+	)
+	return cloneAll(limited(lookalikes, limit), cloneLookalike), nil
+}
+
+// Update updates the registration details of a lookalike
+func (r *LookalikeRepository) Update(ctx context.Context, l *domain.Lookalike) error {
+	if err := r.s.lock(ctx); err != nil {
+		return fmt.Errorf("failed to update lookalike: %w", err)
+	}
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.lookalikes[l.ID]
+	if !ok {
+		return fmt.Errorf("lookalike not found: %s", l.ID)
+	}
+	stored.Registered = l.Registered
+	stored.Registrar = l.Registrar
+	stored.CreatedDate = cloneTime(l.CreatedDate)
+	stored.FirstSeen = cloneTime(l.FirstSeen)
+	stored.LastChecked = cloneTime(l.LastChecked)
+	return nil
+}
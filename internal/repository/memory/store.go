@@ -0,0 +1,130 @@
+// Package memory implements the repository stores in memory, for tests, demo
+// mode and tools that embed DEM without a database
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+// Store holds all data in memory
+// The repositories of a store share its data, as the SQL repositories share a
+// database. Values are copied in and out, so callers never alias stored data.
+type Store struct {
+	mu          sync.Mutex
+	domains     map[string]*domain.Domain
+	alerts      map[string]*domain.Alert
+	config      *domain.Config
+	revisions   []*domain.ConfigRevision // oldest first
+	httpChecks  map[string]*domain.HTTPCheck
+	httpResults map[string]*domain.HTTPCheckResult
+	lookalikes  map[string]*domain.Lookalike
+	leases      map[string]*lease
+	audit       map[string]*domain.AuditEntry
+}
+
+// lease is a row of the leader lease table
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// NewStore creates an empty in-memory store
+func NewStore() *Store {
+	return &Store{
+		domains:     make(map[string]*domain.Domain),
+		alerts:      make(map[string]*domain.Alert),
+		httpChecks:  make(map[string]*domain.HTTPCheck),
+		httpResults: make(map[string]*domain.HTTPCheckResult),
+		lookalikes:  make(map[string]*domain.Lookalike),
+		leases:      make(map[string]*lease),
+		audit:       make(map[string]*domain.AuditEntry),
+	}
+}
+
+// Stores returns the repositories of the store
+func (s *Store) Stores() repository.Stores {
+	return repository.Stores{
+		Domains:    NewDomainRepository(s),
+		Config:     NewConfigRepository(s),
+		Alerts:     NewAlertRepository(s),
+		HTTPChecks: NewHTTPCheckRepository(s),
+		Lookalikes: NewLookalikeRepository(s),
+		Leader:     NewLeaderRepository(s),
+		Audit:      NewAuditRepository(s),
+	}
+}
+
+// lock takes the store's lock unless ctx has ended, as a database call fails
+// once its context is done
+func (s *Store) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	return nil
+}
+
+// sorted returns the values of m ordered by less, ties broken by ID
+func sorted[T any](m map[string]*T, id func(*T) string, keep func(*T) bool, less func(a, b *T) bool) []*T {
+	var items []*T
+	for _, v := range m {
+		if keep(v) {
+			items = append(items, v)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if less(items[i], items[j]) {
+			return true
+		}
+		if less(items[j], items[i]) {
+			return false
+		}
+		return id(items[i]) < id(items[j])
+	})
+	return items
+}
+
+// limited returns at most n items, like a SQL LIMIT
+func limited[T any](items []*T, n int) []*T {
+	if n >= 0 && len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+// cloneAll copies each item with clone
+func cloneAll[T any](items []*T, clone func(*T) *T) []*T {
+	out := make([]*T, 0, len(items))
+	for _, item := range items {
+		out = append(out, clone(item))
+	}
+	return out
+}
+
+// cloneTime copies an optional time
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// cloneStrings copies a string list; like a database column it is never nil
+func cloneStrings(s domain.Strings) domain.Strings {
+	return append(domain.Strings{}, s...)
+}
+
+// timeBefore orders optional times with nil first
+func timeBefore(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Before(*b)
+}
@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/repotest"
+)
+
+// Test that the in-memory repositories pass the conformance suite
+func TestStore_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.Stores {
+		return NewStore().Stores()
+	})
+}
@@ -0,0 +1,668 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationFiles holds the numbered migrations of each driver, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+const (
+	// migrationLockName names the MySQL lock held by the instance migrating the schema
+	migrationLockName = "dem_schema_migrations"
+	// migrationLockKey is the PostgreSQL advisory lock key for the same purpose
+	migrationLockKey = 4_386_420_571
+	// migrationLockTimeout is how long, in seconds, an instance waits for another to finish migrating
+	migrationLockTimeout = 300
+)
+
+// Migration is a numbered schema change with the SQL that applies and reverts it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Applied reports whether the migration has been applied
+func (s MigrationStatus) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// loadMigrations reads the migrations of a driver in version order
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrationStatus lists every known migration and when it was applied
+// Versions applied by a newer release are listed too, with their recorded name.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.driver)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []appliedMigration
+	exists, err := db.tableExists(ctx, db, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := db.SelectContext(ctx, &applied, `SELECT version, name, applied_at FROM schema_migrations`); err != nil {
+			return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+		}
+	}
+
+	byVersion := make(map[int]MigrationStatus, len(migrations)+len(applied))
+	for _, m := range migrations {
+		byVersion[m.Version] = MigrationStatus{Version: m.Version, Name: m.Name}
+	}
+	for _, a := range applied {
+		appliedAt := a.AppliedAt
+		byVersion[a.Version] = MigrationStatus{Version: a.Version, Name: a.Name, AppliedAt: &appliedAt}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(byVersion))
+	for _, s := range byVersion {
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// SchemaVersion returns the newest applied migration version
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, db)
+}
+
+// schemaVersion returns the newest migration version recorded in q
+func schemaVersion(ctx context.Context, q sqlx.QueryerContext) (int, error) {
+	var version sql.NullInt64
+	if err := sqlx.GetContext(ctx, q, &version, `SELECT MAX(version) FROM schema_migrations`); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// MigrateUp applies every pending migration in version order and returns how many it applied
+func (db *DB) MigrateUp(ctx context.Context) (int, error) {
+	migrations, err := loadMigrations(db.driver)
+	if err != nil {
+		return 0, err
+	}
+	return db.migrateUp(ctx, migrations)
+}
+
+// MigrateDown reverts the last steps applied migrations and returns how many it reverted
+func (db *DB) MigrateDown(ctx context.Context, steps int) (int, error) {
+	migrations, err := loadMigrations(db.driver)
+	if err != nil {
+		return 0, err
+	}
+	return db.migrateDown(ctx, migrations, steps)
+}
+
+// migrateUp applies the migrations that are not recorded in schema_migrations
+// A database of the first release, created before versioning, is adopted as
+// being at the first migration.
+func (db *DB) migrateUp(ctx context.Context, migrations []Migration) (int, error) {
+	count := 0
+	err := db.withMigrationLock(ctx, func(q sqlx.ExtContext) error {
+		legacy, err := db.isUnversioned(ctx, q)
+		if err != nil {
+			return err
+		}
+		if legacy {
+			if err := db.checkBaselineSchema(ctx, q); err != nil {
+				return err
+			}
+		}
+		if err := db.createMigrationsTable(ctx, q); err != nil {
+			return err
+		}
+		applied, err := db.appliedVersions(ctx, q)
+		if err != nil {
+			return err
+		}
+		if db.driver == "mysql" && len(applied) == 0 && !legacy {
+			// MySQL commits DDL as it goes, so an adoption or first migration cut
+			// short leaves tables behind and nothing recorded; finish it
+			legacy, err = db.tableExists(ctx, q, "domains")
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+			if i == 0 && legacy {
+				err = db.adoptLegacySchema(ctx, q, m)
+			} else {
+				err = db.execScript(ctx, q, m.Up)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+
+			if _, err := q.ExecContext(ctx, q.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+				m.Version, m.Name, time.Now().UTC()); err != nil {
+				return fmt.Errorf("failed to record migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// migrateDown reverts the most recently applied migrations, newest first
+func (db *DB) migrateDown(ctx context.Context, migrations []Migration, steps int) (int, error) {
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	count := 0
+	err := db.withMigrationLock(ctx, func(q sqlx.ExtContext) error {
+		if err := db.createMigrationsTable(ctx, q); err != nil {
+			return err
+		}
+
+		var versions []int
+		if err := sqlx.SelectContext(ctx, q, &versions, `SELECT version FROM schema_migrations ORDER BY version DESC`); err != nil {
+			return fmt.Errorf("failed to load applied migrations: %w", err)
+		}
+
+		for _, version := range versions {
+			if count == steps {
+				break
+			}
+			m, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migration %d was applied by a newer release and cannot be reverted by this one", version)
+			}
+			if err := db.execScript(ctx, q, m.Down); err != nil {
+				return fmt.Errorf("failed to revert migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := q.ExecContext(ctx, q.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// withMigrationLock runs fn while no other instance migrates the same database
+// SQLite and PostgreSQL run it in one transaction, so a failed migration leaves
+// no trace; PostgreSQL also takes an advisory lock, released when it commits.
+// MySQL commits DDL as it goes, so instances take turns under a named lock
+// instead, and fn runs on the connection holding it.
+func (db *DB) withMigrationLock(ctx context.Context, fn func(q sqlx.ExtContext) error) error {
+	if db.driver != "mysql" {
+		return db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+			if db.driver == "postgres" {
+				if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+					return fmt.Errorf("failed to take migration lock: %w", err)
+				}
+			}
+			return fn(tx)
+		})
+	}
+
+	// The lock belongs to the connection that took it
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	var locked int
+	if err := conn.GetContext(ctx, &locked, `SELECT GET_LOCK(?, ?)`, migrationLockName, migrationLockTimeout); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	if locked != 1 {
+		return fmt.Errorf("timed out after %ds waiting for another instance to finish migrating", migrationLockTimeout)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLockName)
+
+	return fn(lockedConn{Conn: conn, driver: db.driver})
+}
+
+// lockedConn is the connection holding the MySQL migration lock, with the
+// binding methods sqlx.Conn lacks
+type lockedConn struct {
+	*sqlx.Conn
+	driver string
+}
+
+// DriverName returns the name of the database driver
+func (c lockedConn) DriverName() string {
+	return c.driver
+}
+
+// BindNamed binds a query with named parameters for the driver
+func (c lockedConn) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return sqlx.BindNamed(sqlx.BindType(c.driver), query, arg)
+}
+
+// createMigrationsTable creates the table recording applied migrations
+func (db *DB) createMigrationsTable(ctx context.Context, q sqlx.ExtContext) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`
+	switch db.driver {
+	case "mysql":
+		query = `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INT PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				applied_at DATETIME NOT NULL
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+		`
+	case "postgres":
+		query = `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				applied_at TIMESTAMPTZ NOT NULL
+			)
+		`
+	}
+
+	if _, err := q.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions returns the versions recorded in schema_migrations
+func (db *DB) appliedVersions(ctx context.Context, q sqlx.ExtContext) (map[int]bool, error) {
+	var versions []int
+	if err := sqlx.SelectContext(ctx, q, &versions, `SELECT version FROM schema_migrations`); err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+	}
+
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// isUnversioned reports whether the database was created before versioned migrations
+// PostgreSQL support started with versioned migrations.
+func (db *DB) isUnversioned(ctx context.Context, q sqlx.ExtContext) (bool, error) {
+	if db.driver == "postgres" {
+		return false, nil
+	}
+	versioned, err := db.tableExists(ctx, q, "schema_migrations")
+	if err != nil || versioned {
+		return false, err
+	}
+	return db.tableExists(ctx, q, "domains")
+}
+
+// tableExists reports whether a table exists
+func (db *DB) tableExists(ctx context.Context, q sqlx.QueryerContext, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	switch db.driver {
+	case "mysql":
+		query = `
+			SELECT COUNT(*)
+			FROM information_schema.TABLES
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+		`
+	case "postgres":
+		query = `
+			SELECT COUNT(*)
+			FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = $1
+		`
+	}
+
+	var count int
+	if err := sqlx.GetContext(ctx, q, &count, query, table); err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// execScript runs the statements of a migration one at a time
+// Statements end with a semicolon at the end of a line; lines starting with
+// "--" are comments. MySQL applies each statement on its own, so one that
+// finds its change already made is skipped, and a migration cut short can be
+// run again.
+func (db *DB) execScript(ctx context.Context, q sqlx.ExecerContext, script string) error {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";\n") {
+		statement = strings.TrimSuffix(strings.TrimSpace(statement), ";")
+		if statement == "" {
+			continue
+		}
+		if _, err := q.ExecContext(ctx, statement); err != nil {
+			if db.driver == "mysql" && isAlreadyApplied(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// isAlreadyApplied reports whether a MySQL schema change failed because it was
+// already made: the table, column or index exists, or is already dropped
+// Duplicate rows are not among them, as they also fail unique indexes.
+func isAlreadyApplied(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	switch mysqlErr.Number {
+	case 1050, // table exists
+		1060, // duplicate column
+		1061, // duplicate index
+		1091: // column or index to drop does not exist
+		return true
+	}
+	return false
+}
+
+// checkBaselineSchema makes sure an unversioned database has the schema of the
+// first release, the only one adoptLegacySchema upgrades
+func (db *DB) checkBaselineSchema(ctx context.Context, q sqlx.ExtContext) error {
+	tables, err := db.listTables(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if !isBaselineTable(table) {
+			return fmt.Errorf("unversioned database has table %s, which no release before versioned migrations created; restore it from a backup of a release", table)
+		}
+	}
+	for _, upgrade := range columnUpgrades {
+		exists, err := db.columnExists(ctx, q, upgrade.table, upgrade.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("unversioned database has column %s.%s, which no release before versioned migrations created; restore it from a backup of a release", upgrade.table, upgrade.column)
+		}
+	}
+	return nil
+}
+
+// listTables returns the names of the tables in the database
+func (db *DB) listTables(ctx context.Context, q sqlx.QueryerContext) ([]string, error) {
+	query := `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`
+	if db.driver == "mysql" {
+		query = `SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()`
+	}
+
+	var tables []string
+	if err := sqlx.SelectContext(ctx, q, &tables, query); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return tables, nil
+}
+
+// adoptLegacySchema brings a database of the first release up to the first
+// migration, whose statements only create what is missing
+// Each step skips what is done already, so on MySQL an adoption cut short can
+// be run again.
+func (db *DB) adoptLegacySchema(ctx context.Context, q sqlx.ExtContext, initial Migration) error {
+	// Add the columns introduced since to the tables of the first release
+	for _, upgrade := range columnUpgrades {
+		exists, err := db.tableExists(ctx, q, upgrade.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if err := db.ensureColumn(ctx, q, upgrade); err != nil {
+			return err
+		}
+	}
+
+	// Alerts of the first release need a deduplication key before the unique index
+	alerts, err := db.tableExists(ctx, q, "alerts")
+	if err != nil {
+		return err
+	}
+	if alerts {
+		if err := db.backfillAlertDedupKeys(ctx, q); err != nil {
+			return err
+		}
+	}
+
+	// Create the tables the first release did not have
+	if err := db.execScript(ctx, q, initial.Up); err != nil {
+		return err
+	}
+
+	for _, upgrade := range indexUpgrades {
+		if err := db.ensureIndex(ctx, q, upgrade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnExists reports whether a table has a column
+func (db *DB) columnExists(ctx context.Context, q sqlx.QueryerContext, table, column string) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	if db.driver == "mysql" {
+		query = `
+			SELECT COUNT(*)
+			FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?
+		`
+	}
+
+	if err := sqlx.GetContext(ctx, q, &count, query, table, column); err != nil {
+		return false, fmt.Errorf("failed to inspect column %s.%s: %w", table, column, err)
+	}
+	return count > 0, nil
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func (db *DB) ensureColumn(ctx context.Context, q sqlx.ExtContext, upgrade columnUpgrade) error {
+	exists, err := db.columnExists(ctx, q, upgrade.table, upgrade.column)
+	if err != nil || exists {
+		return err
+	}
+
+	definition := upgrade.sqlite
+	if db.driver == "mysql" {
+		definition = upgrade.mysql
+	}
+
+	alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", upgrade.table, upgrade.column, definition)
+	if _, err := q.ExecContext(ctx, alter); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", upgrade.table, upgrade.column, err)
+	}
+	return nil
+}
+
+// ensureIndex creates an index if it does not exist yet
+func (db *DB) ensureIndex(ctx context.Context, q sqlx.ExtContext, upgrade indexUpgrade) error {
+	kind := "INDEX"
+	if upgrade.unique {
+		kind = "UNIQUE INDEX"
+	}
+
+	if db.driver != "mysql" {
+		create := fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s(%s)", kind, upgrade.name, upgrade.table, upgrade.columns)
+		if _, err := q.ExecContext(ctx, create); err != nil {
+			return fmt.Errorf("failed to create index %s: %w", upgrade.name, err)
+		}
+		return nil
+	}
+
+	// MySQL has no CREATE INDEX IF NOT EXISTS
+	var count int
+	query := `
+		SELECT COUNT(*)
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?
+	`
+	if err := sqlx.GetContext(ctx, q, &count, query, upgrade.table, upgrade.name); err != nil {
+		return fmt.Errorf("failed to inspect index %s: %w", upgrade.name, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	create := fmt.Sprintf("CREATE %s %s ON %s(%s)", kind, upgrade.name, upgrade.table, upgrade.columns)
+	if _, err := q.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", upgrade.name, err)
+	}
+	return nil
+}
+
+// backfillAlertDedupKeys gives alerts of the first release a deduplication key
+// Its alerts are all expiration alerts; they get the key a new evaluation would
+// claim, so they are not sent again after an upgrade, and duplicates are keyed
+// by their ID. Its failed alerts were never retried and are dead-lettered.
+func (db *DB) backfillAlertDedupKeys(ctx context.Context, q sqlx.ExtContext) error {
+	var legacy []struct {
+		ID             string    `db:"id"`
+		DomainID       string    `db:"domain_id"`
+		Threshold      int64     `db:"threshold"`
+		ExpirationDate time.Time `db:"expiration_date"`
+		Success        bool      `db:"success"`
+	}
+	query := `
+		SELECT id, domain_id, threshold, expiration_date, success
+		FROM alerts
+		WHERE dedup_key = ''
+		ORDER BY sent_at ASC
+	`
+	if err := sqlx.SelectContext(ctx, q, &legacy, query); err != nil {
+		return fmt.Errorf("failed to load alerts without dedup key: %w", err)
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	var existing []string
+	if err := sqlx.SelectContext(ctx, q, &existing, `SELECT dedup_key FROM alerts WHERE dedup_key <> ''`); err != nil {
+		return fmt.Errorf("failed to load alert dedup keys: %w", err)
+	}
+	taken := make(map[string]bool, len(existing)+len(legacy))
+	for _, key := range existing {
+		taken[key] = true
+	}
+
+	for _, a := range legacy {
+		key := domain.ExpirationDedupKey(a.DomainID, time.Duration(a.Threshold), a.ExpirationDate)
+		if taken[key] {
+			key = a.ID
+		}
+		taken[key] = true
+
+		status := domain.AlertStatusSent
+		if !a.Success {
+			status = domain.AlertStatusDead
+		}
+
+		if _, err := q.ExecContext(ctx, q.Rebind(`UPDATE alerts SET dedup_key = ?, status = ? WHERE id = ?`), key, status, a.ID); err != nil {
+			return fmt.Errorf("failed to backfill alert dedup key: %w", err)
+		}
+	}
+	return nil
+}
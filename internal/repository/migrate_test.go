@@ -0,0 +1,356 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// describeSchema lists the columns and indexes of every table, in an order that
+// does not depend on how they were added
+func describeSchema(t *testing.T, db *DB) map[string][]string {
+	t.Helper()
+
+	var tables []string
+	if err := db.Select(&tables, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`); err != nil {
+		t.Fatalf("Failed to list tables: %v", err)
+	}
+
+	schema := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		var columns []struct {
+			Name    string  `db:"name"`
+			Type    string  `db:"type"`
+			NotNull bool    `db:"notnull"`
+			Default *string `db:"dflt_value"`
+		}
+		if err := db.Select(&columns, `SELECT name, type, "notnull", dflt_value FROM pragma_table_info(?)`, table); err != nil {
+			t.Fatalf("Failed to describe %s: %v", table, err)
+		}
+		var entries []string
+		for _, c := range columns {
+			def := "<none>"
+			if c.Default != nil {
+				def = *c.Default
+			}
+			entries = append(entries, fmt.Sprintf("column %s %s notnull=%v default=%s", c.Name, c.Type, c.NotNull, def))
+		}
+
+		var indexes []string
+		if err := db.Select(&indexes, `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL`, table); err != nil {
+			t.Fatalf("Failed to list indexes of %s: %v", table, err)
+		}
+		for _, index := range indexes {
+			entries = append(entries, "index "+index)
+		}
+
+		sort.Strings(entries)
+		schema[table] = entries
+	}
+	return schema
+}
+
+// Test that databases created by the first release end up with the schema of a
+// fresh install and keep their data
+func TestMigrate_UpgradesUnversionedSchemas(t *testing.T) {
+	ctx := context.Background()
+
+	freshPath := "test_migrate_fresh.db"
+	defer os.Remove(freshPath)
+	fresh, err := NewDB(freshPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to create fresh database: %v", err)
+	}
+	defer fresh.Close()
+	want := describeSchema(t, fresh)
+
+	tests := []struct {
+		name   string
+		schema string
+		seed   string
+	}{
+		{
+			name:   "first release",
+			schema: "schema_baseline.sql",
+			seed: `
+				INSERT INTO domains VALUES ('d1', 'example.com', '2030-01-01', '[]', '', '', '2029-12-01', '2029-12-02', '2029-01-01', '2029-01-01');
+				INSERT INTO alerts VALUES ('a1', 'd1', 'example.com', 1, '2030-01-01', '2029-12-01', 1, '');
+				INSERT INTO alerts VALUES ('a2', 'd1', 'example.com', 7, '2030-01-01', '2029-12-01', 0, 'timeout');
+			`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := os.ReadFile(filepath.Join("testdata", tt.schema))
+			if err != nil {
+				t.Fatalf("Failed to read schema: %v", err)
+			}
+
+			dbPath := "test_migrate_upgrade.db"
+			defer os.Remove(dbPath)
+			legacy, err := sqlx.Connect("sqlite3", dbPath)
+			if err != nil {
+				t.Fatalf("Failed to create legacy database: %v", err)
+			}
+			_, err = legacy.Exec(string(schema) + tt.seed)
+			legacy.Close()
+			if err != nil {
+				t.Fatalf("Failed to create legacy schema: %v", err)
+			}
+
+			db, err := NewDB(dbPath, "sqlite3")
+			if err != nil {
+				t.Fatalf("Failed to upgrade database: %v", err)
+			}
+			defer db.Close()
+
+			got := describeSchema(t, db)
+			for table, entries := range want {
+				if fmt.Sprint(got[table]) != fmt.Sprint(entries) {
+					t.Errorf("Table %s after upgrade:\n got %v\nwant %v", table, got[table], entries)
+				}
+			}
+
+			statuses, err := db.MigrationStatus(ctx)
+			if err != nil {
+				t.Fatalf("MigrationStatus() unexpected error: %v", err)
+			}
+			for _, s := range statuses {
+				if !s.Applied() {
+					t.Errorf("Migration %04d_%s pending after upgrade", s.Version, s.Name)
+				}
+			}
+
+			alerts, err := NewAlertRepository(db).GetByDomainID(ctx, "d1")
+			if err != nil || len(alerts) != 2 {
+				t.Fatalf("Alerts after upgrade = %v, %v; want the existing alerts", alerts, err)
+			}
+			for _, a := range alerts {
+				want := domain.AlertStatusSent
+				if !a.Success {
+					want = domain.AlertStatusDead
+				}
+				if a.DedupKey == "" || a.Status != want {
+					t.Errorf("Alert %s after upgrade has dedup key %q and status %q, want a key and %q", a.ID, a.DedupKey, a.Status, want)
+				}
+			}
+			if _, err := NewDomainRepository(db).GetByID(ctx, "d1"); err != nil {
+				t.Errorf("Domain lost in upgrade: %v", err)
+			}
+		})
+	}
+}
+
+// Test that an unversioned database whose schema no release had is left alone
+// rather than adopted
+func TestMigrate_RefusesUnreleasedSchemas(t *testing.T) {
+	schema, err := os.ReadFile(filepath.Join("testdata", "schema_development.sql"))
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+
+	dbPath := "test_migrate_unreleased.db"
+	defer os.Remove(dbPath)
+	legacy, err := sqlx.Connect("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create legacy database: %v", err)
+	}
+	defer legacy.Close()
+	if _, err := legacy.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	if db, err := NewDB(dbPath, "sqlite3"); err == nil {
+		db.Close()
+		t.Fatal("NewDB() expected an error for a schema no release had")
+	}
+
+	var count int
+	if err := legacy.Get(&count, `SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'`); err != nil || count != 0 {
+		t.Errorf("schema_migrations tables = %d, %v; want the database left untouched", count, err)
+	}
+}
+
+// Test that only MySQL errors of a schema change already made let a migration go on
+func TestIsAlreadyApplied(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1050, Message: "Table 'tags' already exists"}, true},
+		{&mysql.MySQLError{Number: 1060, Message: "Duplicate column name 'owner'"}, true},
+		{&mysql.MySQLError{Number: 1061, Message: "Duplicate key name 'idx_domains_owner'"}, true},
+		{fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1091, Message: "Can't DROP 'owner'"}), true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{&mysql.MySQLError{Number: 1146, Message: "Table 'domains' doesn't exist"}, false},
+		{errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isAlreadyApplied(tt.err); got != tt.want {
+			t.Errorf("isAlreadyApplied(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// Test that migrations are applied once, reverted newest first and reapplied
+func TestMigrate_UpAndDown(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_migrate_up_down.db"
+	defer os.Remove(dbPath)
+
+	db, err := Open(dbPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations("sqlite3")
+	if err != nil {
+		t.Fatalf("loadMigrations() unexpected error: %v", err)
+	}
+	migrations = append(migrations, Migration{
+		Version: 9001,
+		Name:    "add_note",
+		Up:      "-- A column added after release\nALTER TABLE domains ADD COLUMN note TEXT NOT NULL DEFAULT '';\n",
+		Down:    "ALTER TABLE domains DROP COLUMN note;\n",
+	})
+
+	hasNote := func() bool {
+		var count int
+		if err := db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info('domains') WHERE name = 'note'`); err != nil {
+			t.Fatalf("Failed to inspect domains: %v", err)
+		}
+		return count > 0
+	}
+
+	applied, err := db.migrateUp(ctx, migrations)
+	if err != nil || applied != len(migrations) {
+		t.Fatalf("migrateUp() = %d, %v; want %d", applied, err, len(migrations))
+	}
+	if !hasNote() {
+		t.Fatal("Column from the new migration is missing")
+	}
+	if applied, err := db.migrateUp(ctx, migrations); err != nil || applied != 0 {
+		t.Errorf("Second migrateUp() = %d, %v; want 0", applied, err)
+	}
+
+	reverted, err := db.migrateDown(ctx, migrations, 1)
+	if err != nil || reverted != 1 {
+		t.Fatalf("migrateDown() = %d, %v; want 1", reverted, err)
+	}
+	if hasNote() {
+		t.Error("Column still present after reverting its migration")
+	}
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus() unexpected error: %v", err)
+	}
+	for _, s := range statuses {
+		if s.Version == 9001 {
+			t.Errorf("Reverted migration still listed as %+v", s)
+		}
+	}
+
+	// Reverting everything leaves an empty database that migrates up again
+	if _, err := db.migrateDown(ctx, migrations, len(migrations)); err != nil {
+		t.Fatalf("migrateDown() of all migrations unexpected error: %v", err)
+	}
+	if exists, err := db.tableExists(ctx, db, "domains"); err != nil || exists {
+		t.Errorf("domains table after full revert: exists %v, %v", exists, err)
+	}
+	if applied, err := db.migrateUp(ctx, migrations); err != nil || applied != len(migrations) {
+		t.Errorf("migrateUp() after full revert = %d, %v; want %d", applied, err, len(migrations))
+	}
+}
+
+// Test that a failing migration leaves the SQLite schema and its history untouched
+func TestMigrate_FailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_migrate_failure.db"
+	defer os.Remove(dbPath)
+
+	db, err := NewDB(dbPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations("sqlite3")
+	if err != nil {
+		t.Fatalf("loadMigrations() unexpected error: %v", err)
+	}
+	migrations = append(migrations,
+		Migration{Version: 9001, Name: "create_notes", Up: "CREATE TABLE notes (id TEXT PRIMARY KEY);", Down: "DROP TABLE notes;"},
+		Migration{Version: 9002, Name: "broken", Up: "ALTER TABLE missing ADD COLUMN x TEXT;", Down: ""},
+	)
+
+	if _, err := db.migrateUp(ctx, migrations); err == nil {
+		t.Fatal("migrateUp() with a broken migration succeeded")
+	}
+	if exists, err := db.tableExists(ctx, db, "notes"); err != nil || exists {
+		t.Errorf("notes table after failed run: exists %v, %v; want rolled back", exists, err)
+	}
+	applied, err := db.appliedVersions(ctx, db)
+	if err != nil {
+		t.Fatalf("appliedVersions() unexpected error: %v", err)
+	}
+	if applied[9001] || applied[9002] {
+		t.Errorf("Applied versions after failed run = %v, want neither new migration", applied)
+	}
+}
+
+// Test that replicas starting together apply each migration exactly once
+func TestMigrate_ConcurrentReplicas(t *testing.T) {
+	dbPath := "test_migrate_concurrent.db"
+	defer os.Remove(dbPath)
+	dsn := dbPath + "?_busy_timeout=10000"
+
+	const replicas = 4
+	applied := make([]int, replicas)
+	errs := make([]error, replicas)
+	var wg sync.WaitGroup
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := Open(dsn, "sqlite3")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer db.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+			applied[i], errs[i] = db.MigrateUp(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range errs {
+		if errs[i] != nil {
+			t.Errorf("Replica %d: MigrateUp() unexpected error: %v", i, errs[i])
+		}
+		total += applied[i]
+	}
+
+	migrations, err := loadMigrations("sqlite3")
+	if err != nil {
+		t.Fatalf("loadMigrations() unexpected error: %v", err)
+	}
+	if total != len(migrations) {
+		t.Errorf("Replicas applied %d migrations in total, want %d", total, len(migrations))
+	}
+}
@@ -0,0 +1,116 @@
+-- Schema as of the first versioned release. Statements are idempotent so the
+-- migration can also adopt databases created before versioning.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    expiration_date DATETIME NOT NULL,
+    nameservers JSON NOT NULL,
+    registrant TEXT NOT NULL,
+    registrar VARCHAR(255) NOT NULL,
+    last_checked DATETIME NOT NULL,
+    next_check DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    mode VARCHAR(32) NOT NULL DEFAULT 'monitor',
+    registration_state VARCHAR(32) NOT NULL DEFAULT 'registered',
+    statuses JSON NULL,
+    required_statuses JSON NULL,
+    lease_owner VARCHAR(255) NOT NULL DEFAULT '',
+    lease_until DATETIME NULL,
+    check_attempts INT NOT NULL DEFAULT 0,
+    state VARCHAR(16) NOT NULL DEFAULT 'active',
+    archived_at DATETIME NULL,
+    INDEX idx_domains_name (name),
+    INDEX idx_domains_expiration_date (expiration_date),
+    INDEX idx_domains_next_check (next_check),
+    INDEX idx_domains_state_next_check (state, next_check)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS config (
+    id INTEGER PRIMARY KEY,
+    monitoring_interval BIGINT NOT NULL,
+    alert_thresholds JSON NOT NULL,
+    google_chat_webhook TEXT NOT NULL,
+    retention_period BIGINT NOT NULL,
+    check_policy JSON NULL,
+    updated_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id VARCHAR(255) PRIMARY KEY,
+    domain_id VARCHAR(255) NOT NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    threshold BIGINT NOT NULL,
+    expiration_date DATETIME NOT NULL,
+    sent_at DATETIME NOT NULL,
+    success TINYINT(1) NOT NULL,
+    error_message TEXT NOT NULL,
+    type VARCHAR(32) NOT NULL DEFAULT 'expiration',
+    message TEXT NOT NULL,
+    dedup_key VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'sent',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NULL,
+    INDEX idx_alerts_domain_id (domain_id),
+    INDEX idx_alerts_sent_at (sent_at),
+    UNIQUE KEY uq_alerts_dedup_key (dedup_key),
+    INDEX idx_alerts_status_next_attempt (status, next_attempt_at),
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS http_checks (
+    id VARCHAR(255) PRIMARY KEY,
+    domain_id VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    expected_status INTEGER NOT NULL,
+    expected_redirect TEXT NOT NULL,
+    body_contains TEXT NOT NULL,
+    check_interval BIGINT NOT NULL,
+    failure_threshold INTEGER NOT NULL,
+    consecutive_failures INTEGER NOT NULL,
+    last_checked DATETIME NULL,
+    next_check DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    INDEX idx_http_checks_domain_id (domain_id),
+    INDEX idx_http_checks_next_check (next_check),
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS http_check_results (
+    id VARCHAR(255) PRIMARY KEY,
+    check_id VARCHAR(255) NOT NULL,
+    checked_at DATETIME NOT NULL,
+    status_code INTEGER NOT NULL,
+    redirect_url TEXT NOT NULL,
+    latency BIGINT NOT NULL,
+    success TINYINT(1) NOT NULL,
+    error_message TEXT NOT NULL,
+    INDEX idx_http_check_results_check_id (check_id, checked_at),
+    INDEX idx_http_check_results_checked_at (checked_at),
+    FOREIGN KEY (check_id) REFERENCES http_checks(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS lookalikes (
+    id VARCHAR(255) PRIMARY KEY,
+    domain_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    unicode_name VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    registered TINYINT(1) NOT NULL,
+    registrar VARCHAR(255) NOT NULL,
+    created_date DATETIME NULL,
+    first_seen DATETIME NULL,
+    last_checked DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE KEY uq_lookalikes_domain_name (domain_id, name),
+    INDEX idx_lookalikes_last_checked (last_checked),
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name VARCHAR(64) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    expires_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS domain_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE domains
+    DROP INDEX idx_domains_owner,
+    DROP COLUMN annual_cost_cents,
+    DROP COLUMN notes,
+    DROP COLUMN criticality,
+    DROP COLUMN owner;
@@ -0,0 +1,25 @@
+-- Business metadata on domains, and free-form tags shared between domains.
+-- MySQL cannot default TEXT columns; existing rows get empty notes and inserts set them.
+
+ALTER TABLE domains
+    ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN criticality VARCHAR(16) NOT NULL DEFAULT 'normal',
+    ADD COLUMN notes TEXT NOT NULL,
+    ADD COLUMN annual_cost_cents BIGINT NOT NULL DEFAULT 0,
+    ADD INDEX idx_domains_owner (owner);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS domain_tags (
+    id VARCHAR(255) PRIMARY KEY,
+    domain_id VARCHAR(255) NOT NULL,
+    tag_id VARCHAR(255) NOT NULL,
+    UNIQUE KEY uq_domain_tags_domain_tag (domain_id, tag_id),
+    INDEX idx_domain_tags_tag_id (tag_id),
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
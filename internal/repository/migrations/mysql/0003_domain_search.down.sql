@@ -0,0 +1,4 @@
+ALTER TABLE domains
+    DROP INDEX idx_domains_registrar,
+    DROP INDEX idx_domains_mode_state_expiration_date,
+    DROP COLUMN lookup_failures;
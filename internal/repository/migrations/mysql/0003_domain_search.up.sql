@@ -0,0 +1,6 @@
+-- Consecutive failed WHOIS lookups, and indexes behind the dashboard's filters and sort orders.
+
+ALTER TABLE domains
+    ADD COLUMN lookup_failures INT NOT NULL DEFAULT 0,
+    ADD INDEX idx_domains_mode_state_expiration_date (mode, state, expiration_date),
+    ADD INDEX idx_domains_registrar (registrar);
@@ -0,0 +1,19 @@
+-- Append-only record of changes made through the web UI and API. Entries keep
+-- the name of their object, so they outlive it and retention never purges them.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id VARCHAR(255) PRIMARY KEY,
+    created_at DATETIME NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    source_ip VARCHAR(64) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    object_type VARCHAR(32) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    object_name VARCHAR(255) NOT NULL,
+    before_json MEDIUMTEXT NOT NULL,
+    after_json MEDIUMTEXT NOT NULL,
+    INDEX idx_audit_log_created_at (created_at),
+    INDEX idx_audit_log_actor (actor),
+    INDEX idx_audit_log_object_id (object_id),
+    INDEX idx_audit_log_object_name (object_name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
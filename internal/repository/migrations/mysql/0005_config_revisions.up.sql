@@ -0,0 +1,25 @@
+-- Every saved configuration is kept as a numbered revision that can be compared
+-- and restored. The configuration in place before this migration becomes revision 1.
+
+CREATE TABLE IF NOT EXISTS config_revisions (
+    id VARCHAR(255) PRIMARY KEY,
+    revision INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    monitoring_interval BIGINT NOT NULL,
+    alert_thresholds JSON NOT NULL,
+    google_chat_webhook TEXT NOT NULL,
+    retention_period BIGINT NOT NULL,
+    check_policy JSON NULL,
+    restored_from INTEGER NOT NULL DEFAULT 0,
+    UNIQUE INDEX idx_config_revisions_revision (revision)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- A run cut short after recording the first revision must not record it again
+INSERT INTO config_revisions (
+    id, revision, created_at, monitoring_interval, alert_thresholds,
+    google_chat_webhook, retention_period, check_policy, restored_from
+)
+SELECT '00000000-0000-0000-0000-000000000001', 1, updated_at, monitoring_interval, alert_thresholds,
+       google_chat_webhook, retention_period, check_policy, 0
+FROM config
+WHERE NOT EXISTS (SELECT 1 FROM config_revisions);
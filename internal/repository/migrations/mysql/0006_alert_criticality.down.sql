@@ -0,0 +1,2 @@
+ALTER TABLE config_revisions DROP COLUMN alert_criticality;
+ALTER TABLE config DROP COLUMN alert_criticality;
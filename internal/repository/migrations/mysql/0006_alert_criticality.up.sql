@@ -0,0 +1,4 @@
+-- The least criticality of the domains that alert; empty alerts for every domain.
+
+ALTER TABLE config ADD COLUMN alert_criticality VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE config_revisions ADD COLUMN alert_criticality VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,116 @@
+-- Schema as of the first versioned release. Timestamps carry their time zone and
+-- JSON lists are JSONB; durations are nanoseconds and need BIGINT.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    expiration_date TIMESTAMPTZ NOT NULL,
+    nameservers JSONB NOT NULL,
+    registrant TEXT NOT NULL,
+    registrar TEXT NOT NULL,
+    last_checked TIMESTAMPTZ NOT NULL,
+    next_check TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    mode TEXT NOT NULL DEFAULT 'monitor',
+    registration_state TEXT NOT NULL DEFAULT 'registered',
+    statuses JSONB NOT NULL DEFAULT '[]',
+    required_statuses JSONB NOT NULL DEFAULT '[]',
+    lease_owner TEXT NOT NULL DEFAULT '',
+    lease_until TIMESTAMPTZ,
+    check_attempts INTEGER NOT NULL DEFAULT 0,
+    state TEXT NOT NULL DEFAULT 'active',
+    archived_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_domains_expiration_date ON domains(expiration_date);
+CREATE INDEX IF NOT EXISTS idx_domains_next_check ON domains(next_check);
+CREATE INDEX IF NOT EXISTS idx_domains_state_next_check ON domains(state, next_check);
+
+CREATE TABLE IF NOT EXISTS config (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    monitoring_interval BIGINT NOT NULL,
+    alert_thresholds JSONB NOT NULL,
+    google_chat_webhook TEXT NOT NULL,
+    retention_period BIGINT NOT NULL,
+    check_policy JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id TEXT PRIMARY KEY,
+    domain_id TEXT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    domain_name TEXT NOT NULL,
+    threshold BIGINT NOT NULL,
+    expiration_date TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL,
+    success BOOLEAN NOT NULL,
+    error_message TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT 'expiration',
+    message TEXT NOT NULL DEFAULT '',
+    dedup_key TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'sent',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_domain_id ON alerts(domain_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_sent_at ON alerts(sent_at);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_alerts_dedup_key ON alerts(dedup_key);
+CREATE INDEX IF NOT EXISTS idx_alerts_status_next_attempt ON alerts(status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS http_checks (
+    id TEXT PRIMARY KEY,
+    domain_id TEXT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    expected_status INTEGER NOT NULL,
+    expected_redirect TEXT NOT NULL,
+    body_contains TEXT NOT NULL,
+    check_interval BIGINT NOT NULL,
+    failure_threshold INTEGER NOT NULL,
+    consecutive_failures INTEGER NOT NULL,
+    last_checked TIMESTAMPTZ,
+    next_check TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_checks_domain_id ON http_checks(domain_id);
+CREATE INDEX IF NOT EXISTS idx_http_checks_next_check ON http_checks(next_check);
+
+CREATE TABLE IF NOT EXISTS http_check_results (
+    id TEXT PRIMARY KEY,
+    check_id TEXT NOT NULL REFERENCES http_checks(id) ON DELETE CASCADE,
+    checked_at TIMESTAMPTZ NOT NULL,
+    status_code INTEGER NOT NULL,
+    redirect_url TEXT NOT NULL,
+    latency BIGINT NOT NULL,
+    success BOOLEAN NOT NULL,
+    error_message TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_check_results_check_id ON http_check_results(check_id, checked_at);
+CREATE INDEX IF NOT EXISTS idx_http_check_results_checked_at ON http_check_results(checked_at);
+
+CREATE TABLE IF NOT EXISTS lookalikes (
+    id TEXT PRIMARY KEY,
+    domain_id TEXT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    unicode_name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    registered BOOLEAN NOT NULL,
+    registrar TEXT NOT NULL,
+    created_date TIMESTAMPTZ,
+    first_seen TIMESTAMPTZ,
+    last_checked TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (domain_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lookalikes_last_checked ON lookalikes(last_checked);
+
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS domain_tags;
+DROP TABLE IF EXISTS tags;
+DROP INDEX IF EXISTS idx_domains_owner;
+ALTER TABLE domains DROP COLUMN annual_cost_cents;
+ALTER TABLE domains DROP COLUMN notes;
+ALTER TABLE domains DROP COLUMN criticality;
+ALTER TABLE domains DROP COLUMN owner;
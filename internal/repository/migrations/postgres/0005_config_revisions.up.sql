@@ -0,0 +1,22 @@
+-- Every saved configuration is kept as a numbered revision that can be compared
+-- and restored. The configuration in place before this migration becomes revision 1.
+
+CREATE TABLE IF NOT EXISTS config_revisions (
+    id TEXT PRIMARY KEY,
+    revision INTEGER NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    monitoring_interval BIGINT NOT NULL,
+    alert_thresholds JSONB NOT NULL,
+    google_chat_webhook TEXT NOT NULL,
+    retention_period BIGINT NOT NULL,
+    check_policy JSONB NOT NULL DEFAULT '[]',
+    restored_from INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO config_revisions (
+    id, revision, created_at, monitoring_interval, alert_thresholds,
+    google_chat_webhook, retention_period, check_policy, restored_from
+)
+SELECT '00000000-0000-0000-0000-000000000001', 1, updated_at, monitoring_interval, alert_thresholds,
+       google_chat_webhook, retention_period, check_policy, 0
+FROM config;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS leader_leases;
+DROP TABLE IF EXISTS lookalikes;
+DROP TABLE IF EXISTS http_check_results;
+DROP TABLE IF EXISTS http_checks;
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS config;
+DROP TABLE IF EXISTS domains;
@@ -0,0 +1,25 @@
+-- Business metadata on domains, and free-form tags shared between domains.
+
+ALTER TABLE domains ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE domains ADD COLUMN criticality TEXT NOT NULL DEFAULT 'normal';
+ALTER TABLE domains ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE domains ADD COLUMN annual_cost_cents INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_domains_owner ON domains(owner);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS domain_tags (
+    id TEXT PRIMARY KEY,
+    domain_id TEXT NOT NULL,
+    tag_id TEXT NOT NULL,
+    UNIQUE (domain_id, tag_id),
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_tags_tag_id ON domain_tags(tag_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_domains_registrar;
+DROP INDEX IF EXISTS idx_domains_mode_state_expiration_date;
+ALTER TABLE domains DROP COLUMN lookup_failures;
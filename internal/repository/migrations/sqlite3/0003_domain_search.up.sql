@@ -0,0 +1,6 @@
+-- Consecutive failed WHOIS lookups, and indexes behind the dashboard's filters and sort orders.
+
+ALTER TABLE domains ADD COLUMN lookup_failures INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_domains_mode_state_expiration_date ON domains(mode, state, expiration_date);
+CREATE INDEX IF NOT EXISTS idx_domains_registrar ON domains(registrar);
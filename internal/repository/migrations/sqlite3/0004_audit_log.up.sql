@@ -0,0 +1,20 @@
+-- Append-only record of changes made through the web UI and API. Entries keep
+-- the name of their object, so they outlive it and retention never purges them.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id TEXT PRIMARY KEY,
+    created_at DATETIME NOT NULL,
+    actor TEXT NOT NULL,
+    source_ip TEXT NOT NULL,
+    action TEXT NOT NULL,
+    object_type TEXT NOT NULL,
+    object_id TEXT NOT NULL,
+    object_name TEXT NOT NULL,
+    before_json TEXT NOT NULL,
+    after_json TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_log_object_id ON audit_log(object_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_object_name ON audit_log(object_name);
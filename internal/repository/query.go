@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Status buckets a domain query can be limited to
+// The expiry buckets go by expiration date alone; BucketFailing selects domains
+// whose latest WHOIS lookup failed, whatever their expiration date.
+const (
+	BucketExpired = "expired"
+	BucketWeek    = "7d"  // expires within 7 days
+	BucketMonth   = "30d" // expires in 7 to 30 days
+	BucketOK      = "ok"  // expires in 30 days or more
+	BucketFailing = "failing"
+)
+
+// Buckets lists the status buckets in the order the dashboard offers them
+var Buckets = []string{BucketExpired, BucketWeek, BucketMonth, BucketOK, BucketFailing}
+
+// Sort orders of a domain query
+const (
+	SortExpiration  = "expiration"
+	SortName        = "name"
+	SortRegistrar   = "registrar"
+	SortOwner       = "owner"
+	SortLastChecked = "last_checked"
+)
+
+// sortColumns maps each sort order to the column it sorts by; only these
+// column names are ever written into a query
+var sortColumns = map[string]string{
+	SortExpiration:  "expiration_date",
+	SortName:        "name",
+	SortRegistrar:   "registrar",
+	SortOwner:       "owner",
+	SortLastChecked: "last_checked",
+}
+
+// Page sizes of queries
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// likeEscape escapes the wildcards of LIKE patterns; it is not a backslash, whose
+// meaning in string literals differs between the drivers
+const likeEscape = "!"
+
+// DomainQuery selects one page of domains for the dashboard
+type DomainQuery struct {
+	Mode     string // ModeMonitor or ModeWatch; empty for both
+	Archived bool   // archived domains instead of active and paused ones
+
+	Search    string // part of the name or registrar, case-insensitive
+	Bucket    string // one of Buckets; empty for all
+	Tag       string
+	Registrar string
+	Owner     string
+
+	Sort string // one of the Sort orders; defaults to SortExpiration
+	Desc bool
+
+	Page     int // 1-based; defaults to the first page
+	PageSize int // defaults to DefaultPageSize, at most MaxPageSize
+
+	// Now is the time the expiry buckets are relative to; defaults to the current time
+	Now time.Time
+}
+
+// Pagination locates one page in a longer list
+type Pagination struct {
+	Total    int // items on all pages
+	Page     int // 1-based
+	PageSize int
+}
+
+// Pages returns the number of pages, at least one
+func (p Pagination) Pages() int {
+	if p.Total <= p.PageSize {
+		return 1
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+// offset returns how many items come before the page
+func (p Pagination) offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// normalizePage returns a 1-based page and a page size within MaxPageSize,
+// filling in the first page and DefaultPageSize
+func normalizePage(page, size int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	return page, size
+}
+
+// DomainPage is one page of the domains matching a query
+type DomainPage struct {
+	Domains []*domain.Domain
+	Pagination
+}
+
+// First returns the 1-based position of the page's first domain, or 0 when it is empty
+func (p *DomainPage) First() int {
+	if len(p.Domains) == 0 {
+		return 0
+	}
+	return p.offset() + 1
+}
+
+// Last returns the 1-based position of the page's last domain
+func (p *DomainPage) Last() int {
+	return p.offset() + len(p.Domains)
+}
+
+// Normalize checks a query and fills in its defaults
+func (q DomainQuery) Normalize() (DomainQuery, error) {
+	if q.Mode != "" && q.Mode != domain.ModeMonitor && q.Mode != domain.ModeWatch {
+		return q, fmt.Errorf("invalid domain mode: %s", q.Mode)
+	}
+	if q.Bucket != "" && !validBucket(q.Bucket) {
+		return q, fmt.Errorf("invalid status bucket: %s", q.Bucket)
+	}
+	if q.Sort == "" {
+		q.Sort = SortExpiration
+	}
+	if _, ok := sortColumns[q.Sort]; !ok {
+		return q, fmt.Errorf("invalid sort order: %s", q.Sort)
+	}
+
+	q.Search = strings.ToLower(strings.TrimSpace(q.Search))
+	q.Tag = domain.NormalizeTag(q.Tag)
+	q.Page, q.PageSize = normalizePage(q.Page, q.PageSize)
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+	return q, nil
+}
+
+// validBucket reports whether b is one of Buckets
+func validBucket(b string) bool {
+	for _, bucket := range Buckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketRange returns the expiration dates from and before which a domain falls
+// in an expiry bucket; a zero bound is open
+func bucketRange(bucket string, now time.Time) (from, before time.Time) {
+	week := now.Add(7 * 24 * time.Hour)
+	month := now.Add(30 * 24 * time.Hour)
+	switch bucket {
+	case BucketExpired:
+		return time.Time{}, now
+	case BucketWeek:
+		return now, week
+	case BucketMonth:
+		return week, month
+	case BucketOK:
+		return month, time.Time{}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// Matches reports whether a domain is selected by a normalized query
+// It is what the SQL of DomainRepository.Query expresses, for stores without SQL.
+func (q DomainQuery) Matches(d *domain.Domain) bool {
+	if q.Mode != "" && d.Mode != q.Mode {
+		return false
+	}
+	if d.IsArchived() != q.Archived {
+		return false
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(d.Name), q.Search) && !strings.Contains(strings.ToLower(d.Registrar), q.Search) {
+		return false
+	}
+	if q.Tag != "" && !d.HasTag(q.Tag) {
+		return false
+	}
+	if q.Registrar != "" && d.Registrar != q.Registrar {
+		return false
+	}
+	if q.Owner != "" && d.Owner != q.Owner {
+		return false
+	}
+
+	switch q.Bucket {
+	case "":
+		return true
+	case BucketFailing:
+		return d.LookupFailing()
+	default:
+		from, before := bucketRange(q.Bucket, q.Now)
+		return (from.IsZero() || !d.ExpirationDate.Before(from)) && (before.IsZero() || d.ExpirationDate.Before(before))
+	}
+}
+
+// Less reports whether a sorts before b in a normalized query's order; it reports
+// false for domains that tie, which are then ordered by ID
+func (q DomainQuery) Less(a, b *domain.Domain) bool {
+	if q.Desc {
+		a, b = b, a
+	}
+	switch q.Sort {
+	case SortName:
+		return a.Name < b.Name
+	case SortRegistrar:
+		return a.Registrar < b.Registrar
+	case SortOwner:
+		return a.Owner < b.Owner
+	case SortLastChecked:
+		return a.LastChecked.Before(b.LastChecked)
+	default:
+		return a.ExpirationDate.Before(b.ExpirationDate)
+	}
+}
+
+// where builds the WHERE clause of a normalized query and its arguments
+func (q DomainQuery) where() (string, []interface{}) {
+	state := "state <> ?"
+	if q.Archived {
+		state = "state = ?"
+	}
+	conditions := []string{state}
+	args := []interface{}{domain.StateArchived}
+
+	if q.Mode != "" {
+		conditions = append(conditions, "mode = ?")
+		args = append(args, q.Mode)
+	}
+	if q.Search != "" {
+		pattern := "%" + escapeLike(q.Search) + "%"
+		conditions = append(conditions, "(LOWER(name) LIKE ? ESCAPE '"+likeEscape+"' OR LOWER(registrar) LIKE ? ESCAPE '"+likeEscape+"')")
+		args = append(args, pattern, pattern)
+	}
+	if q.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT dt.domain_id FROM domain_tags dt JOIN tags t ON t.id = dt.tag_id WHERE t.name = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.Registrar != "" {
+		conditions = append(conditions, "registrar = ?")
+		args = append(args, q.Registrar)
+	}
+	if q.Owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, q.Owner)
+	}
+
+	switch q.Bucket {
+	case "":
+	case BucketFailing:
+		conditions = append(conditions, "lookup_failures > 0")
+	default:
+		from, before := bucketRange(q.Bucket, q.Now)
+		if !from.IsZero() {
+			conditions = append(conditions, "expiration_date >= ?")
+			args = append(args, from)
+		}
+		if !before.IsZero() {
+			conditions = append(conditions, "expiration_date < ?")
+			args = append(args, before)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// orderBy returns the ORDER BY clause of a normalized query
+func (q DomainQuery) orderBy() string {
+	direction := "ASC"
+	if q.Desc {
+		direction = "DESC"
+	}
+	return sortColumns[q.Sort] + " " + direction + ", id ASC"
+}
+
+// escapeLike escapes the LIKE wildcards in s, so they match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
+
+// Query returns one page of the domains matching q, with their tags
+func (r *DomainRepository) Query(ctx context.Context, q DomainQuery) (*DomainPage, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := q.where()
+	page := &DomainPage{Domains: []*domain.Domain{}, Pagination: Pagination{Page: q.Page, PageSize: q.PageSize}}
+	if err := r.db.GetContext(ctx, &page.Total, r.db.Rebind(`SELECT COUNT(*) FROM domains WHERE `+where), args...); err != nil {
+		return nil, fmt.Errorf("failed to count domains: %w", err)
+	}
+
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE ` + where + `
+		ORDER BY ` + q.orderBy() + `
+		LIMIT ? OFFSET ?
+	`
+	args = append(args, q.PageSize, page.offset())
+	if err := r.db.SelectContext(ctx, &page.Domains, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to query domains: %w", err)
+	}
+
+	if err := r.db.loadTags(ctx, r.db, page.Domains); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// Owners returns the owners of all domains, sorted
+func (r *DomainRepository) Owners(ctx context.Context) ([]string, error) {
+	owners := []string{}
+	query := `SELECT DISTINCT owner FROM domains WHERE owner <> '' ORDER BY owner`
+	if err := r.db.SelectContext(ctx, &owners, query); err != nil {
+		return nil, fmt.Errorf("failed to get owners: %w", err)
+	}
+	return owners, nil
+}
+
+// Registrars returns the registrars of all domains, sorted
+func (r *DomainRepository) Registrars(ctx context.Context) ([]string, error) {
+	registrars := []string{}
+	query := `SELECT DISTINCT registrar FROM domains WHERE registrar <> '' ORDER BY registrar`
+	if err := r.db.SelectContext(ctx, &registrars, query); err != nil {
+		return nil, fmt.Errorf("failed to get registrars: %w", err)
+	}
+	return registrars, nil
+}
@@ -0,0 +1,1162 @@
+// Package repotest is the conformance suite every implementation of the
+// repository stores must pass, so the SQL and in-memory stores behave alike
+package repotest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+// Run runs the conformance suite; open must return the stores of an empty backend
+func Run(t *testing.T, open func(t *testing.T) repository.Stores) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, stores repository.Stores)
+	}{
+		{"Domains", testDomains},
+		{"DomainUpdates", testDomainUpdates},
+		{"DomainMetadata", testDomainMetadata},
+		{"DomainQuery", testDomainQuery},
+		{"DomainDeleteCascades", testDomainDeleteCascades},
+		{"DomainCheckLeases", testDomainCheckLeases},
+		{"DomainRetention", testDomainRetention},
+		{"Alerts", testAlerts},
+		{"AlertClaims", testAlertClaims},
+		{"AlertRetention", testAlertRetention},
+		{"Config", testConfig},
+		{"HTTPChecks", testHTTPChecks},
+		{"Lookalikes", testLookalikes},
+		{"Leader", testLeader},
+		{"Audit", testAudit},
+		{"CanceledContext", testCanceledContext},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// createDomain stores an active domain due for a check at now
+func createDomain(t *testing.T, stores repository.Stores, name string, now time.Time) *domain.Domain {
+	t.Helper()
+
+	d := &domain.Domain{
+		Name:           name,
+		ExpirationDate: now.Add(90 * 24 * time.Hour),
+		Nameservers:    domain.Strings{"ns1." + name},
+		LastChecked:    now,
+		NextCheck:      now,
+	}
+	if err := stores.Domains.Create(context.Background(), d); err != nil {
+		t.Fatalf("Failed to create domain %s: %v", name, err)
+	}
+	return d
+}
+
+// names returns the names of domains, in order
+func names(domains []*domain.Domain) string {
+	var out []string
+	for _, d := range domains {
+		out = append(out, d.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+// wantError fails the test unless err mentions want
+func wantError(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want one containing %q", err, want)
+	}
+}
+
+func testDomains(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	late := createDomain(t, stores, "late.com", now)
+	early := &domain.Domain{Name: "early.com", ExpirationDate: now.Add(24 * time.Hour), LastChecked: now, NextCheck: now}
+	if err := stores.Domains.Create(ctx, early); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+	if early.ID == "" || early.Mode != domain.ModeMonitor || early.State != domain.StateActive || early.RegistrationState != domain.RegistrationRegistered {
+		t.Errorf("Create() left defaults unset: %+v", early)
+	}
+
+	got, err := stores.Domains.GetByID(ctx, late.ID)
+	if err != nil {
+		t.Fatalf("GetByID() unexpected error: %v", err)
+	}
+	if got.Name != "late.com" || !got.ExpirationDate.Equal(late.ExpirationDate) || len(got.Nameservers) != 1 || got.State != domain.StateActive {
+		t.Errorf("GetByID() = %+v, want %+v", got, late)
+	}
+	if got.Statuses == nil || got.RequiredStatuses == nil {
+		t.Error("GetByID() returned nil status lists, want empty lists")
+	}
+
+	// Returned domains are copies
+	got.Nameservers[0] = "changed"
+	if again, _ := stores.Domains.GetByID(ctx, late.ID); again.Nameservers[0] != "ns1.late.com" {
+		t.Error("changing a returned domain changed the stored domain")
+	}
+
+	if got, err := stores.Domains.GetByName(ctx, "early.com"); err != nil || got.ID != early.ID {
+		t.Errorf("GetByName() = %v, %v; want early.com", got, err)
+	}
+	_, err = stores.Domains.GetByID(ctx, "missing")
+	wantError(t, err, "domain not found: missing")
+	_, err = stores.Domains.GetByName(ctx, "missing.com")
+	wantError(t, err, "domain not found: missing.com")
+
+	err = stores.Domains.Create(ctx, &domain.Domain{Name: "late.com", ExpirationDate: now, LastChecked: now, NextCheck: now})
+	wantError(t, err, "domain late.com already exists")
+
+	all, err := stores.Domains.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() unexpected error: %v", err)
+	}
+	if names(all) != "early.com,late.com" {
+		t.Errorf("GetAll() = %s, want soonest expiring first", names(all))
+	}
+}
+
+func testDomainUpdates(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+
+	if err := stores.Domains.UpdateRequiredStatuses(ctx, d.ID, domain.Strings{domain.StatusClientTransferProhibited}); err != nil {
+		t.Fatalf("UpdateRequiredStatuses() unexpected error: %v", err)
+	}
+	if err := stores.Domains.SetState(ctx, d.ID, domain.StatePaused, now); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+
+	// A stale copy does not undo the user's settings
+	d.Registrar = "New Registrar"
+	d.Statuses = domain.Strings{domain.StatusOK}
+	d.NextCheck = now.Add(time.Hour)
+	if err := stores.Domains.Update(ctx, d); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	got, err := stores.Domains.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("GetByID() unexpected error: %v", err)
+	}
+	if got.Registrar != "New Registrar" || !got.HasStatus(domain.StatusOK) || !got.NextCheck.Equal(now.Add(time.Hour)) {
+		t.Errorf("Update() did not save the domain: %+v", got)
+	}
+	if got.State != domain.StatePaused || !got.RequiredStatuses.Contains(domain.StatusClientTransferProhibited) {
+		t.Errorf("Update() changed state %q or required statuses %v", got.State, got.RequiredStatuses)
+	}
+
+	if err := stores.Domains.SetState(ctx, d.ID, domain.StateArchived, now); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, d.ID); got.ArchivedAt == nil || !got.ArchivedAt.Equal(now) {
+		t.Errorf("ArchivedAt = %v, want %v", got.ArchivedAt, now)
+	}
+	if err := stores.Domains.SetState(ctx, d.ID, domain.StateActive, now); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, d.ID); got.ArchivedAt != nil {
+		t.Errorf("ArchivedAt = %v after leaving the archive, want nil", got.ArchivedAt)
+	}
+
+	wantError(t, stores.Domains.SetState(ctx, d.ID, "deleted", now), "invalid domain state: deleted")
+	wantError(t, stores.Domains.SetState(ctx, "missing", domain.StateActive, now), "domain not found")
+	wantError(t, stores.Domains.UpdateRequiredStatuses(ctx, "missing", nil), "domain not found")
+	wantError(t, stores.Domains.Update(ctx, &domain.Domain{ID: "missing"}), "domain not found")
+}
+
+func testDomainMetadata(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	d := &domain.Domain{
+		Name:        "shop.com",
+		Nameservers: domain.Strings{},
+		LastChecked: now,
+		NextCheck:   now,
+		Metadata:    domain.Metadata{Owner: "Web Team", Tags: domain.Strings{"Prod", "web shop", "prod", " "}},
+	}
+	if err := stores.Domains.Create(ctx, d); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	blog := createDomain(t, stores, "blog.com", now)
+
+	got, err := stores.Domains.GetByName(ctx, "shop.com")
+	if err != nil {
+		t.Fatalf("GetByName() unexpected error: %v", err)
+	}
+	want := domain.Metadata{Owner: "Web Team", Criticality: domain.CriticalityNormal, Tags: domain.Strings{"prod", "web-shop"}}
+	if !reflect.DeepEqual(got.Metadata, want) {
+		t.Errorf("Created metadata = %+v, want %+v", got.Metadata, want)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, blog.ID); got.Tags == nil || len(got.Tags) != 0 || got.Criticality != domain.CriticalityNormal {
+		t.Errorf("Untagged domain has tags %#v and criticality %q, want none and normal", got.Tags, got.Criticality)
+	}
+
+	m := domain.Metadata{Owner: "Growth", Criticality: domain.CriticalityCritical, Notes: "Renew by hand", AnnualCostCents: 4599, Tags: domain.Strings{"web-shop", "eu"}}
+	if err := stores.Domains.UpdateMetadata(ctx, d.ID, m); err != nil {
+		t.Fatalf("UpdateMetadata() unexpected error: %v", err)
+	}
+	if err := stores.Domains.UpdateMetadata(ctx, blog.ID, domain.Metadata{Criticality: domain.CriticalityLow, Tags: domain.Strings{"eu"}}); err != nil {
+		t.Fatalf("UpdateMetadata() unexpected error: %v", err)
+	}
+
+	// A check saving a stale copy keeps the metadata
+	d.Registrar = "New Registrar"
+	if err := stores.Domains.Update(ctx, d); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	all, err := stores.Domains.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() unexpected error: %v", err)
+	}
+	for _, got := range all {
+		want := domain.Metadata{Criticality: domain.CriticalityLow, Tags: domain.Strings{"eu"}}
+		if got.ID == d.ID {
+			want = domain.Metadata{Owner: "Growth", Criticality: domain.CriticalityCritical, Notes: "Renew by hand", AnnualCostCents: 4599, Tags: domain.Strings{"eu", "web-shop"}}
+		}
+		if !reflect.DeepEqual(got.Metadata, want) {
+			t.Errorf("%s metadata = %+v, want %+v", got.Name, got.Metadata, want)
+		}
+	}
+
+	// Tags are listed while a domain uses them
+	if tags, err := stores.Domains.Tags(ctx); err != nil || strings.Join(tags, ",") != "eu,web-shop" {
+		t.Errorf("Tags() = %v, %v; want eu,web-shop", tags, err)
+	}
+	if err := stores.Domains.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if tags, err := stores.Domains.Tags(ctx); err != nil || strings.Join(tags, ",") != "eu" {
+		t.Errorf("Tags() after delete = %v, %v; want eu", tags, err)
+	}
+
+	wantError(t, stores.Domains.UpdateMetadata(ctx, blog.ID, domain.Metadata{Criticality: "urgent"}), "invalid criticality: urgent")
+	wantError(t, stores.Domains.UpdateMetadata(ctx, blog.ID, domain.Metadata{Criticality: domain.CriticalityLow, AnnualCostCents: -1}), "must not be negative")
+	wantError(t, stores.Domains.UpdateMetadata(ctx, "missing", domain.Metadata{Criticality: domain.CriticalityLow}), "domain not found")
+	wantError(t, stores.Domains.Create(ctx, &domain.Domain{Name: "bad.com", Metadata: domain.Metadata{Criticality: "urgent"}}), "invalid criticality")
+}
+
+func testDomainQuery(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	day := 24 * time.Hour
+
+	for _, d := range []*domain.Domain{
+		{Name: "expired.com", ExpirationDate: now.Add(-day), Registrar: "Alpha Names", Metadata: domain.Metadata{Owner: "web", Tags: domain.Strings{"prod"}}},
+		{Name: "week.com", ExpirationDate: now.Add(3 * day), Registrar: "Beta_Reg", Metadata: domain.Metadata{Owner: "ops"}},
+		{Name: "watch.net", ExpirationDate: now.Add(5 * day), Mode: domain.ModeWatch},
+		{Name: "month.com", ExpirationDate: now.Add(20 * day), Registrar: "Alpha Names", Metadata: domain.Metadata{Tags: domain.Strings{"prod"}}},
+		{Name: "ok.com", ExpirationDate: now.Add(90 * day), Registrar: "Data Rx"},
+		{Name: "archived.org", ExpirationDate: now.Add(-day), Registrar: "Alpha Names"},
+	} {
+		d.Nameservers = domain.Strings{}
+		d.LastChecked = now
+		d.NextCheck = now
+		if err := stores.Domains.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain %s: %v", d.Name, err)
+		}
+		switch d.Name {
+		case "ok.com":
+			d.LookupFailures = 2
+			if err := stores.Domains.Update(ctx, d); err != nil {
+				t.Fatalf("Update() unexpected error: %v", err)
+			}
+		case "archived.org":
+			if err := stores.Domains.SetState(ctx, d.ID, domain.StateArchived, now); err != nil {
+				t.Fatalf("SetState() unexpected error: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		q     repository.DomainQuery
+		want  string
+		total int
+	}{
+		{name: "all", q: repository.DomainQuery{}, want: "expired.com,week.com,watch.net,month.com,ok.com", total: 5},
+		{name: "mode", q: repository.DomainQuery{Mode: domain.ModeMonitor}, want: "expired.com,week.com,month.com,ok.com", total: 4},
+		{name: "archived", q: repository.DomainQuery{Archived: true}, want: "archived.org", total: 1},
+		{name: "expired", q: repository.DomainQuery{Bucket: repository.BucketExpired}, want: "expired.com", total: 1},
+		{name: "within a week", q: repository.DomainQuery{Bucket: repository.BucketWeek}, want: "week.com,watch.net", total: 2},
+		{name: "within a month", q: repository.DomainQuery{Bucket: repository.BucketMonth}, want: "month.com", total: 1},
+		{name: "ok", q: repository.DomainQuery{Bucket: repository.BucketOK}, want: "ok.com", total: 1},
+		{name: "failing", q: repository.DomainQuery{Bucket: repository.BucketFailing}, want: "ok.com", total: 1},
+		{name: "search registrar", q: repository.DomainQuery{Search: " ALPHA "}, want: "expired.com,month.com", total: 2},
+		{name: "search name", q: repository.DomainQuery{Search: "week"}, want: "week.com", total: 1},
+		{name: "search wildcards", q: repository.DomainQuery{Search: "a_r"}, want: "week.com", total: 1},
+		{name: "search percent", q: repository.DomainQuery{Search: "%"}, want: "", total: 0},
+		{name: "tag", q: repository.DomainQuery{Tag: "Prod"}, want: "expired.com,month.com", total: 2},
+		{name: "registrar", q: repository.DomainQuery{Registrar: "Alpha Names"}, want: "expired.com,month.com", total: 2},
+		{name: "owner", q: repository.DomainQuery{Owner: "ops"}, want: "week.com", total: 1},
+		{name: "combined", q: repository.DomainQuery{Mode: domain.ModeMonitor, Tag: "prod", Bucket: repository.BucketMonth}, want: "month.com", total: 1},
+		{name: "sort by name", q: repository.DomainQuery{Sort: repository.SortName}, want: "expired.com,month.com,ok.com,watch.net,week.com", total: 5},
+		{name: "sort descending", q: repository.DomainQuery{Sort: repository.SortName, Desc: true}, want: "week.com,watch.net,ok.com,month.com,expired.com", total: 5},
+		{name: "ties by ID", q: repository.DomainQuery{Sort: repository.SortLastChecked, Registrar: "Alpha Names"}, want: "", total: 2},
+		{name: "second page", q: repository.DomainQuery{Sort: repository.SortName, Page: 2, PageSize: 2}, want: "ok.com,watch.net", total: 5},
+		{name: "last page", q: repository.DomainQuery{Sort: repository.SortName, Page: 3, PageSize: 2}, want: "week.com", total: 5},
+		{name: "past the last page", q: repository.DomainQuery{Page: 9}, want: "", total: 5},
+	}
+
+	for _, tt := range tests {
+		page, err := stores.Domains.Query(ctx, tt.q)
+		if err != nil {
+			t.Errorf("Query(%s) unexpected error: %v", tt.name, err)
+			continue
+		}
+		if page.Total != tt.total {
+			t.Errorf("Query(%s) total = %d, want %d", tt.name, page.Total, tt.total)
+		}
+		if tt.name == "ties by ID" {
+			if len(page.Domains) != 2 || page.Domains[0].ID > page.Domains[1].ID {
+				t.Errorf("Query(%s) = %s, want both domains ordered by ID", tt.name, names(page.Domains))
+			}
+			continue
+		}
+		if got := names(page.Domains); got != tt.want {
+			t.Errorf("Query(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Pages carry what the domains of GetByID carry
+	page, err := stores.Domains.Query(ctx, repository.DomainQuery{Search: "ok.com"})
+	if err != nil || len(page.Domains) != 1 {
+		t.Fatalf("Query() = %v, %v; want ok.com", page, err)
+	}
+	if got := page.Domains[0]; got.LookupFailures != 2 || got.Tags == nil {
+		t.Errorf("Queried domain has %d lookup failures and tags %#v, want 2 and an empty list", got.LookupFailures, got.Tags)
+	}
+	if page.Page != 1 || page.PageSize != repository.DefaultPageSize {
+		t.Errorf("Query() page %d of size %d, want 1 of size %d", page.Page, page.PageSize, repository.DefaultPageSize)
+	}
+
+	if owners, err := stores.Domains.Owners(ctx); err != nil || strings.Join(owners, ",") != "ops,web" {
+		t.Errorf("Owners() = %v, %v; want ops,web", owners, err)
+	}
+	if registrars, err := stores.Domains.Registrars(ctx); err != nil || strings.Join(registrars, ",") != "Alpha Names,Beta_Reg,Data Rx" {
+		t.Errorf("Registrars() = %v, %v; want Alpha Names,Beta_Reg,Data Rx", registrars, err)
+	}
+
+	_, err = stores.Domains.Query(ctx, repository.DomainQuery{Bucket: "soon"})
+	wantError(t, err, "invalid status bucket")
+	_, err = stores.Domains.Query(ctx, repository.DomainQuery{Sort: "name; DROP TABLE domains"})
+	wantError(t, err, "invalid sort order")
+	_, err = stores.Domains.Query(ctx, repository.DomainQuery{Mode: "own"})
+	wantError(t, err, "invalid domain mode")
+}
+
+func testDomainDeleteCascades(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+	other := createDomain(t, stores, "other.com", now)
+
+	for _, owner := range []*domain.Domain{d, other} {
+		if err := stores.Alerts.Create(ctx, &domain.Alert{DomainID: owner.ID, DomainName: owner.Name, ExpirationDate: now, SentAt: now}); err != nil {
+			t.Fatalf("Failed to create alert: %v", err)
+		}
+		c := &domain.HTTPCheck{DomainID: owner.ID, URL: "https://" + owner.Name, NextCheck: now}
+		if err := stores.HTTPChecks.Create(ctx, c); err != nil {
+			t.Fatalf("Failed to create http check: %v", err)
+		}
+		if err := stores.HTTPChecks.CreateResult(ctx, &domain.HTTPCheckResult{CheckID: c.ID, CheckedAt: now}); err != nil {
+			t.Fatalf("Failed to create http check result: %v", err)
+		}
+		if _, err := stores.Lookalikes.AddCandidates(ctx, owner.ID, []*domain.Lookalike{{Name: "x" + owner.Name}}); err != nil {
+			t.Fatalf("Failed to add lookalike: %v", err)
+		}
+	}
+
+	if err := stores.Domains.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if _, err := stores.Domains.GetByID(ctx, d.ID); err == nil {
+		t.Error("deleted domain still exists")
+	}
+	if alerts, _ := stores.Alerts.GetByDomainID(ctx, d.ID); len(alerts) != 0 {
+		t.Errorf("deleted domain kept %d alerts", len(alerts))
+	}
+	if checks, _ := stores.HTTPChecks.GetByDomainID(ctx, d.ID); len(checks) != 0 {
+		t.Errorf("deleted domain kept %d http checks", len(checks))
+	}
+	if count, _ := stores.Lookalikes.CountByDomainID(ctx, d.ID); count != 0 {
+		t.Errorf("deleted domain kept %d lookalikes", count)
+	}
+	if count, _ := stores.HTTPChecks.CountResultsOlderThan(ctx, now.Add(time.Hour)); count != 1 {
+		t.Errorf("%d http check results left, want only the other domain's", count)
+	}
+	if alerts, _ := stores.Alerts.GetByDomainID(ctx, other.ID); len(alerts) != 1 {
+		t.Errorf("other domain has %d alerts, want 1", len(alerts))
+	}
+
+	wantError(t, stores.Domains.Delete(ctx, d.ID), "domain not found")
+}
+
+func testDomainCheckLeases(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	lease := 5 * time.Minute
+
+	overdue := createDomain(t, stores, "overdue.com", now.Add(-time.Hour))
+	due := createDomain(t, stores, "due.com", now)
+	later := createDomain(t, stores, "later.com", now.Add(time.Hour))
+	paused := createDomain(t, stores, "paused.com", now.Add(-time.Hour))
+	if err := stores.Domains.SetState(ctx, paused.ID, domain.StatePaused, now); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+
+	got, err := stores.Domains.GetDomainsForCheck(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("GetDomainsForCheck() unexpected error: %v", err)
+	}
+	if names(got) != "overdue.com,due.com" {
+		t.Errorf("GetDomainsForCheck() = %s, want active due domains, most overdue first", names(got))
+	}
+	if got, _ := stores.Domains.GetDomainsForCheck(ctx, now, 1); names(got) != "overdue.com" {
+		t.Errorf("GetDomainsForCheck() with limit 1 = %s", names(got))
+	}
+
+	claim := func(id, owner string, at time.Time) bool {
+		t.Helper()
+		ok, err := stores.Domains.ClaimDomain(ctx, id, owner, at, lease)
+		if err != nil {
+			t.Fatalf("ClaimDomain() unexpected error: %v", err)
+		}
+		return ok
+	}
+	if !claim(overdue.ID, "a", now) {
+		t.Fatal("ClaimDomain() of a due domain = false")
+	}
+	if claim(overdue.ID, "b", now) {
+		t.Error("ClaimDomain() of a leased domain = true")
+	}
+	if claim(paused.ID, "a", now) {
+		t.Error("ClaimDomain() of a paused domain = true")
+	}
+	if got, _ := stores.Domains.GetDomainsForCheck(ctx, now, 10); names(got) != "due.com" {
+		t.Errorf("GetDomainsForCheck() = %s, want leased domains left out", names(got))
+	}
+
+	// A lease that ran out can be claimed again and counts another attempt
+	if !claim(overdue.ID, "b", now.Add(lease+time.Second)) {
+		t.Fatal("ClaimDomain() of an expired lease = false")
+	}
+	got1, _ := stores.Domains.GetByID(ctx, overdue.ID)
+	if got1.LeaseOwner != "b" || got1.LeaseUntil == nil || got1.CheckAttempts != 2 {
+		t.Errorf("lease = %q until %v with %d attempts, want b with 2", got1.LeaseOwner, got1.LeaseUntil, got1.CheckAttempts)
+	}
+
+	// Only the owner defers or releases
+	next := now.Add(time.Hour)
+	if err := stores.Domains.DeferCheck(ctx, overdue.ID, "a", now, next); err != nil {
+		t.Fatalf("DeferCheck() unexpected error: %v", err)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, overdue.ID); got.LeaseOwner != "b" {
+		t.Error("DeferCheck() by another owner ended the lease")
+	}
+	if err := stores.Domains.DeferCheck(ctx, overdue.ID, "b", now, next); err != nil {
+		t.Fatalf("DeferCheck() unexpected error: %v", err)
+	}
+	got1, _ = stores.Domains.GetByID(ctx, overdue.ID)
+	if got1.LeaseOwner != "" || got1.LeaseUntil != nil || got1.CheckAttempts != 1 || !got1.NextCheck.Equal(next) {
+		t.Errorf("after DeferCheck() = owner %q until %v, %d attempts, next %v", got1.LeaseOwner, got1.LeaseUntil, got1.CheckAttempts, got1.NextCheck)
+	}
+
+	// A check asked for now ignores the schedule but not a running check
+	if ok, err := stores.Domains.ClaimDomainNow(ctx, overdue.ID, "a", now, lease); err != nil || !ok {
+		t.Fatalf("ClaimDomainNow() = %v, %v; want true", ok, err)
+	}
+	if ok, _ := stores.Domains.ClaimDomainNow(ctx, overdue.ID, "b", now, lease); ok {
+		t.Error("ClaimDomainNow() of a leased domain = true")
+	}
+	if ok, _ := stores.Domains.ClaimDomainNow(ctx, paused.ID, "b", now, lease); ok {
+		t.Error("ClaimDomainNow() of a paused domain = true")
+	}
+	if err := stores.Domains.ReleaseDomain(ctx, overdue.ID, "a", now); err != nil {
+		t.Fatalf("ReleaseDomain() unexpected error: %v", err)
+	}
+	got1, _ = stores.Domains.GetByID(ctx, overdue.ID)
+	if got1.LeaseOwner != "" || got1.LeaseUntil != nil || got1.CheckAttempts != 0 {
+		t.Errorf("after ReleaseDomain() = owner %q until %v, %d attempts", got1.LeaseOwner, got1.LeaseUntil, got1.CheckAttempts)
+	}
+
+	if !claim(due.ID, "a", now) {
+		t.Error("ClaimDomain() of a due domain = false")
+	}
+
+	// Only the lease owner saves the outcome of a check
+	checked, _ := stores.Domains.GetByID(ctx, due.ID)
+	checked.Registrar = "Stale Registrar"
+	if err := stores.Domains.UpdateLeased(ctx, checked, "b", now); !errors.Is(err, repository.ErrLeaseLost) {
+		t.Errorf("UpdateLeased() by another owner error = %v, want ErrLeaseLost", err)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, due.ID); got.Registrar == "Stale Registrar" {
+		t.Error("UpdateLeased() by another owner saved the domain")
+	}
+	checked.Registrar = "New Registrar"
+	if err := stores.Domains.UpdateLeased(ctx, checked, "a", now); err != nil {
+		t.Fatalf("UpdateLeased() unexpected error: %v", err)
+	}
+
+	// Once the lease ran out its owner no longer writes, even before another claim
+	expired := now.Add(lease + time.Second)
+	checked.Registrar = "Late Registrar"
+	if err := stores.Domains.UpdateLeased(ctx, checked, "a", expired); !errors.Is(err, repository.ErrLeaseLost) {
+		t.Errorf("UpdateLeased() after the lease ran out error = %v, want ErrLeaseLost", err)
+	}
+	if err := stores.Domains.ReleaseDomain(ctx, due.ID, "a", expired); err != nil {
+		t.Fatalf("ReleaseDomain() unexpected error: %v", err)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, due.ID); got.Registrar != "New Registrar" || got.LeaseOwner != "a" {
+		t.Errorf("after writes on an expired lease = registrar %q owner %q, want both unchanged", got.Registrar, got.LeaseOwner)
+	}
+
+	// A failed check ends the lease but its attempt still counts
+	retry := now.Add(5 * time.Minute)
+	if err := stores.Domains.RetryCheck(ctx, due.ID, "b", now, retry); err != nil {
+		t.Fatalf("RetryCheck() unexpected error: %v", err)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, due.ID); got.LeaseOwner != "a" {
+		t.Error("RetryCheck() by another owner ended the lease")
+	}
+	if err := stores.Domains.RetryCheck(ctx, due.ID, "a", now, retry); err != nil {
+		t.Fatalf("RetryCheck() unexpected error: %v", err)
+	}
+	got1, _ = stores.Domains.GetByID(ctx, due.ID)
+	if got1.Registrar != "New Registrar" || got1.LeaseOwner != "" || got1.LeaseUntil != nil || got1.CheckAttempts != 1 || !got1.NextCheck.Equal(retry) {
+		t.Errorf("after RetryCheck() = registrar %q owner %q until %v, %d attempts, next %v", got1.Registrar, got1.LeaseOwner, got1.LeaseUntil, got1.CheckAttempts, got1.NextCheck)
+	}
+	if !claim(due.ID, "b", retry) {
+		t.Fatal("ClaimDomain() of a retried domain = false")
+	}
+	if got, _ := stores.Domains.GetByID(ctx, due.ID); got.CheckAttempts != 2 {
+		t.Errorf("attempts after a retried claim = %d, want 2", got.CheckAttempts)
+	}
+
+	// A queued check makes an active domain due, never later than it was
+	early := createDomain(t, stores, "early.com", now.Add(-time.Minute))
+	for _, d := range []*domain.Domain{later, early, paused} {
+		if err := stores.Domains.QueueCheck(ctx, d.ID, now); err != nil {
+			t.Fatalf("QueueCheck() unexpected error: %v", err)
+		}
+	}
+	if got, _ := stores.Domains.GetByID(ctx, later.ID); !got.NextCheck.Equal(now) {
+		t.Errorf("next check after QueueCheck() = %v, want %v", got.NextCheck, now)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, early.ID); !got.NextCheck.Equal(now.Add(-time.Minute)) {
+		t.Errorf("next check of a due domain after QueueCheck() = %v, want it unchanged", got.NextCheck)
+	}
+	if got, _ := stores.Domains.GetByID(ctx, paused.ID); !got.NextCheck.Equal(now.Add(-time.Hour)) {
+		t.Errorf("next check of a paused domain after QueueCheck() = %v, want it unchanged", got.NextCheck)
+	}
+}
+
+func testDomainRetention(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	for i, name := range []string{"old1.com", "old2.com", "recent.com"} {
+		d := createDomain(t, stores, name, now)
+		archivedAt := now.Add(-time.Duration(3-i) * 24 * time.Hour)
+		if name == "recent.com" {
+			archivedAt = now
+		}
+		if err := stores.Domains.SetState(ctx, d.ID, domain.StateArchived, archivedAt); err != nil {
+			t.Fatalf("SetState() unexpected error: %v", err)
+		}
+	}
+	createDomain(t, stores, "active.com", now.Add(-10*24*time.Hour))
+
+	cutoff := now.Add(-time.Hour)
+	if count, err := stores.Domains.CountOlderThan(ctx, cutoff); err != nil || count != 2 {
+		t.Errorf("CountOlderThan() = %d, %v; want 2", count, err)
+	}
+	if deleted, err := stores.Domains.DeleteOlderThan(ctx, cutoff, 1); err != nil || deleted != 1 {
+		t.Errorf("DeleteOlderThan() = %d, %v; want 1", deleted, err)
+	}
+	if _, err := stores.Domains.GetByName(ctx, "old1.com"); err == nil {
+		t.Error("DeleteOlderThan() kept the oldest archived domain")
+	}
+	if deleted, _ := stores.Domains.DeleteOlderThan(ctx, cutoff, 10); deleted != 1 {
+		t.Errorf("DeleteOlderThan() = %d, want the remaining 1", deleted)
+	}
+
+	all, _ := stores.Domains.GetAll(ctx)
+	if len(all) != 2 {
+		t.Errorf("GetAll() = %s, want recent.com and active.com", names(all))
+	}
+}
+
+func testAlerts(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+
+	sent := &domain.Alert{DomainID: d.ID, DomainName: d.Name, Threshold: 1, ExpirationDate: d.ExpirationDate, SentAt: now.Add(-2 * time.Hour), Success: true}
+	if err := stores.Alerts.Create(ctx, sent); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if sent.ID == "" || sent.DedupKey != sent.ID || sent.Type != domain.AlertTypeExpiration || sent.Status != domain.AlertStatusSent {
+		t.Errorf("Create() left defaults unset: %+v", sent)
+	}
+	failed := &domain.Alert{DomainID: d.ID, DomainName: d.Name, Type: domain.AlertTypeHTTPCheck, ExpirationDate: now, SentAt: now.Add(-time.Hour)}
+	if err := stores.Alerts.Create(ctx, failed); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if failed.Status != domain.AlertStatusFailed {
+		t.Errorf("Status of an unsuccessful alert = %q, want failed", failed.Status)
+	}
+
+	got, err := stores.Alerts.GetByID(ctx, sent.ID)
+	if err != nil || got.DomainName != d.Name || !got.SentAt.Equal(sent.SentAt) || !got.Success {
+		t.Errorf("GetByID() = %+v, %v; want %+v", got, err, sent)
+	}
+	_, err = stores.Alerts.GetByID(ctx, "missing")
+	wantError(t, err, "alert not found: missing")
+
+	byDomain, err := stores.Alerts.GetByDomainID(ctx, d.ID)
+	if err != nil || len(byDomain) != 2 || byDomain[0].ID != failed.ID {
+		t.Errorf("GetByDomainID() = %v, %v; want both alerts, newest first", byDomain, err)
+	}
+	if recent, _ := stores.Alerts.GetRecentAlerts(ctx, now.Add(-90*time.Minute)); len(recent) != 1 || recent[0].ID != failed.ID {
+		t.Errorf("GetRecentAlerts() = %v, want the failed alert", recent)
+	}
+	if failedAlerts, _ := stores.Alerts.GetFailedAlerts(ctx); len(failedAlerts) != 1 || failedAlerts[0].ID != failed.ID {
+		t.Errorf("GetFailedAlerts() = %v, want the failed alert", failedAlerts)
+	}
+
+	next := now.Add(10 * time.Minute)
+	failed.Attempts = 3
+	failed.NextAttemptAt = &next
+	failed.ErrorMessage = "webhook down"
+	if err := stores.Alerts.Finalize(ctx, failed); err != nil {
+		t.Fatalf("Finalize() unexpected error: %v", err)
+	}
+	got, _ = stores.Alerts.GetByID(ctx, failed.ID)
+	if got.Attempts != 3 || got.NextAttemptAt == nil || !got.NextAttemptAt.Equal(next) || got.ErrorMessage != "webhook down" {
+		t.Errorf("Finalize() did not save the outcome: %+v", got)
+	}
+	wantError(t, stores.Alerts.Finalize(ctx, &domain.Alert{ID: "missing"}), "alert not found: missing")
+}
+
+func testAlertClaims(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	stale := time.Hour
+	d := createDomain(t, stores, "example.com", now)
+
+	claim := func(key string, sentAt time.Time) (*domain.Alert, bool) {
+		t.Helper()
+		a := &domain.Alert{DomainID: d.ID, DomainName: d.Name, ExpirationDate: d.ExpirationDate, DedupKey: key, SentAt: sentAt}
+		ok, err := stores.Alerts.Claim(ctx, a, stale)
+		if err != nil {
+			t.Fatalf("Claim() unexpected error: %v", err)
+		}
+		return a, ok
+	}
+
+	first, ok := claim("fresh", now)
+	if !ok || first.Status != domain.AlertStatusPending {
+		t.Fatalf("Claim() of a new key = %v with status %q, want a pending claim", ok, first.Status)
+	}
+	if _, ok := claim("fresh", now); ok {
+		t.Error("Claim() of a live pending key = true")
+	}
+
+	// A pending claim whose sender died is taken over under its ID
+	abandoned, _ := claim("abandoned", now.Add(-2*time.Hour))
+	takeover, ok := claim("abandoned", now)
+	if !ok || takeover.ID != abandoned.ID {
+		t.Errorf("Claim() of a stale key = %v with ID %s, want a takeover of %s", ok, takeover.ID, abandoned.ID)
+	}
+
+	// Delivered alerts are never claimed again
+	first.Status = domain.AlertStatusSent
+	first.Success = true
+	if err := stores.Alerts.Finalize(ctx, first); err != nil {
+		t.Fatalf("Finalize() unexpected error: %v", err)
+	}
+	if _, ok := claim("fresh", now.Add(-2*time.Hour)); ok {
+		t.Error("Claim() of a delivered key = true")
+	}
+
+	// A failed alert is retried once its next attempt is due
+	retry, _ := claim("retry", now.Add(-30*time.Minute))
+	next := now.Add(time.Minute)
+	retry.Status = domain.AlertStatusFailed
+	retry.NextAttemptAt = &next
+	retry.Attempts = 1
+	if err := stores.Alerts.Finalize(ctx, retry); err != nil {
+		t.Fatalf("Finalize() unexpected error: %v", err)
+	}
+	if due, _ := stores.Alerts.GetDueRetries(ctx, now, stale, 10); len(due) != 0 {
+		t.Errorf("GetDueRetries() before the next attempt = %v, want none", due)
+	}
+	later := now.Add(2 * time.Minute)
+	due, err := stores.Alerts.GetDueRetries(ctx, later, stale, 10)
+	if err != nil || len(due) != 1 || due[0].ID != retry.ID {
+		t.Errorf("GetDueRetries() = %v, %v; want the failed alert", due, err)
+	}
+	if ok, err := stores.Alerts.ClaimRetry(ctx, retry.ID, now, stale); err != nil || ok {
+		t.Errorf("ClaimRetry() before the next attempt = %v, %v; want false", ok, err)
+	}
+	if ok, err := stores.Alerts.ClaimRetry(ctx, retry.ID, later, stale); err != nil || !ok {
+		t.Errorf("ClaimRetry() = %v, %v; want true", ok, err)
+	}
+	if ok, _ := stores.Alerts.ClaimRetry(ctx, retry.ID, later, stale); ok {
+		t.Error("ClaimRetry() of a claimed retry = true")
+	}
+
+	// An abandoned retry is picked up once stale
+	if due, _ := stores.Alerts.GetDueRetries(ctx, later.Add(2*stale), stale, 10); len(due) != 2 {
+		t.Errorf("GetDueRetries() after the claims went stale = %d alerts, want 2", len(due))
+	}
+
+	// A manual resend resets the attempts of a failed or dead alert only
+	retry.Status = domain.AlertStatusDead
+	if err := stores.Alerts.Finalize(ctx, retry); err != nil {
+		t.Fatalf("Finalize() unexpected error: %v", err)
+	}
+	if ok, err := stores.Alerts.ClaimResend(ctx, retry.ID, now); err != nil || !ok {
+		t.Errorf("ClaimResend() of a dead alert = %v, %v; want true", ok, err)
+	}
+	got, _ := stores.Alerts.GetByID(ctx, retry.ID)
+	if got.Status != domain.AlertStatusPending || got.Attempts != 0 || got.NextAttemptAt != nil {
+		t.Errorf("after ClaimResend() = %+v, want a pending alert with no attempts", got)
+	}
+	if ok, _ := stores.Alerts.ClaimResend(ctx, first.ID, now); ok {
+		t.Error("ClaimResend() of a delivered alert = true")
+	}
+}
+
+func testAlertRetention(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	cutoff := now.Add(-24 * time.Hour)
+	d := createDomain(t, stores, "example.com", now)
+
+	old := now.Add(-48 * time.Hour)
+	alerts := []*domain.Alert{
+		{DedupKey: "old-sent", Type: domain.AlertTypeHTTPCheck, SentAt: old, Success: true},
+		{DedupKey: "old-dead", Type: domain.AlertTypeHTTPCheck, SentAt: old.Add(time.Minute), Status: domain.AlertStatusDead},
+		{DedupKey: "old-failed", Type: domain.AlertTypeHTTPCheck, SentAt: old},
+		{DedupKey: "old-expiry-current", SentAt: old, ExpirationDate: now.Add(24 * time.Hour), Success: true},
+		{DedupKey: "old-expiry-past", SentAt: old, ExpirationDate: old, Success: true},
+		{DedupKey: "recent", Type: domain.AlertTypeHTTPCheck, SentAt: now, Success: true},
+	}
+	for _, a := range alerts {
+		a.DomainID = d.ID
+		a.DomainName = d.Name
+		if a.ExpirationDate.IsZero() {
+			a.ExpirationDate = now
+		}
+		if err := stores.Alerts.Create(ctx, a); err != nil {
+			t.Fatalf("Failed to create alert %s: %v", a.DedupKey, err)
+		}
+	}
+
+	if count, err := stores.Alerts.CountOlderThan(ctx, cutoff); err != nil || count != 3 {
+		t.Errorf("CountOlderThan() = %d, %v; want 3", count, err)
+	}
+	if deleted, err := stores.Alerts.DeleteOlderThan(ctx, cutoff, 2); err != nil || deleted != 2 {
+		t.Errorf("DeleteOlderThan() = %d, %v; want 2", deleted, err)
+	}
+	if deleted, _ := stores.Alerts.DeleteOlderThan(ctx, cutoff, 10); deleted != 1 {
+		t.Errorf("DeleteOlderThan() = %d, want the remaining 1", deleted)
+	}
+
+	left, _ := stores.Alerts.GetByDomainID(ctx, d.ID)
+	kept := make(map[string]bool)
+	for _, a := range left {
+		kept[a.DedupKey] = true
+	}
+	for _, key := range []string{"old-failed", "old-expiry-current", "recent"} {
+		if !kept[key] {
+			t.Errorf("retention deleted %s", key)
+		}
+	}
+	if len(left) != 3 {
+		t.Errorf("%d alerts left, want 3", len(left))
+	}
+}
+
+func testConfig(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+
+	config, err := stores.Config.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if config.GetMonitoringInterval() != 24*time.Hour || len(config.AlertThresholds) != 4 || len(config.CheckPolicy) == 0 {
+		t.Errorf("default config = %+v", config)
+	}
+
+	config.GoogleChatWebhook = "https://chat.example.com/hook"
+	config.SetAlertThresholds([]time.Duration{7 * 24 * time.Hour})
+	config.AlertCriticality = domain.CriticalityHigh
+	if err := stores.Config.Update(ctx, config); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	got, err := stores.Config.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if got.ID != 1 || got.GoogleChatWebhook != config.GoogleChatWebhook || len(got.AlertThresholds) != 1 || got.AlertThresholds[0] != 7*24*time.Hour || got.AlertCriticality != domain.CriticalityHigh {
+		t.Errorf("Get() after Update() = %+v", got)
+	}
+
+	// The default configuration is revision 1 and each save adds one
+	got.SetRetentionPeriod(30 * 24 * time.Hour)
+	if err := stores.Config.Update(ctx, got); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	revisions, err := stores.Config.Revisions(ctx, 10)
+	if err != nil {
+		t.Fatalf("Revisions() unexpected error: %v", err)
+	}
+	if len(revisions) != 3 || revisions[0].Revision != 3 || revisions[2].Revision != 1 {
+		t.Fatalf("Revisions() = %+v, want revisions 3, 2 and 1", revisions)
+	}
+	if revisions[0].Config().GetRetentionPeriod() != 30*24*time.Hour || revisions[1].GoogleChatWebhook != config.GoogleChatWebhook || revisions[1].AlertCriticality != domain.CriticalityHigh || len(revisions[2].AlertThresholds) != 4 {
+		t.Errorf("Revisions() = %+v, want the saved configurations", revisions)
+	}
+	if limited, err := stores.Config.Revisions(ctx, 2); err != nil || len(limited) != 2 || limited[0].Revision != 3 {
+		t.Errorf("Revisions(2) = %+v, %v; want the newest two", limited, err)
+	}
+
+	restored, err := stores.Config.Rollback(ctx, 1)
+	if err != nil {
+		t.Fatalf("Rollback() unexpected error: %v", err)
+	}
+	if restored.GoogleChatWebhook != "" || len(restored.AlertThresholds) != 4 || restored.GetRetentionPeriod() != 90*24*time.Hour {
+		t.Errorf("Rollback(1) = %+v, want the default configuration", restored)
+	}
+	if got, err := stores.Config.Get(ctx); err != nil || got.GoogleChatWebhook != "" || len(got.AlertThresholds) != 4 || got.AlertCriticality != "" {
+		t.Errorf("Get() after Rollback() = %+v, %v; want the default configuration", got, err)
+	}
+	rev, err := stores.Config.GetRevision(ctx, 4)
+	if err != nil {
+		t.Fatalf("GetRevision() unexpected error: %v", err)
+	}
+	if rev.RestoredFrom != 1 || len(rev.AlertThresholds) != 4 {
+		t.Errorf("GetRevision(4) = %+v, want a revision restored from 1", rev)
+	}
+
+	if _, err := stores.Config.Rollback(ctx, 99); err == nil {
+		t.Error("Rollback() of a missing revision succeeded, want an error")
+	}
+	if _, err := stores.Config.GetRevision(ctx, 99); err == nil {
+		t.Error("GetRevision() of a missing revision succeeded, want an error")
+	}
+}
+
+func testHTTPChecks(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+	paused := createDomain(t, stores, "paused.com", now)
+	if err := stores.Domains.SetState(ctx, paused.ID, domain.StatePaused, now); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+
+	c := &domain.HTTPCheck{DomainID: d.ID, URL: "https://example.com", ExpectedStatus: 200, FailureThreshold: 3, NextCheck: now.Add(-time.Minute)}
+	if err := stores.HTTPChecks.Create(ctx, c); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	later := &domain.HTTPCheck{DomainID: d.ID, URL: "https://example.com/health", NextCheck: now.Add(time.Hour)}
+	if err := stores.HTTPChecks.Create(ctx, later); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if err := stores.HTTPChecks.Create(ctx, &domain.HTTPCheck{DomainID: paused.ID, URL: "https://paused.com", NextCheck: now.Add(-time.Minute)}); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+
+	got, err := stores.HTTPChecks.GetByID(ctx, c.ID)
+	if err != nil || got.URL != c.URL || got.ExpectedStatus != 200 || got.LastChecked != nil {
+		t.Errorf("GetByID() = %+v, %v; want %+v", got, err, c)
+	}
+	_, err = stores.HTTPChecks.GetByID(ctx, "missing")
+	wantError(t, err, "http check not found: missing")
+
+	if checks, _ := stores.HTTPChecks.GetByDomainID(ctx, d.ID); len(checks) != 2 {
+		t.Errorf("GetByDomainID() = %d checks, want 2", len(checks))
+	}
+	due, err := stores.HTTPChecks.GetDueChecks(ctx, now)
+	if err != nil || len(due) != 1 || due[0].ID != c.ID {
+		t.Errorf("GetDueChecks() = %v, %v; want the due check of the active domain", due, err)
+	}
+
+	checked := now
+	c.LastChecked = &checked
+	c.ConsecutiveFailures = 2
+	c.NextCheck = now.Add(time.Minute)
+	if err := stores.HTTPChecks.Update(ctx, c); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	got, _ = stores.HTTPChecks.GetByID(ctx, c.ID)
+	if got.LastChecked == nil || !got.LastChecked.Equal(now) || got.ConsecutiveFailures != 2 || !got.NextCheck.Equal(c.NextCheck) {
+		t.Errorf("Update() did not save the check: %+v", got)
+	}
+	wantError(t, stores.HTTPChecks.Update(ctx, &domain.HTTPCheck{ID: "missing"}), "http check not found: missing")
+
+	for i := 0; i < 3; i++ {
+		res := &domain.HTTPCheckResult{CheckID: c.ID, CheckedAt: now.Add(-time.Duration(i) * time.Hour), StatusCode: 200 + i, Success: i == 0}
+		if err := stores.HTTPChecks.CreateResult(ctx, res); err != nil {
+			t.Fatalf("CreateResult() unexpected error: %v", err)
+		}
+	}
+	results, err := stores.HTTPChecks.GetResults(ctx, c.ID, 2)
+	if err != nil || len(results) != 2 || results[0].StatusCode != 200 || results[1].StatusCode != 201 {
+		t.Errorf("GetResults() = %v, %v; want the 2 newest", results, err)
+	}
+
+	cutoff := now.Add(-30 * time.Minute)
+	if count, err := stores.HTTPChecks.CountResultsOlderThan(ctx, cutoff); err != nil || count != 2 {
+		t.Errorf("CountResultsOlderThan() = %d, %v; want 2", count, err)
+	}
+	if deleted, err := stores.HTTPChecks.DeleteResultsOlderThan(ctx, cutoff, 1); err != nil || deleted != 1 {
+		t.Errorf("DeleteResultsOlderThan() = %d, %v; want 1", deleted, err)
+	}
+	if results, _ := stores.HTTPChecks.GetResults(ctx, c.ID, 10); len(results) != 2 || results[1].StatusCode != 201 {
+		t.Errorf("GetResults() after retention = %v, want the oldest deleted first", results)
+	}
+
+	if err := stores.HTTPChecks.Delete(ctx, c.ID); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if results, _ := stores.HTTPChecks.GetResults(ctx, c.ID, 10); len(results) != 0 {
+		t.Errorf("deleted check kept %d results", len(results))
+	}
+	wantError(t, stores.HTTPChecks.Delete(ctx, c.ID), "http check not found")
+}
+
+func testLookalikes(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+	paused := createDomain(t, stores, "paused.com", now)
+	if err := stores.Domains.SetState(ctx, paused.ID, domain.StatePaused, now); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+
+	candidates := []*domain.Lookalike{{Name: "exampel.com", Kind: "transposition"}, {Name: "examp1e.com", Kind: "homoglyph"}, {Name: "xample.com", Kind: "omission"}}
+	added, err := stores.Lookalikes.AddCandidates(ctx, d.ID, candidates)
+	if err != nil || added != 3 {
+		t.Fatalf("AddCandidates() = %d, %v; want 3", added, err)
+	}
+	added, err = stores.Lookalikes.AddCandidates(ctx, d.ID, []*domain.Lookalike{{Name: "exampel.com"}, {Name: "examplee.com"}})
+	if err != nil || added != 1 {
+		t.Errorf("AddCandidates() of known names = %d, %v; want only the new one added", added, err)
+	}
+	if _, err := stores.Lookalikes.AddCandidates(ctx, paused.ID, []*domain.Lookalike{{Name: "exampel.com"}}); err != nil {
+		t.Errorf("AddCandidates() of a name tracked for another domain: %v", err)
+	}
+
+	all, err := stores.Lookalikes.GetByDomainID(ctx, d.ID)
+	if err != nil || len(all) != 4 || all[0].Name != "examp1e.com" || all[0].DomainID != d.ID {
+		t.Errorf("GetByDomainID() = %v, %v; want 4 by name", all, err)
+	}
+	if count, err := stores.Lookalikes.CountByDomainID(ctx, d.ID); err != nil || count != 4 {
+		t.Errorf("CountByDomainID() = %d, %v; want 4", count, err)
+	}
+
+	// Never checked first, then least recently checked; recent checks and
+	// lookalikes of inactive domains are not due
+	checkedAt := func(l *domain.Lookalike, at time.Time, registered bool) {
+		t.Helper()
+		l.LastChecked = &at
+		l.Registered = registered
+		if registered {
+			l.Registrar = "Squatter Inc"
+			l.FirstSeen = &at
+		}
+		if err := stores.Lookalikes.Update(ctx, l); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+	}
+	checkedAt(all[0], now.Add(-2*time.Hour), true)
+	checkedAt(all[1], now.Add(-3*time.Hour), true)
+	checkedAt(all[2], now, false)
+
+	due, err := stores.Lookalikes.GetDueForCheck(ctx, now.Add(-time.Hour), 10)
+	if err != nil || len(due) != 3 || due[0].Name != all[3].Name || due[1].Name != all[1].Name || due[2].Name != all[0].Name {
+		t.Errorf("GetDueForCheck() = %v, %v; want %s, %s, %s", due, err, all[3].Name, all[1].Name, all[0].Name)
+	}
+	if due, _ := stores.Lookalikes.GetDueForCheck(ctx, now.Add(-time.Hour), 1); len(due) != 1 {
+		t.Errorf("GetDueForCheck() with limit 1 = %d lookalikes", len(due))
+	}
+
+	registered, err := stores.Lookalikes.GetRegisteredByDomainID(ctx, d.ID)
+	if err != nil || len(registered) != 2 || registered[0].ID != all[0].ID || registered[0].Registrar != "Squatter Inc" {
+		t.Errorf("GetRegisteredByDomainID() = %v, %v; want 2, most recently seen first", registered, err)
+	}
+
+	wantError(t, stores.Lookalikes.Update(ctx, &domain.Lookalike{ID: "missing"}), "lookalike not found: missing")
+}
+
+func testLeader(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	ttl := 30 * time.Second
+
+	acquire := func(holder string, at time.Time) bool {
+		t.Helper()
+		ok, err := stores.Leader.TryAcquire(ctx, "scheduler", holder, at, ttl)
+		if err != nil {
+			t.Fatalf("TryAcquire(%s) unexpected error: %v", holder, err)
+		}
+		return ok
+	}
+
+	if _, err := stores.Leader.Holder(ctx, "scheduler"); err == nil {
+		t.Error("Holder() of a lease never taken succeeded")
+	}
+	if !acquire("a", now) {
+		t.Fatal("a should acquire a free lease")
+	}
+	if acquire("b", now.Add(time.Second)) {
+		t.Error("b should not acquire a live lease")
+	}
+	if !acquire("a", now.Add(20*time.Second)) {
+		t.Error("a should renew its own lease")
+	}
+	if acquire("b", now.Add(40*time.Second)) {
+		t.Error("b should not acquire a renewed lease")
+	}
+	if !acquire("b", now.Add(51*time.Second)) {
+		t.Error("b should acquire an expired lease")
+	}
+	if holder, err := stores.Leader.Holder(ctx, "scheduler"); err != nil || holder != "b" {
+		t.Errorf("Holder() = %q, %v; want b", holder, err)
+	}
+
+	// Only the holder releases, and a released lease is free at once
+	if err := stores.Leader.Release(ctx, "scheduler", "a"); err != nil {
+		t.Fatalf("Release() unexpected error: %v", err)
+	}
+	if holder, _ := stores.Leader.Holder(ctx, "scheduler"); holder != "b" {
+		t.Errorf("Holder() after a release by another = %q, want b", holder)
+	}
+	if err := stores.Leader.Release(ctx, "scheduler", "b"); err != nil {
+		t.Fatalf("Release() unexpected error: %v", err)
+	}
+	if !acquire("a", now.Add(52*time.Second)) {
+		t.Error("a should acquire a released lease")
+	}
+}
+
+func testAudit(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+
+	for i, e := range []*domain.AuditEntry{
+		{Actor: "alice", Action: domain.AuditDomainCreate, ObjectType: domain.AuditObjectDomain, ObjectID: d.ID, ObjectName: d.Name, After: `{"name":"example.com"}`},
+		{Actor: "bob", Action: domain.AuditConfigUpdate, ObjectType: domain.AuditObjectConfig, ObjectID: "1", ObjectName: "configuration", Before: `{"a":1}`, After: `{"a":2}`},
+		{Actor: "alice", Action: domain.AuditDomainState, ObjectType: domain.AuditObjectDomain, ObjectID: d.ID, ObjectName: d.Name, SourceIP: "192.0.2.1"},
+		{Actor: "alice", Action: domain.AuditDomainDelete, ObjectType: domain.AuditObjectDomain, ObjectID: d.ID, ObjectName: d.Name, Before: `{"name":"example.com"}`},
+	} {
+		e.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		if err := stores.Audit.Create(ctx, e); err != nil {
+			t.Fatalf("Create() unexpected error: %v", err)
+		}
+		if e.ID == "" {
+			t.Fatal("Create() left the ID unset")
+		}
+	}
+
+	// Deleting the object keeps its history
+	if err := stores.Domains.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+
+	actions := func(page *repository.AuditPage) string {
+		var out []string
+		for _, e := range page.Entries {
+			out = append(out, e.Action)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name  string
+		q     repository.AuditQuery
+		want  string
+		total int
+	}{
+		{name: "all", q: repository.AuditQuery{}, want: "domain.delete,domain.state,config.update,domain.create", total: 4},
+		{name: "actor", q: repository.AuditQuery{Actor: "bob"}, want: "config.update", total: 1},
+		{name: "action", q: repository.AuditQuery{Action: domain.AuditDomainState}, want: "domain.state", total: 1},
+		{name: "object type", q: repository.AuditQuery{ObjectType: domain.AuditObjectDomain}, want: "domain.delete,domain.state,domain.create", total: 3},
+		{name: "object name", q: repository.AuditQuery{Object: "example.com"}, want: "domain.delete,domain.state,domain.create", total: 3},
+		{name: "object ID", q: repository.AuditQuery{Object: d.ID, Actor: " alice "}, want: "domain.delete,domain.state,domain.create", total: 3},
+		{name: "time range", q: repository.AuditQuery{Since: now.Add(time.Minute), Until: now.Add(3 * time.Minute)}, want: "domain.state,config.update", total: 2},
+		{name: "page", q: repository.AuditQuery{Page: 2, PageSize: 3}, want: "domain.create", total: 4},
+		{name: "no match", q: repository.AuditQuery{Actor: "carol"}, want: "", total: 0},
+	}
+
+	for _, tt := range tests {
+		page, err := stores.Audit.Query(ctx, tt.q)
+		if err != nil {
+			t.Errorf("Query(%s) unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := actions(page); got != tt.want || page.Total != tt.total {
+			t.Errorf("Query(%s) = %s of %d, want %s of %d", tt.name, got, page.Total, tt.want, tt.total)
+		}
+	}
+
+	page, err := stores.Audit.Query(ctx, repository.AuditQuery{Action: domain.AuditConfigUpdate})
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("Query() = %v, %v; want the config update", page, err)
+	}
+	if got := page.Entries[0]; got.Before != `{"a":1}` || got.After != `{"a":2}` || got.ObjectID != "1" || !got.CreatedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Config update entry = %+v", got)
+	}
+}
+
+func testCanceledContext(t *testing.T, stores repository.Stores) {
+	now := time.Now().Truncate(time.Second)
+	d := createDomain(t, stores, "example.com", now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := stores.Domains.GetByID(ctx, d.ID); err == nil {
+		t.Error("GetByID() with a canceled context succeeded")
+	}
+	d.Registrar = "Changed"
+	if err := stores.Domains.Update(ctx, d); err == nil {
+		t.Error("Update() with a canceled context succeeded")
+	}
+	if err := stores.Alerts.Finalize(ctx, &domain.Alert{ID: "any"}); err == nil {
+		t.Error("Finalize() with a canceled context succeeded")
+	}
+	if _, err := stores.Config.Get(ctx); err == nil {
+		t.Error("Config Get() with a canceled context succeeded")
+	}
+	if err := stores.Audit.Create(ctx, &domain.AuditEntry{Actor: "alice", Action: domain.AuditDomainCheck}); err == nil {
+		t.Error("Audit Create() with a canceled context succeeded")
+	}
+
+	if got, _ := stores.Domains.GetByID(context.Background(), d.ID); got.Registrar == "Changed" {
+		t.Error("Update() with a canceled context saved the domain")
+	}
+}
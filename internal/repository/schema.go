@@ -1,88 +1,63 @@
 package repository
 
-// SQLite schema
-const schema = `
-CREATE TABLE IF NOT EXISTS domains (
-    id TEXT PRIMARY KEY,
-    name TEXT NOT NULL UNIQUE,
-    expiration_date DATETIME NOT NULL,
-    nameservers TEXT NOT NULL,
-    registrant TEXT NOT NULL,
-    registrar TEXT NOT NULL,
-    last_checked DATETIME NOT NULL,
-    next_check DATETIME NOT NULL,
-    created_at DATETIME NOT NULL,
-    updated_at DATETIME NOT NULL
-);
-
-CREATE INDEX IF NOT EXISTS idx_domains_name ON domains(name);
-CREATE INDEX IF NOT EXISTS idx_domains_expiration_date ON domains(expiration_date);
-CREATE INDEX IF NOT EXISTS idx_domains_next_check ON domains(next_check);
-
-CREATE TABLE IF NOT EXISTS config (
-    id INTEGER PRIMARY KEY CHECK (id = 1),
-    monitoring_interval INTEGER NOT NULL,
-    alert_thresholds TEXT NOT NULL,
-    google_chat_webhook TEXT NOT NULL,
-    retention_period INTEGER NOT NULL,
-    updated_at DATETIME NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS alerts (
-    id TEXT PRIMARY KEY,
-    domain_id TEXT NOT NULL,
-    domain_name TEXT NOT NULL,
-    threshold INTEGER NOT NULL,
-    expiration_date DATETIME NOT NULL,
-    sent_at DATETIME NOT NULL,
-    success INTEGER NOT NULL,
-    error_message TEXT NOT NULL,
-    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
-);
-
-CREATE INDEX IF NOT EXISTS idx_alerts_domain_id ON alerts(domain_id);
-CREATE INDEX IF NOT EXISTS idx_alerts_sent_at ON alerts(sent_at);
-`
-
-
-// MySQL schema
-const schemaMySQL = `
-CREATE TABLE IF NOT EXISTS domains (
-    id VARCHAR(255) PRIMARY KEY,
-    name VARCHAR(255) NOT NULL UNIQUE,
-    expiration_date DATETIME NOT NULL,
-    nameservers JSON NOT NULL,
-    registrant TEXT NOT NULL,
-    registrar VARCHAR(255) NOT NULL,
-    last_checked DATETIME NOT NULL,
-    next_check DATETIME NOT NULL,
-    created_at DATETIME NOT NULL,
-    updated_at DATETIME NOT NULL,
-    INDEX idx_domains_name (name),
-    INDEX idx_domains_expiration_date (expiration_date),
-    INDEX idx_domains_next_check (next_check)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-
-CREATE TABLE IF NOT EXISTS config (
-    id INTEGER PRIMARY KEY,
-    monitoring_interval BIGINT NOT NULL,
-    alert_thresholds JSON NOT NULL,
-    google_chat_webhook TEXT NOT NULL,
-    retention_period BIGINT NOT NULL,
-    updated_at DATETIME NOT NULL
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-
-CREATE TABLE IF NOT EXISTS alerts (
-    id VARCHAR(255) PRIMARY KEY,
-    domain_id VARCHAR(255) NOT NULL,
-    domain_name VARCHAR(255) NOT NULL,
-    threshold BIGINT NOT NULL,
-    expiration_date DATETIME NOT NULL,
-    sent_at DATETIME NOT NULL,
-    success TINYINT(1) NOT NULL,
-    error_message TEXT NOT NULL,
-    INDEX idx_alerts_domain_id (domain_id),
-    INDEX idx_alerts_sent_at (sent_at),
-    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-`
+// Schema changes are numbered migrations under migrations/<driver>; see migrate.go.
+// The upgrades below bring a database of the first release, the only release
+// before versioning, up to the first migration.
+
+// baselineTables are the tables of the first release
+var baselineTables = []string{"domains", "config", "alerts"}
+
+// isBaselineTable reports whether the first release had a table
+func isBaselineTable(name string) bool {
+	for _, table := range baselineTables {
+		if table == name {
+			return true
+		}
+	}
+	return false
+}
+
+// columnUpgrade describes a column the first migration has and the first release lacks
+type columnUpgrade struct {
+	table  string
+	column string
+	sqlite string
+	mysql  string
+}
+
+// columnUpgrades are applied in order to databases of the first release
+var columnUpgrades = []columnUpgrade{
+	{"alerts", "type", "TEXT NOT NULL DEFAULT 'expiration'", "VARCHAR(32) NOT NULL DEFAULT 'expiration'"},
+	{"alerts", "message", "TEXT NOT NULL DEFAULT ''", "TEXT NOT NULL"},
+	{"alerts", "dedup_key", "TEXT NOT NULL DEFAULT ''", "VARCHAR(255) NOT NULL DEFAULT ''"},
+	{"alerts", "status", "TEXT NOT NULL DEFAULT 'sent'", "VARCHAR(16) NOT NULL DEFAULT 'sent'"},
+	{"alerts", "attempts", "INTEGER NOT NULL DEFAULT 0", "INT NOT NULL DEFAULT 0"},
+	{"alerts", "next_attempt_at", "DATETIME", "DATETIME NULL"},
+	{"config", "check_policy", "TEXT NOT NULL DEFAULT '[]'", "JSON NULL"},
+	{"domains", "mode", "TEXT NOT NULL DEFAULT 'monitor'", "VARCHAR(32) NOT NULL DEFAULT 'monitor'"},
+	{"domains", "registration_state", "TEXT NOT NULL DEFAULT 'registered'", "VARCHAR(32) NOT NULL DEFAULT 'registered'"},
+	{"domains", "statuses", "TEXT NOT NULL DEFAULT '[]'", "JSON NULL"},
+	{"domains", "required_statuses", "TEXT NOT NULL DEFAULT '[]'", "JSON NULL"},
+	{"domains", "lease_owner", "TEXT NOT NULL DEFAULT ''", "VARCHAR(255) NOT NULL DEFAULT ''"},
+	{"domains", "lease_until", "DATETIME", "DATETIME NULL"},
+	{"domains", "check_attempts", "INTEGER NOT NULL DEFAULT 0", "INT NOT NULL DEFAULT 0"},
+	{"domains", "state", "TEXT NOT NULL DEFAULT 'active'", "VARCHAR(16) NOT NULL DEFAULT 'active'"},
+	{"domains", "archived_at", "DATETIME", "DATETIME NULL"},
+}
+
+// indexUpgrade describes an index the first migration has on a table of the
+// first release, often on columns added by a columnUpgrade
+type indexUpgrade struct {
+	table   string
+	name    string
+	columns string
+	unique  bool
+}
+
+// indexUpgrades are created after the column upgrades, once their columns exist
+// MySQL cannot add them to existing tables with CREATE TABLE IF NOT EXISTS
+var indexUpgrades = []indexUpgrade{
+	{"alerts", "uq_alerts_dedup_key", "dedup_key", true},
+	{"alerts", "idx_alerts_status_next_attempt", "status, next_attempt_at", false},
+	{"domains", "idx_domains_state_next_check", "state, next_check", false},
+}
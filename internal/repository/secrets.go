@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/domain-expiration-monitor/dem/internal/secret"
+	"github.com/jmoiron/sqlx"
+)
+
+// secretColumn is a column whose values are encrypted with the database's cipher
+type secretColumn struct {
+	table  string
+	column string
+}
+
+// secretColumns lists every column holding secrets; RotateSecrets re-encrypts them all
+// and EncryptSecrets encrypts their plain text values
+var secretColumns = []secretColumn{
+	{table: "config", column: "google_chat_webhook"},
+	{table: "config_revisions", column: "google_chat_webhook"},
+}
+
+// RotateSecrets re-encrypts every stored secret from the database's cipher to
+// next in one transaction, encrypting plain text values as well, and returns how
+// many values it wrote. The database uses next afterwards.
+func (db *DB) RotateSecrets(ctx context.Context, next *secret.Cipher) (int, error) {
+	rotated, err := db.rewriteSecrets(ctx, func(value string) (string, error) {
+		plaintext, err := db.secrets.Decrypt(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt: %w", err)
+		}
+		value, err = next.Encrypt(plaintext)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt: %w", err)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	db.secrets = next
+	return rotated, nil
+}
+
+// EncryptSecrets encrypts the secrets still stored in plain text, such as those
+// saved before a key was set, with the database's cipher in one transaction, and
+// returns how many values it wrote. Without a cipher it does nothing.
+func (db *DB) EncryptSecrets(ctx context.Context) (int, error) {
+	if db.secrets == nil {
+		return 0, nil
+	}
+	return db.rewriteSecrets(ctx, func(value string) (string, error) {
+		if secret.IsEncrypted(value) {
+			return value, nil
+		}
+		value, err := db.secrets.Encrypt(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt: %w", err)
+		}
+		return value, nil
+	})
+}
+
+// rewriteSecrets replaces every stored secret with what rewrite returns for it,
+// in one transaction, and returns how many values changed
+func (db *DB) rewriteSecrets(ctx context.Context, rewrite func(value string) (string, error)) (int, error) {
+	rewritten := 0
+	err := db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		rewritten = 0
+		for _, col := range secretColumns {
+			var rows []struct {
+				ID    string `db:"id"`
+				Value string `db:"value"`
+			}
+			query := `SELECT id, ` + col.column + ` AS value FROM ` + col.table + ` WHERE ` + col.column + ` <> ''`
+			if err := tx.SelectContext(ctx, &rows, query); err != nil {
+				return fmt.Errorf("failed to read %s.%s: %w", col.table, col.column, err)
+			}
+
+			update := tx.Rebind(`UPDATE ` + col.table + ` SET ` + col.column + ` = ? WHERE id = ?`)
+			for _, row := range rows {
+				value, err := rewrite(row.Value)
+				if err != nil {
+					return fmt.Errorf("%s.%s of %s: %w", col.table, col.column, row.ID, err)
+				}
+				if value == row.Value {
+					continue
+				}
+				if _, err := tx.ExecContext(ctx, update, value, row.ID); err != nil {
+					return fmt.Errorf("failed to update %s.%s of %s: %w", col.table, col.column, row.ID, err)
+				}
+				rewritten++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rewritten, nil
+}
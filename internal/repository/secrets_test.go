@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/domain-expiration-monitor/dem/internal/secret"
+)
+
+func newTestCipher(t *testing.T) *secret.Cipher {
+	t.Helper()
+	key, err := secret.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	c, err := secret.NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher() unexpected error: %v", err)
+	}
+	return c
+}
+
+// Test that secrets are encrypted at rest once a key is set, and that rotating
+// the key re-encrypts them, plain text values included
+func TestRotateSecrets(t *testing.T) {
+	forEachDriver(t, "test_secrets.db", func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		configs := NewConfigRepository(db)
+		webhook := "https://chat.googleapis.com/v1/spaces/AAA/messages?key=secret-key"
+
+		storedWebhooks := func() []string {
+			t.Helper()
+			var values []string
+			for _, col := range secretColumns {
+				var column []string
+				if err := db.SelectContext(ctx, &column, `SELECT `+col.column+` FROM `+col.table+` WHERE `+col.column+` <> ''`); err != nil {
+					t.Fatalf("Failed to read %s: %v", col.table, err)
+				}
+				values = append(values, column...)
+			}
+			return values
+		}
+
+		// Without a key the webhook is stored as it is
+		config, err := configs.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get() unexpected error: %v", err)
+		}
+		config.GoogleChatWebhook = webhook
+		if err := configs.Update(ctx, config); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+		if stored := storedWebhooks(); len(stored) != 2 || stored[0] != webhook {
+			t.Fatalf("stored webhooks = %q, want the plain URL in config and its revision", stored)
+		}
+
+		first := newTestCipher(t)
+		rotated, err := db.RotateSecrets(ctx, first)
+		if err != nil {
+			t.Fatalf("RotateSecrets() unexpected error: %v", err)
+		}
+		if rotated != 2 {
+			t.Errorf("RotateSecrets() = %d, want 2", rotated)
+		}
+		for _, value := range storedWebhooks() {
+			if !secret.IsEncrypted(value) || strings.Contains(value, "secret-key") {
+				t.Errorf("stored webhook = %q, want it encrypted", value)
+			}
+		}
+		if got, err := configs.Get(ctx); err != nil || got.GoogleChatWebhook != webhook {
+			t.Errorf("Get() after RotateSecrets() = %+v, %v; want the webhook decrypted", got, err)
+		}
+
+		// New revisions are encrypted too, and read back decrypted
+		config.SetRetentionPeriod(config.GetRetentionPeriod() * 2)
+		if err := configs.Update(ctx, config); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+		revisions, err := configs.Revisions(ctx, 10)
+		if err != nil {
+			t.Fatalf("Revisions() unexpected error: %v", err)
+		}
+		if len(revisions) != 3 || revisions[0].GoogleChatWebhook != webhook || revisions[1].GoogleChatWebhook != webhook {
+			t.Errorf("Revisions() = %+v, want the webhook decrypted", revisions)
+		}
+
+		second := newTestCipher(t)
+		if rotated, err := db.RotateSecrets(ctx, second); err != nil || rotated != 3 {
+			t.Fatalf("RotateSecrets() = %d, %v; want 3 values re-encrypted", rotated, err)
+		}
+		if got, err := configs.Get(ctx); err != nil || got.GoogleChatWebhook != webhook {
+			t.Errorf("Get() with the new key = %+v, %v; want the webhook decrypted", got, err)
+		}
+
+		db.SetCipher(first)
+		if _, err := configs.Get(ctx); err == nil {
+			t.Error("Get() with the old key succeeded, want an error")
+		}
+		db.SetCipher(nil)
+		if _, err := configs.Get(ctx); !errors.Is(err, secret.ErrNoKey) {
+			t.Errorf("Get() without a key error = %v, want ErrNoKey", err)
+		}
+		if _, err := db.RotateSecrets(ctx, second); !errors.Is(err, secret.ErrNoKey) {
+			t.Errorf("RotateSecrets() without the current key error = %v, want ErrNoKey", err)
+		}
+	})
+}
+
+// Test that secrets stored in plain text before a key was set are encrypted
+// once the key is set, leaving values that are already encrypted alone
+func TestEncryptSecrets(t *testing.T) {
+	forEachDriver(t, "test_encrypt_secrets.db", func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		configs := NewConfigRepository(db)
+		webhook := "https://chat.googleapis.com/v1/spaces/AAA/messages?key=secret-key"
+
+		if n, err := db.EncryptSecrets(ctx); err != nil || n != 0 {
+			t.Fatalf("EncryptSecrets() without a key = %d, %v; want 0", n, err)
+		}
+
+		config, err := configs.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get() unexpected error: %v", err)
+		}
+		config.GoogleChatWebhook = webhook
+		if err := configs.Update(ctx, config); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+
+		db.SetCipher(newTestCipher(t))
+		// A revision saved with the key is encrypted already
+		config.SetRetentionPeriod(config.GetRetentionPeriod() * 2)
+		if err := configs.Update(ctx, config); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+
+		if n, err := db.EncryptSecrets(ctx); err != nil || n != 1 {
+			t.Fatalf("EncryptSecrets() = %d, %v; want the plain text revision encrypted", n, err)
+		}
+		for _, col := range secretColumns {
+			var values []string
+			if err := db.SelectContext(ctx, &values, `SELECT `+col.column+` FROM `+col.table+` WHERE `+col.column+` <> ''`); err != nil {
+				t.Fatalf("Failed to read %s: %v", col.table, err)
+			}
+			for _, value := range values {
+				if !secret.IsEncrypted(value) {
+					t.Errorf("%s.%s = %q, want it encrypted", col.table, col.column, value)
+				}
+			}
+		}
+		if n, err := db.EncryptSecrets(ctx); err != nil || n != 0 {
+			t.Errorf("EncryptSecrets() again = %d, %v; want 0", n, err)
+		}
+		if revisions, err := configs.Revisions(ctx, 10); err != nil || len(revisions) != 3 || revisions[1].GoogleChatWebhook != webhook {
+			t.Errorf("Revisions() = %+v, %v; want the webhook decrypted", revisions, err)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// ErrLeaseLost is returned by DomainStore.UpdateLeased when the caller no longer holds
+// the check lease of the domain
+var ErrLeaseLost = errors.New("domain check lease lost")
+
+// DomainStore persists monitored domains, their tags and the leases of their checks
+type DomainStore interface {
+	Create(ctx context.Context, d *domain.Domain) error
+	GetByID(ctx context.Context, id string) (*domain.Domain, error)
+	GetByName(ctx context.Context, name string) (*domain.Domain, error)
+	GetAll(ctx context.Context) ([]*domain.Domain, error)
+	Query(ctx context.Context, q DomainQuery) (*DomainPage, error)
+	Update(ctx context.Context, d *domain.Domain) error
+	UpdateLeased(ctx context.Context, d *domain.Domain, owner string, now time.Time) error
+	UpdateRequiredStatuses(ctx context.Context, id string, statuses domain.Strings) error
+	UpdateMetadata(ctx context.Context, id string, m domain.Metadata) error
+	Tags(ctx context.Context) ([]string, error)
+	Owners(ctx context.Context) ([]string, error)
+	Registrars(ctx context.Context) ([]string, error)
+	SetState(ctx context.Context, id, state string, now time.Time) error
+	Delete(ctx context.Context, id string) error
+	CountOlderThan(ctx context.Context, cutoff time.Time) (int, error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error)
+	GetDomainsForCheck(ctx context.Context, now time.Time, limit int) ([]*domain.Domain, error)
+	ClaimDomain(ctx context.Context, id, owner string, now time.Time, lease time.Duration) (bool, error)
+	ClaimDomainNow(ctx context.Context, id, owner string, now time.Time, lease time.Duration) (bool, error)
+	QueueCheck(ctx context.Context, id string, now time.Time) error
+	DeferCheck(ctx context.Context, id, owner string, now, nextCheck time.Time) error
+	RetryCheck(ctx context.Context, id, owner string, now, nextCheck time.Time) error
+	ReleaseDomain(ctx context.Context, id, owner string, now time.Time) error
+}
+
+// AlertStore persists alerts and the claims that deduplicate their delivery
+type AlertStore interface {
+	Create(ctx context.Context, alert *domain.Alert) error
+	Claim(ctx context.Context, alert *domain.Alert, staleAfter time.Duration) (bool, error)
+	ClaimRetry(ctx context.Context, id string, now time.Time, staleAfter time.Duration) (bool, error)
+	ClaimResend(ctx context.Context, id string, now time.Time) (bool, error)
+	Finalize(ctx context.Context, alert *domain.Alert) error
+	GetByID(ctx context.Context, id string) (*domain.Alert, error)
+	GetDueRetries(ctx context.Context, now time.Time, staleAfter time.Duration, limit int) ([]*domain.Alert, error)
+	GetByDomainID(ctx context.Context, domainID string) ([]*domain.Alert, error)
+	GetRecentAlerts(ctx context.Context, since time.Time) ([]*domain.Alert, error)
+	CountOlderThan(ctx context.Context, cutoff time.Time) (int, error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error)
+	GetFailedAlerts(ctx context.Context) ([]*domain.Alert, error)
+}
+
+// ConfigStore persists the application configuration and its revisions
+type ConfigStore interface {
+	Get(ctx context.Context) (*domain.Config, error)
+	Update(ctx context.Context, config *domain.Config) error
+	Rollback(ctx context.Context, revision int) (*domain.Config, error)
+	Revisions(ctx context.Context, limit int) ([]*domain.ConfigRevision, error)
+	GetRevision(ctx context.Context, revision int) (*domain.ConfigRevision, error)
+}
+
+// HTTPCheckStore persists HTTP checks and their results
+type HTTPCheckStore interface {
+	Create(ctx context.Context, c *domain.HTTPCheck) error
+	GetByID(ctx context.Context, id string) (*domain.HTTPCheck, error)
+	GetByDomainID(ctx context.Context, domainID string) ([]*domain.HTTPCheck, error)
+	GetDueChecks(ctx context.Context, now time.Time) ([]*domain.HTTPCheck, error)
+	Update(ctx context.Context, c *domain.HTTPCheck) error
+	Delete(ctx context.Context, id string) error
+	CreateResult(ctx context.Context, res *domain.HTTPCheckResult) error
+	GetResults(ctx context.Context, checkID string, limit int) ([]*domain.HTTPCheckResult, error)
+	CountResultsOlderThan(ctx context.Context, cutoff time.Time) (int, error)
+	DeleteResultsOlderThan(ctx context.Context, cutoff time.Time, limit int) (int, error)
+}
+
+// LookalikeStore persists the lookalike candidates of domains
+type LookalikeStore interface {
+	AddCandidates(ctx context.Context, domainID string, candidates []*domain.Lookalike) (int, error)
+	GetByDomainID(ctx context.Context, domainID string) ([]*domain.Lookalike, error)
+	GetRegisteredByDomainID(ctx context.Context, domainID string) ([]*domain.Lookalike, error)
+	CountByDomainID(ctx context.Context, domainID string) (int, error)
+	GetDueForCheck(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Lookalike, error)
+	Update(ctx context.Context, l *domain.Lookalike) error
+}
+
+// LeaderStore persists the leases replicas use to elect a leader
+type LeaderStore interface {
+	TryAcquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error)
+	Holder(ctx context.Context, name string) (string, error)
+	Release(ctx context.Context, name, holder string) error
+}
+
+// AuditStore appends to and reads the audit log; entries are never changed or deleted
+type AuditStore interface {
+	Create(ctx context.Context, e *domain.AuditEntry) error
+	Query(ctx context.Context, q AuditQuery) (*AuditPage, error)
+}
+
+var (
+	_ DomainStore    = (*DomainRepository)(nil)
+	_ AlertStore     = (*AlertRepository)(nil)
+	_ ConfigStore    = (*ConfigRepository)(nil)
+	_ HTTPCheckStore = (*HTTPCheckRepository)(nil)
+	_ LookalikeStore = (*LookalikeRepository)(nil)
+	_ LeaderStore    = (*LeaderRepository)(nil)
+	_ AuditStore     = (*AuditRepository)(nil)
+)
+
+// Stores groups the stores of one backend
+type Stores struct {
+	Domains    DomainStore
+	Config     ConfigStore
+	Alerts     AlertStore
+	HTTPChecks HTTPCheckStore
+	Lookalikes LookalikeStore
+	Leader     LeaderStore
+	Audit      AuditStore
+}
+
+// NewStores returns the SQL repositories of db
+func NewStores(db *DB) Stores {
+	return Stores{
+		Domains:    NewDomainRepository(db),
+		Config:     NewConfigRepository(db),
+		Alerts:     NewAlertRepository(db),
+		HTTPChecks: NewHTTPCheckRepository(db),
+		Lookalikes: NewLookalikeRepository(db),
+		Leader:     NewLeaderRepository(db),
+		Audit:      NewAuditRepository(db),
+	}
+}
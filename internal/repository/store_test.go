@@ -0,0 +1,17 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/repotest"
+)
+
+// Test that the SQL repositories pass the conformance suite on every driver
+func TestStores_Conformance(t *testing.T) {
+	repository.ForEachDriver(t, "test_conformance.db", func(t *testing.T, open func(t *testing.T) *repository.DB) {
+		repotest.Run(t, func(t *testing.T) repository.Stores {
+			return repository.NewStores(open(t))
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// Columns of the tables whose repositories have no shared column list
+const (
+	httpCheckColumns = `id, domain_id, url, expected_status, expected_redirect, body_contains,
+		       check_interval, failure_threshold, consecutive_failures,
+		       last_checked, next_check, created_at, updated_at`
+	httpCheckResultColumns = `id, check_id, checked_at, status_code, redirect_url,
+		       latency, success, error_message`
+	lookalikeColumns = `id, domain_id, name, unicode_name, kind, registered, registrar,
+		       created_date, first_seen, last_checked, created_at`
+	configColumns = `id, monitoring_interval, alert_thresholds, google_chat_webhook,
+		       retention_period, check_policy, alert_criticality, updated_at`
+)
+
+// dataTable describes a table whose rows are copied, backed up and restored as a whole
+type dataTable struct {
+	name    string
+	columns []string
+	newRow  func() interface{}           // returns a pointer to an empty row
+	rowID   func(row interface{}) string // returns the primary key of a row
+	whole   bool                         // replaced as a whole rather than merged, like the configuration
+}
+
+// newDataTable describes table, whose rows scan into a T
+func newDataTable[T any](name, columns string, id func(*T) string) dataTable {
+	names := strings.Split(columns, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return dataTable{
+		name:    name,
+		columns: names,
+		newRow:  func() interface{} { return new(T) },
+		rowID:   func(row interface{}) string { return id(row.(*T)) },
+	}
+}
+
+// dataTables lists the tables holding user data, parents before the rows that reference them
+// Leader leases are left out; they belong to the instances running on each database.
+var dataTables = []dataTable{
+	newDataTable("domains", domainColumns, func(d *domain.Domain) string { return d.ID }),
+	newDataTable("tags", tagColumns, func(t *tagRow) string { return t.ID }),
+	newDataTable("domain_tags", domainTagColumns, func(t *domainTagRow) string { return t.ID }),
+	replacedWhole(newDataTable("config", configColumns, func(c *domain.Config) string { return strconv.Itoa(c.ID) })),
+	replacedWhole(newDataTable("config_revisions", configRevisionColumns, func(r *domain.ConfigRevision) string { return r.ID })),
+	newDataTable("alerts", alertColumns, func(a *domain.Alert) string { return a.ID }),
+	newDataTable("http_checks", httpCheckColumns, func(c *domain.HTTPCheck) string { return c.ID }),
+	newDataTable("http_check_results", httpCheckResultColumns, func(r *domain.HTTPCheckResult) string { return r.ID }),
+	newDataTable("lookalikes", lookalikeColumns, func(l *domain.Lookalike) string { return l.ID }),
+	newDataTable("audit_log", auditColumns, func(e *domain.AuditEntry) string { return e.ID }),
+}
+
+// replacedWhole marks t as replaced as a whole: the configuration is a single row,
+// and its revisions are numbered in the database they were saved in
+func replacedWhole(t dataTable) dataTable {
+	t.whole = true
+	return t
+}
+
+// selectQuery returns the query for all rows of t in primary key order, followed by where
+func (t dataTable) selectQuery(where string) string {
+	return `SELECT ` + strings.Join(t.columns, ", ") + ` FROM ` + t.name + ` ` + where + ` ORDER BY id`
+}
+
+// insertQuery returns the named insert of a row of t
+func (t dataTable) insertQuery() string {
+	return `INSERT INTO ` + t.name + ` (` + strings.Join(t.columns, ", ") + `) VALUES (:` + strings.Join(t.columns, ", :") + `)`
+}
+
+// scanRows calls fn with each row query returns
+func (t dataTable) scanRows(ctx context.Context, q sqlx.QueryerContext, query string, args []interface{}, fn func(row interface{}) error) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", t.name, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := t.newRow()
+		if err := rows.StructScan(row); err != nil {
+			return fmt.Errorf("failed to read %s: %w", t.name, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", t.name, err)
+	}
+	return nil
+}
+
+// countRows returns the number of rows in table
+func (db *DB) countRows(ctx context.Context, table string) (int, error) {
+	var count int
+	if err := db.GetContext(ctx, &count, `SELECT COUNT(*) FROM `+table); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", table, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Columns of the tag tables
+const (
+	tagColumns       = `id, name, created_at`
+	domainTagColumns = `id, domain_id, tag_id`
+)
+
+// tagLoadBatchSize limits the domains whose tags are loaded by one query
+const tagLoadBatchSize = 500
+
+// tagRow is a row of the tags table
+type tagRow struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// domainTagRow links a domain to one of its tags
+type domainTagRow struct {
+	ID       string `db:"id" json:"id"`
+	DomainID string `db:"domain_id" json:"domain_id"`
+	TagID    string `db:"tag_id" json:"tag_id"`
+}
+
+// loadTags fills in the tags of domains, sorted by name
+func (db *DB) loadTags(ctx context.Context, q sqlx.QueryerContext, domains []*domain.Domain) error {
+	byID := make(map[string]*domain.Domain, len(domains))
+	ids := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d.Tags = domain.Strings{}
+		byID[d.ID] = d
+		ids = append(ids, d.ID)
+	}
+
+	for start := 0; start < len(ids); start += tagLoadBatchSize {
+		end := start + tagLoadBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		query, args, err := sqlx.In(`
+			SELECT dt.domain_id, t.name
+			FROM domain_tags dt JOIN tags t ON t.id = dt.tag_id
+			WHERE dt.domain_id IN (?)
+			ORDER BY t.name
+		`, ids[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to build tag lookup: %w", err)
+		}
+
+		var rows []struct {
+			DomainID string `db:"domain_id"`
+			Name     string `db:"name"`
+		}
+		if err := sqlx.SelectContext(ctx, q, &rows, db.Rebind(query), args...); err != nil {
+			return fmt.Errorf("failed to get domain tags: %w", err)
+		}
+		for _, row := range rows {
+			d := byID[row.DomainID]
+			d.Tags = append(d.Tags, row.Name)
+		}
+	}
+
+	return nil
+}
+
+// setTags replaces the tags of a domain, creating tags that do not exist yet and
+// dropping those no domain uses any more
+func (db *DB) setTags(ctx context.Context, tx *sqlx.Tx, domainID string, tags domain.Strings) error {
+	if _, err := tx.ExecContext(ctx, db.Rebind(`DELETE FROM domain_tags WHERE domain_id = ?`), domainID); err != nil {
+		return fmt.Errorf("failed to clear domain tags: %w", err)
+	}
+
+	for _, name := range tags {
+		var tagID string
+		err := tx.GetContext(ctx, &tagID, db.Rebind(`SELECT id FROM tags WHERE name = ?`), name)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to get tag: %w", err)
+		}
+		if tagID == "" {
+			tagID = uuid.New().String()
+			if _, err := tx.ExecContext(ctx, db.Rebind(`INSERT INTO tags (`+tagColumns+`) VALUES (?, ?, ?)`), tagID, name, time.Now()); err != nil {
+				return fmt.Errorf("failed to create tag %s: %w", name, err)
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, db.Rebind(`INSERT INTO domain_tags (`+domainTagColumns+`) VALUES (?, ?, ?)`),
+			uuid.New().String(), domainID, tagID)
+		if err != nil {
+			return fmt.Errorf("failed to tag domain: %w", err)
+		}
+	}
+
+	return db.pruneTags(ctx, tx)
+}
+
+// pruneTags deletes the tags no domain uses any more
+func (db *DB) pruneTags(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM domain_tags)`); err != nil {
+		return fmt.Errorf("failed to delete unused tags: %w", err)
+	}
+	return nil
+}
+
+// Tags returns the names of all tags in use, sorted
+func (r *DomainRepository) Tags(ctx context.Context) ([]string, error) {
+	tags := []string{}
+	query := `SELECT name FROM tags WHERE id IN (SELECT tag_id FROM domain_tags) ORDER BY name`
+	if err := r.db.SelectContext(ctx, &tags, query); err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tags, nil
+}
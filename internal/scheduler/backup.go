@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+// BackupSchedule configures the backups the leader writes to a local directory
+type BackupSchedule struct {
+	Dir      string        // Directory the archives are written to
+	Interval time.Duration // Time between backups
+	Keep     int           // Number of archives kept; 0 keeps all
+}
+
+// SetBackups enables scheduled backups of db; call it before Start
+func (s *Scheduler) SetBackups(db *repository.DB, schedule BackupSchedule) {
+	s.backupDB = db
+	s.backupSchedule = schedule
+}
+
+// runBackups writes a backup whenever the newest one in the directory is an
+// interval old, so restarts and leader changes do not take extra backups
+func (s *Scheduler) runBackups(ctx context.Context) {
+	if s.backupDB == nil {
+		return
+	}
+
+	for {
+		wait, err := s.nextBackupIn(time.Now())
+		if err != nil {
+			log.Printf("Failed to list backups: %v", err)
+			wait = s.backupSchedule.Interval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if _, err := s.takeBackup(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("Scheduled backup failed: %v", err)
+		}
+	}
+}
+
+// nextBackupIn returns how long until the next backup is due
+func (s *Scheduler) nextBackupIn(now time.Time) (time.Duration, error) {
+	backups, err := listBackups(s.backupSchedule.Dir)
+	if err != nil || len(backups) == 0 {
+		return 0, err
+	}
+
+	info, err := os.Stat(backups[len(backups)-1])
+	if err != nil {
+		return 0, err
+	}
+	wait := info.ModTime().Add(s.backupSchedule.Interval).Sub(now)
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// takeBackup writes a backup to the backup directory and deletes the oldest
+// archives beyond the number kept
+func (s *Scheduler) takeBackup(ctx context.Context, now time.Time) (string, error) {
+	if err := os.MkdirAll(s.backupSchedule.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(s.backupSchedule.Dir, repository.BackupFileName(now))
+	manifest, err := s.backupDB.BackupFile(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	rows := 0
+	for _, table := range manifest.Tables {
+		rows += table.Rows
+	}
+	log.Printf("Wrote backup %s (%d rows, schema version %d)", path, rows, manifest.SchemaVersion)
+
+	if err := rotateBackups(s.backupSchedule.Dir, s.backupSchedule.Keep); err != nil {
+		return path, fmt.Errorf("failed to rotate backups: %w", err)
+	}
+	return path, nil
+}
+
+// listBackups returns the archives in dir, oldest first
+func listBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, "dem-backup-") || !strings.HasSuffix(name, ".zip") {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// Archive names carry their UTC timestamp
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// rotateBackups deletes all but the newest keep archives in dir
+func rotateBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := listBackups(dir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
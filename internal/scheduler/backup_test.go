@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+// Test that scheduled backups keep only the newest archives and wait an
+// interval after the last one
+func TestTakeBackup_Rotates(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_backup_schedule.db"
+	defer os.Remove(dbPath)
+
+	db, err := repository.NewDB(dbPath, "sqlite3")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	s := newReplica(t, repository.NewStores(db))
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	s.SetBackups(db, BackupSchedule{Dir: dir, Interval: time.Hour, Keep: 2})
+
+	if wait, err := s.nextBackupIn(time.Now()); err != nil || wait != 0 {
+		t.Errorf("nextBackupIn() without backups = %v, %v; want 0", wait, err)
+	}
+
+	start := time.Now()
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path, err := s.takeBackup(ctx, start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("takeBackup() unexpected error: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := rotateBackups(dir, 2); err != nil {
+		t.Fatalf("rotateBackups() unexpected error: %v", err)
+	}
+
+	backups, err := listBackups(dir)
+	if err != nil {
+		t.Fatalf("listBackups() unexpected error: %v", err)
+	}
+	if len(backups) != 2 || backups[0] != paths[1] || backups[1] != paths[2] {
+		t.Errorf("Backups after rotation = %v, want the newest two of %v", backups, paths)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Rotation removed a file that is not a backup: %v", err)
+	}
+
+	f, err := os.Open(backups[1])
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	if _, err := repository.OpenBackup(f, info.Size()); err != nil {
+		t.Errorf("Scheduled backup is not a valid archive: %v", err)
+	}
+
+	if wait, err := s.nextBackupIn(time.Now()); err != nil || wait < 59*time.Minute {
+		t.Errorf("nextBackupIn() after a backup = %v, %v; want about an hour", wait, err)
+	}
+}
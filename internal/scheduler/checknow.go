@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// CheckStatus describes how a domain check ended
+type CheckStatus string
+
+const (
+	// CheckCompleted means WHOIS answered and the domain was updated
+	CheckCompleted CheckStatus = "completed"
+	// CheckFailed means the lookup or saving its outcome failed
+	CheckFailed CheckStatus = "failed"
+	// CheckDeferred means the WHOIS server is rate limited; the domain's next
+	// check is set to when it may be queried again
+	CheckDeferred CheckStatus = "deferred"
+	// CheckQueued means this instance is on standby and made the domain due
+	// for the leader to check instead
+	CheckQueued CheckStatus = "queued"
+	// CheckInProgress means another instance is checking the domain
+	CheckInProgress CheckStatus = "in_progress"
+	// CheckNotFound means the domain does not exist
+	CheckNotFound CheckStatus = "not_found"
+	// CheckInactive means the domain is paused or archived and is not checked
+	CheckInactive CheckStatus = "inactive"
+	// CheckCanceled means the caller stopped waiting before the check finished
+	CheckCanceled CheckStatus = "canceled"
+)
+
+// CheckResult reports the outcome of a domain check
+type CheckResult struct {
+	DomainID string         `json:"domain_id"`
+	Status   CheckStatus    `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Domain   *domain.Domain `json:"domain,omitempty"`
+}
+
+// newCheckResult describes a finished check of d
+func newCheckResult(d *domain.Domain, status CheckStatus, err error) *CheckResult {
+	result := &CheckResult{DomainID: d.ID, Status: status, Domain: d}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// inflightCheck is a check running on this instance that callers can wait for
+// It is canceled once every caller waiting for it has left.
+type inflightCheck struct {
+	done    chan struct{}
+	result  *CheckResult
+	cancel  context.CancelFunc
+	waiters int // guarded by Scheduler.mu
+}
+
+// track runs check for a domain unless one is already running on this instance
+// It returns the check to wait for and whether it was started by this call.
+// Either way the caller is counted as waiting for the check and must call leave
+// once it stops waiting.
+func (s *Scheduler) track(parent context.Context, id string, check func(context.Context) *CheckResult) (*inflightCheck, bool) {
+	s.mu.Lock()
+	if c, ok := s.inflight[id]; ok {
+		c.waiters++
+		s.mu.Unlock()
+		return c, false
+	}
+	ctx, cancel := context.WithCancel(parent)
+	c := &inflightCheck{done: make(chan struct{}), cancel: cancel, waiters: 1}
+	s.inflight[id] = c
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		c.result = check(ctx)
+
+		s.mu.Lock()
+		delete(s.inflight, id)
+		s.mu.Unlock()
+		close(c.done)
+	}()
+	return c, true
+}
+
+// leave stops waiting for c, canceling it when no one else waits for it
+func (s *Scheduler) leave(c *inflightCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.waiters--
+	if c.waiters == 0 {
+		c.cancel()
+	}
+}
+
+// CheckNow checks domains right away instead of waiting for their next check
+// Results are sent on the returned channel as each check finishes, and the
+// channel is closed once all are done. A domain already being checked is not
+// queried twice: the result of the running check is sent instead, or
+// CheckInProgress when another instance runs it. A standby does not check
+// domains itself but queues them for the leader. When ctx is canceled,
+// CheckCanceled is sent for the checks that have not finished, and those no
+// one else waits for, such as the dispatcher or another request, are aborted.
+func (s *Scheduler) CheckNow(ctx context.Context, ids ...string) <-chan *CheckResult {
+	results := make(chan *CheckResult, len(ids))
+
+	var wg sync.WaitGroup
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		id := id
+		c, _ := s.track(s.ctx, id, func(ctx context.Context) *CheckResult { return s.checkDomainNow(ctx, id) })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.leave(c)
+			select {
+			case <-c.done:
+				results <- c.result
+			case <-ctx.Done():
+				results <- &CheckResult{DomainID: id, Status: CheckCanceled, Error: ctx.Err().Error()}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// checkDomainNow claims a domain ahead of its schedule and checks it on the worker pool
+// On a standby it makes the domain due instead, so only the leader checks and alerts.
+func (s *Scheduler) checkDomainNow(ctx context.Context, id string) *CheckResult {
+	d, err := s.domainRepo.GetByID(ctx, id)
+	if err != nil {
+		return &CheckResult{DomainID: id, Status: CheckNotFound, Error: err.Error()}
+	}
+	if !d.IsActive() {
+		return newCheckResult(d, CheckInactive, nil)
+	}
+
+	if !s.IsLeader() {
+		now := time.Now()
+		if err := s.domainRepo.QueueCheck(ctx, id, now); err != nil {
+			return newCheckResult(d, CheckFailed, err)
+		}
+		if d.NextCheck.After(now) {
+			d.NextCheck = now
+		}
+		return newCheckResult(d, CheckQueued, nil)
+	}
+
+	select {
+	case s.workerPool <- struct{}{}:
+		defer func() { <-s.workerPool }()
+	case <-ctx.Done():
+		return newCheckResult(d, CheckCanceled, ctx.Err())
+	}
+
+	lease := s.newLease()
+	claimed, err := s.domainRepo.ClaimDomainNow(ctx, id, lease, time.Now(), checkLeaseDuration)
+	if err != nil {
+		return newCheckResult(d, CheckFailed, err)
+	}
+	if !claimed {
+		return newCheckResult(d, CheckInProgress, nil)
+	}
+
+	return s.checkDomain(ctx, id, lease)
+}
@@ -0,0 +1,418 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/memory"
+	"github.com/domain-expiration-monitor/dem/internal/whois"
+)
+
+// collect reads every result from a CheckNow channel
+func collect(t *testing.T, results <-chan *CheckResult) []*CheckResult {
+	t.Helper()
+
+	var all []*CheckResult
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				return all
+			}
+			all = append(all, r)
+		case <-timeout:
+			t.Fatal("CheckNow() results not closed in time")
+		}
+	}
+}
+
+// Test that manual checks of a domain share one WHOIS lookup and report the
+// updated domain
+func TestCheckNow_DeduplicatesInFlightChecks(t *testing.T) {
+	s := newTestScheduler(t)
+	ctx := context.Background()
+
+	raw, err := os.ReadFile(filepath.Join("..", "whois", "testdata", "registered", "co.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		lookups.Add(1)
+		<-release
+		return string(raw), nil
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "google.co", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now.Add(-time.Hour), NextCheck: now.Add(24 * time.Hour)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	got := make([][]*CheckResult, 3)
+	for i, ids := range [][]string{{d.ID}, {d.ID}, {d.ID, d.ID, "missing"}} {
+		results := s.CheckNow(ctx, ids...)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = collect(t, results)
+		}(i)
+	}
+
+	if !waitFor(2*time.Second, func() bool { return lookups.Load() == 1 }) {
+		t.Fatal("WHOIS lookup was not started")
+	}
+	close(release)
+	wg.Wait()
+
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("WHOIS lookups = %d, want 1", n)
+	}
+	if len(got[0]) != 1 || len(got[1]) != 1 || len(got[2]) != 2 {
+		t.Fatalf("Result counts = %d, %d, %d; want 1, 1, 2", len(got[0]), len(got[1]), len(got[2]))
+	}
+	for _, results := range got {
+		for _, r := range results {
+			switch r.DomainID {
+			case d.ID:
+				if r.Status != CheckCompleted || r.Domain == nil || r.Domain.Registrar != "MarkMonitor, Inc." {
+					t.Errorf("Result for %s = %+v, want completed with fresh WHOIS data", d.Name, r)
+				}
+			case "missing":
+				if r.Status != CheckNotFound {
+					t.Errorf("Result for missing domain = %s, want %s", r.Status, CheckNotFound)
+				}
+			default:
+				t.Errorf("Unexpected result for %q", r.DomainID)
+			}
+		}
+	}
+
+	saved, err := s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.LeaseOwner != "" || !saved.LastChecked.After(now.Add(-time.Minute)) {
+		t.Errorf("Saved domain: owner %q last checked %v, want released and just checked", saved.LeaseOwner, saved.LastChecked)
+	}
+}
+
+// Test that a domain checked by another instance is not queried again
+func TestCheckNow_LeasedElsewhere(t *testing.T) {
+	s := newTestScheduler(t)
+	ctx := context.Background()
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		t.Errorf("Unexpected WHOIS lookup for %q", query)
+		return "", nil
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "busy.co", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(-time.Minute)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+	if claimed, err := s.domainRepo.ClaimDomain(ctx, d.ID, "other-instance", now, time.Minute); err != nil || !claimed {
+		t.Fatalf("ClaimDomain() = %v, %v; want true", claimed, err)
+	}
+
+	results := collect(t, s.CheckNow(ctx, d.ID))
+	if len(results) != 1 || results[0].Status != CheckInProgress {
+		t.Errorf("CheckNow() = %+v, want one %s result", results, CheckInProgress)
+	}
+}
+
+// Test that a caller that stops waiting gets a canceled result and that the
+// check it alone waited for is aborted without touching the domain
+func TestCheckNow_Canceled(t *testing.T) {
+	s := newTestScheduler(t)
+	ctx := context.Background()
+	started := make(chan struct{})
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "slow.co", Registrar: "Old Registrar", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(time.Hour)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	results := s.CheckNow(waitCtx, d.ID)
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("WHOIS lookup was not started")
+	}
+	cancel()
+	if got := collect(t, results); len(got) != 1 || got[0].Status != CheckCanceled {
+		t.Errorf("CheckNow() = %+v, want one %s result", got, CheckCanceled)
+	}
+
+	// The lookup is aborted and the domain handed back unchanged
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Check kept running after its only caller left")
+	}
+	saved, err := s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.Registrar != "Old Registrar" || saved.LeaseOwner != "" {
+		t.Errorf("Domain after canceled check: registrar %q owner %q, want unchanged and released", saved.Registrar, saved.LeaseOwner)
+	}
+}
+
+// Test that a bulk check reports every domain once
+func TestCheckNow_Bulk(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, open func() repository.Stores) {
+		// On a fresh database every concurrent check creates the default config
+		s := newReplica(t, open())
+		s.leader.Store(true)
+		ctx := context.Background()
+		raw, err := os.ReadFile(filepath.Join("..", "whois", "testdata", "registered", "co.txt"))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		s.whoisSvc.SetLimits(whois.Limits{Default: whois.Limit{Rate: 100, Per: time.Second, Burst: 100, Concurrency: 10}})
+		s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+			if len(servers) == 0 {
+				return "refer: whois.nic.co\n", nil
+			}
+			return string(raw), nil
+		})
+
+		now := time.Now()
+		want := make(map[string]bool)
+		for _, name := range []string{"one.co", "two.co", "three.co", "four.co"} {
+			d := &domain.Domain{Name: name, ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now.Add(-time.Hour), NextCheck: now.Add(time.Hour)}
+			if err := s.domainRepo.Create(ctx, d); err != nil {
+				t.Fatalf("Failed to create domain: %v", err)
+			}
+			want[d.ID] = true
+		}
+
+		ids := make([]string, 0, len(want))
+		for id := range want {
+			ids = append(ids, id)
+		}
+		for _, r := range collect(t, s.CheckNow(ctx, ids...)) {
+			if !want[r.DomainID] {
+				t.Errorf("Unexpected or repeated result for %q", r.DomainID)
+			}
+			delete(want, r.DomainID)
+			if r.Status != CheckCompleted || r.Domain == nil || r.Domain.ID != r.DomainID {
+				t.Errorf("Result for %s = %+v, want completed", r.DomainID, r)
+			}
+		}
+		if len(want) != 0 {
+			t.Errorf("Missing results for %v", want)
+		}
+	})
+}
+
+// Test that paused and archived domains are not checked on request
+func TestCheckNow_Inactive(t *testing.T) {
+	s := newTestScheduler(t)
+	ctx := context.Background()
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		t.Errorf("Unexpected WHOIS lookup for %q", query)
+		return "", nil
+	})
+
+	now := time.Now()
+	for _, state := range []string{domain.StatePaused, domain.StateArchived} {
+		d := &domain.Domain{Name: state + ".co", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(-time.Minute)}
+		if err := s.domainRepo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+		if err := s.domainRepo.SetState(ctx, d.ID, state, now); err != nil {
+			t.Fatalf("Failed to set state: %v", err)
+		}
+
+		results := collect(t, s.CheckNow(ctx, d.ID))
+		if len(results) != 1 || results[0].Status != CheckInactive {
+			t.Errorf("CheckNow() of %s domain = %+v, want one %s result", state, results, CheckInactive)
+		}
+	}
+}
+
+// Test that failed WHOIS lookups are counted until one succeeds
+func TestCheckNow_CountsLookupFailures(t *testing.T) {
+	s := newTestScheduler(t)
+	ctx := context.Background()
+
+	raw, err := os.ReadFile(filepath.Join("..", "whois", "testdata", "registered", "co.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var failing atomic.Bool
+	failing.Store(true)
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		if failing.Load() {
+			return "", errors.New("connection refused")
+		}
+		return string(raw), nil
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "google.co", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now.Add(-time.Hour), NextCheck: now.Add(24 * time.Hour)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	// Each failed check takes the WHOIS service's retries, so one is enough
+	results := collect(t, s.CheckNow(ctx, d.ID))
+	if len(results) != 1 || results[0].Status != CheckFailed {
+		t.Fatalf("CheckNow() with failing WHOIS = %+v, want one %s result", results, CheckFailed)
+	}
+	saved, err := s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.LookupFailures != 1 || !saved.LookupFailing() {
+		t.Errorf("LookupFailures after a failed check = %d, want 1", saved.LookupFailures)
+	}
+	// The failed attempt is counted and retried soon, without holding the lease
+	if saved.LeaseOwner != "" || saved.CheckAttempts != 1 || saved.NextCheck.After(time.Now().Add(checkRetryDelay)) {
+		t.Errorf("Domain after a failed check: owner %q attempts %d next check %v, want unleased, 1 attempt and a retry within %v", saved.LeaseOwner, saved.CheckAttempts, saved.NextCheck, checkRetryDelay)
+	}
+
+	failing.Store(false)
+	results = collect(t, s.CheckNow(ctx, d.ID))
+	if len(results) != 1 || results[0].Status != CheckCompleted {
+		t.Fatalf("CheckNow() = %+v, want one %s result", results, CheckCompleted)
+	}
+	saved, err = s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.LookupFailures != 0 {
+		t.Errorf("LookupFailures after a successful check = %d, want 0", saved.LookupFailures)
+	}
+}
+
+// Test that a standby queues manual checks for the leader instead of running them
+func TestCheckNow_StandbyQueuesForLeader(t *testing.T) {
+	ctx := context.Background()
+	s := newReplica(t, memory.NewStore().Stores())
+
+	var lookups atomic.Int32
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		lookups.Add(1)
+		return "", errors.New("standby must not query WHOIS")
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "google.co", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now.Add(-time.Hour), NextCheck: now.Add(24 * time.Hour)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	results := collect(t, s.CheckNow(ctx, d.ID, "missing"))
+	statuses := map[string]CheckStatus{}
+	for _, r := range results {
+		statuses[r.DomainID] = r.Status
+	}
+	if statuses[d.ID] != CheckQueued || statuses["missing"] != CheckNotFound {
+		t.Errorf("CheckNow() on a standby = %v, want %s and %s", statuses, CheckQueued, CheckNotFound)
+	}
+	if lookups.Load() != 0 {
+		t.Errorf("standby ran %d WHOIS lookups, want none", lookups.Load())
+	}
+
+	saved, err := s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.NextCheck.After(time.Now()) || saved.LeaseOwner != "" || !saved.LastChecked.Equal(d.LastChecked) {
+		t.Errorf("Domain after a queued check: next check %v owner %q last checked %v, want due now, unleased and unchecked", saved.NextCheck, saved.LeaseOwner, saved.LastChecked)
+	}
+	if due, _ := s.domainRepo.GetDomainsForCheck(ctx, time.Now(), 10); len(due) != 1 || due[0].ID != d.ID {
+		t.Errorf("GetDomainsForCheck() after a queued check = %d domains, want the queued one", len(due))
+	}
+}
+
+// Test that a check which overran its lease saves nothing once the same instance
+// claimed the domain again
+func TestCheckNow_OverranLease(t *testing.T) {
+	s := newTestScheduler(t)
+	ctx := context.Background()
+
+	raw, err := os.ReadFile(filepath.Join("..", "whois", "testdata", "registered", "co.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		close(started)
+		<-release
+		return string(raw), nil
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "google.co", Registrar: "Old Registrar", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now.Add(-time.Hour), NextCheck: now.Add(24 * time.Hour)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	results := s.CheckNow(ctx, d.ID)
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("WHOIS lookup was not started")
+	}
+
+	// The lease runs out during the lookup and this instance claims the domain again
+	lease := s.newLease()
+	if ok, err := s.domainRepo.ClaimDomainNow(ctx, d.ID, lease, time.Now().Add(checkLeaseDuration+time.Second), checkLeaseDuration); err != nil || !ok {
+		t.Fatalf("ClaimDomainNow() = %v, %v; want true", ok, err)
+	}
+	close(release)
+
+	got := collect(t, results)
+	if len(got) != 1 || got[0].Status != CheckFailed {
+		t.Fatalf("CheckNow() that overran its lease = %+v, want one %s result", got, CheckFailed)
+	}
+	saved, err := s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.Registrar != "Old Registrar" || saved.LeaseOwner != lease {
+		t.Errorf("Domain after the overran check: registrar %q owner %q, want unchanged and still leased by the new claim", saved.Registrar, saved.LeaseOwner)
+	}
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/permutation"
+	"github.com/domain-expiration-monitor/dem/internal/whois"
+)
+
+const (
+	// lookalikePollInterval is how often a batch of lookalike candidates is checked
+	lookalikePollInterval = 5 * time.Minute
+	// lookalikeRecheckInterval is how long a candidate's registration status is trusted
+	lookalikeRecheckInterval = 7 * 24 * time.Hour
+	// lookalikeBatchSize limits the WHOIS lookups made per poll
+	lookalikeBatchSize = 25
+)
+
+// runLookalikeScans periodically generates lookalike candidates and checks their registration
+func (s *Scheduler) runLookalikeScans(ctx context.Context) {
+	ticker := time.NewTicker(lookalikePollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.scanLookalikes(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanLookalikes seeds candidates for new domains and checks the next due batch
+func (s *Scheduler) scanLookalikes(ctx context.Context) {
+	s.seedLookalikes(ctx)
+
+	due, err := s.lookalikeRepo.GetDueForCheck(ctx, time.Now().Add(-lookalikeRecheckInterval), lookalikeBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to load lookalikes for check: %v", err)
+		}
+		return
+	}
+
+	for _, l := range due {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		s.checkLookalike(ctx, l)
+	}
+}
+
+// seedLookalikes generates permutations for domains that have not been seeded since startup
+func (s *Scheduler) seedLookalikes(ctx context.Context) {
+	domains, err := s.domainRepo.GetAll(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to load domains for lookalike generation: %v", err)
+		}
+		return
+	}
+
+	for _, d := range domains {
+		// Watched domains belong to someone else; paused and archived ones are not scanned
+		if d.IsWatched() || !d.IsActive() {
+			continue
+		}
+
+		s.mu.RLock()
+		seeded := s.seededLookalikes[d.ID]
+		s.mu.RUnlock()
+		if seeded {
+			continue
+		}
+
+		perms := permutation.Generate(d.Name)
+		candidates := make([]*domain.Lookalike, 0, len(perms))
+		for _, p := range perms {
+			candidates = append(candidates, &domain.Lookalike{
+				Name:        p.Domain,
+				UnicodeName: p.Unicode,
+				Kind:        string(p.Kind),
+			})
+		}
+
+		added, err := s.lookalikeRepo.AddCandidates(ctx, d.ID, candidates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to store lookalikes for %s: %v", d.Name, err)
+			continue
+		}
+		if added > 0 {
+			log.Printf("Tracking %d new lookalike candidates for %s", added, d.Name)
+		}
+
+		s.mu.Lock()
+		s.seededLookalikes[d.ID] = true
+		s.mu.Unlock()
+	}
+}
+
+// checkLookalike looks up a single candidate and alerts when it has newly been registered
+// The first lookup of a candidate establishes a baseline and never alerts
+func (s *Scheduler) checkLookalike(ctx context.Context, l *domain.Lookalike) {
+	d, err := s.domainRepo.GetByID(ctx, l.DomainID)
+	if err != nil || !d.IsActive() {
+		// Domain might have been deleted, paused or archived
+		return
+	}
+
+	info, err := s.whoisSvc.QueryDomain(ctx, l.Name)
+	if ctx.Err() != nil || errors.Is(err, whois.ErrRateLimited) {
+		// Not checked; the next scan picks it up again
+		return
+	}
+	if err != nil && !errors.Is(err, whois.ErrDomainNotRegistered) {
+		// A failed lookup says nothing about the registration, so it is neither
+		// recorded nor taken as a baseline; the next scan retries it
+		log.Printf("Failed to look up lookalike %s: %v", l.Name, err)
+		return
+	}
+
+	baseline := l.LastChecked == nil
+	now := time.Now()
+	l.LastChecked = &now
+
+	newlyRegistered := false
+	if err != nil {
+		// Not registered, or dropped again; a later registration will alert anew
+		l.Registered = false
+	} else {
+		newlyRegistered = !l.Registered
+		l.Registered = true
+		l.Registrar = info.Registrar
+		if !info.CreatedDate.IsZero() {
+			created := info.CreatedDate
+			l.CreatedDate = &created
+		}
+		if newlyRegistered {
+			l.FirstSeen = &now
+		}
+	}
+
+	if err := s.lookalikeRepo.Update(ctx, l); err != nil {
+		log.Printf("Failed to update lookalike %s: %v", l.Name, err)
+		return
+	}
+
+	if newlyRegistered && !baseline {
+		if err := s.alertSvc.NotifyLookalikeRegistered(ctx, d, l); err != nil {
+			log.Printf("Failed to send lookalike alert for %s: %v", l.Name, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/permutation"
+)
+
+// Test that a failed lookup leaves a lookalike unchecked, without recording a
+// baseline, while a "not registered" answer is recorded
+func TestCheckLookalike_LookupFailure(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	lookupErr := errors.New("connection reset by peer")
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.verisign-grs.com\n", nil
+		}
+		if lookupErr != nil {
+			return "", lookupErr
+		}
+		return "No match for \"EXAMPL.COM\".", nil
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "example.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(24 * time.Hour)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+	if _, err := s.lookalikeRepo.AddCandidates(ctx, d.ID, []*domain.Lookalike{{Name: "exampl.com", Kind: string(permutation.KindOmission)}}); err != nil {
+		t.Fatalf("Failed to add lookalike: %v", err)
+	}
+	candidate := func() *domain.Lookalike {
+		t.Helper()
+		lookalikes, err := s.lookalikeRepo.GetByDomainID(ctx, d.ID)
+		if err != nil || len(lookalikes) != 1 {
+			t.Fatalf("GetByDomainID() = %v, %v, want the candidate", lookalikes, err)
+		}
+		return lookalikes[0]
+	}
+
+	s.checkLookalike(ctx, candidate())
+	if l := candidate(); l.LastChecked != nil || l.Registered {
+		t.Errorf("after a failed lookup LastChecked = %v, Registered = %v, want it left unchecked", l.LastChecked, l.Registered)
+	}
+
+	lookupErr = nil
+	s.checkLookalike(ctx, candidate())
+	if l := candidate(); l.LastChecked == nil || l.Registered {
+		t.Errorf("after a not registered answer LastChecked = %v, Registered = %v, want it checked and unregistered", l.LastChecked, l.Registered)
+	}
+}
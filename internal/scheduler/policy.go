@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// checkJitter spreads checks by up to this fraction of their interval either way,
+// so domains added together do not all query WHOIS at the same moment
+const checkJitter = 0.1
+
+// NextCheck returns when a domain that was just looked up should be checked again
+// Domains that are no longer registered have no expiration to track and are
+// checked on the monitoring interval.
+func (s *Scheduler) NextCheck(d *domain.Domain, config *domain.Config) time.Time {
+	now := time.Now()
+	if d.RegistrationState == domain.RegistrationAvailable {
+		return now.Add(config.GetMonitoringInterval())
+	}
+	return nextCheckTime(d.ExpirationDate, now, config.GetCheckPolicy(), rand.Float64()*2-1)
+}
+
+// retryAt returns when a domain whose lookup failed should be checked again
+// The retry never comes later than its regular next check.
+func (s *Scheduler) retryAt(d *domain.Domain, config *domain.Config) time.Time {
+	next := s.NextCheck(d, config)
+	if retry := time.Now().Add(checkRetryDelay); retry.Before(next) {
+		return retry
+	}
+	return next
+}
+
+// nextCheckTime applies a check policy to a domain expiring at expiration
+// jitter in [-1, 1] scales the random spread. The next check never lands after
+// the domain enters a more frequent tier, so it is not skipped over.
+func nextCheckTime(expiration, now time.Time, policy domain.CheckPolicy, jitter float64) time.Time {
+	tiers := policy.Sorted()
+	if len(tiers) == 0 {
+		tiers = domain.DefaultCheckPolicy().Sorted()
+	}
+	remaining := expiration.Sub(now)
+
+	// The last tier also covers expired domains
+	current := len(tiers) - 1
+	for i, tier := range tiers {
+		if remaining >= tier.MinRemaining {
+			current = i
+			break
+		}
+	}
+
+	interval := tiers[current].Interval
+	next := now.Add(interval + time.Duration(jitter*checkJitter*float64(interval)))
+
+	// A domain leaves its tier once less than MinRemaining is left
+	if current+1 < len(tiers) {
+		boundary := expiration.Add(-tiers[current].MinRemaining)
+		if boundary.After(now) && next.After(boundary) {
+			next = boundary
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+func TestNextCheckTime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	policy := domain.DefaultCheckPolicy()
+
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		policy    domain.CheckPolicy
+		jitter    float64
+		want      time.Duration
+	}{
+		{name: "three years out checks weekly", remaining: 3 * 365 * day, policy: policy, want: 7 * day},
+		{name: "four months out checks every three days", remaining: 120 * day, policy: policy, want: 3 * day},
+		{name: "a month out checks daily", remaining: 30 * day, policy: policy, want: day},
+		{name: "tomorrow checks hourly", remaining: day, policy: policy, want: time.Hour},
+		{name: "expired checks hourly", remaining: -10 * day, policy: policy, want: time.Hour},
+		{name: "exactly on a tier boundary uses that tier", remaining: 60 * day, policy: policy, want: 3 * day},
+		{name: "stops at the next tier boundary", remaining: 182 * day, policy: policy, want: 2 * day},
+		{name: "positive jitter", remaining: 30 * day, policy: policy, jitter: 1, want: day + day/10},
+		{name: "negative jitter", remaining: 30 * day, policy: policy, jitter: -1, want: day - day/10},
+		{
+			name:      "custom policy",
+			remaining: 400 * day,
+			policy:    domain.CheckPolicy{{MinRemaining: 0, Interval: 12 * time.Hour}, {MinRemaining: 365 * day, Interval: 30 * day}},
+			want:      30 * day,
+		},
+		{name: "empty policy falls back to the default", remaining: day, policy: nil, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextCheckTime(now.Add(tt.remaining), now, tt.policy, tt.jitter)
+			if got.Sub(now) != tt.want {
+				t.Errorf("nextCheckTime() = now+%v, want now+%v", got.Sub(now), tt.want)
+			}
+		})
+	}
+}
+
+// Test that jittered checks stay within the configured spread
+func TestNextCheckTime_JitterBounds(t *testing.T) {
+	now := time.Now()
+	d := &domain.Domain{ExpirationDate: now.Add(30 * 24 * time.Hour), RegistrationState: domain.RegistrationRegistered}
+	config := &domain.Config{}
+	s := &Scheduler{}
+
+	for i := 0; i < 100; i++ {
+		wait := s.NextCheck(d, config).Sub(now)
+		if wait < 21*time.Hour+36*time.Minute || wait > 26*time.Hour+25*time.Minute {
+			t.Fatalf("NextCheck() in %v, want within 10%% of a day", wait)
+		}
+	}
+}
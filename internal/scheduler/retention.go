@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// retentionPollInterval is how often data past the retention period is purged
+	retentionPollInterval = time.Hour
+	// retentionBatchSize limits the rows deleted per statement, so a large purge
+	// does not hold the database's write lock for long
+	retentionBatchSize = 500
+)
+
+// RetentionReport counts the rows a retention pass deleted, or would delete in a dry run
+type RetentionReport struct {
+	Cutoff           time.Time `json:"cutoff"`
+	DryRun           bool      `json:"dry_run"`
+	Alerts           int       `json:"alerts"`
+	HTTPCheckResults int       `json:"http_check_results"`
+	Domains          int       `json:"domains"`
+	FinishedAt       time.Time `json:"finished_at"`
+}
+
+// Total returns the number of rows counted across all tables
+func (r *RetentionReport) Total() int {
+	return r.Alerts + r.HTTPCheckResults + r.Domains
+}
+
+// LastRetention returns the last retention pass this instance ran, or nil
+func (s *Scheduler) LastRetention() *RetentionReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastRetention
+}
+
+// runRetention periodically purges data older than the retention period
+func (s *Scheduler) runRetention(ctx context.Context) {
+	ticker := time.NewTicker(retentionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.enforceRetention(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to enforce retention: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enforceRetention deletes alerts, HTTP check results and archived domains older
+// than the configured retention period, in batches
+// Domains go last; whatever history they still have is deleted with them.
+func (s *Scheduler) enforceRetention(ctx context.Context) (*RetentionReport, error) {
+	config, err := s.configRepo.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	period := config.GetRetentionPeriod()
+	if period <= 0 {
+		return nil, nil
+	}
+
+	report := &RetentionReport{Cutoff: time.Now().Add(-period)}
+	steps := []struct {
+		count *int
+		purge func(ctx context.Context, cutoff time.Time, limit int) (int, error)
+	}{
+		{&report.Alerts, s.alertRepo.DeleteOlderThan},
+		{&report.HTTPCheckResults, s.httpCheckRepo.DeleteResultsOlderThan},
+		{&report.Domains, s.domainRepo.DeleteOlderThan},
+	}
+
+	for _, step := range steps {
+		for {
+			deleted, err := step.purge(ctx, report.Cutoff, s.retentionBatch)
+			*step.count += deleted
+			if err != nil {
+				return report, err
+			}
+			if deleted < s.retentionBatch {
+				break
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	s.mu.Lock()
+	s.lastRetention = report
+	s.mu.Unlock()
+
+	if report.Total() > 0 {
+		log.Printf("Retention removed %d alerts, %d HTTP check results and %d archived domains older than %s",
+			report.Alerts, report.HTTPCheckResults, report.Domains, report.Cutoff.Format("2006-01-02 15:04"))
+	}
+	return report, nil
+}
+
+// PreviewRetention counts what a retention pass with the given period would
+// delete now, without deleting anything
+func (s *Scheduler) PreviewRetention(ctx context.Context, period time.Duration) (*RetentionReport, error) {
+	report := &RetentionReport{Cutoff: time.Now().Add(-period), DryRun: true}
+
+	var err error
+	if report.Alerts, err = s.alertRepo.CountOlderThan(ctx, report.Cutoff); err != nil {
+		return nil, err
+	}
+	if report.HTTPCheckResults, err = s.httpCheckRepo.CountResultsOlderThan(ctx, report.Cutoff); err != nil {
+		return nil, err
+	}
+	if report.Domains, err = s.domainRepo.CountOlderThan(ctx, report.Cutoff); err != nil {
+		return nil, err
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
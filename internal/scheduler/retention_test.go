@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+)
+
+// Test that retention deletes everything past the period across several
+// batches, and that the preview counts the same rows without deleting them
+func TestEnforceRetention(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	s.retentionBatch = 2
+
+	config, err := s.configRepo.Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	config.SetRetentionPeriod(30 * 24 * time.Hour)
+	if err := s.configRepo.Update(ctx, config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+
+	kept := &domain.Domain{Name: "kept.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now}
+	archived := &domain.Domain{Name: "archived.com", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: old, NextCheck: old}
+	for _, d := range []*domain.Domain{kept, archived} {
+		if err := s.domainRepo.Create(ctx, d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+	}
+	if err := s.domainRepo.SetState(ctx, archived.ID, domain.StateArchived, old); err != nil {
+		t.Fatalf("Failed to archive domain: %v", err)
+	}
+
+	for i, sentAt := range []time.Time{old, old, old, now} {
+		a := &domain.Alert{DomainID: kept.ID, DomainName: kept.Name, Type: domain.AlertTypeHTTPCheck, Status: domain.AlertStatusSent, SentAt: sentAt, ExpirationDate: now}
+		if err := s.alertRepo.Create(ctx, a); err != nil {
+			t.Fatalf("Failed to create alert %d: %v", i, err)
+		}
+	}
+	for i, checkedAt := range []time.Time{old, old, old, old, old, now} {
+		if err := s.httpCheckRepo.CreateResult(ctx, &domain.HTTPCheckResult{CheckID: "c1", CheckedAt: checkedAt}); err != nil {
+			t.Fatalf("Failed to create result %d: %v", i, err)
+		}
+	}
+
+	preview, err := s.PreviewRetention(ctx, config.GetRetentionPeriod())
+	if err != nil {
+		t.Fatalf("PreviewRetention() error = %v", err)
+	}
+	if !preview.DryRun || preview.Alerts != 3 || preview.HTTPCheckResults != 5 || preview.Domains != 1 {
+		t.Errorf("PreviewRetention() = %+v, want a dry run of 3 alerts, 5 results and 1 domain", preview)
+	}
+	if s.LastRetention() != nil {
+		t.Error("LastRetention() set by a preview")
+	}
+
+	report, err := s.enforceRetention(context.Background())
+	if err != nil {
+		t.Fatalf("enforceRetention() error = %v", err)
+	}
+	if report.DryRun || report.Alerts != preview.Alerts || report.HTTPCheckResults != preview.HTTPCheckResults || report.Domains != preview.Domains {
+		t.Errorf("enforceRetention() = %+v, want the previewed counts %+v", report, preview)
+	}
+	if s.LastRetention() != report {
+		t.Error("LastRetention() does not return the last report")
+	}
+
+	after, err := s.PreviewRetention(ctx, config.GetRetentionPeriod())
+	if err != nil {
+		t.Fatalf("PreviewRetention() error = %v", err)
+	}
+	if after.Total() != 0 {
+		t.Errorf("PreviewRetention() after cleanup = %+v, want nothing left", after)
+	}
+	if _, err := s.domainRepo.GetByID(ctx, kept.ID); err != nil {
+		t.Errorf("Active domain deleted: %v", err)
+	}
+	if _, err := s.domainRepo.GetByID(ctx, archived.ID); err == nil {
+		t.Error("Archived domain past retention was kept")
+	}
+}
@@ -2,76 +2,234 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/alert"
 	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/httpcheck"
 	"github.com/domain-expiration-monitor/dem/internal/repository"
 	"github.com/domain-expiration-monitor/dem/internal/whois"
+	"github.com/google/uuid"
 )
 
-// Scheduler manages periodic WHOIS checks for domains
+const (
+	// dispatchPollInterval is how often the dispatcher looks for due domain checks
+	dispatchPollInterval = 10 * time.Second
+	// dispatchBatchSize limits the due domains loaded per poll
+	dispatchBatchSize = 100
+	// checkLeaseDuration is how long a claimed domain stays invisible to other
+	// dispatchers; a check that has not finished by then is retried
+	checkLeaseDuration = 5 * time.Minute
+	// maxCheckAttempts is how many times a failing check is retried before it
+	// waits for the next monitoring interval
+	maxCheckAttempts = 5
+	// checkRetryDelay is how long a check whose WHOIS lookup failed waits before
+	// it is retried
+	checkRetryDelay = 5 * time.Minute
+	// httpCheckPollInterval is how often the scheduler looks for due HTTP checks
+	httpCheckPollInterval = 15 * time.Second
+	// alertRetryPollInterval is how often failed alert deliveries are retried
+	alertRetryPollInterval = time.Minute
+	// leaderLeaseName names the lease replicas compete for
+	leaderLeaseName = "scheduler"
+	// leaderLeaseTTL is how long a leader that stopped renewing keeps its lease
+	leaderLeaseTTL = 30 * time.Second
+	// leaderRenewInterval is how often the lease is renewed or, on a standby, tried
+	leaderRenewInterval = 10 * time.Second
+)
+
+// Scheduler manages periodic WHOIS checks for domains, HTTP checks for their
+// endpoints and registration checks for their lookalikes
+// Domain checks are driven by next_check in the database: a polling dispatcher
+// leases due domains to this instance, so state survives restarts and checks
+// whose worker died are picked up again once the lease runs out
+// When several replicas share a database only the elected leader runs checks and
+// sends alerts; the others stand by until the leader's lease expires
 type Scheduler struct {
-	domainRepo  *repository.DomainRepository
-	configRepo  *repository.ConfigRepository
-	whoisSvc    *whois.Service
-	alertSvc    *alert.Service
-	ctx         context.Context
-	cancel      context.CancelFunc
-	wg          sync.WaitGroup
-	workerPool  chan struct{}
-	mu          sync.RWMutex
-	scheduledDomains map[string]*time.Timer
+	domainRepo       repository.DomainStore
+	configRepo       repository.ConfigStore
+	alertRepo        repository.AlertStore
+	httpCheckRepo    repository.HTTPCheckStore
+	lookalikeRepo    repository.LookalikeStore
+	leaderRepo       repository.LeaderStore
+	whoisSvc         *whois.Service
+	alertSvc         *alert.Service
+	httpSvc          *httpcheck.Service
+	instanceID       string
+	leaderTTL        time.Duration
+	leaderRenewal    time.Duration
+	retentionBatch   int
+	leader           atomic.Bool
+	ctx              context.Context
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+	workerPool       chan struct{}
+	mu               sync.RWMutex
+	seededLookalikes map[string]bool
+	inflight         map[string]*inflightCheck
+	lastRetention    *RetentionReport
+	backupDB         *repository.DB
+	backupSchedule   BackupSchedule
 }
 
 // NewScheduler creates a new scheduler
 func NewScheduler(
-	domainRepo *repository.DomainRepository,
-	configRepo *repository.ConfigRepository,
+	domainRepo repository.DomainStore,
+	configRepo repository.ConfigStore,
+	alertRepo repository.AlertStore,
+	httpCheckRepo repository.HTTPCheckStore,
+	lookalikeRepo repository.LookalikeStore,
+	leaderRepo repository.LeaderStore,
 	whoisSvc *whois.Service,
 	alertSvc *alert.Service,
+	httpSvc *httpcheck.Service,
 ) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
 		domainRepo:       domainRepo,
 		configRepo:       configRepo,
+		alertRepo:        alertRepo,
+		httpCheckRepo:    httpCheckRepo,
+		lookalikeRepo:    lookalikeRepo,
+		leaderRepo:       leaderRepo,
 		whoisSvc:         whoisSvc,
 		alertSvc:         alertSvc,
+		httpSvc:          httpSvc,
+		instanceID:       newInstanceID(),
+		leaderTTL:        leaderLeaseTTL,
+		leaderRenewal:    leaderRenewInterval,
+		retentionBatch:   retentionBatchSize,
 		ctx:              ctx,
 		cancel:           cancel,
 		workerPool:       make(chan struct{}, 10), // 10 concurrent workers
-		scheduledDomains: make(map[string]*time.Timer),
+		seededLookalikes: make(map[string]bool),
+		inflight:         make(map[string]*inflightCheck),
 	}
 }
 
+// newLease returns a token for one claim of a domain by this instance
+// Each claim gets its own, so a check that overran its lease is told apart from
+// a later claim of the same domain by this instance.
+func (s *Scheduler) newLease() string {
+	return s.instanceID + "/" + uuid.New().String()
+}
+
+// newInstanceID identifies this process as the owner of the leases it takes
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "dem"
+	}
+	return fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+}
+
 // Start initializes and starts the scheduler
+// Checks begin once this instance is elected leader
 func (s *Scheduler) Start() error {
-	// Load all domains
-	domains, err := s.domainRepo.GetAll()
-	if err != nil {
-		return fmt.Errorf("failed to load domains: %w", err)
+	s.wg.Add(1)
+	go s.runLeaderElection()
+
+	return nil
+}
+
+// IsLeader reports whether this instance currently runs checks and sends alerts
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// InstanceID returns the name this instance holds the leader lease under, which
+// also prefixes its check leases
+func (s *Scheduler) InstanceID() string {
+	return s.instanceID
+}
+
+// runLeaderElection keeps trying to acquire the leader lease, runs the check loops
+// while it holds it and stops them when it is lost
+func (s *Scheduler) runLeaderElection() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.leaderRenewal)
+	defer ticker.Stop()
+
+	var stopWork func()
+	var leaseUntil time.Time
+	for {
+		now := time.Now()
+		leader, err := s.leaderRepo.TryAcquire(s.ctx, leaderLeaseName, s.instanceID, now, s.leaderTTL)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				log.Printf("Failed to renew leader lease: %v", err)
+			}
+			// Keep leading while the last renewal is still safely valid
+			leader = stopWork != nil && now.Add(s.leaderRenewal).Before(leaseUntil)
+		} else if leader {
+			leaseUntil = now.Add(s.leaderTTL)
+		}
+
+		switch {
+		case leader && stopWork == nil:
+			log.Printf("Instance %s elected leader, starting checks", s.instanceID)
+			stopWork = s.startWork()
+		case !leader && stopWork != nil:
+			log.Printf("Instance %s lost leadership, stopping checks", s.instanceID)
+			stopWork()
+			stopWork = nil
+		}
+
+		select {
+		case <-s.ctx.Done():
+			if stopWork != nil {
+				stopWork()
+				// s.ctx is done; the handover must still reach the database
+				if err := s.leaderRepo.Release(context.Background(), leaderLeaseName, s.instanceID); err != nil {
+					log.Printf("Failed to release leader lease: %v", err)
+				}
+			}
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	// Schedule each domain
-	for _, d := range domains {
-		s.ScheduleDomain(d)
+// startWork starts the check loops and returns a function that stops them
+func (s *Scheduler) startWork() func() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.leader.Store(true)
+
+	var wg sync.WaitGroup
+	for _, loop := range []func(context.Context){
+		s.runDispatcher,     // due domain checks
+		s.runHTTPChecks,     // due HTTP checks
+		s.runLookalikeScans, // registered lookalikes of monitored domains
+		s.runAlertRetries,   // failed alert deliveries
+		s.runRetention,      // data past the retention period
+		s.runBackups,        // scheduled backups, when enabled
+	} {
+		wg.Add(1)
+		go func(loop func(context.Context)) {
+			defer wg.Done()
+			loop(ctx)
+		}(loop)
 	}
 
-	return nil
+	return func() {
+		s.leader.Store(false)
+		cancel()
+		wg.Wait()
+	}
 }
 
 // Stop gracefully shuts down the scheduler
+// In-flight lookups, probes and alert deliveries are canceled and their domains
+// handed back to the queue.
 func (s *Scheduler) Stop() error {
 	s.cancel()
-	
-	// Cancel all timers
-	s.mu.Lock()
-	for _, timer := range s.scheduledDomains {
-		timer.Stop()
-	}
-	s.mu.Unlock()
 
 	// Wait for all workers to finish (with timeout)
 	done := make(chan struct{})
@@ -88,121 +246,328 @@ func (s *Scheduler) Stop() error {
 	}
 }
 
-// ScheduleDomain adds a domain to the monitoring schedule
-func (s *Scheduler) ScheduleDomain(d *domain.Domain) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// runDispatcher periodically claims due domains and checks them on the worker pool
+func (s *Scheduler) runDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(dispatchPollInterval)
+	defer ticker.Stop()
 
-	// Cancel existing timer if any
-	if timer, exists := s.scheduledDomains[d.ID]; exists {
-		timer.Stop()
-	}
+	for {
+		s.dispatchDueDomains(ctx)
 
-	// Calculate time until next check
-	var delay time.Duration
-	if time.Now().Before(d.NextCheck) {
-		delay = time.Until(d.NextCheck)
-	} else {
-		delay = 0 // Check immediately
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	// Schedule the check
-	timer := time.AfterFunc(delay, func() {
-		s.checkDomain(d.ID)
-	})
+// dispatchDueDomains claims each due domain and starts its check once a worker is free
+// Domains claimed by another instance in the meantime are skipped
+func (s *Scheduler) dispatchDueDomains(ctx context.Context) {
+	domains, err := s.domainRepo.GetDomainsForCheck(ctx, time.Now(), dispatchBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to load due domains: %v", err)
+		}
+		return
+	}
 
-	s.scheduledDomains[d.ID] = timer
-}
+	for _, d := range domains {
+		select {
+		case s.workerPool <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
 
-// UnscheduleDomain removes a domain from the monitoring schedule
-func (s *Scheduler) UnscheduleDomain(domainID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+		lease := s.newLease()
+		claimed, err := s.domainRepo.ClaimDomain(ctx, d.ID, lease, time.Now(), checkLeaseDuration)
+		if err != nil || !claimed {
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Failed to claim %s for check: %v", d.Name, err)
+			}
+			<-s.workerPool
+			continue
+		}
 
-	if timer, exists := s.scheduledDomains[domainID]; exists {
-		timer.Stop()
-		delete(s.scheduledDomains, domainID)
+		// Register the check so manual checks of the domain wait for it. A manual
+		// check that is about to start gets the claim handed back instead.
+		id := d.ID
+		d.LeaseOwner = lease
+		c, started := s.track(ctx, id, func(ctx context.Context) *CheckResult { return s.checkDomain(ctx, id, lease) })
+		if !started {
+			s.leave(c)
+			s.deferCheck(ctx, d)
+			<-s.workerPool
+			continue
+		}
+		go func() {
+			<-c.done
+			s.leave(c)
+			<-s.workerPool
+		}()
 	}
 }
 
-// checkDomain performs a WHOIS check for a domain
-func (s *Scheduler) checkDomain(domainID string) {
-	// Acquire worker slot
-	select {
-	case s.workerPool <- struct{}{}:
-		defer func() { <-s.workerPool }()
-	case <-s.ctx.Done():
-		return
-	}
-
-	s.wg.Add(1)
-	defer s.wg.Done()
-
+// checkDomain performs a WHOIS check for a domain claimed by this instance
+// The lease is released once the outcome is saved. A failed lookup also ends the
+// lease but keeps its attempt count, and is retried after checkRetryDelay until
+// maxCheckAttempts is reached. A check canceled during the lookup hands the
+// domain back to the queue unchanged. Outcomes are only saved while the lease,
+// the token the domain was claimed with, is live, so a check that overran it
+// cannot overwrite a newer one.
+func (s *Scheduler) checkDomain(ctx context.Context, domainID, lease string) *CheckResult {
 	// Get domain
-	d, err := s.domainRepo.GetByID(domainID)
+	d, err := s.domainRepo.GetByID(ctx, domainID)
 	if err != nil {
 		// Domain might have been deleted
-		return
+		return &CheckResult{DomainID: domainID, Status: CheckNotFound, Error: err.Error()}
+	}
+
+	// The writes below are made under d.LeaseOwner, which must be this claim
+	if d.LeaseOwner != lease {
+		return newCheckResult(d, CheckFailed, repository.ErrLeaseLost)
+	}
+
+	// Paused or archived since it was claimed
+	if !d.IsActive() {
+		s.release(ctx, d)
+		return newCheckResult(d, CheckInactive, nil)
 	}
 
 	// Get config for scheduling
-	config, err := s.configRepo.Get()
+	config, err := s.configRepo.Get(ctx)
 	if err != nil {
-		s.reschedule(d)
-		return
+		return newCheckResult(d, CheckFailed, err)
+	}
+
+	// Stop retrying a check that keeps failing until the next interval
+	if d.CheckAttempts > maxCheckAttempts {
+		log.Printf("Check for %s failed %d times, retrying after the monitoring interval", d.Name, d.CheckAttempts-1)
+		d.NextCheck = time.Now().Add(config.GetMonitoringInterval())
+		if err := s.domainRepo.UpdateLeased(ctx, d, d.LeaseOwner, time.Now()); err != nil {
+			return newCheckResult(d, CheckFailed, err)
+		}
+		s.release(ctx, d)
+		return newCheckResult(d, CheckFailed, fmt.Errorf("check failed %d times", d.CheckAttempts-1))
 	}
 
 	// Perform WHOIS query
-	info, err := s.whoisSvc.QueryDomain(d.Name)
+	info, err := s.whoisSvc.QueryDomain(ctx, d.Name)
+	if ctx.Err() != nil {
+		// ctx is done; hand the domain back without counting the attempt
+		s.deferCheck(context.WithoutCancel(ctx), d)
+		return newCheckResult(d, CheckCanceled, ctx.Err())
+	}
+	if errors.Is(err, whois.ErrRateLimited) {
+		d.NextCheck = time.Now().Add(whois.RetryAfter(err))
+		s.deferCheck(ctx, d)
+		return newCheckResult(d, CheckDeferred, err)
+	}
+	if d.IsWatched() {
+		return s.checkWatchedDomain(ctx, d, config, info, err)
+	}
+
+	if errors.Is(err, whois.ErrDomainNotRegistered) {
+		return s.markNotRegistered(ctx, d, config)
+	}
+
 	if err != nil {
 		// WHOIS failed, but still evaluate alerts with existing data
+		d.LookupFailures++
 		d.LastChecked = time.Now()
-		d.NextCheck = time.Now().Add(config.GetMonitoringInterval())
+		d.NextCheck = s.retryAt(d, config)
 		
 		// Save updated check times
-		if err := s.domainRepo.Update(d); err != nil {
-			s.reschedule(d)
-			return
+		if err := s.domainRepo.UpdateLeased(ctx, d, d.LeaseOwner, time.Now()); err != nil {
+			return newCheckResult(d, CheckFailed, err)
 		}
 		
 		// Evaluate alerts with existing expiration date
-		if err := s.alertSvc.EvaluateAlerts(d); err != nil {
+		if err := s.alertSvc.EvaluateAlerts(ctx, d); err != nil {
 			// Log error but continue
 		}
 		
-		s.reschedule(d)
-		return
+		s.retryCheck(ctx, d)
+		return newCheckResult(d, CheckFailed, err)
 	}
 
 	// Update domain with new WHOIS data
-	d.ExpirationDate = info.ExpirationDate
-	d.Nameservers = domain.Strings(info.Nameservers)
-	d.Registrant = info.Registrant
-	d.Registrar = info.Registrar
+	previousStatuses := d.Statuses
+	applyDomainInfo(d, info)
 	d.LastChecked = time.Now()
-	d.NextCheck = time.Now().Add(config.GetMonitoringInterval())
+	d.NextCheck = s.NextCheck(d, config)
 
 	// Save updated domain
-	if err := s.domainRepo.Update(d); err != nil {
-		s.reschedule(d)
-		return
+	if err := s.domainRepo.UpdateLeased(ctx, d, d.LeaseOwner, time.Now()); err != nil {
+		return newCheckResult(d, CheckFailed, err)
 	}
 
 	// Evaluate alerts
-	if err := s.alertSvc.EvaluateAlerts(d); err != nil {
+	if err := s.alertSvc.EvaluateAlerts(ctx, d); err != nil {
 		// Log error but continue
 	}
+	if err := s.alertSvc.EvaluateStatusChanges(ctx, d, previousStatuses); err != nil {
+		log.Printf("Failed to send status alert for %s: %v", d.Name, err)
+	}
 
-	// Reschedule next check
-	s.reschedule(d)
+	// Release the lease until the next check is due
+	s.release(ctx, d)
+	return newCheckResult(d, CheckCompleted, nil)
 }
 
-// reschedule schedules the next check for a domain
-func (s *Scheduler) reschedule(d *domain.Domain) {
-	select {
-	case <-s.ctx.Done():
+// markNotRegistered records that a monitored domain has been dropped by its registry
+// The last known expiration date is kept but no longer drives expiration alerts
+func (s *Scheduler) markNotRegistered(ctx context.Context, d *domain.Domain, config *domain.Config) *CheckResult {
+	previousState := d.RegistrationState
+	d.RegistrationState = domain.RegistrationAvailable
+	d.LookupFailures = 0
+	d.LastChecked = time.Now()
+	d.NextCheck = time.Now().Add(config.GetMonitoringInterval())
+
+	if err := s.domainRepo.UpdateLeased(ctx, d, d.LeaseOwner, time.Now()); err != nil {
+		return newCheckResult(d, CheckFailed, err)
+	}
+
+	if err := s.alertSvc.EvaluateRegistration(ctx, d, previousState); err != nil {
+		log.Printf("Failed to send not-registered alert for %s: %v", d.Name, err)
+	}
+
+	s.release(ctx, d)
+	return newCheckResult(d, CheckCompleted, nil)
+}
+
+// applyDomainInfo copies fresh WHOIS data onto a domain
+func applyDomainInfo(d *domain.Domain, info *domain.DomainInfo) {
+	d.LookupFailures = 0
+	d.ExpirationDate = info.ExpirationDate
+	d.Nameservers = domain.Strings(info.Nameservers)
+	d.Registrant = info.Registrant
+	d.Registrar = info.Registrar
+	d.RegistrationState = domain.RegistrationStateFromStatuses(info.Statuses)
+	d.Statuses = domain.NormalizeStatuses(info.Statuses)
+}
+
+// release gives up the lease d was claimed with after its check completed
+func (s *Scheduler) release(ctx context.Context, d *domain.Domain) {
+	if err := s.domainRepo.ReleaseDomain(ctx, d.ID, d.LeaseOwner, time.Now()); err != nil {
+		log.Printf("Failed to release %s: %v", d.Name, err)
+	}
+}
+
+// retryCheck gives up the lease on a domain whose lookup failed and schedules the
+// retry for its NextCheck, keeping the attempt count
+func (s *Scheduler) retryCheck(ctx context.Context, d *domain.Domain) {
+	if err := s.domainRepo.RetryCheck(ctx, d.ID, d.LeaseOwner, time.Now(), d.NextCheck); err != nil {
+		log.Printf("Failed to schedule retry for %s: %v", d.Name, err)
+	}
+}
+
+// deferCheck puts a domain whose check could not run, because its WHOIS server
+// is rate limited or the check was canceled, back in the queue for its NextCheck.
+// The claim does not count as a failed attempt.
+func (s *Scheduler) deferCheck(ctx context.Context, d *domain.Domain) {
+	if err := s.domainRepo.DeferCheck(ctx, d.ID, d.LeaseOwner, time.Now(), d.NextCheck); err != nil {
+		log.Printf("Failed to defer check for %s: %v", d.Name, err)
+	}
+}
+
+// runAlertRetries periodically redelivers failed alerts whose retry is due
+func (s *Scheduler) runAlertRetries(ctx context.Context) {
+	ticker := time.NewTicker(alertRetryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.alertSvc.RetryFailed(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to retry alerts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runHTTPChecks periodically runs the HTTP checks that are due
+func (s *Scheduler) runHTTPChecks(ctx context.Context) {
+	ticker := time.NewTicker(httpCheckPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runDueHTTPChecks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueHTTPChecks probes every due check and waits for all of them to finish
+func (s *Scheduler) runDueHTTPChecks(ctx context.Context) {
+	checks, err := s.httpCheckRepo.GetDueChecks(ctx, time.Now())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to load due HTTP checks: %v", err)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		select {
+		case s.workerPool <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(c *domain.HTTPCheck) {
+			defer wg.Done()
+			defer func() { <-s.workerPool }()
+			s.runHTTPCheck(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// runHTTPCheck probes a single HTTP check, stores the result and evaluates alerts
+func (s *Scheduler) runHTTPCheck(ctx context.Context, c *domain.HTTPCheck) {
+	d, err := s.domainRepo.GetByID(ctx, c.DomainID)
+	if err != nil || !d.IsActive() {
+		// Domain might have been deleted, paused or archived
+		return
+	}
+
+	result := s.httpSvc.Probe(ctx, c)
+	if ctx.Err() != nil {
+		// Canceled probes say nothing about the endpoint; the check stays due
 		return
-	default:
-		s.ScheduleDomain(d)
+	}
+	if err := s.httpCheckRepo.CreateResult(ctx, result); err != nil {
+		log.Printf("Failed to save HTTP check result for %s: %v", c.URL, err)
+	}
+
+	previousFailures := c.ConsecutiveFailures
+	if result.Success {
+		c.ConsecutiveFailures = 0
+	} else {
+		c.ConsecutiveFailures++
+	}
+	checkedAt := result.CheckedAt
+	c.LastChecked = &checkedAt
+	c.NextCheck = result.CheckedAt.Add(c.GetInterval())
+
+	if err := s.httpCheckRepo.Update(ctx, c); err != nil {
+		log.Printf("Failed to update HTTP check %s: %v", c.URL, err)
+		return
+	}
+
+	if err := s.alertSvc.EvaluateHTTPCheck(ctx, d, c, result, previousFailures); err != nil {
+		log.Printf("Failed to evaluate HTTP check alerts for %s: %v", c.URL, err)
 	}
 }
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/alert"
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/httpcheck"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/memory"
+	"github.com/domain-expiration-monitor/dem/internal/whois"
+)
+
+// newReplica creates a scheduler on shared stores, as a separate DEM container
+// sharing a database would be
+func newReplica(t *testing.T, stores repository.Stores) *Scheduler {
+	t.Helper()
+
+	s := NewScheduler(
+		stores.Domains,
+		stores.Config,
+		stores.Alerts,
+		stores.HTTPChecks,
+		stores.Lookalikes,
+		stores.Leader,
+		whois.NewService(),
+		alert.NewService(stores.Alerts, stores.Config),
+		httpcheck.NewService(),
+	)
+	s.leaderTTL = 300 * time.Millisecond
+	s.leaderRenewal = 50 * time.Millisecond
+	return s
+}
+
+// newTestScheduler creates a scheduler on an empty in-memory store
+// It runs manual checks as the leader would, without starting its loops.
+func newTestScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+	s := newReplica(t, memory.NewStore().Stores())
+	s.leader.Store(true)
+	return s
+}
+
+// forEachBackend runs fn against the in-memory store and against SQLite
+// open returns stores on the backend's shared data; on SQLite each call opens its
+// own connection, as a separate DEM container sharing the database would.
+func forEachBackend(t *testing.T, fn func(t *testing.T, open func() repository.Stores)) {
+	t.Run("memory", func(t *testing.T) {
+		store := memory.NewStore()
+		fn(t, store.Stores)
+	})
+	t.Run("sqlite3", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dem.db")
+		fn(t, func() repository.Stores {
+			db, err := repository.NewDB(path, "sqlite3")
+			if err != nil {
+				t.Fatalf("Failed to create test database: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return repository.NewStores(db)
+		})
+	})
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+// Test that only one of two replicas leads and that the standby takes over when
+// the leader crashes or shuts down
+func TestScheduler_LeaderFailover(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, open func() repository.Stores) {
+		ctx := context.Background()
+		first := newReplica(t, open())
+		second := newReplica(t, open())
+
+		// A replica that crashed while leading still holds the lease
+		if ok, err := open().Leader.TryAcquire(ctx, leaderLeaseName, "crashed", time.Now(), 300*time.Millisecond); err != nil || !ok {
+			t.Fatalf("Failed to seed crashed leader: %v", err)
+		}
+
+		if err := first.Start(); err != nil {
+			t.Fatalf("Start() unexpected error: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if first.IsLeader() {
+			t.Fatal("first replica took over before the crashed leader's lease expired")
+		}
+		if !waitFor(2*time.Second, first.IsLeader) {
+			t.Fatal("first replica did not take over the expired lease")
+		}
+
+		if err := second.Start(); err != nil {
+			t.Fatalf("Start() unexpected error: %v", err)
+		}
+		defer second.Stop()
+
+		// Several renewal rounds pass without the standby taking over
+		time.Sleep(500 * time.Millisecond)
+		if second.IsLeader() {
+			t.Fatal("both replicas are leading")
+		}
+		if !first.IsLeader() {
+			t.Fatal("first replica lost leadership while renewing")
+		}
+
+		if err := first.Stop(); err != nil {
+			t.Fatalf("Stop() unexpected error: %v", err)
+		}
+		if first.IsLeader() {
+			t.Fatal("stopped replica still reports leadership")
+		}
+		if !waitFor(2*time.Second, second.IsLeader) {
+			t.Fatal("standby did not take over after the leader stopped")
+		}
+	})
+}
+
+// Test that stopping the scheduler aborts a lookup in flight and hands its
+// domain back to the queue without counting the attempt
+func TestScheduler_StopCancelsChecks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	started := make(chan struct{})
+	s.whoisSvc.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		// Lookalike scans of the domain hang as well but are not the check under test
+		if query == "hanging.co" {
+			close(started)
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	now := time.Now()
+	d := &domain.Domain{Name: "hanging.co", Registrar: "Old Registrar", ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now.Add(-time.Hour), NextCheck: now.Add(-time.Minute)}
+	if err := s.domainRepo.Create(ctx, d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() unexpected error: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("WHOIS lookup was not started")
+	}
+
+	stopped := time.Now()
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() unexpected error: %v", err)
+	}
+	if elapsed := time.Since(stopped); elapsed > time.Second {
+		t.Errorf("Stop() took %v, want the lookup aborted promptly", elapsed)
+	}
+
+	saved, err := s.domainRepo.GetByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if saved.Registrar != "Old Registrar" || saved.LeaseOwner != "" || saved.CheckAttempts != 0 {
+		t.Errorf("Domain after Stop(): registrar %q owner %q attempts %d, want unchanged, released and not counted", saved.Registrar, saved.LeaseOwner, saved.CheckAttempts)
+	}
+}
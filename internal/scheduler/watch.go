@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/whois"
+)
+
+// checkWatchedDomain records the outcome of a WHOIS lookup for a watchlist domain
+// and alerts when it moves towards becoming available
+func (s *Scheduler) checkWatchedDomain(ctx context.Context, d *domain.Domain, config *domain.Config, info *domain.DomainInfo, lookupErr error) *CheckResult {
+	previousState := d.RegistrationState
+
+	switch {
+	case lookupErr == nil:
+		applyDomainInfo(d, info)
+	case errors.Is(lookupErr, whois.ErrDomainNotRegistered):
+		// Keep the last known registration details for reference
+		d.RegistrationState = domain.RegistrationAvailable
+		d.LookupFailures = 0
+	default:
+		// Lookup failed; the previous state is still the best information we have
+		d.LookupFailures++
+		log.Printf("WHOIS lookup failed for watched domain %s: %v", d.Name, lookupErr)
+	}
+
+	failed := lookupErr != nil && !errors.Is(lookupErr, whois.ErrDomainNotRegistered)
+	d.LastChecked = time.Now()
+	if failed {
+		d.NextCheck = s.retryAt(d, config)
+	} else {
+		d.NextCheck = s.NextCheck(d, config)
+	}
+
+	if err := s.domainRepo.UpdateLeased(ctx, d, d.LeaseOwner, time.Now()); err != nil {
+		return newCheckResult(d, CheckFailed, err)
+	}
+
+	if err := s.alertSvc.EvaluateWatchState(ctx, d, previousState); err != nil {
+		log.Printf("Failed to evaluate watch alerts for %s: %v", d.Name, err)
+	}
+
+	if failed {
+		s.retryCheck(ctx, d)
+		return newCheckResult(d, CheckFailed, lookupErr)
+	}
+	s.release(ctx, d)
+	return newCheckResult(d, CheckCompleted, nil)
+}
@@ -0,0 +1,162 @@
+// Package secret encrypts the secrets DEM stores, such as webhook URLs, with
+// AES-256-GCM under a key kept outside the database
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of a key in bytes, for AES-256
+const KeySize = 32
+
+// prefix marks encrypted values; values without it are plain text, stored
+// before a key was configured
+const prefix = "enc:v1:"
+
+// ErrNoKey is returned when an encrypted value is read without a key
+var ErrNoKey = errors.New("value is encrypted but no secret key is configured; set SECRET_KEY or SECRET_KEY_FILE")
+
+// Cipher encrypts and decrypts secrets with one key
+// A nil Cipher stands for no key: it stores secrets as plain text and fails to
+// read encrypted ones.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher for a KeySize-byte key
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secret key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// IsEncrypted reports whether a stored value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt encrypts a secret for storage, with a fresh random nonce each time
+// Empty secrets stay empty, and without a key secrets are returned unchanged.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the secret of a stored value; plain text values are returned unchanged
+func (c *Cipher) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrNoKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted value: %w", err)
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("encrypted value is too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt value: wrong secret key or corrupted data")
+	}
+	return string(plaintext), nil
+}
+
+// GenerateKey returns a new random key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeKey formats a key as base64, as ParseKey reads it
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParseKey reads a base64-encoded key, ignoring surrounding whitespace
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("secret key must be base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secret key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadCipher returns the cipher of a base64 key given directly or in a file, or
+// nil when neither is set
+func LoadCipher(key, keyFile string) (*Cipher, error) {
+	if key != "" && keyFile != "" {
+		return nil, errors.New("set either SECRET_KEY or SECRET_KEY_FILE, not both")
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret key file: %w", err)
+		}
+		key = string(data)
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	raw, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(raw)
+}
+
+// WriteKeyFile writes a new random key to path, which must not exist yet, readable
+// by its owner only
+func WriteKeyFile(path string) (*Cipher, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret key file: %w", err)
+	}
+	if _, err := f.WriteString(EncodeKey(key) + "\n"); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write secret key file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write secret key file: %w", err)
+	}
+	return NewCipher(key)
+}
@@ -0,0 +1,117 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T) *Cipher {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher() unexpected error: %v", err)
+	}
+	return c
+}
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c := newTestCipher(t)
+	webhook := "https://chat.googleapis.com/v1/spaces/AAA/messages?key=secret-key&token=secret-token"
+
+	encrypted, err := c.Encrypt(webhook)
+	if err != nil {
+		t.Fatalf("Encrypt() unexpected error: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "secret-key") {
+		t.Errorf("Encrypt() = %q, want an encrypted value", encrypted)
+	}
+	if again, _ := c.Encrypt(webhook); again == encrypted {
+		t.Error("Encrypt() twice gave the same value, want a fresh nonce each time")
+	}
+
+	got, err := c.Decrypt(encrypted)
+	if err != nil || got != webhook {
+		t.Errorf("Decrypt() = %q, %v; want %q", got, err, webhook)
+	}
+
+	if got, err := c.Encrypt(""); err != nil || got != "" {
+		t.Errorf("Encrypt(\"\") = %q, %v; want empty", got, err)
+	}
+	if got, err := c.Decrypt("https://plain.example.com/hook"); err != nil || got != "https://plain.example.com/hook" {
+		t.Errorf("Decrypt() of plain text = %q, %v; want it unchanged", got, err)
+	}
+}
+
+func TestCipher_WrongKey(t *testing.T) {
+	encrypted, err := newTestCipher(t).Encrypt("https://hooks.example.com/notify?token=x")
+	if err != nil {
+		t.Fatalf("Encrypt() unexpected error: %v", err)
+	}
+
+	if _, err := newTestCipher(t).Decrypt(encrypted); err == nil {
+		t.Error("Decrypt() with another key succeeded, want an error")
+	}
+	if _, err := newTestCipher(t).Decrypt(encrypted[:len(encrypted)-4]); err == nil {
+		t.Error("Decrypt() of a truncated value succeeded, want an error")
+	}
+
+	var none *Cipher
+	if _, err := none.Decrypt(encrypted); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Decrypt() without a key error = %v, want ErrNoKey", err)
+	}
+	if got, err := none.Encrypt("plain"); err != nil || got != "plain" {
+		t.Errorf("Encrypt() without a key = %q, %v; want plain text", got, err)
+	}
+}
+
+func TestLoadCipher(t *testing.T) {
+	key, _ := GenerateKey()
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "secret.key")
+	if err := os.WriteFile(keyFile, []byte(EncodeKey(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := LoadCipher("", ""); c != nil || err != nil {
+		t.Errorf("LoadCipher() without a key = %v, %v; want nil", c, err)
+	}
+	if c, err := LoadCipher(EncodeKey(key), ""); c == nil || err != nil {
+		t.Errorf("LoadCipher(key) = %v, %v; want a cipher", c, err)
+	}
+	if c, err := LoadCipher("", keyFile); c == nil || err != nil {
+		t.Errorf("LoadCipher(file) = %v, %v; want a cipher", c, err)
+	}
+	if _, err := LoadCipher(EncodeKey(key), keyFile); err == nil {
+		t.Error("LoadCipher() with both a key and a file succeeded, want an error")
+	}
+	if _, err := LoadCipher(EncodeKey(key[:16]), ""); err == nil {
+		t.Error("LoadCipher() with a short key succeeded, want an error")
+	}
+
+	written := filepath.Join(dir, "new.key")
+	c, err := WriteKeyFile(written)
+	if err != nil {
+		t.Fatalf("WriteKeyFile() unexpected error: %v", err)
+	}
+	if info, err := os.Stat(written); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("WriteKeyFile() file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	loaded, err := LoadCipher("", written)
+	if err != nil {
+		t.Fatalf("LoadCipher() of the written key unexpected error: %v", err)
+	}
+	encrypted, _ := c.Encrypt("secret")
+	if got, err := loaded.Decrypt(encrypted); err != nil || got != "secret" {
+		t.Errorf("Decrypt() with the written key = %q, %v; want the secret", got, err)
+	}
+	if _, err := WriteKeyFile(written); err == nil {
+		t.Error("WriteKeyFile() over an existing file succeeded, want an error")
+	}
+}
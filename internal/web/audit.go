@@ -0,0 +1,331 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/scheduler"
+)
+
+// auditDateLayout is the layout of the since and until dates of the audit filters
+const auditDateLayout = "2006-01-02"
+
+// SetActorHeader trusts an authenticating reverse proxy in front of the server:
+// the actor of each change is read from the named request header, and its
+// source IP from the last address the proxy appended to X-Forwarded-For
+func (s *Server) SetActorHeader(name string) {
+	s.actorHeader = name
+}
+
+// requestActor returns who made a request and from which IP address
+func (s *Server) requestActor(r *http.Request) (actor, ip string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if s.actorHeader == "" {
+		return domain.AnonymousActor, ip
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		ip = strings.TrimSpace(hops[len(hops)-1])
+	}
+	actor = strings.TrimSpace(r.Header.Get(s.actorHeader))
+	if actor == "" {
+		actor = domain.AnonymousActor
+	}
+	return actor, ip
+}
+
+// audit appends a change made by a request to the audit log, with the object
+// before and after it; nil stands for an object that did not or no longer exists
+// A change that cannot be recorded is logged; it has already been made.
+func (s *Server) audit(r *http.Request, e domain.AuditEntry, before, after interface{}) {
+	e.Actor, e.SourceIP = s.requestActor(r)
+	if err := e.SetBefore(before); err != nil {
+		log.Printf("Audit %s of %s: %v", e.Action, e.ObjectID, err)
+	}
+	if err := e.SetAfter(after); err != nil {
+		log.Printf("Audit %s of %s: %v", e.Action, e.ObjectID, err)
+	}
+
+	// The entry is recorded even when the client has gone away after the change
+	if err := s.auditRepo.Create(context.WithoutCancel(r.Context()), &e); err != nil {
+		log.Printf("Failed to record audit entry %s of %s: %v", e.Action, e.ObjectID, err)
+	}
+}
+
+// auditDomain appends a change of a domain to the audit log
+func (s *Server) auditDomain(r *http.Request, action string, d *domain.Domain, before, after interface{}) {
+	s.audit(r, domain.AuditEntry{
+		Action:     action,
+		ObjectType: domain.AuditObjectDomain,
+		ObjectID:   d.ID,
+		ObjectName: d.Name,
+	}, before, after)
+}
+
+// reloadDomain loads a domain after a change for its audit entry, or returns nil
+func (s *Server) reloadDomain(ctx context.Context, id string) *domain.Domain {
+	d, err := s.domainRepo.GetByID(ctx, id)
+	if err != nil {
+		log.Printf("Failed to reload domain %s for the audit log: %v", id, err)
+		return nil
+	}
+	return d
+}
+
+// auditChecks records a domain.check entry for each domain a check-now request
+// checks, as its result passes through
+func (s *Server) auditChecks(r *http.Request, results <-chan *scheduler.CheckResult) <-chan *scheduler.CheckResult {
+	out := make(chan *scheduler.CheckResult)
+	go func() {
+		defer close(out)
+		for result := range results {
+			if result.Domain != nil {
+				s.auditDomain(r, domain.AuditDomainCheck, result.Domain, nil, result)
+			}
+			out <- result
+		}
+	}()
+	return out
+}
+
+// auditFilter is the filter of the audit page, as read from its URL
+type auditFilter struct {
+	Actor      string
+	Action     string
+	ObjectType string
+	Object     string
+	Since      string // date, inclusive
+	Until      string // date, inclusive
+	Page       int
+}
+
+// parseAuditFilter reads the audit filter from the query parameters of a request
+func parseAuditFilter(values url.Values) (auditFilter, error) {
+	f := auditFilter{
+		Actor:      strings.TrimSpace(values.Get("actor")),
+		Action:     values.Get("action"),
+		ObjectType: values.Get("type"),
+		Object:     strings.TrimSpace(values.Get("object")),
+		Since:      values.Get("since"),
+		Until:      values.Get("until"),
+	}
+	if f.Action != "" && !contains(domain.AuditActions, f.Action) {
+		return f, fmt.Errorf("unknown action: %s", f.Action)
+	}
+	if f.ObjectType != "" && !contains(domain.AuditObjectTypes, f.ObjectType) {
+		return f, fmt.Errorf("unknown object type: %s", f.ObjectType)
+	}
+	if page := values.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return f, fmt.Errorf("invalid page: %s", page)
+		}
+		f.Page = n
+	}
+	return f, nil
+}
+
+// contains reports whether s is one of values
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// query converts the filter into an audit query; dates are days in loc
+func (f auditFilter) query(loc *time.Location) (repository.AuditQuery, error) {
+	q := repository.AuditQuery{
+		Actor:      f.Actor,
+		Action:     f.Action,
+		ObjectType: f.ObjectType,
+		Object:     f.Object,
+		Page:       f.Page,
+	}
+	if f.Since != "" {
+		since, err := time.ParseInLocation(auditDateLayout, f.Since, loc)
+		if err != nil {
+			return q, fmt.Errorf("invalid since date: %s", f.Since)
+		}
+		q.Since = since
+	}
+	if f.Until != "" {
+		until, err := time.ParseInLocation(auditDateLayout, f.Until, loc)
+		if err != nil {
+			return q, fmt.Errorf("invalid until date: %s", f.Until)
+		}
+		q.Until = until.AddDate(0, 0, 1)
+	}
+	return q, nil
+}
+
+// values returns the query parameters of the filter, leaving out the page
+func (f auditFilter) values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("actor", f.Actor)
+	set("action", f.Action)
+	set("type", f.ObjectType)
+	set("object", f.Object)
+	set("since", f.Since)
+	set("until", f.Until)
+	return values
+}
+
+// Filtered reports whether the filter narrows the audit log down
+func (f auditFilter) Filtered() bool {
+	return len(f.values()) > 0
+}
+
+// PageURL returns the URL of a page of the filtered audit log
+func (f auditFilter) PageURL(page int) string {
+	values := f.values()
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+	if len(values) == 0 {
+		return "/audit"
+	}
+	return "/audit?" + values.Encode()
+}
+
+// ExportURL returns the URL exporting the filtered audit log in a format
+func (f auditFilter) ExportURL(format string) string {
+	values := f.values()
+	values.Set("format", format)
+	return "/audit/export?" + values.Encode()
+}
+
+// handleAudit displays the audit log
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		s.renderError(w, "Invalid filter", err, http.StatusBadRequest)
+		return
+	}
+	q, err := filter.query(time.Local)
+	if err != nil {
+		s.renderError(w, "Invalid filter", err, http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.auditRepo.Query(r.Context(), q)
+	if err != nil {
+		s.renderError(w, "Failed to load audit log", err, http.StatusInternalServerError)
+		return
+	}
+	if len(page.Entries) == 0 && page.Page > 1 {
+		http.Redirect(w, r, filter.PageURL(page.Pages()), http.StatusSeeOther)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Page":        page,
+		"Filter":      filter,
+		"Actions":     domain.AuditActions,
+		"ObjectTypes": domain.AuditObjectTypes,
+	}
+	if page.Page > 1 {
+		data["PrevURL"] = filter.PageURL(page.Page - 1)
+	}
+	if page.Page < page.Pages() {
+		data["NextURL"] = filter.PageURL(page.Page + 1)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "audit-page", data); err != nil {
+		s.renderError(w, "Failed to render template", err, http.StatusInternalServerError)
+	}
+}
+
+// handleAuditExport downloads the filtered audit log as CSV or JSON
+// GET /audit/export?format=csv|json takes the filters of the audit page.
+func (s *Server) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		s.renderError(w, "Export format must be csv or json", nil, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		s.renderError(w, "Invalid filter", err, http.StatusBadRequest)
+		return
+	}
+	q, err := filter.query(time.Local)
+	if err != nil {
+		s.renderError(w, "Invalid filter", err, http.StatusBadRequest)
+		return
+	}
+
+	// Entries recorded while the export pages through the log would shift the
+	// pages, so the export ends at the time it started
+	if now := time.Now(); q.Until.IsZero() || q.Until.After(now) {
+		q.Until = now
+	}
+	q.PageSize = repository.MaxPageSize
+
+	entries := []*domain.AuditEntry{}
+	for q.Page = 1; ; q.Page++ {
+		page, err := s.auditRepo.Query(r.Context(), q)
+		if err != nil {
+			s.renderError(w, "Failed to load audit log", err, http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, page.Entries...)
+		if q.Page >= page.Pages() {
+			break
+		}
+	}
+
+	filename := "audit-" + time.Now().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "actor", "source_ip", "action", "object_type", "object_id", "object_name", "before", "after"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.CreatedAt.UTC().Format(time.RFC3339), e.Actor, e.SourceIP, e.Action,
+			e.ObjectType, e.ObjectID, e.ObjectName, e.Before, e.After,
+		})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+)
+
+// Dashboard tabs
+const (
+	viewMonitor  = ""
+	viewWatch    = "watch"
+	viewArchived = "archived"
+)
+
+// dashboardBuckets labels the status buckets offered by the dashboard filter
+var dashboardBuckets = []struct{ Value, Label string }{
+	{repository.BucketExpired, "Expired"},
+	{repository.BucketWeek, "Expires within 7 days"},
+	{repository.BucketMonth, "Expires in 7 to 30 days"},
+	{repository.BucketOK, "Expires in 30+ days"},
+	{repository.BucketFailing, "Lookup failing"},
+}
+
+// dashboardFilter is the tab, search, filters, sort order and page of the
+// dashboard, as kept in its URL so every view can be linked to
+type dashboardFilter struct {
+	View      string
+	Search    string
+	Bucket    string
+	Tag       string
+	Registrar string
+	Owner     string
+	Sort      string
+	Desc      bool
+	Page      int
+}
+
+// parseDashboardFilter reads a dashboard filter from the query string
+func parseDashboardFilter(v url.Values) dashboardFilter {
+	f := dashboardFilter{
+		View:      v.Get("view"),
+		Search:    v.Get("q"),
+		Bucket:    v.Get("status"),
+		Tag:       domain.NormalizeTag(v.Get("tag")),
+		Registrar: v.Get("registrar"),
+		Owner:     v.Get("owner"),
+		Sort:      v.Get("sort"),
+		Desc:      v.Get("order") == "desc",
+	}
+	if f.View != viewWatch && f.View != viewArchived {
+		f.View = viewMonitor
+	}
+	f.Page, _ = strconv.Atoi(v.Get("page"))
+	return f
+}
+
+// query returns the repository query listing the filter's page
+func (f dashboardFilter) query() repository.DomainQuery {
+	q := repository.DomainQuery{
+		Search:    f.Search,
+		Bucket:    f.Bucket,
+		Tag:       f.Tag,
+		Registrar: f.Registrar,
+		Owner:     f.Owner,
+		Sort:      f.Sort,
+		Desc:      f.Desc,
+		Page:      f.Page,
+	}
+	switch f.View {
+	case viewWatch:
+		q.Mode = domain.ModeWatch
+	case viewArchived:
+		q.Archived = true
+	default:
+		q.Mode = domain.ModeMonitor
+	}
+	return q
+}
+
+// Filtered reports whether the search or any filter narrows the list
+func (f dashboardFilter) Filtered() bool {
+	return f.Search != "" || f.Bucket != "" || f.Tag != "" || f.Registrar != "" || f.Owner != ""
+}
+
+// URL returns the dashboard link of the filter, leaving out what is at its default
+func (f dashboardFilter) URL() string {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("view", f.View)
+	set("q", f.Search)
+	set("status", f.Bucket)
+	set("tag", f.Tag)
+	set("registrar", f.Registrar)
+	set("owner", f.Owner)
+	set("sort", f.Sort)
+	if f.Desc {
+		v.Set("order", "desc")
+	}
+	if f.Page > 1 {
+		v.Set("page", strconv.Itoa(f.Page))
+	}
+	if len(v) == 0 {
+		return "/"
+	}
+	return "/?" + v.Encode()
+}
+
+// PageURL links to another page of the same list
+func (f dashboardFilter) PageURL(page int) string {
+	f.Page = page
+	return f.URL()
+}
+
+// SortURL links to the first page sorted by a column; sorting by the current
+// column again reverses the order
+func (f dashboardFilter) SortURL(sort string) string {
+	f.Desc = f.sortedBy(sort) && !f.Desc
+	f.Sort = sort
+	f.Page = 0
+	return f.URL()
+}
+
+// SortMark returns the arrow shown next to the column the list is sorted by
+func (f dashboardFilter) SortMark(sort string) string {
+	switch {
+	case !f.sortedBy(sort):
+		return ""
+	case f.Desc:
+		return " ▼"
+	default:
+		return " ▲"
+	}
+}
+
+// sortedBy reports whether the list is sorted by a column
+func (f dashboardFilter) sortedBy(sort string) bool {
+	return f.Sort == sort || (f.Sort == "" && sort == repository.SortExpiration)
+}
+
+// ViewURL links to another tab with the same search and filters
+func (f dashboardFilter) ViewURL(view string) string {
+	f.View = view
+	f.Sort = ""
+	f.Desc = false
+	f.Page = 0
+	return f.URL()
+}
+
+// ClearURL links to the tab without search or filters
+func (f dashboardFilter) ClearURL() string {
+	return dashboardFilter{View: f.View}.URL()
+}
@@ -1,37 +1,101 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/domain-expiration-monitor/dem/internal/alert"
 	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/scheduler"
+	"github.com/domain-expiration-monitor/dem/internal/whois"
 )
 
-// handleHealth returns the health status
+// handleHealth returns the health status and whether this replica is the scheduler leader
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	role := "standby"
+	if s.scheduler.IsLeader() {
+		role = "leader"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "ok",
+		"role":     role,
+		"instance": s.scheduler.InstanceID(),
+	})
 }
 
-// handleDashboard displays the main dashboard
+// handleDashboard displays one page of a dashboard tab, searched, filtered and
+// sorted as its URL asks
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
 	}
 
-	domains, err := s.domainRepo.GetAll()
+	filter := parseDashboardFilter(r.URL.Query())
+	query, err := filter.query().Normalize()
+	if err != nil {
+		s.renderError(w, "Invalid dashboard filter", err, http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.domainRepo.Query(r.Context(), query)
 	if err != nil {
 		s.renderError(w, "Failed to load domains", err, http.StatusInternalServerError)
 		return
 	}
 
+	// A page past the end, as left by archiving the last domains on it, shows the last page
+	if len(page.Domains) == 0 && page.Page > 1 {
+		http.Redirect(w, r, filter.PageURL(page.Pages()), http.StatusSeeOther)
+		return
+	}
+
+	// The filters offer the values of any domain, so a filter can always be undone
+	tags, err := s.domainRepo.Tags(r.Context())
+	if err != nil {
+		s.renderError(w, "Failed to load tags", err, http.StatusInternalServerError)
+		return
+	}
+	owners, err := s.domainRepo.Owners(r.Context())
+	if err != nil {
+		s.renderError(w, "Failed to load owners", err, http.StatusInternalServerError)
+		return
+	}
+	registrars, err := s.domainRepo.Registrars(r.Context())
+	if err != nil {
+		s.renderError(w, "Failed to load registrars", err, http.StatusInternalServerError)
+		return
+	}
+
+	var prevURL, nextURL string
+	if page.Page > 1 {
+		prevURL = filter.PageURL(page.Page - 1)
+	}
+	if page.Page < page.Pages() {
+		nextURL = filter.PageURL(page.Page + 1)
+	}
+
 	data := map[string]interface{}{
-		"Domains": domains,
-		"Now":     time.Now(),
+		"Page":       page,
+		"Filter":     filter,
+		"Buckets":    dashboardBuckets,
+		"Tags":       tags,
+		"Owners":     owners,
+		"Registrars": registrars,
+		"PrevURL":    prevURL,
+		"NextURL":    nextURL,
+		"Now":        time.Now(),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -40,29 +104,98 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleDomainDetail displays details for a specific domain
+// handleDomainDetail routes requests under /domains/{id}
 func (s *Server) handleDomainDetail(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/domains/")
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/domains/"), "/")
 	if id == "" {
 		http.NotFound(w, r)
 		return
 	}
 
-	d, err := s.domainRepo.GetByID(id)
+	switch action {
+	case "":
+		s.renderDomainDetail(w, r, id)
+	case "http-checks":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleAddHTTPCheck(w, r, id)
+	case "required-statuses":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleRequiredStatuses(w, r, id)
+	case "state":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleDomainState(w, r, id)
+	case "metadata":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleDomainMetadata(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// httpCheckView pairs an HTTP check with its recent results for rendering
+type httpCheckView struct {
+	Check   *domain.HTTPCheck
+	Results []*domain.HTTPCheckResult
+}
+
+// renderDomainDetail displays details for a specific domain
+func (s *Server) renderDomainDetail(w http.ResponseWriter, r *http.Request, id string) {
+	d, err := s.domainRepo.GetByID(r.Context(), id)
 	if err != nil {
 		s.renderError(w, "Domain not found", err, http.StatusNotFound)
 		return
 	}
 
-	alerts, err := s.alertRepo.GetByDomainID(id)
+	alerts, err := s.alertRepo.GetByDomainID(r.Context(), id)
 	if err != nil {
 		alerts = []*domain.Alert{}
 	}
 
+	checks, err := s.httpCheckRepo.GetByDomainID(r.Context(), id)
+	if err != nil {
+		checks = []*domain.HTTPCheck{}
+	}
+
+	checkViews := make([]httpCheckView, 0, len(checks))
+	for _, c := range checks {
+		results, err := s.httpCheckRepo.GetResults(r.Context(), c.ID, 10)
+		if err != nil {
+			results = []*domain.HTTPCheckResult{}
+		}
+		checkViews = append(checkViews, httpCheckView{Check: c, Results: results})
+	}
+
+	lookalikes, err := s.lookalikeRepo.GetRegisteredByDomainID(r.Context(), id)
+	if err != nil {
+		lookalikes = []*domain.Lookalike{}
+	}
+
+	candidateCount, err := s.lookalikeRepo.CountByDomainID(r.Context(), id)
+	if err != nil {
+		candidateCount = 0
+	}
+
 	data := map[string]interface{}{
-		"Domain": d,
-		"Alerts": alerts,
-		"Now":    time.Now(),
+		"Domain":              d,
+		"Alerts":              alerts,
+		"HTTPChecks":          checkViews,
+		"Lookalikes":          lookalikes,
+		"LookalikeCandidates": candidateCount,
+		"LockStatuses":        domain.LockStatuses,
+		"Criticalities":       domain.Criticalities,
+		"Now":                 time.Now(),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -96,8 +229,38 @@ func (s *Server) handleAddDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = domain.ModeMonitor
+	}
+	if mode != domain.ModeMonitor && mode != domain.ModeWatch {
+		s.renderError(w, fmt.Sprintf("Invalid mode: %s", mode), nil, http.StatusBadRequest)
+		return
+	}
+
+	metadata := domain.Metadata{
+		Owner:       strings.TrimSpace(r.FormValue("owner")),
+		Criticality: domain.CriticalityNormal,
+		Tags:        domain.ParseTags(r.FormValue("tags")),
+	}
+	if err := metadata.Validate(); err != nil {
+		s.renderError(w, "Invalid domain details", err, http.StatusBadRequest)
+		return
+	}
+
+	// The check policy schedules the next check after the immediate one
+	config, err := s.configRepo.Get(r.Context())
+	if err != nil {
+		s.renderError(w, "Failed to load configuration", err, http.StatusInternalServerError)
+		return
+	}
+
 	// Perform immediate WHOIS query
-	info, err := s.whoisSvc.QueryDomain(domainName)
+	info, err := s.whoisSvc.QueryDomain(r.Context(), domainName)
+	if errors.Is(err, whois.ErrDomainNotRegistered) {
+		s.renderError(w, fmt.Sprintf("%s is not registered and can be registered now", domainName), nil, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		s.renderError(w, "Failed to query domain", err, http.StatusBadRequest)
 		return
@@ -105,22 +268,26 @@ func (s *Server) handleAddDomain(w http.ResponseWriter, r *http.Request) {
 
 	// Create domain
 	d := &domain.Domain{
-		Name:           domainName,
-		ExpirationDate: info.ExpirationDate,
-		Nameservers:    domain.Strings(info.Nameservers),
-		Registrant:     info.Registrant,
-		Registrar:      info.Registrar,
-		LastChecked:    time.Now(),
-		NextCheck:      time.Now().Add(24 * time.Hour),
+		Name:              domainName,
+		ExpirationDate:    info.ExpirationDate,
+		Nameservers:       domain.Strings(info.Nameservers),
+		Registrant:        info.Registrant,
+		Registrar:         info.Registrar,
+		LastChecked:       time.Now(),
+		Mode:              mode,
+		RegistrationState: domain.RegistrationStateFromStatuses(info.Statuses),
+		Statuses:          domain.NormalizeStatuses(info.Statuses),
+		Metadata:          metadata,
 	}
+	d.NextCheck = s.scheduler.NextCheck(d, config)
 
-	if err := s.domainRepo.Create(d); err != nil {
+	if err := s.domainRepo.Create(r.Context(), d); err != nil {
 		s.renderError(w, "Failed to add domain", err, http.StatusInternalServerError)
 		return
 	}
+	s.auditDomain(r, domain.AuditDomainCreate, d, nil, d)
 
-	// Schedule monitoring
-	s.scheduler.ScheduleDomain(d)
+	// The scheduler picks the domain up from the database once its next check is due
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -133,16 +300,435 @@ func (s *Server) handleDeleteDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Unschedule monitoring
-	s.scheduler.UnscheduleDomain(id)
+	d, err := s.domainRepo.GetByID(r.Context(), id)
+	if err != nil {
+		s.renderError(w, "Domain not found", err, http.StatusNotFound)
+		return
+	}
+	// Archived domains and their history are kept until retention purges them
+	if d.IsArchived() {
+		s.renderError(w, "Archived domains are deleted once the retention period has passed; restore the domain to delete it", nil, http.StatusConflict)
+		return
+	}
 
 	// Delete domain
-	if err := s.domainRepo.Delete(id); err != nil {
+	if err := s.domainRepo.Delete(r.Context(), id); err != nil {
 		s.renderError(w, "Failed to delete domain", err, http.StatusInternalServerError)
 		return
 	}
+	s.auditDomain(r, domain.AuditDomainDelete, d, d, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAddHTTPCheck attaches a new HTTP check to a domain
+func (s *Server) handleAddHTTPCheck(w http.ResponseWriter, r *http.Request, domainID string) {
+	if err := r.ParseForm(); err != nil {
+		s.renderError(w, "Invalid form data", err, http.StatusBadRequest)
+		return
+	}
+
+	d, ok := s.writableDomain(w, r, domainID)
+	if !ok {
+		return
+	}
+
+	checkURL := strings.TrimSpace(r.FormValue("url"))
+	parsed, err := url.Parse(checkURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		s.renderError(w, "URL must be an absolute http or https URL", err, http.StatusBadRequest)
+		return
+	}
+
+	check := &domain.HTTPCheck{
+		DomainID:         domainID,
+		URL:              checkURL,
+		ExpectedRedirect: strings.TrimSpace(r.FormValue("expected_redirect")),
+		BodyContains:     r.FormValue("body_contains"),
+		FailureThreshold: 3,
+		NextCheck:        time.Now(),
+	}
+	check.SetInterval(5 * time.Minute)
+
+	if status := r.FormValue("expected_status"); status != "" {
+		var code int
+		fmt.Sscanf(status, "%d", &code)
+		if code < 100 || code > 599 {
+			s.renderError(w, "Expected status must be a valid HTTP status code", nil, http.StatusBadRequest)
+			return
+		}
+		check.ExpectedStatus = code
+	}
+
+	if minutes := r.FormValue("interval"); minutes != "" {
+		var m int
+		fmt.Sscanf(minutes, "%d", &m)
+		if m < 1 {
+			s.renderError(w, "Check interval must be at least 1 minute", nil, http.StatusBadRequest)
+			return
+		}
+		check.SetInterval(time.Duration(m) * time.Minute)
+	}
+
+	if threshold := r.FormValue("failure_threshold"); threshold != "" {
+		var n int
+		fmt.Sscanf(threshold, "%d", &n)
+		if n < 1 {
+			s.renderError(w, "Failure threshold must be at least 1", nil, http.StatusBadRequest)
+			return
+		}
+		check.FailureThreshold = n
+	}
+
+	if err := s.httpCheckRepo.Create(r.Context(), check); err != nil {
+		s.renderError(w, "Failed to add HTTP check", err, http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, domain.AuditEntry{
+		Action:     domain.AuditHTTPCheckCreate,
+		ObjectType: domain.AuditObjectHTTPCheck,
+		ObjectID:   check.ID,
+		ObjectName: d.Name + " " + check.URL,
+	}, nil, check)
+
+	http.Redirect(w, r, "/domains/"+domainID, http.StatusSeeOther)
+}
+
+// handleRequiredStatuses replaces the set of lock statuses a domain must keep
+func (s *Server) handleRequiredStatuses(w http.ResponseWriter, r *http.Request, domainID string) {
+	if err := r.ParseForm(); err != nil {
+		s.renderError(w, "Invalid form data", err, http.StatusBadRequest)
+		return
+	}
+
+	before, ok := s.writableDomain(w, r, domainID)
+	if !ok {
+		return
+	}
+
+	locks := domain.Strings(domain.LockStatuses)
+	required := domain.NormalizeStatuses(r.Form["required_statuses"])
+	for _, status := range required {
+		if !locks.Contains(status) {
+			s.renderError(w, fmt.Sprintf("Unsupported required status: %s", status), nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.domainRepo.UpdateRequiredStatuses(r.Context(), domainID, required); err != nil {
+		s.renderError(w, "Failed to update required statuses", err, http.StatusInternalServerError)
+		return
+	}
+	s.auditDomain(r, domain.AuditDomainRequiredStatuses, before, before, s.reloadDomain(r.Context(), domainID))
+
+	http.Redirect(w, r, "/domains/"+domainID, http.StatusSeeOther)
+}
+
+// handleDomainMetadata saves the owner, criticality, notes, annual cost and tags of a domain
+func (s *Server) handleDomainMetadata(w http.ResponseWriter, r *http.Request, domainID string) {
+	if err := r.ParseForm(); err != nil {
+		s.renderError(w, "Invalid form data", err, http.StatusBadRequest)
+		return
+	}
+
+	before, ok := s.writableDomain(w, r, domainID)
+	if !ok {
+		return
+	}
+
+	cost, err := domain.ParseCost(r.FormValue("annual_cost"))
+	if err != nil {
+		s.renderError(w, "Invalid annual cost", err, http.StatusBadRequest)
+		return
+	}
+
+	m := domain.Metadata{
+		Owner:           strings.TrimSpace(r.FormValue("owner")),
+		Criticality:     r.FormValue("criticality"),
+		Notes:           strings.TrimSpace(r.FormValue("notes")),
+		AnnualCostCents: cost,
+		Tags:            domain.ParseTags(r.FormValue("tags")),
+	}
+	if err := m.Validate(); err != nil {
+		s.renderError(w, "Invalid domain details", err, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.domainRepo.UpdateMetadata(r.Context(), domainID, m); err != nil {
+		s.renderError(w, "Failed to update domain details", err, http.StatusInternalServerError)
+		return
+	}
+	s.auditDomain(r, domain.AuditDomainMetadata, before, before, s.reloadDomain(r.Context(), domainID))
+
+	http.Redirect(w, r, "/domains/"+domainID, http.StatusSeeOther)
+}
+
+// handleDomainState pauses, resumes, archives or restores a domain
+func (s *Server) handleDomainState(w http.ResponseWriter, r *http.Request, domainID string) {
+	if err := r.ParseForm(); err != nil {
+		s.renderError(w, "Invalid form data", err, http.StatusBadRequest)
+		return
+	}
+
+	state := r.FormValue("state")
+	if !domain.ValidState(state) {
+		s.renderError(w, fmt.Sprintf("Invalid state: %s", state), nil, http.StatusBadRequest)
+		return
+	}
+
+	before, err := s.domainRepo.GetByID(r.Context(), domainID)
+	if err != nil {
+		s.renderError(w, "Domain not found", err, http.StatusNotFound)
+		return
+	}
+
+	if err := s.domainRepo.SetState(r.Context(), domainID, state, time.Now()); err != nil {
+		s.renderError(w, "Failed to update domain state", err, http.StatusInternalServerError)
+		return
+	}
+	s.auditDomain(r, domain.AuditDomainState, before, before, s.reloadDomain(r.Context(), domainID))
+
+	http.Redirect(w, r, "/domains/"+domainID, http.StatusSeeOther)
+}
+
+// writableDomain loads a domain that is about to be changed
+// Archived domains are read-only; for them, and for missing domains, it writes
+// the error response and reports false.
+func (s *Server) writableDomain(w http.ResponseWriter, r *http.Request, id string) (*domain.Domain, bool) {
+	d, err := s.domainRepo.GetByID(r.Context(), id)
+	if err != nil {
+		s.renderError(w, "Domain not found", err, http.StatusNotFound)
+		return nil, false
+	}
+	if d.IsArchived() {
+		s.renderError(w, "Archived domains are read-only; restore the domain to change it", nil, http.StatusConflict)
+		return nil, false
+	}
+	return d, true
+}
+
+// handleHTTPChecks handles HTTP check management (delete)
+func (s *Server) handleHTTPChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		s.renderError(w, "HTTP check ID is required", nil, http.StatusBadRequest)
+		return
+	}
 
+	check, err := s.httpCheckRepo.GetByID(r.Context(), id)
+	if err != nil {
+		s.renderError(w, "HTTP check not found", err, http.StatusNotFound)
+		return
+	}
+	d, ok := s.writableDomain(w, r, check.DomainID)
+	if !ok {
+		return
+	}
+
+	if err := s.httpCheckRepo.Delete(r.Context(), id); err != nil {
+		s.renderError(w, "Failed to delete HTTP check", err, http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, domain.AuditEntry{
+		Action:     domain.AuditHTTPCheckDelete,
+		ObjectType: domain.AuditObjectHTTPCheck,
+		ObjectID:   check.ID,
+		ObjectName: d.Name + " " + check.URL,
+	}, check, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAlerts displays alerts whose delivery failed
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alerts, err := s.alertRepo.GetFailedAlerts(r.Context())
+	if err != nil {
+		s.renderError(w, "Failed to load alerts", err, http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Alerts": alerts,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "alerts-page", data); err != nil {
+		s.renderError(w, "Failed to render template", err, http.StatusInternalServerError)
+	}
+}
+
+// handleAPIAlerts lists alerts whose delivery failed as JSON
+func (s *Server) handleAPIAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alerts, err := s.alertRepo.GetFailedAlerts(r.Context())
+	if err != nil {
+		writeJSONError(w, "Failed to load alerts", err, http.StatusInternalServerError)
+		return
+	}
+	if alerts == nil {
+		alerts = []*domain.Alert{}
+	}
+
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+// handleAPIAlertAction routes requests under /api/alerts/{id}
+func (s *Server) handleAPIAlertAction(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/alerts/"), "/")
+	if id == "" || action != "resend" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	before, err := s.alertRepo.GetByID(r.Context(), id)
+	if err != nil {
+		writeJSONError(w, "Alert not found", nil, http.StatusNotFound)
+		return
+	}
+
+	a, err := s.alertSvc.Resend(r.Context(), id)
+	if errors.Is(err, alert.ErrAlertNotResendable) {
+		writeJSONError(w, "Alert cannot be resent", err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, "Failed to resend alert", err, http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, domain.AuditEntry{
+		Action:     domain.AuditAlertResend,
+		ObjectType: domain.AuditObjectAlert,
+		ObjectID:   a.ID,
+		ObjectName: before.DomainName,
+	}, before, a)
+
+	writeJSON(w, http.StatusOK, a)
+}
+
+// maxCheckBatch caps how many domains one bulk check may name, a full dashboard page
+const maxCheckBatch = repository.MaxPageSize
+
+// handleAPIDomainAction routes requests under /api/domains/
+// POST /api/domains/check checks the domains whose IDs are posted as {"ids": [...]}
+// and POST /api/domains/{id}/check checks one domain.
+func (s *Server) handleAPIDomainAction(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/domains/")
+	id, action, _ := strings.Cut(path, "/")
+	if path != "check" && (id == "" || action != "check") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if path != "check" {
+		s.handleCheckNow(w, r, []string{id}, false)
+		return
+	}
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, "No domains selected", nil, http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxCheckBatch {
+		writeJSONError(w, fmt.Sprintf("At most %d domains can be checked at once, got %d", maxCheckBatch, len(req.IDs)), nil, http.StatusBadRequest)
+		return
+	}
+	s.handleCheckNow(w, r, req.IDs, true)
+}
+
+// handleCheckNow checks domains right away and returns their results
+// Clients that accept text/event-stream get each result as an event once its
+// check finishes, followed by a "done" event; others get JSON after all finish.
+func (s *Server) handleCheckNow(w http.ResponseWriter, r *http.Request, ids []string, bulk bool) {
+	results := s.auditChecks(r, s.scheduler.CheckNow(r.Context(), ids...))
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamCheckResults(w, results)
+		return
+	}
+
+	all := make([]*scheduler.CheckResult, 0, len(ids))
+	for result := range results {
+		all = append(all, result)
+	}
+	if !bulk {
+		if all[0].Status == scheduler.CheckNotFound {
+			writeJSONError(w, "Domain not found", nil, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, all[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, all)
+}
+
+// streamCheckResults writes check results as server-sent events as they arrive
+func streamCheckResults(w http.ResponseWriter, results <-chan *scheduler.CheckResult) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
 	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	for result := range results {
+		data, err := json.Marshal(result)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "event: result\ndata: %s\n\n", data)
+		flush()
+	}
+	fmt.Fprint(w, "event: done\ndata: {}\n\n")
+	flush()
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error as a JSON response
+func writeJSONError(w http.ResponseWriter, message string, err error, statusCode int) {
+	if err != nil {
+		message = fmt.Sprintf("%s: %v", message, err)
+	}
+	writeJSON(w, statusCode, map[string]string{"error": message})
 }
 
 // handleConfig handles configuration management
@@ -159,14 +745,34 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 
 // handleGetConfig displays the configuration page
 func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
-	config, err := s.configRepo.Get()
+	config, err := s.configRepo.Get(r.Context())
 	if err != nil {
 		s.renderError(w, "Failed to load configuration", err, http.StatusInternalServerError)
 		return
 	}
 
+	revisions, err := s.configRepo.Revisions(r.Context(), configHistorySize+1)
+	if err != nil {
+		s.renderError(w, "Failed to load configuration history", err, http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]interface{}{
-		"Config": config,
+		"Config":        config,
+		"Webhook":       config.Redacted().GoogleChatWebhook,
+		"Revisions":     configHistory(revisions),
+		"LastRetention": s.scheduler.LastRetention(),
+		"Criticalities": domain.Criticalities,
+	}
+
+	if days := r.URL.Query().Get("preview_days"); days != "" {
+		preview, err := s.previewRetention(r.Context(), days, config)
+		if err != nil {
+			s.renderError(w, "Failed to preview retention", err, http.StatusBadRequest)
+			return
+		}
+		data["Preview"] = preview
+		data["PreviewDays"] = days
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -175,6 +781,118 @@ func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleAPIRetention reports the last retention pass and a dry run of the next
+// one, for the configured period or the one given in days
+func (s *Server) handleAPIRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	config, err := s.configRepo.Get(r.Context())
+	if err != nil {
+		writeJSONError(w, "Failed to load configuration", err, http.StatusInternalServerError)
+		return
+	}
+
+	preview, err := s.previewRetention(r.Context(), r.URL.Query().Get("days"), config)
+	if err != nil {
+		writeJSONError(w, "Failed to preview retention", err, http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"last_run": s.scheduler.LastRetention(),
+		"preview":  preview,
+	})
+}
+
+// previewRetention counts what retention would delete for a period in days,
+// or for the configured period when days is empty
+func (s *Server) previewRetention(ctx context.Context, days string, config *domain.Config) (*scheduler.RetentionReport, error) {
+	period := config.GetRetentionPeriod()
+	if days != "" {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err != nil || n < 1 {
+			return nil, fmt.Errorf("retention period must be at least 1 day, got %q", days)
+		}
+		period = time.Duration(n) * 24 * time.Hour
+	}
+	return s.scheduler.PreviewRetention(ctx, period)
+}
+
+// configHistorySize is how many configuration revisions the configuration page lists
+const configHistorySize = 20
+
+// configRevisionView is a configuration revision with its changes to the revision before
+type configRevisionView struct {
+	*domain.ConfigRevision
+	Changes []domain.ConfigChange
+	Current bool
+}
+
+// configHistory pairs revisions, newest first, with what each changed; the last
+// one is only compared with, unless it is the first revision
+func configHistory(revisions []*domain.ConfigRevision) []configRevisionView {
+	views := make([]configRevisionView, 0, len(revisions))
+	for i, rev := range revisions {
+		var previous *domain.Config
+		if i+1 < len(revisions) {
+			previous = revisions[i+1].Config()
+		} else if rev.Revision > 1 {
+			break
+		}
+		views = append(views, configRevisionView{
+			ConfigRevision: rev,
+			Changes:        domain.DiffConfigs(previous, rev.Config()),
+			Current:        i == 0,
+		})
+	}
+	return views
+}
+
+// handleConfigRollback restores the configuration of an earlier revision
+func (s *Server) handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.renderError(w, "Invalid form data", err, http.StatusBadRequest)
+		return
+	}
+
+	revision, err := strconv.Atoi(r.FormValue("revision"))
+	if err != nil || revision < 1 {
+		s.renderError(w, "A configuration revision is required", nil, http.StatusBadRequest)
+		return
+	}
+	if _, err := s.configRepo.GetRevision(r.Context(), revision); err != nil {
+		s.renderError(w, "Configuration revision not found", err, http.StatusNotFound)
+		return
+	}
+
+	before, err := s.configRepo.Get(r.Context())
+	if err != nil {
+		s.renderError(w, "Failed to load configuration", err, http.StatusInternalServerError)
+		return
+	}
+
+	config, err := s.configRepo.Rollback(r.Context(), revision)
+	if err != nil {
+		s.renderError(w, "Failed to restore configuration", err, http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, domain.AuditEntry{
+		Action:     domain.AuditConfigRollback,
+		ObjectType: domain.AuditObjectConfig,
+		ObjectID:   strconv.Itoa(config.ID),
+		ObjectName: "configuration",
+	}, before.Redacted(), config.Redacted())
+
+	http.Redirect(w, r, "/config", http.StatusSeeOther)
+}
+
 // handleUpdateConfig updates the configuration
 func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
@@ -182,11 +900,12 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	config, err := s.configRepo.Get()
+	config, err := s.configRepo.Get(r.Context())
 	if err != nil {
 		s.renderError(w, "Failed to load configuration", err, http.StatusInternalServerError)
 		return
 	}
+	before := config.Redacted()
 
 	// Parse and validate monitoring interval
 	intervalHours := r.FormValue("monitoring_interval")
@@ -200,13 +919,35 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		config.SetMonitoringInterval(time.Duration(hours) * time.Hour)
 	}
 
-	// Parse webhook URL
-	webhook := r.FormValue("webhook_url")
-	if webhook != "" && !strings.HasPrefix(webhook, "https://") {
-		s.renderError(w, "Webhook URL must use HTTPS", nil, http.StatusBadRequest)
-		return
+	// Parse check schedule
+	if policyStr := strings.TrimSpace(r.FormValue("check_policy")); policyStr != "" {
+		policy, err := domain.ParseCheckPolicy(policyStr)
+		if err != nil {
+			s.renderError(w, "Invalid check schedule", err, http.StatusBadRequest)
+			return
+		}
+		config.CheckPolicy = policy
+	}
+
+	// Parse webhook URL; the page only shows it redacted, so an empty field keeps it
+	if r.FormValue("remove_webhook") != "" {
+		config.GoogleChatWebhook = ""
+	} else if webhook := strings.TrimSpace(r.FormValue("webhook_url")); webhook != "" {
+		if !strings.HasPrefix(webhook, "https://") {
+			s.renderError(w, "Webhook URL must use HTTPS", nil, http.StatusBadRequest)
+			return
+		}
+		config.GoogleChatWebhook = webhook
+	}
+
+	// Parse the least criticality of the domains that alert; empty alerts for all
+	if criticality, ok := r.Form["alert_criticality"]; ok {
+		if criticality[0] != "" && !domain.ValidCriticality(criticality[0]) {
+			s.renderError(w, "Invalid alert criticality", nil, http.StatusBadRequest)
+			return
+		}
+		config.AlertCriticality = criticality[0]
 	}
-	config.GoogleChatWebhook = webhook
 
 	// Parse retention period
 	retentionDays := r.FormValue("retention_period")
@@ -251,20 +992,28 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update configuration
-	if err := s.configRepo.Update(config); err != nil {
+	if err := s.configRepo.Update(r.Context(), config); err != nil {
 		s.renderError(w, "Failed to update configuration", err, http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, domain.AuditEntry{
+		Action:     domain.AuditConfigUpdate,
+		ObjectType: domain.AuditObjectConfig,
+		ObjectID:   strconv.Itoa(config.ID),
+		ObjectName: "configuration",
+	}, before, config.Redacted())
 
 	http.Redirect(w, r, "/config", http.StatusSeeOther)
 }
 
 // renderError renders an error page
+// The message is escaped, as messages and errors may echo request input.
 func (s *Server) renderError(w http.ResponseWriter, message string, err error, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(statusCode)
 	errorMsg := message
 	if err != nil {
 		errorMsg = fmt.Sprintf("%s: %v", message, err)
 	}
-	fmt.Fprintf(w, "<html><body><h1>Error</h1><p>%s</p></body></html>", errorMsg)
+	fmt.Fprintf(w, "<html><body><h1>Error</h1><p>%s</p></body></html>", html.EscapeString(errorMsg))
 }
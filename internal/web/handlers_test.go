@@ -0,0 +1,302 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domain-expiration-monitor/dem/internal/alert"
+	"github.com/domain-expiration-monitor/dem/internal/domain"
+	"github.com/domain-expiration-monitor/dem/internal/httpcheck"
+	"github.com/domain-expiration-monitor/dem/internal/repository"
+	"github.com/domain-expiration-monitor/dem/internal/repository/memory"
+	"github.com/domain-expiration-monitor/dem/internal/scheduler"
+	"github.com/domain-expiration-monitor/dem/internal/whois"
+)
+
+// newTestServer creates a server on an empty in-memory store
+// Its scheduler is not started, so it stands by and queues manual checks.
+func newTestServer(t *testing.T) (*Server, repository.Stores) {
+	t.Helper()
+
+	stores := memory.NewStore().Stores()
+	whoisSvc := whois.NewService()
+	alertSvc := alert.NewService(stores.Alerts, stores.Config)
+	sched := scheduler.NewScheduler(
+		stores.Domains,
+		stores.Config,
+		stores.Alerts,
+		stores.HTTPChecks,
+		stores.Lookalikes,
+		stores.Leader,
+		whoisSvc,
+		alertSvc,
+		httpcheck.NewService(),
+	)
+	s, err := NewServer(stores.Domains, stores.Config, stores.Alerts, stores.HTTPChecks, stores.Lookalikes, stores.Audit, whoisSvc, alertSvc, sched)
+	if err != nil {
+		t.Fatalf("NewServer() unexpected error: %v", err)
+	}
+	return s, stores
+}
+
+// createTestDomain stores a monitored domain in the given state
+func createTestDomain(t *testing.T, stores repository.Stores, name, state string) *domain.Domain {
+	t.Helper()
+
+	now := time.Now()
+	d := &domain.Domain{Name: name, ExpirationDate: now.Add(365 * 24 * time.Hour), LastChecked: now, NextCheck: now.Add(24 * time.Hour)}
+	if err := stores.Domains.Create(context.Background(), d); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+	if state != domain.StateActive {
+		if err := stores.Domains.SetState(context.Background(), d.ID, state, now); err != nil {
+			t.Fatalf("Failed to set domain state: %v", err)
+		}
+	}
+	return d
+}
+
+// serve sends a request to the server and returns the response
+func serve(s *Server, method, target string, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// postForm sends form values to the server
+func postForm(s *Server, target string, form url.Values) *httptest.ResponseRecorder {
+	return serve(s, http.MethodPost, target, form.Encode(), http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+}
+
+// Test that bulk checks must name between one and maxCheckBatch domains
+func TestHandleAPIDomainAction_BulkLimit(t *testing.T) {
+	s, stores := newTestServer(t)
+	d := createTestDomain(t, stores, "example.com", domain.StateActive)
+
+	ids := func(n int) string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = d.ID
+		}
+		body, _ := json.Marshal(map[string][]string{"ids": list})
+		return string(body)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"invalid JSON", "{", http.StatusBadRequest},
+		{"no domains", ids(0), http.StatusBadRequest},
+		{"one domain", ids(1), http.StatusOK},
+		{"full batch", ids(maxCheckBatch), http.StatusOK},
+		{"too many domains", ids(maxCheckBatch + 1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(s, http.MethodPost, "/api/domains/check", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("POST /api/domains/check status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+// Test that check-now streams one result event per domain followed by "done",
+// and answers other clients with JSON
+func TestHandleCheckNow_Stream(t *testing.T) {
+	s, stores := newTestServer(t)
+	d := createTestDomain(t, stores, "example.com", domain.StateActive)
+
+	w := serve(s, http.MethodPost, "/api/domains/check", fmt.Sprintf(`{"ids": [%q, "missing"]}`, d.ID),
+		http.Header{"Accept": {"text/event-stream"}})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("streamed check status %d content type %q, want 200 text/event-stream", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	statuses := map[string]scheduler.CheckStatus{}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	for _, event := range events[:len(events)-1] {
+		data, ok := strings.CutPrefix(event, "event: result\ndata: ")
+		if !ok {
+			t.Fatalf("unexpected event %q", event)
+		}
+		var result scheduler.CheckResult
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			t.Fatalf("Failed to decode result %q: %v", data, err)
+		}
+		statuses[result.DomainID] = result.Status
+	}
+	if last := events[len(events)-1]; last != "event: done\ndata: {}" {
+		t.Errorf("last event = %q, want done", last)
+	}
+	if len(statuses) != 2 || statuses[d.ID] != scheduler.CheckQueued || statuses["missing"] != scheduler.CheckNotFound {
+		t.Errorf("streamed results = %v, want %s for the domain and %s for the missing one", statuses, scheduler.CheckQueued, scheduler.CheckNotFound)
+	}
+
+	w = serve(s, http.MethodPost, "/api/domains/"+d.ID+"/check", "", nil)
+	var result scheduler.CheckResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); w.Code != http.StatusOK || err != nil || result.Status != scheduler.CheckQueued {
+		t.Errorf("POST /api/domains/{id}/check = %d %s, want 200 with a %s result", w.Code, w.Body, scheduler.CheckQueued)
+	}
+	if w := serve(s, http.MethodPost, "/api/domains/missing/check", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("check of a missing domain status = %d, want 404", w.Code)
+	}
+}
+
+// Test that archived domains cannot be changed until they are restored
+func TestArchivedDomainsAreReadOnly(t *testing.T) {
+	s, stores := newTestServer(t)
+	d := createTestDomain(t, stores, "archived.com", domain.StateArchived)
+
+	tests := []struct {
+		action string
+		form   url.Values
+	}{
+		{"metadata", url.Values{"owner": {"web"}, "criticality": {domain.CriticalityHigh}}},
+		{"required-statuses", url.Values{"required_statuses": {domain.StatusClientTransferProhibited}}},
+		{"http-checks", url.Values{"url": {"https://archived.com"}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.action, func(t *testing.T) {
+			w := postForm(s, "/domains/"+d.ID+"/"+tt.action, tt.form)
+			if w.Code != http.StatusConflict {
+				t.Errorf("POST %s on an archived domain status = %d, want 409", tt.action, w.Code)
+			}
+		})
+	}
+
+	got, err := stores.Domains.GetByID(context.Background(), d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if got.Metadata.Owner != "" || len(got.RequiredStatuses) != 0 {
+		t.Errorf("archived domain changed: owner %q required statuses %v", got.Metadata.Owner, got.RequiredStatuses)
+	}
+
+	// It is left to retention rather than deleted
+	if w := serve(s, http.MethodDelete, "/domains?id="+d.ID, "", nil); w.Code != http.StatusConflict {
+		t.Errorf("DELETE of an archived domain status = %d, want 409", w.Code)
+	}
+	if _, err := stores.Domains.GetByID(context.Background(), d.ID); err != nil {
+		t.Fatalf("archived domain deleted: %v", err)
+	}
+
+	// Restoring it makes it writable again
+	if w := postForm(s, "/domains/"+d.ID+"/state", url.Values{"state": {domain.StateActive}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("restoring an archived domain status = %d, want 303", w.Code)
+	}
+	if w := postForm(s, "/domains/"+d.ID+"/metadata", url.Values{"owner": {"web"}, "criticality": {domain.CriticalityHigh}}); w.Code != http.StatusSeeOther {
+		t.Errorf("updating a restored domain status = %d, want 303: %s", w.Code, w.Body)
+	}
+	if w := serve(s, http.MethodDelete, "/domains?id="+d.ID, "", nil); w.Code != http.StatusOK {
+		t.Errorf("DELETE of a restored domain status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+// Test that invalid parameters are rejected without changing anything
+func TestInvalidParameters(t *testing.T) {
+	s, stores := newTestServer(t)
+	d := createTestDomain(t, stores, "example.com", domain.StateActive)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		form       url.Values
+		wantStatus int
+	}{
+		{"unknown state", http.MethodPost, "/domains/" + d.ID + "/state", url.Values{"state": {"deleted"}}, http.StatusBadRequest},
+		{"state of a missing domain", http.MethodPost, "/domains/missing/state", url.Values{"state": {domain.StatePaused}}, http.StatusNotFound},
+		{"unknown mode", http.MethodPost, "/domains", url.Values{"domain": {"new.com"}, "mode": {"spy"}}, http.StatusBadRequest},
+		{"missing domain name", http.MethodPost, "/domains", url.Values{}, http.StatusBadRequest},
+		{"unsupported required status", http.MethodPost, "/domains/" + d.ID + "/required-statuses", url.Values{"required_statuses": {"ok"}}, http.StatusBadRequest},
+		{"invalid annual cost", http.MethodPost, "/domains/" + d.ID + "/metadata", url.Values{"annual_cost": {"lots"}}, http.StatusBadRequest},
+		{"invalid criticality", http.MethodPost, "/domains/" + d.ID + "/metadata", url.Values{"criticality": {"urgent"}}, http.StatusBadRequest},
+		{"non-http check URL", http.MethodPost, "/domains/" + d.ID + "/http-checks", url.Values{"url": {"ftp://example.com"}}, http.StatusBadRequest},
+		{"unknown domain action", http.MethodPost, "/domains/" + d.ID + "/rename", nil, http.StatusNotFound},
+		{"check with GET", http.MethodGet, "/api/domains/" + d.ID + "/check", nil, http.StatusMethodNotAllowed},
+		{"unknown API action", http.MethodPost, "/api/domains/" + d.ID + "/delete", nil, http.StatusNotFound},
+		{"unknown audit action", http.MethodGet, "/audit?action=domain.explode", nil, http.StatusBadRequest},
+		{"invalid audit page", http.MethodGet, "/audit?page=0", nil, http.StatusBadRequest},
+		{"unknown export format", http.MethodGet, "/audit/export?format=xml", nil, http.StatusBadRequest},
+		{"unknown alert criticality", http.MethodPost, "/config", url.Values{"alert_criticality": {"urgent"}}, http.StatusBadRequest},
+		{"unknown dashboard sort", http.MethodGet, "/?sort=nonsense", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var w *httptest.ResponseRecorder
+			if tt.method == http.MethodPost {
+				w = postForm(s, tt.target, tt.form)
+			} else {
+				w = serve(s, tt.method, tt.target, "", nil)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.target, w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	got, err := stores.Domains.GetByID(context.Background(), d.ID)
+	if err != nil {
+		t.Fatalf("Failed to get domain: %v", err)
+	}
+	if got.State != domain.StateActive || got.Metadata.Criticality != d.Metadata.Criticality || len(got.RequiredStatuses) != 0 {
+		t.Errorf("domain changed by invalid requests: state %q criticality %q required statuses %v", got.State, got.Metadata.Criticality, got.RequiredStatuses)
+	}
+	if all, _ := stores.Domains.GetAll(context.Background()); len(all) != 1 {
+		t.Errorf("%d domains stored, want the invalid additions rejected", len(all))
+	}
+}
+
+// Test that error pages escape the request input they echo
+func TestRenderError_EscapesInput(t *testing.T) {
+	s, stores := newTestServer(t)
+	d := createTestDomain(t, stores, "example.com", domain.StateActive)
+	const script = "<script>alert(1)</script>"
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+	}{
+		{"mode", http.MethodPost, "/domains", url.Values{"domain": {"new.com"}, "mode": {script}}},
+		{"required status", http.MethodPost, "/domains/" + d.ID + "/required-statuses", url.Values{"required_statuses": {script}}},
+		{"state", http.MethodPost, "/domains/" + d.ID + "/state", url.Values{"state": {script}}},
+		{"audit filter", http.MethodGet, "/audit?action=" + url.QueryEscape(script), nil},
+		{"export format", http.MethodGet, "/audit/export?format=" + url.QueryEscape(script), nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var w *httptest.ResponseRecorder
+			if tt.method == http.MethodPost {
+				w = postForm(s, tt.target, tt.form)
+			} else {
+				w = serve(s, tt.method, tt.target, "", nil)
+			}
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%s %s status = %d, want 400", tt.method, tt.target, w.Code)
+			}
+			if body := w.Body.String(); strings.Contains(body, "<script>") {
+				t.Errorf("error page echoes the %s unescaped: %s", tt.name, body)
+			}
+		})
+	}
+}
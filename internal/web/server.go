@@ -6,7 +6,9 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/domain-expiration-monitor/dem/internal/alert"
 	"github.com/domain-expiration-monitor/dem/internal/repository"
 	"github.com/domain-expiration-monitor/dem/internal/scheduler"
 	"github.com/domain-expiration-monitor/dem/internal/whois"
@@ -17,21 +19,33 @@ var templatesFS embed.FS
 
 // Server represents the HTTP server
 type Server struct {
-	domainRepo  *repository.DomainRepository
-	configRepo  *repository.ConfigRepository
-	alertRepo   *repository.AlertRepository
-	whoisSvc    *whois.Service
-	scheduler   *scheduler.Scheduler
-	templates   *template.Template
-	mux         *http.ServeMux
+	domainRepo    repository.DomainStore
+	configRepo    repository.ConfigStore
+	alertRepo     repository.AlertStore
+	httpCheckRepo repository.HTTPCheckStore
+	lookalikeRepo repository.LookalikeStore
+	auditRepo     repository.AuditStore
+	whoisSvc      *whois.Service
+	alertSvc      *alert.Service
+	scheduler     *scheduler.Scheduler
+	templates     *template.Template
+	mux           *http.ServeMux
+
+	// actorHeader names the request header identifying the user; empty when
+	// requests are anonymous
+	actorHeader string
 }
 
 // NewServer creates a new HTTP server
 func NewServer(
-	domainRepo *repository.DomainRepository,
-	configRepo *repository.ConfigRepository,
-	alertRepo *repository.AlertRepository,
+	domainRepo repository.DomainStore,
+	configRepo repository.ConfigStore,
+	alertRepo repository.AlertStore,
+	httpCheckRepo repository.HTTPCheckStore,
+	lookalikeRepo repository.LookalikeStore,
+	auditRepo repository.AuditStore,
 	whoisSvc *whois.Service,
+	alertSvc *alert.Service,
 	sched *scheduler.Scheduler,
 ) (*Server, error) {
 	// Create template with custom functions
@@ -42,6 +56,7 @@ func NewServer(
 			}
 			return a / b
 		},
+		"join": strings.Join,
 	}
 	
 	tmpl, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/*.html")
@@ -50,13 +65,17 @@ func NewServer(
 	}
 
 	s := &Server{
-		domainRepo: domainRepo,
-		configRepo: configRepo,
-		alertRepo:  alertRepo,
-		whoisSvc:   whoisSvc,
-		scheduler:  sched,
-		templates:  tmpl,
-		mux:        http.NewServeMux(),
+		domainRepo:    domainRepo,
+		configRepo:    configRepo,
+		alertRepo:     alertRepo,
+		httpCheckRepo: httpCheckRepo,
+		lookalikeRepo: lookalikeRepo,
+		auditRepo:     auditRepo,
+		whoisSvc:      whoisSvc,
+		alertSvc:      alertSvc,
+		scheduler:     sched,
+		templates:     tmpl,
+		mux:           http.NewServeMux(),
 	}
 
 	s.setupRoutes()
@@ -70,6 +89,15 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/domains/", s.handleDomainDetail)
 	s.mux.HandleFunc("/domains", s.handleDomains)
 	s.mux.HandleFunc("/config", s.handleConfig)
+	s.mux.HandleFunc("/config/rollback", s.handleConfigRollback)
+	s.mux.HandleFunc("/http-checks", s.handleHTTPChecks)
+	s.mux.HandleFunc("/alerts", s.handleAlerts)
+	s.mux.HandleFunc("/audit", s.handleAudit)
+	s.mux.HandleFunc("/audit/export", s.handleAuditExport)
+	s.mux.HandleFunc("/api/alerts", s.handleAPIAlerts)
+	s.mux.HandleFunc("/api/alerts/", s.handleAPIAlertAction)
+	s.mux.HandleFunc("/api/domains/", s.handleAPIDomainAction)
+	s.mux.HandleFunc("/api/retention", s.handleAPIRetention)
 }
 
 // ServeHTTP implements http.Handler
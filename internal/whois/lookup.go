@@ -0,0 +1,49 @@
+package whois
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/likexian/whois"
+)
+
+// lookup sends a WHOIS query, closing its connections once ctx ends so a
+// canceled or timed out lookup does not stay blocked on a slow server
+func lookup(ctx context.Context, query string, servers ...string) (string, error) {
+	client := whois.NewClient().SetDialer(&contextDialer{ctx: ctx})
+	result, err := client.Whois(query, servers...)
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return result, err
+}
+
+// contextDialer dials WHOIS servers under a context
+type contextDialer struct {
+	ctx    context.Context
+	dialer net.Dialer
+}
+
+// Dial connects to addr; the connection is closed when the dialer's context ends
+func (d *contextDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(d.ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(d.ctx, func() { conn.Close() })
+	return &contextConn{Conn: conn, stop: stop}, nil
+}
+
+// contextConn is a connection that stops watching its context once closed
+type contextConn struct {
+	net.Conn
+	stop func() bool
+	once sync.Once
+}
+
+// Close closes the connection
+func (c *contextConn) Close() error {
+	c.once.Do(func() { c.stop() })
+	return c.Conn.Close()
+}
@@ -0,0 +1,79 @@
+package whois
+
+import (
+	"regexp"
+	"strings"
+)
+
+// notFoundPatterns match registry responses for domains that do not exist.
+// whois-parser recognises most registries on its own; these cover the ones it
+// parses as an empty record instead. Patterns are matched against single lines
+// after normalizeLine, so disclaimers that merely mention availability do not match.
+var notFoundPatterns = []*regexp.Regexp{
+	// Verisign (.com, .net), Nominet (.uk), Registro.br
+	regexp.MustCompile(`^no match for\b`),
+	// JPRS (.jp)
+	regexp.MustCompile(`^no match!!$`),
+	// DNS.PT (.pt)
+	regexp.MustCompile(`\s-\s*no match$`),
+	// DENIC (.de), DNS Belgium (.be), DOMREG (.lt), NIC.LV (.lv), EURid (.eu), NIC.IT (.it)
+	regexp.MustCompile(`^status:\s*(free|available)$`),
+	// SIDN (.nl)
+	regexp.MustCompile(`\bis free$`),
+	// Identity Digital (.io, .info), Google Registry (.app, .dev), Traficom (.fi)
+	regexp.MustCompile(`^domain not found\.?$`),
+	// CIRA (.ca)
+	regexp.MustCompile(`^not found:\s`),
+	// auDA (.au), Channel Isles (.gg)
+	regexp.MustCompile(`^not found\.?$`),
+	// GoDaddy Registry (.us, .co, .biz)
+	regexp.MustCompile(`^no data found$`),
+	// RIPE-style servers (.ru, .si, .ro, .is, .cz, .ua)
+	regexp.MustCompile(`^(error:\d+:\s*)?no entries found\b`),
+	// NIC.AT (.at), PS.KZ (.kz)
+	regexp.MustCompile(`^nothing found\b`),
+	// CentralNic and other RDAP-backed gTLD registries
+	regexp.MustCompile(`^the queried object does not exist\b`),
+	// CNNIC (.cn)
+	regexp.MustCompile(`^no matching record\.?$`),
+	// SWITCH (.ch), HKIRC (.hk)
+	regexp.MustCompile(`^the domain (name )?(is not|has not been) registered\.?$`),
+	// Nominet (.uk) follow-up line
+	regexp.MustCompile(`^this domain name has not been registered\.?$`),
+	// KISA (.kr)
+	regexp.MustCompile(`^the requested domain was not found\b`),
+	// NASK (.pl)
+	regexp.MustCompile(`^no information available about domain name\b`),
+	// Nic.ai, CoCCA (.cx, .gs)
+	regexp.MustCompile(`^domain status:\s*no object found$`),
+	// InternetNZ (.nz)
+	regexp.MustCompile(`^query_status:\s*220 available$`),
+	// NIC México (.mx)
+	regexp.MustCompile(`^object_not_found$`),
+	// Dot TK (.tk)
+	regexp.MustCompile(`\bdomain name not known\b`),
+	// Red.es (.es)
+	regexp.MustCompile(`^dominio disponible para registro$`),
+}
+
+// isNotFoundResponse reports whether a raw WHOIS response says the domain is not registered
+func isNotFoundResponse(rawResponse string) bool {
+	for _, line := range strings.Split(rawResponse, "\n") {
+		line = normalizeLine(line)
+		if line == "" {
+			continue
+		}
+		for _, pattern := range notFoundPatterns {
+			if pattern.MatchString(line) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeLine lowercases a response line, strips comment markers and collapses whitespace
+func normalizeLine(line string) string {
+	line = strings.TrimLeft(strings.TrimSpace(line), "%#*> ")
+	return strings.ToLower(strings.Join(strings.Fields(line), " "))
+}
@@ -0,0 +1,88 @@
+package whois
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that real "no match" responses from many registries are recognised
+func TestParseWHOISResponse_NotFoundCorpus(t *testing.T) {
+	service := NewService()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "notfound", "*.txt"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No not-found fixtures")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			_, err = service.ParseWHOISResponse(string(data))
+			if !errors.Is(err, ErrDomainNotRegistered) {
+				t.Errorf("ParseWHOISResponse() error = %v, want ErrDomainNotRegistered", err)
+			}
+		})
+	}
+}
+
+// Test that registered domains are never reported as unregistered, even when the
+// registry disclaimer talks about availability
+func TestParseWHOISResponse_RegisteredCorpus(t *testing.T) {
+	service := NewService()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "registered", "*.txt"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No registered fixtures")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			_, err = service.ParseWHOISResponse(string(data))
+			if errors.Is(err, ErrDomainNotRegistered) {
+				t.Errorf("ParseWHOISResponse() reported a registered domain as not registered")
+			}
+		})
+	}
+}
+
+// Test that unrelated failures are not mistaken for a missing domain
+func TestIsNotFoundResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     bool
+	}{
+		{name: "verisign no match", response: "No match for \"EXAMPLE.COM\".", want: true},
+		{name: "status free", response: "Domain: example.de\nStatus: free", want: true},
+		{name: "registered status", response: "Domain: example.de\nStatus: connect", want: false},
+		{name: "rate limited", response: "Query rate limit exceeded. Try again later.", want: false},
+		{name: "availability disclaimer", response: "% It allows persons to check whether a specific domain name is still available or not", want: false},
+		{name: "locate disclaimer", response: "NOTE: FAILURE TO LOCATE A RECORD IN THE WHOIS DATABASE IS NOT INDICATIVE OF THE AVAILABILITY OF A DOMAIN NAME.", want: false},
+		{name: "empty", response: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFoundResponse(tt.response); got != tt.want {
+				t.Errorf("isNotFoundResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package whois
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a lookup is deferred because its WHOIS server's
+// limits are reached or the server answered that we are querying too often
+var ErrRateLimited = errors.New("WHOIS server rate limit reached")
+
+const (
+	// busyRetryAfter is how long to wait when all of a server's lookup slots are taken
+	busyRetryAfter = 15 * time.Second
+	// minPenalty is how long a server is left alone after its first rate-limit response
+	minPenalty = time.Minute
+	// maxPenalty caps the pause after repeated rate-limit responses
+	maxPenalty = time.Hour
+)
+
+// RateLimitError reports which server is limited and when to try it again
+type RateLimitError struct {
+	Server     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: %v, retry in %v", e.Server, ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RetryAfter returns how long to wait before retrying a rate-limited lookup, or 0
+// when err is not a rate limit
+func RetryAfter(err error) time.Duration {
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) {
+		return rateErr.RetryAfter
+	}
+	return 0
+}
+
+// Limit caps the lookups sent to one WHOIS server
+type Limit struct {
+	Rate        int           // lookups allowed per Per
+	Per         time.Duration // period Rate applies to
+	Burst       int           // lookups allowed at once after a quiet period
+	Concurrency int           // lookups in flight at the same time
+}
+
+// Limits holds the default limit and overrides for specific WHOIS servers
+type Limits struct {
+	Default Limit
+	Servers map[string]Limit
+}
+
+// DefaultLimits returns conservative limits, lower for registries known to block
+// clients that query them often
+func DefaultLimits() Limits {
+	return Limits{
+		Default: Limit{Rate: 30, Per: time.Minute, Burst: 5, Concurrency: 2},
+		Servers: map[string]Limit{
+			"whois.denic.de": {Rate: 10, Per: time.Minute, Burst: 2, Concurrency: 1},
+			"whois.eu":       {Rate: 10, Per: time.Minute, Burst: 2, Concurrency: 1},
+			"whois.nic.it":   {Rate: 10, Per: time.Minute, Burst: 2, Concurrency: 1},
+			"whois.jprs.jp":  {Rate: 10, Per: time.Minute, Burst: 2, Concurrency: 1},
+		},
+	}
+}
+
+// For returns the limit that applies to a server
+func (l Limits) For(server string) Limit {
+	if limit, ok := l.Servers[server]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// ParseLimits applies semicolon-separated overrides such as
+// "default=60/1m:10:4; whois.denic.de=5/1m:1:1" to base. Each limit is
+// rate/period:burst:concurrency; burst and concurrency may be omitted.
+func ParseLimits(s string, base Limits) (Limits, error) {
+	limits := Limits{Default: base.Default, Servers: make(map[string]Limit, len(base.Servers))}
+	for server, limit := range base.Servers {
+		limits.Servers[server] = limit
+	}
+
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		server, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return Limits{}, fmt.Errorf("invalid WHOIS limit %q: expected server=rate/period", entry)
+		}
+		limit, err := parseLimit(strings.TrimSpace(spec))
+		if err != nil {
+			return Limits{}, fmt.Errorf("invalid WHOIS limit %q: %w", entry, err)
+		}
+
+		server = strings.ToLower(strings.TrimSpace(server))
+		if server == "default" {
+			limits.Default = limit
+		} else {
+			limits.Servers[server] = limit
+		}
+	}
+
+	return limits, nil
+}
+
+// parseLimit parses rate/period[:burst[:concurrency]]
+func parseLimit(spec string) (Limit, error) {
+	parts := strings.Split(spec, ":")
+	rate, period, ok := strings.Cut(parts[0], "/")
+	if !ok || len(parts) > 3 {
+		return Limit{}, fmt.Errorf("expected rate/period[:burst[:concurrency]]")
+	}
+
+	limit := Limit{Burst: 1, Concurrency: 1}
+	var err error
+	if limit.Rate, err = strconv.Atoi(rate); err != nil || limit.Rate < 1 {
+		return Limit{}, fmt.Errorf("rate must be a positive number")
+	}
+	if limit.Per, err = time.ParseDuration(period); err != nil || limit.Per <= 0 {
+		return Limit{}, fmt.Errorf("period must be a positive duration such as 1m")
+	}
+	if len(parts) > 1 {
+		if limit.Burst, err = strconv.Atoi(parts[1]); err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("burst must be a positive number")
+		}
+	}
+	if len(parts) > 2 {
+		if limit.Concurrency, err = strconv.Atoi(parts[2]); err != nil || limit.Concurrency < 1 {
+			return Limit{}, fmt.Errorf("concurrency must be a positive number")
+		}
+	}
+
+	return limit, nil
+}
+
+// serverLimiter is a token bucket with a concurrency cap for one WHOIS server
+// A server that answers with a rate-limit response is paused with an
+// exponentially growing penalty until it answers normally again.
+type serverLimiter struct {
+	mu          sync.Mutex
+	limit       Limit
+	tokens      float64
+	updated     time.Time
+	inFlight    int
+	pausedUntil time.Time
+	penalty     time.Duration
+}
+
+// newServerLimiter creates a limiter with a full bucket
+func newServerLimiter(limit Limit, now time.Time) *serverLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	if limit.Concurrency < 1 {
+		limit.Concurrency = 1
+	}
+	return &serverLimiter{limit: limit, tokens: float64(limit.Burst), updated: now}
+}
+
+// acquire takes a token and a lookup slot
+// When it cannot, it returns how long to wait before trying again.
+func (l *serverLimiter) acquire(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now), false
+	}
+	if l.inFlight >= l.limit.Concurrency {
+		return busyRetryAfter, false
+	}
+
+	// Refill for the time since the last acquire
+	perToken := l.limit.Per / time.Duration(l.limit.Rate)
+	if elapsed := now.Sub(l.updated); elapsed > 0 {
+		l.tokens = math.Min(float64(l.limit.Burst), l.tokens+float64(elapsed)/float64(perToken))
+	}
+	l.updated = now
+
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) * float64(perToken)), false
+	}
+
+	l.tokens--
+	l.inFlight++
+	return 0, true
+}
+
+// release returns a lookup slot taken by acquire
+func (l *serverLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+}
+
+// penalize pauses the server after a rate-limit response and returns the pause
+func (l *serverLimiter) penalize(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.penalty == 0 {
+		l.penalty = minPenalty
+	} else {
+		l.penalty = time.Duration(math.Min(float64(maxPenalty), float64(l.penalty*2)))
+	}
+	l.pausedUntil = now.Add(l.penalty)
+	l.tokens = 0
+	return l.penalty
+}
+
+// succeeded resets the penalty once the server answers normally
+func (l *serverLimiter) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.penalty = 0
+}
+
+// rateLimitPatterns match responses in which a server refuses a lookup because
+// we query it too often. Like notFoundPatterns they are matched against single
+// lines after normalizeLine and anchored at the start of the line, since terms of
+// use often describe throttling in similar words.
+var rateLimitPatterns = []*regexp.Regexp{
+	// PIR (.org) and other Identity Digital registries
+	regexp.MustCompile(`^whois limit exceeded\b`),
+	// Generic "query rate exceeded" wording, e.g. NIC.IT (.it)
+	regexp.MustCompile(`^(error:\s*)?(query|request|lookup)s? rate (limit )?(exceeded|reached)\b`),
+	regexp.MustCompile(`^(error:\s*)?too many (queries|requests|connections|lookups)\b`),
+	regexp.MustCompile(`^(error:\s*)?quota (exceeded|reached)\b`),
+	// EURid (.eu), DNS Belgium (.be)
+	regexp.MustCompile(`^(-?\d+:\s*)?%?excessive querying\b`),
+	// SIDN (.nl), Nominet (.uk)
+	regexp.MustCompile(`^(error:\s*)?you have exceeded the maximum\b`),
+	// DENIC (.de)
+	regexp.MustCompile(`\baccess control limit (reached|exceeded)\b`),
+}
+
+// isRateLimitedResponse reports whether a raw WHOIS response refuses the lookup
+// because of rate limiting
+func isRateLimitedResponse(rawResponse string) bool {
+	for _, line := range strings.Split(rawResponse, "\n") {
+		line = normalizeLine(line)
+		if line == "" {
+			continue
+		}
+		for _, pattern := range rateLimitPatterns {
+			if pattern.MatchString(line) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package whois
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test that the token bucket allows a burst, then one lookup per refill
+func TestServerLimiter_TokenBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newServerLimiter(Limit{Rate: 6, Per: time.Minute, Burst: 2, Concurrency: 10}, now)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := limiter.acquire(now); !ok {
+			t.Fatalf("acquire() %d within the burst was refused", i+1)
+		}
+	}
+	wait, ok := limiter.acquire(now)
+	if ok || wait != 10*time.Second {
+		t.Fatalf("acquire() after the burst = %v, %v; want refused for 10s", wait, ok)
+	}
+
+	if _, ok := limiter.acquire(now.Add(10 * time.Second)); !ok {
+		t.Fatal("acquire() after a refill was refused")
+	}
+	if _, ok := limiter.acquire(now.Add(15 * time.Second)); ok {
+		t.Fatal("acquire() before the next refill was allowed")
+	}
+}
+
+// Test that lookups beyond the concurrency cap are refused until one finishes
+func TestServerLimiter_Concurrency(t *testing.T) {
+	now := time.Now()
+	limiter := newServerLimiter(Limit{Rate: 100, Per: time.Second, Burst: 10, Concurrency: 1}, now)
+
+	if _, ok := limiter.acquire(now); !ok {
+		t.Fatal("first acquire() was refused")
+	}
+	if wait, ok := limiter.acquire(now); ok || wait != busyRetryAfter {
+		t.Fatalf("acquire() at the concurrency cap = %v, %v; want refused for %v", wait, ok, busyRetryAfter)
+	}
+	limiter.release()
+	if _, ok := limiter.acquire(now); !ok {
+		t.Fatal("acquire() after release() was refused")
+	}
+}
+
+// Test that repeated rate-limit responses double the pause up to the cap
+func TestServerLimiter_Penalty(t *testing.T) {
+	now := time.Now()
+	limiter := newServerLimiter(DefaultLimits().Default, now)
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for _, w := range want {
+		if got := limiter.penalize(now); got != w {
+			t.Fatalf("penalize() = %v, want %v", got, w)
+		}
+	}
+	if wait, ok := limiter.acquire(now.Add(time.Minute)); ok || wait != 3*time.Minute {
+		t.Fatalf("acquire() while paused = %v, %v; want refused for 3m", wait, ok)
+	}
+
+	for i := 0; i < 10; i++ {
+		limiter.penalize(now)
+	}
+	if got := limiter.penalize(now); got != maxPenalty {
+		t.Errorf("penalize() = %v, want the %v cap", got, maxPenalty)
+	}
+
+	limiter.succeeded()
+	if got := limiter.penalize(now); got != minPenalty {
+		t.Errorf("penalize() after success = %v, want %v", got, minPenalty)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("default=60/1m:10:4; WHOIS.DENIC.DE=5/1m; whois.nic.uk=2/1s:3", DefaultLimits())
+	if err != nil {
+		t.Fatalf("ParseLimits() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		server string
+		want   Limit
+	}{
+		{"whois.verisign-grs.com", Limit{Rate: 60, Per: time.Minute, Burst: 10, Concurrency: 4}},
+		{"whois.denic.de", Limit{Rate: 5, Per: time.Minute, Burst: 1, Concurrency: 1}},
+		{"whois.nic.uk", Limit{Rate: 2, Per: time.Second, Burst: 3, Concurrency: 1}},
+		{"whois.jprs.jp", DefaultLimits().Servers["whois.jprs.jp"]},
+	}
+	for _, tt := range tests {
+		if got := limits.For(tt.server); got != tt.want {
+			t.Errorf("For(%s) = %+v, want %+v", tt.server, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"whois.nic.uk", "default=0/1m", "default=5/never", "default=5/1m:0", "default=5/1m:1:1:1"} {
+		if _, err := ParseLimits(invalid, DefaultLimits()); err == nil {
+			t.Errorf("ParseLimits(%q) expected an error", invalid)
+		}
+	}
+}
+
+// Test that lookups over a server's limit are deferred without querying it and that
+// a rate-limit response pauses the server
+func TestQueryDomain_RateLimited(t *testing.T) {
+	registered, err := os.ReadFile(filepath.Join("testdata", "registered", "co.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var lookups, ianaLookups int32
+	response := string(registered)
+	service := NewService()
+	service.SetLimits(Limits{Default: Limit{Rate: 1, Per: time.Hour, Burst: 2, Concurrency: 1}})
+	service.lookup = func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			atomic.AddInt32(&ianaLookups, 1)
+			return "domain:       CO\nrefer:        whois.registry.co\n", nil
+		}
+		if servers[0] != "whois.registry.co" {
+			t.Errorf("Queried %s, want the referred server", servers[0])
+		}
+		atomic.AddInt32(&lookups, 1)
+		return response, nil
+	}
+
+	if _, err := service.QueryDomain(context.Background(), "google.co"); err != nil {
+		t.Fatalf("QueryDomain() unexpected error: %v", err)
+	}
+
+	// The server answers that we are querying too often
+	response = "WHOIS LIMIT EXCEEDED - SEE WWW.PIR.ORG/WHOIS FOR DETAILS"
+	_, err = service.QueryDomain(context.Background(), "example.co")
+	if !errors.Is(err, ErrRateLimited) || RetryAfter(err) != minPenalty {
+		t.Fatalf("QueryDomain() error = %v (retry after %v), want ErrRateLimited for %v", err, RetryAfter(err), minPenalty)
+	}
+
+	// Paused: refused without a lookup and without retries
+	_, err = service.QueryDomain(context.Background(), "example.co")
+	if !errors.Is(err, ErrRateLimited) || RetryAfter(err) <= 0 {
+		t.Fatalf("QueryDomain() while paused error = %v, want ErrRateLimited", err)
+	}
+
+	if got := atomic.LoadInt32(&lookups); got != 2 {
+		t.Errorf("Expected 2 lookups sent to the server, got %d", got)
+	}
+	if got := atomic.LoadInt32(&ianaLookups); got != 1 {
+		t.Errorf("Expected the referral to be looked up once, got %d", got)
+	}
+}
+
+// Test that rate-limit responses are recognised and ordinary responses are not
+func TestIsRateLimitedResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     bool
+	}{
+		{name: "pir", response: "WHOIS LIMIT EXCEEDED - SEE WWW.PIR.ORG/WHOIS FOR DETAILS", want: true},
+		{name: "query rate", response: "Query rate limit exceeded. Try again later.", want: true},
+		{name: "eurid", response: "-2: Excessive querying, grace period of 5 seconds", want: true},
+		{name: "denic", response: "% Error: 55000000002 Connection refused; access control limit reached.", want: true},
+		{name: "too many", response: "Too many requests, please slow down", want: true},
+		{name: "maximum queries", response: "You have exceeded the maximum allowable number of WHOIS queries", want: true},
+		{name: "not found", response: "No match for \"EXAMPLE.COM\".", want: false},
+		{name: "high volume disclaimer", response: "to enable high volume, automated, electronic processes that apply to VeriSign", want: false},
+		{name: "throttling disclaimer", response: "Queries to the Whois services are throttled. If too many queries are received from a single IP address", want: false},
+		{name: "wrapped throttling disclaimer", response: "% exceeded the maximum number of queries will be blocked", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRateLimitedResponse(tt.response); got != tt.want {
+				t.Errorf("isRateLimitedResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// No fixture in either corpus is a rate-limit response
+	files, _ := filepath.Glob(filepath.Join("testdata", "*", "*.txt"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		if isRateLimitedResponse(string(data)) {
+			t.Errorf("%s recognised as a rate-limit response", file)
+		}
+	}
+}
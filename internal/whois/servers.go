@@ -0,0 +1,66 @@
+package whois
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// serverFor returns the WHOIS server of a domain's TLD as referred by IANA
+// Referrals are cached, so IANA is asked once per TLD.
+func (s *Service) serverFor(ctx context.Context, domainName string) (string, error) {
+	tld := strings.ToLower(strings.Trim(domainName, "."))
+	if i := strings.LastIndex(tld, "."); i >= 0 {
+		tld = tld[i+1:]
+	}
+
+	s.mu.Lock()
+	server, ok := s.servers[tld]
+	s.mu.Unlock()
+	if ok {
+		return server, nil
+	}
+
+	// A query without a dot goes to IANA
+	rawResponse, err := s.lookup(ctx, tld)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up WHOIS server for .%s: %w", tld, err)
+	}
+	server = referralServer(rawResponse)
+	if server == "" {
+		return "", fmt.Errorf("no WHOIS server for .%s", tld)
+	}
+
+	s.mu.Lock()
+	s.servers[tld] = server
+	s.mu.Unlock()
+	return server, nil
+}
+
+// referralServer extracts the WHOIS server from an IANA TLD record
+func referralServer(rawResponse string) string {
+	for _, prefix := range []string{"refer:", "whois:"} {
+		for _, line := range strings.Split(rawResponse, "\n") {
+			line = strings.TrimSpace(line)
+			if value, ok := strings.CutPrefix(strings.ToLower(line), prefix); ok {
+				if server := strings.TrimSpace(value); server != "" {
+					return server
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// limiterFor returns the rate limiter of a WHOIS server
+func (s *Service) limiterFor(server string) *serverLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limiter, ok := s.limiters[server]
+	if !ok {
+		limiter = newServerLimiter(s.limits.For(server), s.now())
+		s.limiters[server] = limiter
+	}
+	return limiter
+}
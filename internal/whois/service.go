@@ -1,18 +1,31 @@
 package whois
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/domain-expiration-monitor/dem/internal/domain"
-	"github.com/likexian/whois"
 	whoisparser "github.com/likexian/whois-parser"
 )
 
+// ErrDomainNotRegistered is returned when the registry reports that a domain does not exist
+var ErrDomainNotRegistered = errors.New("domain is not registered")
+
 // Service handles WHOIS queries and parsing
+// Lookups are rate limited per WHOIS server; a lookup that would exceed a
+// server's limits fails with ErrRateLimited instead of waiting.
 type Service struct {
 	timeout time.Duration
 	maxRetries int
+	lookup     func(ctx context.Context, query string, servers ...string) (string, error)
+	now        func() time.Time
+	mu         sync.Mutex
+	limits     Limits
+	servers    map[string]string         // TLD to WHOIS server
+	limiters   map[string]*serverLimiter // WHOIS server to its limiter
 }
 
 // NewService creates a new WHOIS service
@@ -20,23 +33,57 @@ func NewService() *Service {
 	return &Service{
 		timeout:    30 * time.Second,
 		maxRetries: 3,
+		lookup:     lookup,
+		now:        time.Now,
+		limits:     DefaultLimits(),
+		servers:    make(map[string]string),
+		limiters:   make(map[string]*serverLimiter),
 	}
 }
 
+// SetLimits replaces the per-server lookup limits
+func (s *Service) SetLimits(limits Limits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+	s.limiters = make(map[string]*serverLimiter)
+}
+
+// SetLookup replaces the function that sends WHOIS queries, which is
+// a whois.Client that aborts when its context ends by default. It must be
+// called before the service is used.
+func (s *Service) SetLookup(lookup func(ctx context.Context, query string, servers ...string) (string, error)) {
+	s.lookup = lookup
+}
+
 // QueryDomain performs a WHOIS lookup for a domain with retry logic
-func (s *Service) QueryDomain(domainName string) (*domain.DomainInfo, error) {
+// Canceling ctx aborts the lookup in progress and any remaining retries.
+func (s *Service) QueryDomain(ctx context.Context, domainName string) (*domain.DomainInfo, error) {
 	var lastErr error
 	backoff := time.Second
 
 	for attempt := 0; attempt < s.maxRetries; attempt++ {
-		info, err := s.queryWithTimeout(domainName)
+		info, err := s.queryWithTimeout(ctx, domainName)
 		if err == nil {
 			return info, nil
 		}
 
+		// A definitive "not found" answer will not change on retry, and retrying
+		// a rate-limited server only makes matters worse
+		if errors.Is(err, ErrDomainNotRegistered) || errors.Is(err, ErrRateLimited) {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("WHOIS query canceled: %w", ctx.Err())
+		}
+
 		lastErr = err
 		if attempt < s.maxRetries-1 {
-			time.Sleep(backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("WHOIS query canceled: %w", ctx.Err())
+			}
 			backoff *= 2 // Exponential backoff
 		}
 	}
@@ -45,37 +92,45 @@ func (s *Service) QueryDomain(domainName string) (*domain.DomainInfo, error) {
 }
 
 // queryWithTimeout performs a single WHOIS query with timeout
-func (s *Service) queryWithTimeout(domainName string) (*domain.DomainInfo, error) {
-	// Create a channel for the result
-	type result struct {
-		info *domain.DomainInfo
-		err  error
-	}
-	resultChan := make(chan result, 1)
-
-	go func() {
-		info, err := s.query(domainName)
-		resultChan <- result{info, err}
-	}()
-
-	select {
-	case res := <-resultChan:
-		return res.info, res.err
-	case <-time.After(s.timeout):
+func (s *Service) queryWithTimeout(ctx context.Context, domainName string) (*domain.DomainInfo, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	info, err := s.query(queryCtx, domainName)
+	if err != nil && ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
 		return nil, fmt.Errorf("WHOIS query timed out after %v", s.timeout)
 	}
+	return info, err
 }
 
 // query performs the actual WHOIS lookup and parsing
-func (s *Service) query(domainName string) (*domain.DomainInfo, error) {
+func (s *Service) query(ctx context.Context, domainName string) (*domain.DomainInfo, error) {
+	server, err := s.serverFor(ctx, domainName)
+	if err != nil {
+		return nil, fmt.Errorf("WHOIS query failed: %w", err)
+	}
+
+	limiter := s.limiterFor(server)
+	if wait, ok := limiter.acquire(s.now()); !ok {
+		return nil, &RateLimitError{Server: server, RetryAfter: wait}
+	}
+	defer limiter.release()
+
 	// Perform WHOIS query
-	rawResponse, err := whois.Whois(domainName)
+	rawResponse, err := s.lookup(ctx, domainName, server)
 	if err != nil {
 		return nil, fmt.Errorf("WHOIS query failed: %w", err)
 	}
 
 	// Parse WHOIS response
 	info, err := s.ParseWHOISResponse(rawResponse)
+	if errors.Is(err, ErrRateLimited) {
+		return nil, &RateLimitError{Server: server, RetryAfter: limiter.penalize(s.now())}
+	}
+	limiter.succeeded()
+	if errors.Is(err, ErrDomainNotRegistered) {
+		return nil, fmt.Errorf("%s: %w", domainName, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("WHOIS parsing failed for %s: %w\nRaw response: %s", domainName, err, rawResponse)
 	}
@@ -84,8 +139,27 @@ func (s *Service) query(domainName string) (*domain.DomainInfo, error) {
 }
 
 // ParseWHOISResponse parses a raw WHOIS response into structured data
+// It returns ErrDomainNotRegistered when the response says the domain does not exist
+// and ErrRateLimited when the server refused the lookup because of rate limiting
 func (s *Service) ParseWHOISResponse(rawResponse string) (*domain.DomainInfo, error) {
 	parsed, err := whoisparser.Parse(rawResponse)
+	if errors.Is(err, whoisparser.ErrNotFoundDomain) {
+		return nil, ErrDomainNotRegistered
+	}
+	if errors.Is(err, whoisparser.ErrDomainLimitExceed) {
+		return nil, ErrRateLimited
+	}
+
+	// Some registries answer with a record the parser cannot make sense of;
+	// check their own "no match" or "slow down" wording before giving up
+	if err != nil || parsed.Domain == nil || parsed.Domain.ExpirationDate == "" {
+		if isRateLimitedResponse(rawResponse) {
+			return nil, ErrRateLimited
+		}
+		if isNotFoundResponse(rawResponse) {
+			return nil, ErrDomainNotRegistered
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse WHOIS response: %w", err)
 	}
@@ -159,6 +233,7 @@ func (s *Service) ParseWHOISResponse(rawResponse string) (*domain.DomainInfo, er
 		Nameservers:    nameservers,
 		Registrant:     extractRegistrant(&parsed),
 		Registrar:      registrarName,
+		Statuses:       parsed.Domain.Status,
 		CreatedDate:    createdDate,
 		UpdatedDate:    updatedDate,
 	}
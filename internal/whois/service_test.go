@@ -1,6 +1,9 @@
 package whois
 
 import (
+	"context"
+	"errors"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -91,7 +94,7 @@ func TestQueryWithTimeout(t *testing.T) {
 
 	// This will timeout because we're querying an invalid domain
 	// In a real scenario, this would be mocked
-	_, err := service.queryWithTimeout("invalid-domain-that-does-not-exist-12345.com")
+	_, err := service.queryWithTimeout(context.Background(), "invalid-domain-that-does-not-exist-12345.com")
 	if err == nil {
 		// If it doesn't error, that's also acceptable (might succeed quickly with an error response)
 		return
@@ -110,7 +113,7 @@ func TestQueryDomain_RetryLogic(t *testing.T) {
 	service.timeout = 50 * time.Millisecond
 
 	// Query an invalid domain to trigger retries
-	_, err := service.QueryDomain("invalid-domain-that-does-not-exist-12345.com")
+	_, err := service.QueryDomain(context.Background(), "invalid-domain-that-does-not-exist-12345.com")
 	if err == nil {
 		t.Error("Expected error for invalid domain")
 	}
@@ -121,6 +124,98 @@ func TestQueryDomain_RetryLogic(t *testing.T) {
 	}
 }
 
+// Test that a timed out lookup is canceled rather than left running
+func TestQueryWithTimeout_CancelsLookup(t *testing.T) {
+	service := NewService()
+	service.timeout = 50 * time.Millisecond
+
+	returned := make(chan struct{})
+	service.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		defer close(returned)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	_, err := service.queryWithTimeout(context.Background(), "slow.co")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("queryWithTimeout() error = %v, want a timeout", err)
+	}
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Error("Lookup still running after the timeout")
+	}
+}
+
+// Test that canceling the context stops a query and its retries
+func TestQueryDomain_Canceled(t *testing.T) {
+	service := NewService()
+
+	var lookups int
+	service.SetLookup(func(ctx context.Context, query string, servers ...string) (string, error) {
+		if len(servers) == 0 {
+			return "refer: whois.nic.co\n", nil
+		}
+		lookups++
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := service.QueryDomain(ctx, "slow.co")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("QueryDomain() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("QueryDomain() returned after %v, want it to stop when canceled", elapsed)
+	}
+	if lookups != 1 {
+		t.Errorf("Lookups = %d, want no retries after cancellation", lookups)
+	}
+}
+
+// Test that the default lookup gives up on a server that never answers once
+// its context is canceled
+func TestLookup_Canceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := lookup(ctx, "example.com", listener.Addr().String())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("lookup() error = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup() still blocked after its context was canceled")
+	}
+}
+
 // Test extractRegistrant function
 func TestExtractRegistrant(t *testing.T) {
 	tests := []struct {
@@ -173,3 +268,37 @@ func TestExtractRegistrant(t *testing.T) {
 		})
 	}
 }
+
+// Test that a registry "no match" response is reported as an unregistered domain
+func TestParseWHOISResponse_NotRegistered(t *testing.T) {
+	service := NewService()
+
+	response := "No match for \"AVAILABLE-EXAMPLE.COM\".\n>>> Last update of whois database: 2025-01-01T00:00:00Z <<<"
+
+	_, err := service.ParseWHOISResponse(response)
+	if !errors.Is(err, ErrDomainNotRegistered) {
+		t.Fatalf("ParseWHOISResponse() error = %v, want ErrDomainNotRegistered", err)
+	}
+}
+
+// Test that EPP status codes are extracted
+func TestParseWHOISResponse_Statuses(t *testing.T) {
+	service := NewService()
+
+	expirationDate := time.Now().Add(30 * 24 * time.Hour).Format("2006-01-02")
+	response := strings.Join([]string{
+		"Domain Name: example.com",
+		"Registry Expiry Date: " + expirationDate + "T00:00:00Z",
+		"Registrar: Test Registrar Inc",
+		"Domain Status: redemptionPeriod https://icann.org/epp#redemptionPeriod",
+	}, "\n")
+
+	info, err := service.ParseWHOISResponse(response)
+	if err != nil {
+		t.Fatalf("ParseWHOISResponse() unexpected error: %v", err)
+	}
+
+	if len(info.Statuses) == 0 {
+		t.Fatal("Expected statuses to be set")
+	}
+}
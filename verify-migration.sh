@@ -41,6 +41,10 @@ if docker-compose ps mysql 2>/dev/null | grep -q "Up"; then
     echo "📊 Alerts table structure:"
     echo "DESCRIBE alerts;" | $DB_CMD
     
+    echo ""
+    echo "🗂️  Applied migrations:"
+    echo "SELECT version, name, applied_at FROM schema_migrations ORDER BY version;" | $DB_CMD
+    
     echo ""
     echo "📈 Record counts:"
     echo "SELECT 'Domains' as table_name, COUNT(*) as count FROM domains
@@ -65,6 +69,10 @@ else
     echo "📊 Alerts table structure:"
     echo ".schema alerts" | $DB_CMD
     
+    echo ""
+    echo "🗂️  Applied migrations:"
+    echo "SELECT version, name, applied_at FROM schema_migrations ORDER BY version;" | $DB_CMD
+    
     echo ""
     echo "📈 Record counts:"
     echo "SELECT 'Domains' as table_name, COUNT(*) as count FROM domains